@@ -0,0 +1,68 @@
+package loadgen
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// DefaultOptions returns the options that the loadgen command has when none
+// are given, to be changed before they're used with NewTraceSource.
+func DefaultOptions() (*Options, error) {
+	opts := newOptions()
+	if _, err := flags.NewParser(opts, flags.None).ParseArgs(nil); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// A TraceSource generates traces with the shape and fields given by the
+// options, one at a time in the caller's goroutine and as fast as the sender
+// takes them, so that loadgen can be embedded in a program or a benchmark
+// without running the command. Like a Fielder, it isn't safe for concurrent
+// use, so each goroutine needs its own.
+type TraceSource struct {
+	gen      *TraceGenerator
+	fielders *serviceFielders
+	count    int64
+}
+
+// NewTraceSource returns a TraceSource that sends its traces to sender. The
+// times of the spans are synthetic, as with --synthetictime, so nothing
+// sleeps. It returns an error if the options are invalid.
+func NewTraceSource(opts *Options, sender Sender) (*TraceSource, error) {
+	o := *opts
+	o.Output.Signal = "traces"
+	o.Format.SyntheticTime = true
+	if err := checkOptions(&o); err != nil {
+		return nil, err
+	}
+	gen, err := newGenerator(o.Logger(), sender, &o)
+	if err != nil {
+		return nil, err
+	}
+	tgen := gen.(*TraceGenerator)
+	fielders, err := tgen.newFielders()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create fields as specified: %w", err)
+	}
+	return &TraceSource{gen: tgen, fielders: fielders}, nil
+}
+
+// GenerateTrace sends the next trace through the sender.
+func (t *TraceSource) GenerateTrace() {
+	t.count++
+	t.gen.generate_root(t.fielders, t.count, t.gen.depth, t.gen.nspans, t.gen.duration)
+}
+
+// GenerateTrace sends a single trace with the shape and fields given by the
+// options through sender. To send more than one, a TraceSource does the setup
+// only once.
+func GenerateTrace(opts *Options, sender Sender) error {
+	source, err := NewTraceSource(opts, sender)
+	if err != nil {
+		return err
+	}
+	source.GenerateTrace()
+	return nil
+}
@@ -0,0 +1,84 @@
+package loadgen_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/honeycombio/loadgen/pkg/loadgen"
+)
+
+// countingSender counts the traces and spans it's asked to create, and makes
+// their fields the way a real sender would.
+type countingSender struct {
+	traces, spans int
+}
+
+func (c *countingSender) CreateTrace(ctx context.Context, name string, fielder *loadgen.Fielder, count int64) (context.Context, loadgen.Sendable) {
+	c.traces++
+	c.spans++
+	fielder.GetFields(count, 0)
+	return ctx, loadgen.DummySendable{}
+}
+
+func (c *countingSender) CreateSpan(ctx context.Context, name string, level int, fielder *loadgen.Fielder) (context.Context, loadgen.Sendable) {
+	c.spans++
+	fielder.GetFields(0, level)
+	return ctx, loadgen.DummySendable{}
+}
+
+func (c *countingSender) Close() {}
+
+func TestGenerateTrace(t *testing.T) {
+	opts, err := loadgen.DefaultOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &countingSender{}
+	if err := loadgen.GenerateTrace(opts, sender); err != nil {
+		t.Fatal(err)
+	}
+	// by default, a trace is 3 spans deep
+	if sender.traces != 1 || sender.spans != 3 {
+		t.Errorf("expected a trace of 3 spans, got %d traces of %d spans", sender.traces, sender.spans)
+	}
+
+	opts.Fields["bad"] = "/nosuchgen"
+	if err := loadgen.GenerateTrace(opts, sender); err == nil {
+		t.Error("expected an error for a field that doesn't parse")
+	}
+	delete(opts.Fields, "bad")
+
+	// invalid options are errors too, rather than exiting the process
+	opts.Format.Jitter = 150
+	if err := loadgen.GenerateTrace(opts, sender); err == nil {
+		t.Error("expected an error for a jitter over 100")
+	}
+	opts.Format.Jitter = 0
+	opts.Format.SpanNameTemplate = "bogus"
+	if err := loadgen.GenerateTrace(opts, sender); err == nil {
+		t.Error("expected an error for an unknown span name template")
+	}
+}
+
+func BenchmarkTraceSource(b *testing.B) {
+	opts, err := loadgen.DefaultOptions()
+	if err != nil {
+		b.Fatal(err)
+	}
+	opts.Format.Depth = 4
+	opts.Format.NSpans = 10
+	opts.Format.Extra = 10
+	opts.Fields["status"] = "/st10,1"
+	opts.Fields["user"] = "/sw1000"
+	sender := &countingSender{}
+	source, err := loadgen.NewTraceSource(opts, sender)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		source.GenerateTrace()
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "traces/s")
+	b.ReportMetric(float64(sender.spans)/b.Elapsed().Seconds(), "spans/s")
+}
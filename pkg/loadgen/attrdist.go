@@ -0,0 +1,74 @@
+package loadgen
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// attrCountDist is the distribution that the number of attributes of each span
+// is drawn from (--attrdist), so that spans vary in width instead of averaging
+// --apspan: gaussian:mean,stddev or uniform:min,max.
+type attrCountDist struct {
+	kind   string
+	p1, p2 float64
+}
+
+// parseAttrCountDist parses an --attrdist spec; an empty one is nil, which
+// leaves the widths to --apspan.
+func parseAttrCountDist(spec string) (*attrCountDist, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	kind, args, ok := strings.Cut(spec, ":")
+	if !ok || (kind != "gaussian" && kind != "uniform") {
+		return nil, fmt.Errorf("attrdist %q must be gaussian:mean,stddev or uniform:min,max", spec)
+	}
+	a1, a2, ok := strings.Cut(args, ",")
+	if !ok {
+		return nil, fmt.Errorf("attrdist %q needs two numbers", spec)
+	}
+	p1, err1 := strconv.ParseFloat(strings.TrimSpace(a1), 64)
+	p2, err2 := strconv.ParseFloat(strings.TrimSpace(a2), 64)
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("attrdist %q needs two numbers", spec)
+	}
+	if p1 < 0 || p2 < 0 {
+		return nil, fmt.Errorf("attrdist %q must not have negative numbers", spec)
+	}
+	if kind == "uniform" && p2 < p1 {
+		return nil, fmt.Errorf("attrdist %q has a max less than its min", spec)
+	}
+	return &attrCountDist{kind: kind, p1: p1, p2: p2}, nil
+}
+
+// sample returns a number of attributes, which can be out of range.
+func (d *attrCountDist) sample(rng Rng) int {
+	if d.kind == "uniform" {
+		return int(rng.Int(int(d.p1), int(d.p2)+1))
+	}
+	return int(math.Round(rng.Gaussian(d.p1, d.p2)))
+}
+
+// SetAttrCountDist draws the number of attributes of each span from d instead
+// of using --apspan; nil goes back to --apspan.
+func (f *Fielder) SetAttrCountDist(d *attrCountDist) {
+	f.attrDist = d
+}
+
+// chooseKeys returns the keys of a span's attributes when their number is drawn
+// from the distribution, clamped to at least the intrinsic ones and at most all
+// of them. The intrinsic keys always come first, and the rest are chosen at
+// random, so the presences of the optional ones don't apply.
+func (f *Fielder) chooseKeys() []string {
+	n := max(f.intrinsicAttributes, min(f.attrDist.sample(f.rng), len(f.keys)))
+	// a partial shuffle of the optional keys picks the ones that are present
+	optional := append([]string(nil), f.keys[f.intrinsicAttributes:]...)
+	for i := 0; i < n-f.intrinsicAttributes; i++ {
+		j := i + int(f.rng.Intn(len(optional)-i))
+		optional[i], optional[j] = optional[j], optional[i]
+	}
+	intrinsic := f.keys[:f.intrinsicAttributes:f.intrinsicAttributes]
+	return append(intrinsic, optional[:n-f.intrinsicAttributes]...)
+}
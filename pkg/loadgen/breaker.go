@@ -0,0 +1,23 @@
+package loadgen
+
+import "time"
+
+// watchErrors polls the sender's error count and, once maxErrors sends have
+// failed, closes stop to shut down the same way an interrupt does. It returns
+// true if it stopped loadgen and false if something else closed stop first.
+func watchErrors(log Logger, sender ErrorCounter, maxErrors int64, stop chan struct{}, interval time.Duration) bool {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return false
+		case <-ticker.C:
+			if errs := sender.SendErrors(); errs >= maxErrors {
+				log.Error("%d sends have failed, reaching the limit of %d; shutting down\n", errs, maxErrors)
+				closeStop(stop)
+				return true
+			}
+		}
+	}
+}
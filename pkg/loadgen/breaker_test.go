@@ -0,0 +1,83 @@
+package loadgen
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingSender records traces like recordingSender, but every send fails.
+type failingSender struct {
+	recordingSender
+	errors atomic.Int64
+}
+
+type failingSendable struct {
+	sender *failingSender
+}
+
+func (s failingSendable) Send() {
+	s.sender.errors.Add(1)
+}
+
+func (f *failingSender) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	ctx, _ = f.recordingSender.CreateTrace(ctx, name, fielder, count)
+	return ctx, failingSendable{f}
+}
+
+func (f *failingSender) SendErrors() int64 {
+	return f.errors.Load()
+}
+
+func TestWatchErrors(t *testing.T) {
+	opts := newOptions()
+	opts.Format.Depth = 1
+	opts.Format.NSpans = 1
+	opts.Format.TraceTime = 10 * time.Millisecond
+	opts.Quantity.TPS = 100
+	opts.Quantity.RampTime = 10 * time.Millisecond
+	sender := &failingSender{}
+	gen, err := NewTraceGenerator(sender, func(string) (*Fielder, error) { return newTestFielder(t, 1), nil }, NewLogger(0), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	counter := make(chan int64)
+	wg := &sync.WaitGroup{}
+	// plenty of traces, so only the breaker can stop it in time
+	go TraceCounter(NewLogger(0), 100000, 0, counter, stop)
+	wg.Add(1)
+	go gen.Generate(opts, wg, stop, counter)
+
+	tripped := make(chan bool)
+	go func() { tripped <- watchErrors(NewLogger(0), sender, 5, stop, time.Millisecond) }()
+	select {
+	case ok := <-tripped:
+		if !ok {
+			t.Errorf("expected watchErrors to report that it stopped loadgen")
+		}
+	case <-time.After(5 * time.Second):
+		closeStop(stop)
+		t.Fatalf("loadgen didn't stop after %d errors", sender.SendErrors())
+	}
+	select {
+	case <-stop:
+	default:
+		t.Errorf("expected the stop channel to be closed")
+	}
+	wg.Wait()
+	if sender.traces >= 100000 {
+		t.Errorf("expected an early stop, but all %d traces were sent", sender.traces)
+	}
+}
+
+func TestWatchErrors_stoppedElsewhere(t *testing.T) {
+	stop := make(chan struct{})
+	closeStop(stop)
+	if watchErrors(NewLogger(0), &failingSender{}, 5, stop, time.Millisecond) {
+		t.Errorf("watchErrors shouldn't report tripping when stop was already closed")
+	}
+}
@@ -0,0 +1,74 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+)
+
+// cardinalityCap is the most distinct values of a field that are kept; past
+// it, a field is only reported to have at least this many.
+const cardinalityCap = 10000
+
+// cardinalityCounter counts the distinct values of each field over a run
+// (--cardinalityreport), to check that the generators' cardinalities are what
+// they should be. It's shared by all of the fielders, so it's guarded by a
+// mutex. Values are compared by how they print, so that arrays can be counted.
+type cardinalityCounter struct {
+	mut    sync.Mutex
+	values map[string]map[string]struct{}
+}
+
+func newCardinalityCounter() *cardinalityCounter {
+	return &cardinalityCounter{values: make(map[string]map[string]struct{})}
+}
+
+// record counts the values of a span's fields.
+func (c *cardinalityCounter) record(values map[string]any) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	for name, v := range values {
+		seen, ok := c.values[name]
+		if !ok {
+			seen = make(map[string]struct{})
+			c.values[name] = seen
+		}
+		if len(seen) < cardinalityCap {
+			seen[fmt.Sprint(v)] = struct{}{}
+		}
+	}
+}
+
+// count returns the number of distinct values of the field, and whether it
+// reached the cap.
+func (c *cardinalityCounter) count(name string) (int, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	n := len(c.values[name])
+	return n, n >= cardinalityCap
+}
+
+// report writes a table of the fields and their distinct counts, in order of
+// their names.
+func (c *cardinalityCounter) report(w io.Writer) {
+	c.mut.Lock()
+	names := make([]string, 0, len(c.values))
+	for name := range c.values {
+		names = append(names, name)
+	}
+	c.mut.Unlock()
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "field\tdistinct values")
+	for _, name := range names {
+		n, capped := c.count(name)
+		if capped {
+			fmt.Fprintf(tw, "%s\t%d+\n", name, n)
+		} else {
+			fmt.Fprintf(tw, "%s\t%d\n", name, n)
+		}
+	}
+	tw.Flush()
+}
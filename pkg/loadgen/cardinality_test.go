@@ -0,0 +1,47 @@
+package loadgen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCardinalityCounter(t *testing.T) {
+	fielder, err := NewFielder("cardinality", map[string]string{"word": "/sw5", "id": "/sxc8,20", "tags": "/arr s,2"}, 0, 1, 4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := newCardinalityCounter()
+	fielder.SetCardinalityCounter(counter)
+	for i := 0; i < 1000; i++ {
+		fielder.GetFields(0, 0)
+	}
+
+	for name, want := range map[string]int{"word": 5, "id": 20} {
+		if n, capped := counter.count(name); n != want || capped {
+			t.Errorf("expected %d distinct values of %s, got %d", want, name, n)
+		}
+	}
+	if n, _ := counter.count("tags"); n < 100 {
+		t.Errorf("expected many distinct arrays of tags, got %d", n)
+	}
+
+	var b strings.Builder
+	counter.report(&b)
+	if !strings.Contains(b.String(), "word") || !strings.Contains(b.String(), " 5\n") {
+		t.Errorf("expected the report to list word with 5 values, got:\n%s", b.String())
+	}
+
+	// past the cap, a field is reported to have at least that many
+	many := newCardinalityCounter()
+	for i := 0; i < cardinalityCap+10; i++ {
+		many.record(map[string]any{"n": i})
+	}
+	if n, capped := many.count("n"); n != cardinalityCap || !capped {
+		t.Errorf("expected %d values and capped, got %d and %v", cardinalityCap, n, capped)
+	}
+	b.Reset()
+	many.report(&b)
+	if !strings.Contains(b.String(), "10000+") {
+		t.Errorf("expected the report to show the cap, got:\n%s", b.String())
+	}
+}
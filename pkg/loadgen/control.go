@@ -0,0 +1,77 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// A TPSController is a generator whose rate can be changed while it runs.
+type TPSController interface {
+	SetTPS(tps float64)
+	Status() GeneratorStatus
+}
+
+// make sure it implements TPSController
+var _ TPSController = (*TraceGenerator)(nil)
+
+// GeneratorStatus is what GET /status reports, summed over all the controlled generators.
+type GeneratorStatus struct {
+	TPS        float64 `json:"tps"`
+	Generators int     `json:"generators"`
+	Traces     int64   `json:"traces"`
+}
+
+// newControlHandler returns the handler for --controlport. POST /tps with a body
+// like {"tps": 50} sets the total rate; when there are several generators (one per
+// profile), it's divided among them in proportion to their current rates.
+func newControlHandler(log Logger, controllers []TPSController) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tps", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			TPS *float64 `json:"tps"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TPS == nil || *req.TPS < 0 {
+			http.Error(w, `the body must be like {"tps": 10}`, http.StatusBadRequest)
+			return
+		}
+		if len(controllers) == 0 {
+			http.Error(w, "no generators support changing the TPS", http.StatusConflict)
+			return
+		}
+		setTotalTPS(controllers, *req.TPS)
+		log.Info("set TPS to %v from control port\n", *req.TPS)
+		writeStatus(w, controllers)
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, controllers)
+	})
+	return mux
+}
+
+// setTotalTPS divides the total rate among the generators in proportion to
+// their current rates, or equally if none of them have one.
+func setTotalTPS(controllers []TPSController, tps float64) {
+	var total float64
+	for _, c := range controllers {
+		total += c.Status().TPS
+	}
+	for _, c := range controllers {
+		share := 1 / float64(len(controllers))
+		if total > 0 {
+			share = c.Status().TPS / total
+		}
+		c.SetTPS(tps * share)
+	}
+}
+
+func writeStatus(w http.ResponseWriter, controllers []TPSController) {
+	var status GeneratorStatus
+	for _, c := range controllers {
+		s := c.Status()
+		status.TPS += s.TPS
+		status.Generators += s.Generators
+		status.Traces += s.Traces
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,78 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestControlHandler_tps(t *testing.T) {
+	opts := newOptions()
+	opts.Format.Depth = 1
+	opts.Format.NSpans = 1
+	opts.Format.TraceTime = 100 * time.Millisecond
+	opts.Quantity.TPS = 10
+	opts.Quantity.RampTime = 10 * time.Millisecond
+	gen, _ := NewTraceGenerator(&recordingSender{}, func(string) (*Fielder, error) { return newTestFielder(t, 1), nil }, NewLogger(0), opts)
+	handler := newControlHandler(NewLogger(0), []TPSController{gen})
+
+	stop := make(chan struct{})
+	counter := make(chan int64)
+	wg := &sync.WaitGroup{}
+	go TraceCounter(NewLogger(0), 0, 0, counter, stop)
+	wg.Add(1)
+	go gen.Generate(opts, wg, stop, counter)
+	defer func() {
+		closeStop(stop)
+		wg.Wait()
+	}()
+
+	// waitFor polls GET /status until the generator count is n
+	waitFor := func(n int) GeneratorStatus {
+		t.Helper()
+		var status GeneratorStatus
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+			if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+				t.Fatal(err)
+			}
+			if status.Generators == n {
+				return status
+			}
+		}
+		t.Fatalf("expected %d generators, status is %+v", n, status)
+		return status
+	}
+
+	waitFor(1)
+	for _, tps := range []int{50, 20} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tps", strings.NewReader(`{"tps": `+strconv.Itoa(tps)+`}`)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST /tps returned %d: %s", rec.Code, rec.Body.String())
+		}
+		status := waitFor(tps / 10)
+		if status.TPS != float64(tps) {
+			t.Errorf("expected a TPS of %d, got %v", tps, status.TPS)
+		}
+	}
+	// each generator sends a trace every tracetime
+	for deadline := time.Now().Add(time.Second); gen.Status().Traces == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if gen.Status().Traces == 0 {
+		t.Errorf("expected some traces to have been sent")
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tps", strings.NewReader(`{"rate": 5}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a bad request for a body without tps, got %d", rec.Code)
+	}
+}
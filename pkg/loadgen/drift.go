@@ -0,0 +1,84 @@
+package loadgen
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxDriftSteps is the number of changes that --drift makes to each Fielder's fields.
+const maxDriftSteps = 6
+
+// schemaDrift changes a Fielder's fields as a run goes on (--drift), to test
+// how a backend copes with a schema that evolves: every interval, one more
+// step is taken, until they've all been taken. The steps are chosen from the
+// seed when the Fielder is created, so a given seed always drifts the same way.
+type schemaDrift struct {
+	steps []driftStep
+	taken int
+	start time.Time
+	every time.Duration // 0 if the fields don't drift
+}
+
+// A driftStep either changes the type of an existing field or adds a new one.
+type driftStep struct {
+	key string
+	add func() any // the generator of a new field, or nil to change the type of key
+}
+
+// newSchemaDrift chooses the steps: each one changes the type of one of the
+// random (--extra) fields, none of them more than once, or adds a field with
+// a new random name.
+func newSchemaDrift(seed string, extras []string, gens []func() any, formatKey func(adj, noun string) string) schemaDrift {
+	rng := NewRng(seed + "drift")
+	candidates := append([]string(nil), extras...)
+	steps := make([]driftStep, 0, maxDriftSteps)
+	for i := 0; i < maxDriftSteps; i++ {
+		if len(candidates) > 0 && rng.Bool() {
+			j := rng.Intn(len(candidates))
+			steps = append(steps, driftStep{key: candidates[j]})
+			candidates = append(candidates[:j], candidates[j+1:]...)
+			continue
+		}
+		key := formatKey(rng.Choice(adjectives), rng.Choice(nouns))
+		steps = append(steps, driftStep{key: key, add: gens[rng.Intn(len(gens))]})
+	}
+	return schemaDrift{steps: steps}
+}
+
+// retype returns a generator like gen whose values are of another type:
+// strings become ints, and everything else becomes a string.
+func retype(rng Rng, gen func() any) func() any {
+	if _, ok := gen().(string); ok {
+		return func() any { return rng.Intn(1000) }
+	}
+	return func() any { return fmt.Sprint(gen()) }
+}
+
+// SetDrift makes the fields drift, taking a step every interval after start;
+// every Fielder of a run should have the same start.
+func (f *Fielder) SetDrift(start time.Time, every time.Duration) {
+	f.drift.start = start
+	f.drift.every = every
+}
+
+// applyDrift takes the steps that are due. A new field is always present, so
+// that it's seen as soon as it appears.
+func (f *Fielder) applyDrift() {
+	d := &f.drift
+	if d.every <= 0 || d.taken == len(d.steps) {
+		return
+	}
+	due := min(int(time.Since(d.start)/d.every), len(d.steps))
+	for ; d.taken < due; d.taken++ {
+		step := d.steps[d.taken]
+		if step.add == nil {
+			f.fields[step.key] = retype(f.rng, f.fields[step.key])
+			continue
+		}
+		if _, ok := f.fields[step.key]; !ok {
+			f.keys = append(f.keys, step.key)
+			f.presence = append(f.presence, 1)
+		}
+		f.fields[step.key] = step.add
+	}
+}
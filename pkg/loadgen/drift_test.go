@@ -0,0 +1,63 @@
+package loadgen
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFielder_drift(t *testing.T) {
+	newDrifting := func(start time.Time) *Fielder {
+		fielder, err := NewFielder("drift", map[string]string{}, 8, 1, 100, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fielder.SetDrift(start, time.Minute)
+		return fielder
+	}
+	typesOf := func(fields map[string]any) map[string]string {
+		types := make(map[string]string)
+		for k, v := range fields {
+			types[k] = fmt.Sprintf("%T", v)
+		}
+		return types
+	}
+
+	early := typesOf(newDrifting(time.Now()).GetFields(0, 0))
+	late := typesOf(newDrifting(time.Now().Add(-time.Hour)).GetFields(0, 0))
+	var retyped, added int
+	for k, typ := range late {
+		switch earlyType, ok := early[k]; {
+		case !ok:
+			added++
+		case earlyType != typ:
+			retyped++
+		}
+	}
+	if retyped == 0 || retyped+added != maxDriftSteps {
+		t.Errorf("expected %d fields to change type or appear, got %d and %d: %v then %v", maxDriftSteps, retyped, added, early, late)
+	}
+
+	// the same seed drifts the same way
+	if again := typesOf(newDrifting(time.Now().Add(-time.Hour)).GetFields(0, 0)); !reflect.DeepEqual(late, again) {
+		t.Errorf("expected the same drift for the same seed, got %v and %v", late, again)
+	}
+
+	// halfway, only half the steps have been taken
+	halfway := newDrifting(time.Now().Add(-maxDriftSteps / 2 * time.Minute))
+	halfway.GetFields(0, 0)
+	if halfway.drift.taken != maxDriftSteps/2 {
+		t.Errorf("expected %d steps after %d minutes, got %d", maxDriftSteps/2, maxDriftSteps/2, halfway.drift.taken)
+	}
+
+	// without --drift, nothing changes
+	fielder, err := NewFielder("drift", map[string]string{}, 8, 1, 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fielder.SetDrift(time.Now().Add(-time.Hour), 0)
+	if still := typesOf(fielder.GetFields(0, 0)); !reflect.DeepEqual(early, still) {
+		t.Errorf("expected no drift, got %v then %v", early, still)
+	}
+}
@@ -0,0 +1,64 @@
+package loadgen
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanEventNames are the informational events that --eventrate adds to child
+// spans, as opposed to the exceptions that are added to error spans.
+var spanEventNames = []string{
+	"cache_miss", "cache_hit", "retry", "gc_pause", "queue_wait",
+	"connection_acquired", "message_published", "feature_flag_evaluated",
+}
+
+// spanEvent is an event to be added to a span when it ends, once its
+// lifetime is known, so that the events can be spread across it.
+type spanEvent struct {
+	name  string
+	attrs []attribute.KeyValue
+}
+
+// spanEventGen decides which spans get events and what they are.
+type spanEventGen struct {
+	rng     *lockedRng
+	rate    float64
+	perSpan int
+}
+
+func newSpanEventGen(opts *Options) *spanEventGen {
+	return &spanEventGen{rng: newLockedRng(opts.Global.Seed + "events"), rate: opts.Format.EventRate, perSpan: opts.Format.EventsPerSpan}
+}
+
+// next returns the events for one span: perSpan of them for a fraction rate
+// of spans, and none for the rest. A nil generator never makes events.
+func (g *spanEventGen) next() []spanEvent {
+	if g == nil || g.rate <= 0 || g.perSpan <= 0 {
+		return nil
+	}
+	if g.rng.Float(0, 1) >= g.rate {
+		return nil
+	}
+	events := make([]spanEvent, g.perSpan)
+	for i := range events {
+		events[i] = spanEvent{
+			name: g.rng.Choice(spanEventNames),
+			attrs: []attribute.KeyValue{
+				attribute.String("event.target", g.rng.Choice(nouns)),
+				attribute.String("event.detail", g.rng.Choice(adjectives)+" "+g.rng.Choice(nouns)),
+			},
+		}
+	}
+	return events
+}
+
+// addSpanEvents adds the events to the span with timestamps spread evenly
+// between start and end.
+func addSpanEvents(span trace.Span, events []spanEvent, start, end time.Time) {
+	step := end.Sub(start) / time.Duration(len(events)+1)
+	for i, e := range events {
+		span.AddEvent(e.name, trace.WithTimestamp(start.Add(step*time.Duration(i+1))), trace.WithAttributes(e.attrs...))
+	}
+}
@@ -0,0 +1,69 @@
+package loadgen
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSenderOTel_spanEvents(t *testing.T) {
+	opts := newOptions()
+	opts.Format.EventRate = 0.25
+	opts.Format.EventsPerSpan = 4
+	exporter := tracetest.NewInMemoryExporter()
+	sender := &SenderOTel{opts: opts, exceptions: newExceptionGen("test", nil), events: newSpanEventGen(opts), exporters: []*sharedExporter{{SpanExporter: exporter, log: NewLogger(0)}}, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0)}
+
+	fielder := newTestFielder(t, 2)
+	const nspans = 2000
+	for i := 0; i < nspans; i++ {
+		ctx, root := sender.CreateTrace(context.Background(), "svc", fielder, 1)
+		_, child := sender.CreateSpan(ctx, "svc", 1, fielder)
+		child.Send()
+		root.Send()
+		if i%100 == 0 {
+			// flush often so that the batcher's queue never overflows and drops spans
+			for _, tp := range sender.providers {
+				tp.ForceFlush(context.Background())
+			}
+		}
+	}
+	for _, tp := range sender.providers {
+		tp.ForceFlush(context.Background())
+	}
+	if n := len(exporter.GetSpans()); n != 2*nspans {
+		t.Fatalf("expected %d spans, got %d", 2*nspans, n)
+	}
+
+	events := 0
+	for _, span := range exporter.GetSpans() {
+		for _, e := range span.Events {
+			if e.Name == "exception" {
+				continue
+			}
+			events++
+			if e.Time.Before(span.StartTime) || e.Time.After(span.EndTime) {
+				t.Errorf("event %s at %v is outside its span (%v to %v)", e.Name, e.Time, span.StartTime, span.EndTime)
+			}
+		}
+		if !span.Parent.IsValid() && len(span.Events) > 0 && span.Events[0].Name != "exception" {
+			t.Errorf("root spans shouldn't get informational events")
+		}
+	}
+	perSpan := float64(events) / nspans
+	if want := opts.Format.EventRate * float64(opts.Format.EventsPerSpan); math.Abs(perSpan-want) > 0.15 {
+		t.Errorf("expected about %.2f events per span, got %.2f", want, perSpan)
+	}
+}
+
+func TestSpanEventGen_disabled(t *testing.T) {
+	var g *spanEventGen
+	if events := g.next(); events != nil {
+		t.Errorf("a nil generator shouldn't make events, got %v", events)
+	}
+	if events := newSpanEventGen(newOptions()).next(); events != nil {
+		t.Errorf("a zero rate shouldn't make events, got %v", events)
+	}
+}
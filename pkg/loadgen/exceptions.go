@@ -0,0 +1,85 @@
+package loadgen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// An Exception is one kind of exception recorded on error spans; the pool of
+// them can be replaced with an exceptions list in the config file.
+type Exception struct {
+	Type     string   `yaml:"type"`
+	Messages []string `yaml:"messages,omitempty"`
+}
+
+// defaultExceptions are used when the config file doesn't list any. A %s in a
+// message is replaced by a random noun.
+var defaultExceptions = []Exception{
+	{Type: "NullPointerException", Messages: []string{
+		"Cannot invoke \"%s.getId()\" because the return value is null",
+		"Cannot read field \"%s\" because it is null",
+	}},
+	{Type: "TimeoutError", Messages: []string{
+		"timed out waiting for %s after 30s",
+		"operation on %s timed out",
+	}},
+	{Type: "ConnectionRefusedError", Messages: []string{
+		"[Errno 111] Connection refused connecting to %s",
+	}},
+	{Type: "IllegalArgumentException", Messages: []string{
+		"%s must not be empty",
+		"invalid value for %s",
+	}},
+	{Type: "KeyError", Messages: []string{
+		"'%s'",
+	}},
+	{Type: "IndexOutOfBoundsException", Messages: []string{
+		"Index 3 out of bounds for length 3",
+	}},
+}
+
+// exceptionGen chooses exceptions from a pool.
+type exceptionGen struct {
+	rng    *lockedRng
+	pool   []Exception
+	stacks map[string]string
+}
+
+func newExceptionGen(seed string, pool []Exception) *exceptionGen {
+	if len(pool) == 0 {
+		pool = defaultExceptions
+	}
+	stacks := make(map[string]string, len(pool))
+	for _, e := range pool {
+		stacks[e.Type] = fakeStacktrace(e.Type)
+	}
+	return &exceptionGen{rng: newLockedRng(seed + "exceptions"), pool: pool, stacks: stacks}
+}
+
+// next returns the type, message, and stacktrace of a randomly chosen exception.
+func (g *exceptionGen) next() (string, string, string) {
+	e := g.pool[g.rng.Intn(len(g.pool))]
+	message := e.Type
+	if len(e.Messages) > 0 {
+		message = g.rng.Choice(e.Messages)
+		if strings.Contains(message, "%s") {
+			message = fmt.Sprintf(message, g.rng.Choice(nouns))
+		}
+	}
+	return e.Type, message, g.stacks[e.Type]
+}
+
+// fakeStacktrace returns a plausible multi-line stacktrace for the exception type.
+// It's seeded by the type so that every exception of a type has the same stack,
+// the way a real bug would, which lets backends group them.
+func fakeStacktrace(typ string) string {
+	rng := NewRng(typ)
+	var b strings.Builder
+	b.WriteString(typ)
+	for i := 0; i < 3+int(rng.Intn(5)); i++ {
+		pkg := rng.Choice(nouns)
+		file := rng.Choice(adjectives) + "_" + rng.Choice(nouns)
+		fmt.Fprintf(&b, "\n\tat %s.%s (%s/%s.go:%d)", pkg, strings.ToUpper(file[:1])+file[1:], pkg, file, rng.Int(10, 500))
+	}
+	return b.String()
+}
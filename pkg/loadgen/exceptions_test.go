@@ -0,0 +1,55 @@
+package loadgen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExceptionGen_next(t *testing.T) {
+	tests := []struct {
+		name string
+		pool []Exception
+	}{
+		{"default pool", nil},
+		{"configured pool", []Exception{
+			{Type: "PaymentDeclined", Messages: []string{"card %s was declined"}},
+			{Type: "OutOfStock"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected := map[string]bool{}
+			pool := tt.pool
+			if pool == nil {
+				pool = defaultExceptions
+			}
+			for _, e := range pool {
+				expected[e.Type] = true
+			}
+
+			gen := newExceptionGen("test", tt.pool)
+			seen := map[string]bool{}
+			stacks := map[string]string{}
+			for i := 0; i < 500; i++ {
+				typ, message, stack := gen.next()
+				if !expected[typ] {
+					t.Fatalf("unexpected exception type %s", typ)
+				}
+				seen[typ] = true
+				if message == "" || strings.Contains(message, "%s") {
+					t.Errorf("bad message %q for %s", message, typ)
+				}
+				if !strings.HasPrefix(stack, typ+"\n") || strings.Count(stack, "\n") < 3 {
+					t.Errorf("stacktrace for %s isn't multi-line:\n%s", typ, stack)
+				}
+				if prev, ok := stacks[typ]; ok && prev != stack {
+					t.Errorf("exceptions of type %s have different stacktraces", typ)
+				}
+				stacks[typ] = stack
+			}
+			if len(seen) != len(expected) {
+				t.Errorf("expected all of %v, saw %v", expected, seen)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package loadgen
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// An externalCall is a leaf span for a call to a database or cache
+// (--leafrate), which the calling service makes as a CLIENT, with the OTel
+// database attributes, since the database itself isn't traced.
+type externalCall struct {
+	name  string
+	attrs []attribute.KeyValue
+}
+
+type externalCallKey struct{}
+
+// contextWithExternalCall asks the sender to make the next span a call to a
+// database or cache.
+func contextWithExternalCall(ctx context.Context) context.Context {
+	return context.WithValue(ctx, externalCallKey{}, true)
+}
+
+func isExternalCall(ctx context.Context) bool {
+	return ctx.Value(externalCallKey{}) != nil
+}
+
+// externalSystems are the databases and caches that leaf spans call, with the
+// port each one listens on.
+var externalSystems = []struct {
+	system string
+	port   int
+	sql    bool
+}{
+	{"postgresql", 5432, true},
+	{"mysql", 3306, true},
+	{"redis", 6379, false},
+}
+
+// externalCallGen chooses the calls.
+type externalCallGen struct {
+	rng *lockedRng
+	sql *sqlGen // has an Rng of its own, which is drawn from with rng locked
+}
+
+// newExternalCallGen returns a generator of calls whose SQL queries are of
+// the given numbers of tables and columns (--sqltables and --sqlcolumns).
+func newExternalCallGen(seed string, tables, columns int) (*externalCallGen, error) {
+	rng := newLockedRng(seed + "externals")
+	sql, err := newSQLGen(NewRng(seed+"sql"), tables, columns)
+	if err != nil {
+		return nil, err
+	}
+	return &externalCallGen{rng: rng, sql: sql}, nil
+}
+
+// next returns a query of a random table, or a command on a random key, and
+// names the span for its operation, as the OTel conventions do.
+func (g *externalCallGen) next() externalCall {
+	ext := externalSystems[g.rng.Intn(len(externalSystems))]
+	noun := g.rng.Choice(nouns)
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", ext.system),
+		attribute.String("server.address", ext.system),
+		attribute.Int("server.port", ext.port),
+		attribute.String("peer.service", ext.system),
+	}
+	if !ext.sql {
+		op := g.rng.Choice([]string{"GET", "GET", "GET", "SET", "DEL"})
+		return externalCall{name: op, attrs: append(attrs,
+			attribute.String("db.operation", op),
+			attribute.String("db.statement", fmt.Sprintf("%s %s:%d", op, noun, g.rng.Intn(10000))),
+		)}
+	}
+	st := withLock(g.rng, g.sql.next)
+	return externalCall{name: st.verb + " " + st.table, attrs: append(attrs,
+		attribute.String("db.name", ResourceLibrary),
+		attribute.String("db.operation", st.verb),
+		attribute.String("db.sql.table", st.table),
+		attribute.String("db.statement", st.text),
+	)}
+}
@@ -0,0 +1,2199 @@
+package loadgen
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/netip"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dgryski/go-wyhash"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spices is a list of common spices
+var spices = []string{
+	"allspice", "anise", "basil", "bay", "black pepper", "cardamom", "cayenne",
+	"cinnamon", "cloves", "coriander", "cumin", "curry", "dill", "fennel", "fenugreek",
+	"garlic", "ginger", "marjoram", "mustard", "nutmeg", "oregano", "paprika", "parsley",
+	"pepper", "rosemary", "saffron", "sage", "salt", "tarragon", "thyme", "turmeric", "vanilla",
+	"caraway", "chili", "masala", "lemongrass", "mint", "poppy", "sesame", "sumac", "mace",
+	"nigella", "peppercorn", "wasabi",
+}
+
+// adjectives is a list of common adjectives
+var adjectives = []string{
+	"able", "bad", "best", "better", "big", "black", "certain", "clear", "different", "early",
+	"easy", "economic", "federal", "free", "full", "good", "great", "hard", "high", "human",
+	"important", "international", "large", "late", "little", "local", "long", "low", "major",
+	"military", "national", "new", "old", "only", "other", "political", "possible", "public",
+	"real", "recent", "right", "small", "social", "special", "strong", "sure", "true", "white",
+	"whole", "young",
+}
+
+// nouns is a list of common nouns
+var nouns = []string{
+	"angle", "ant", "apple", "arch", "arm", "army", "baby", "bag", "ball", "band", "basin", "basket", "bath", "bed", "bee", "bell",
+	"berry", "bird", "blade", "board", "boat", "bone", "book", "boot", "bottle", "box", "boy", "brain", "brake", "branch", "brick", "bridge",
+	"brush", "bucket", "bulb", "button", "cake", "camera", "card", "carriage", "cart", "cat", "chain", "cheese", "chess", "chin", "church", "circle",
+	"clock", "cloud", "coat", "collar", "comb", "cord", "cow", "cup", "curtain", "cushion", "dog", "door", "drain", "drawer", "dress", "drop",
+	"ear", "egg", "engine", "eye", "face", "farm", "feather", "finger", "fish", "flag", "floor", "fly", "foot", "fork", "fowl", "frame",
+	"garden", "girl", "glove", "goat", "gun", "hair", "hammer", "hand", "hat", "head", "heart", "hook", "horn", "horse", "hospital", "house",
+	"island", "jewel", "kettle", "key", "knee", "knife", "knot", "leaf", "leg", "library", "line", "lip", "lock", "map", "match", "monkey",
+	"moon", "mouth", "muscle", "nail", "neck", "needle", "nerve", "net", "nose", "nut", "office", "orange", "oven", "parcel", "pen", "pencil",
+	"picture", "pig", "pin", "pipe", "plane", "plate", "plough", "pocket", "pot", "potato", "prison", "pump", "rail", "rat", "receipt", "ring",
+	"rod", "roof", "root", "sail", "school", "scissors", "screw", "seed", "sheep", "shelf", "ship", "shirt", "shoe", "skin", "skirt", "snake",
+	"sock", "spade", "sponge", "spoon", "spring", "square", "stamp", "star", "station", "stem", "stick", "stocking", "stomach", "store", "street", "sun",
+	"table", "tail", "thread", "throat", "thumb", "ticket", "toe", "tongue", "tooth", "town", "train", "tray", "tree", "trousers", "umbrella", "wall",
+	"watch", "wheel", "whip", "whistle", "window", "wing", "wire", "worm",
+}
+
+// constfield is a field that *doesn't* start with slash
+var constfield = regexp.MustCompile(`^([^/].*)$`)
+
+// genfield is used to parse generator fields by matching valid commands and numeric arguments
+var genfield = regexp.MustCompile(`^/([ibfsuk][awxrgqtpz]?[c]?|geo|seqts|seq|uuid|ulid|dur|moneyl|money|sql)([0-9.-]+)?(?:,([0-9.-]+))?(?:,([0-9.-]+))?(?:,([0-9.-]+))?$`)
+
+// wordfield is used to parse generators whose arguments aren't just numbers (like /enum a:1,b:2);
+// the arguments are separated from the generator name by whitespace
+var wordfield = regexp.MustCompile(`^/([a-z][a-z0-9]*)(?:\s+(.*))?$`)
+
+// wordGenerators are the generators that are parsed with wordfield instead of genfield
+var wordGenerators = map[string]func(rng Rng, args string) (func() any, error){
+	"enum": getEnumGen,
+	"arr":  getArrGen,
+	"hist": getHistGen,
+	"ipc":  getCIDRGen,
+	"b64":  getBase64Gen,
+
+	"useragent": getUserAgentGen,
+	"httproute": getHTTPRouteGen,
+}
+
+// dependentGenerators are parsed with wordfield too, but their values depend on
+// another field of the same span, which is named by their first argument
+var dependentGenerators = map[string]func(rng Rng, args string) (string, func(source any) any, error){
+	"latency":    getLatencyGen,
+	"httpmethod": getHTTPMethodGen,
+	"httptarget": getHTTPTargetGen,
+}
+
+// errorGenerator is the generator of a bool that's true exactly on the spans
+// that the sender gives an error status (/iserror); it takes no arguments.
+const errorGenerator = "iserror"
+
+// serviceGenerator is the generator of a string made from a pattern, like
+// "/svc {service}-queue", with each {service} replaced by the service of the
+// span (/svc); see Fielder.addServiceFields.
+const (
+	serviceGenerator   = "svc"
+	servicePlaceholder = "{service}"
+)
+
+// A serviceField is a field whose value is its pattern with the span's
+// service in it.
+type serviceField struct {
+	name    string
+	pattern string
+}
+
+// A dependentField is a field whose value is computed from the source field's
+// value in the same span; see Fielder.addDependents for the ordering.
+type dependentField struct {
+	name   string
+	source string
+	gen    func(source any) any
+}
+
+// presenceSuffix is an optional suffix of a field spec, like "/ir100 presence:0.3",
+// that gives the probability that the field appears in a span
+var presenceSuffix = regexp.MustCompile(`^(.*?)\s+presence:([0-9.]+)$`)
+
+// keysplitter separates fields that look like number.name (ex: 1.myfield)
+var keysplitter = regexp.MustCompile(`^([0-9]+)\.(.*$)`)
+
+type Rng struct {
+	rng *rand.Rand
+}
+
+func NewRng(seed string) Rng {
+	return Rng{rand.New(rand.NewSource(int64(wyhash.Hash([]byte(seed), 2467825690))))}
+}
+
+func (r Rng) Intn(n int) int64 {
+	return int64(r.rng.Intn(n))
+}
+
+// Chooses a random element from a slice of strings.
+func (r Rng) Choice(a []string) string {
+	if len(a) == 0 {
+		return ""
+	}
+	return a[r.Intn(len(a))]
+}
+
+// ZipfChoice returns a function that chooses random elements of a slice of
+// strings following Zipf's law with exponent s (which must be greater than 1):
+// the first element is the most common, the second is 2^s times less common,
+// and so on, so a few of them dominate a long tail.
+func (r Rng) ZipfChoice(a []string, s float64) func() string {
+	if len(a) == 0 {
+		return func() string { return "" }
+	}
+	z := rand.NewZipf(r.rng, s, 1, uint64(len(a)-1))
+	return func() string { return a[z.Uint64()] }
+}
+
+// Chooses a random element from a slice of strings, with a quadratic bias
+// towards the first elements.
+func (r Rng) QuadraticChoice(a []string) string {
+	if len(a) == 0 {
+		return ""
+	}
+	sq := float64(len(a) * len(a))
+	rn := r.Float(0, sq)
+	choice := len(a) - int(math.Floor(math.Sqrt(rn))) - 1
+	// clamp in case floating point rounding puts us just outside the slice
+	choice = max(0, min(choice, len(a)-1))
+	return a[choice]
+}
+
+func (r Rng) Bool() bool {
+	return r.Intn(2) == 0
+}
+
+// Int returns an int from min up to but not including max, or min if the range is empty.
+func (r Rng) Int(min, max int) int64 {
+	if max <= min {
+		return int64(min)
+	}
+	return int64(r.rng.Intn(max-min) + min)
+}
+
+func (r Rng) Float(min, max float64) float64 {
+	return r.rng.Float64()*(max-min) + min
+}
+
+func (r Rng) Exponential(mean float64) float64 {
+	return r.rng.ExpFloat64() * mean
+}
+
+// Pareto returns a value of at least xm from a Pareto distribution with shape alpha;
+// smaller alphas have longer tails.
+func (r Rng) Pareto(xm, alpha float64) float64 {
+	return xm / math.Pow(1-r.rng.Float64(), 1/alpha)
+}
+
+func (r Rng) Gaussian(mean, stddev float64) float64 {
+	return r.rng.NormFloat64()*stddev + mean
+}
+
+func (r Rng) GaussianInt(mean, stddev float64) int64 {
+	return int64(r.rng.NormFloat64()*stddev + mean)
+}
+
+func (r Rng) String(len int) string {
+	var b strings.Builder
+	for i := 0; i < len; i++ {
+		b.WriteByte(byte("abcdefghijklmnopqrstuvwxyz"[r.Int(0, 26)]))
+	}
+	return b.String()
+}
+
+// Bytes returns n random bytes.
+func (r Rng) Bytes(n int) []byte {
+	b := make([]byte, n)
+	r.rng.Read(b)
+	return b
+}
+
+func (r Rng) HexString(len int) string {
+	var b strings.Builder
+	for i := 0; i < len; i++ {
+		b.WriteByte(byte("0123456789abcdef"[r.Int(0, 16)]))
+	}
+	return b.String()
+}
+
+// UUID returns a random (version 4) UUID in the canonical 8-4-4-4-12 form.
+func (r Rng) UUID() string {
+	b := make([]byte, 16)
+	r.rng.Read(b)
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// crockford is the base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID returns a ULID for the given time: a 48-bit millisecond timestamp followed
+// by 80 random bits, encoded so that ULIDs from later milliseconds sort after earlier ones.
+func (r Rng) ULID(t time.Time) string {
+	b := make([]byte, 16)
+	ms := uint64(t.UnixMilli())
+	for i := 0; i < 6; i++ {
+		b[i] = byte(ms >> (40 - 8*i))
+	}
+	r.rng.Read(b[6:])
+	// 26 characters of 5 bits each hold the 128 bits with 2 to spare at the top
+	out := make([]byte, 26)
+	for i := range out {
+		bit := i*5 - 2
+		var v int
+		for j := 0; j < 5; j++ {
+			v <<= 1
+			if pos := bit + j; pos >= 0 && b[pos/8]&(0x80>>(pos%8)) != 0 {
+				v |= 1
+			}
+		}
+		out[i] = crockford[v]
+	}
+	return string(out)
+}
+
+func (r Rng) WordPair() string {
+	return r.Choice(adjectives) + "-" + r.Choice(nouns)
+}
+
+// keyStyles write the adjective and noun of a random field's name in the
+// style chosen by --keystyle.
+var keyStyles = map[string]func(adj, noun string) string{
+	"wordpair": func(adj, noun string) string { return adj + "-" + noun },
+	"dotted":   func(adj, noun string) string { return "service." + adj + "." + noun },
+	"snake":    func(adj, noun string) string { return adj + "_" + noun },
+	"camel":    func(adj, noun string) string { return adj + strings.ToUpper(noun[:1]) + noun[1:] },
+}
+
+func (r Rng) BoolWithProb(p float64) bool {
+	return r.Float(0, 100) < p
+}
+
+// getProcessID returns the process ID
+func getProcessID() int64 {
+	return int64(os.Getpid())
+}
+
+// newProcessIDs returns n distinct synthetic process ids (--processes), the
+// same ones for a given seed, in the range a Linux pid would be.
+func newProcessIDs(seed string, n int) []int64 {
+	rng := NewRng(seed + "processes")
+	ids := make([]int64, 0, n)
+	seen := make(map[int64]bool)
+	for len(ids) < n {
+		id := rng.Int(300, 4194304)
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (r Rng) getValueGenerators() []func() any {
+	return []func() any{
+		func() any { return r.Intn(100) },
+		func() any { return r.BoolWithProb(99) },
+		func() any { return r.BoolWithProb(50) },
+		func() any { return r.BoolWithProb(1) },
+		func() any { return r.Int(-100, 100) },
+		func() any { return r.Float(0, 1000) },
+		func() any { return r.Float(0, 1) },
+		func() any { return r.GaussianInt(50, 30) },
+		func() any { return r.Gaussian(10000, 1000) },
+		func() any { return r.Gaussian(500, 300) },
+		func() any { return r.String(2) },
+		func() any { return r.String(5) },
+		func() any { return r.String(10) },
+		func() any { return r.String(4) + "-" + r.HexString(8) + "-" + r.String(4) },
+		func() any { return r.HexString(16) },
+	}
+}
+
+// getWordList returns a list of words with the specified cardinality;
+// if a source word list is specified and cardinality fits within it, it uses it.
+func getWordList(rng Rng, cardinality int, source []string) []string {
+	generator := rng.WordPair
+	if source != nil && len(source) >= cardinality {
+		generator = func() string { return rng.Choice(source) }
+	}
+	words := make([]string, cardinality)
+	for i := 0; i < cardinality; i++ {
+		words[i] = generator()
+	}
+	return words
+}
+
+type EligibilityPeriod struct {
+	word  string
+	start time.Duration
+	end   time.Duration
+}
+
+type PeriodicEligibility struct {
+	rng     Rng
+	periods []EligibilityPeriod
+	period  time.Duration
+}
+
+// generates a list of eligibility periods for a set of words
+// each word is eligible for some period of time that is proportional to its position in the list
+// this is so that all the words are not available at the same time, but eventually all of them are
+func newPeriodicEligibility(rng Rng, words []string, period time.Duration) *PeriodicEligibility {
+	cardinality := len(words)
+	periods := make([]EligibilityPeriod, cardinality)
+	for i := 0; i < cardinality; i++ {
+		// calculate a period length that is proportional to the number of remaining words
+		periodLength := time.Duration(float64(period) * float64(cardinality-i) / float64(cardinality))
+		// startTime is a random value that ensures it will end before the next period starts
+		startTime := (period - periodLength) * time.Duration(rng.Float(0, 1))
+		periods[i] = EligibilityPeriod{
+			word:  words[i],
+			start: startTime,
+			end:   startTime + periodLength,
+		}
+	}
+	return &PeriodicEligibility{
+		rng:     rng,
+		periods: periods,
+		period:  period,
+	}
+}
+
+// gets one word from the list of eligible words based on the time since the start of the period
+// This is, on average, slower than the random selection, but the random one can sometimes
+// be very slow, so we use this as a fallback if we try randomly a few times and fail.
+func (pe *PeriodicEligibility) getEligibleWordFallback(durationSinceStart time.Duration) string {
+	tInPeriod := durationSinceStart % pe.period
+	eligibleIndexes := make([]int, 0, 20)
+	for i, period := range pe.periods {
+		if period.start <= tInPeriod && tInPeriod < period.end {
+			eligibleIndexes = append(eligibleIndexes, i)
+		}
+	}
+
+	if len(eligibleIndexes) == 0 {
+		// shouldn't happen, but if it does, just pick the first word
+		return pe.periods[0].word
+	}
+	ix := eligibleIndexes[pe.rng.Intn(len(eligibleIndexes))]
+	return pe.periods[ix].word
+}
+
+func (pe *PeriodicEligibility) getEligibleWord(durationSinceStart time.Duration) string {
+	tInPeriod := durationSinceStart % pe.period
+	// try 10 times to find an eligible word
+	for i := 0; i < 5; i++ {
+		ix := pe.rng.Intn(len(pe.periods))
+		period := pe.periods[ix]
+		if period.start <= tInPeriod && tInPeriod < period.end {
+			return period.word
+		}
+	}
+	// use the fallback
+	return pe.getEligibleWordFallback(durationSinceStart)
+}
+
+// parseUserFields expects a list of fields in the form of name=constant or name=/gen.
+// See README.md for more information.
+func parseUserFields(rng Rng, userfields map[string]string) (map[string]func() any, error) {
+	// groups                                        1                   2	         3         4
+	fields := make(map[string]func() any)
+	for name, value := range userfields {
+		if strings.HasPrefix(value, traceScope) {
+			// these are parsed by parseTraceFields
+			continue
+		}
+		// see if it's a constant
+		if constfield.MatchString(value) {
+			fields[name] = getConst(value)
+			continue
+		}
+
+		// see if it's a generator that takes non-numeric arguments; /b64 without
+		// a length is still a bool that's true 64% of the time
+		if matches := wordfield.FindStringSubmatch(value); matches != nil && !(matches[1] == "b64" && matches[2] == "") {
+			if _, ok := dependentGenerators[matches[1]]; ok {
+				// these are parsed by parseDependentFields
+				continue
+			}
+			if matches[1] == errorGenerator {
+				// these are parsed by parseErrorFields
+				continue
+			}
+			if matches[1] == serviceGenerator {
+				// these are parsed by parseServiceFields
+				continue
+			}
+			if wordgen, ok := wordGenerators[matches[1]]; ok {
+				gen, err := wordgen(rng, strings.TrimSpace(matches[2]))
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s in user field %s=%s: %w", matches[1], name, value, err)
+				}
+				fields[name] = gen
+				continue
+			}
+		}
+
+		// see if it's a generator
+		matches := genfield.FindStringSubmatch(value)
+		if matches == nil {
+			return nil, fmt.Errorf("unparseable user field %s=%s", name, value)
+		}
+		var err error
+		gentype := matches[1]
+		p1 := matches[2]
+		p2 := matches[3]
+		p3 := matches[4]
+		p4 := matches[5]
+		switch gentype {
+		case "ip":
+			// /ip6 parses as ip with a single argument of 6, which /ip otherwise can't have
+			if p1 == "6" && p2 == "" {
+				fields[name] = func() any { return randomAddrIn(rng, globalUnicast6).String() }
+				continue
+			}
+			fields[name], err = getIpGen(rng, p1, p2, p3, p4)
+			if err != nil {
+				return nil, fmt.Errorf("invalid int in user field %s=%s: %w", name, value, err)
+			}
+		case "i", "ir", "ig":
+			fields[name], err = getIntGen(rng, gentype, p1, p2)
+			if err != nil {
+				return nil, fmt.Errorf("invalid int in user field %s=%s: %w", name, value, err)
+			}
+		case "f", "fr", "fg":
+			fields[name], err = getFloatGen(rng, gentype, p1, p2)
+			if err != nil {
+				return nil, fmt.Errorf("invalid float in user field %s=%s: %w", name, value, err)
+			}
+		case "b":
+			n := 50.0
+			var err error
+			if p1 != "" {
+				n, err = strconv.ParseFloat(p1, 64)
+				if err != nil || n < 0 || n > 100 {
+					return nil, fmt.Errorf("invalid bool option in %s=%s", name, value)
+				}
+			}
+			fields[name] = func() any { return rng.BoolWithProb(n) }
+		case "s", "sw", "sx", "sa", "sq", "sz", "sxc":
+			n := 16
+			if p1 != "" {
+				n, err = strconv.Atoi(p1)
+				if err != nil {
+					return nil, fmt.Errorf("invalid string option in %s=%s", name, value)
+				}
+			}
+			switch gentype {
+			case "sw":
+				// words with specified cardinality in a rectangular distribution
+				words := getWordList(rng, n, nil)
+				fields[name] = func() any { return rng.Choice(words) }
+			case "sq":
+				// words with specified cardinality in a quadratic distribution
+				words := getWordList(rng, n, nil)
+				fields[name] = func() any { return rng.QuadraticChoice(words) }
+			case "sz":
+				// words with specified cardinality in a zipfian distribution
+				skew := 1.1
+				if p2 != "" {
+					skew, err = strconv.ParseFloat(p2, 64)
+					if err != nil || skew <= 1 {
+						return nil, fmt.Errorf("invalid skew in user field %s=%s: it must be greater than 1", name, value)
+					}
+				}
+				words := getWordList(rng, n, nil)
+				choose := rng.ZipfChoice(words, skew)
+				fields[name] = func() any { return choose() }
+			case "sx":
+				fields[name] = func() any { return rng.HexString(n) }
+			case "sxc":
+				fields[name], err = genHexStringWithCardinality(rng, p1, p2)
+				if err != nil {
+					return nil, fmt.Errorf("invalid int in user field %s=%s: %w", name, value, err)
+				}
+			default:
+				fields[name] = func() any { return rng.String(n) }
+			}
+		case "geo":
+			lat, lon, err := getGeoGens(rng, p1, p2, p3, p4)
+			if err != nil {
+				return nil, fmt.Errorf("invalid geo in user field %s=%s: %w", name, value, err)
+			}
+			// a name like pickup.latlon becomes the two float fields pickup.lat and pickup.lon
+			if prefix, ok := strings.CutSuffix(name, ".latlon"); ok {
+				fields[prefix+".lat"] = lat
+				fields[prefix+".lon"] = lon
+			} else {
+				fields[name] = func() any { return fmt.Sprintf("%.6f,%.6f", lat(), lon()) }
+			}
+		case "uuid":
+			fields[name] = func() any { return rng.UUID() }
+		case "ulid":
+			fields[name] = func() any { return rng.ULID(time.Now()) }
+		case "dur":
+			fields[name], err = getDurGen(rng, p1, p2)
+			if err != nil {
+				return nil, fmt.Errorf("invalid duration in user field %s=%s: %w", name, value, err)
+			}
+		case "money", "moneyl":
+			fields[name], err = getMoneyGen(rng, gentype, p1, p2)
+			if err != nil {
+				return nil, fmt.Errorf("invalid money in user field %s=%s: %w", name, value, err)
+			}
+		case "sql":
+			fields[name], err = getSQLGen(rng, p1, p2)
+			if err != nil {
+				return nil, fmt.Errorf("invalid sql in user field %s=%s: %w", name, value, err)
+			}
+		case "seqts":
+			// these are parsed by parseTraceFields
+			continue
+		case "seq":
+			fields[name], err = getSeqGen(name+"="+value, p1, p2)
+			if err != nil {
+				return nil, fmt.Errorf("invalid seq in user field %s=%s: %w", name, value, err)
+			}
+		case "k":
+			fields[name], err = getKeyGen(rng, p1, p2)
+			if err != nil {
+				return nil, fmt.Errorf("invalid key in key field %s=%s: %w", name, value, err)
+			}
+		case "u", "uq":
+			// Generate a URL-like string with a random path and possibly a query string
+			fields[name], err = getURLGen(rng, gentype, p1, p2)
+			if err != nil {
+				return nil, fmt.Errorf("invalid float in user field %s=%s: %w", name, value, err)
+			}
+		case "st":
+			// Generate a semi-plausible mix of status codes; percentage of 400s and 500s can be controlled by the extra args
+			twos := 95.0
+			fours := 4.0
+			fives := 1.0
+			if p1 != "" {
+				fours, err = strconv.ParseFloat(p1, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid float in user field %s=%s: %w", name, value, err)
+				}
+			}
+			if p2 != "" {
+				fives, err = strconv.ParseFloat(p2, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid float in user field %s=%s: %w", name, value, err)
+				}
+			}
+			twos = 100 - fours - fives
+			fields[name] = func() any {
+				r := rng.Float(0, 100)
+				if r < twos {
+					return rng.QuadraticChoice([]string{"200", "200", "200", "201", "202"})
+				} else if r < twos+fours {
+					return rng.QuadraticChoice([]string{"404", "400", "400", "400", "402", "429", "403"})
+				} else {
+					return "500"
+				}
+			}
+
+		default:
+			return nil, fmt.Errorf("invalid generator type %s in field %s=%s", gentype, name, value)
+		}
+	}
+	return fields, nil
+}
+
+func getConst(value string) func() any {
+	var gen func() any
+	if value == "true" {
+		gen = func() any { return true }
+	} else if value == "false" {
+		gen = func() any { return false }
+	} else {
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			gen = func() any { return i }
+		} else if f, err := strconv.ParseFloat(value, 64); err == nil {
+			gen = func() any { return f }
+		} else {
+			gen = func() any { return value }
+		}
+	}
+	return gen
+}
+
+func gaussianDefaults(v1, v2 float64) (float64, float64) {
+	if v1 == 0 && v2 == 0 {
+		v1 = 100
+		v2 = 10
+	} else if v2 == 0 {
+		v2 = v1 / 10
+	}
+	return v1, v2
+}
+
+func genHexStringWithCardinality(rng Rng, p1 string, p2 string) (func() any, error) {
+
+	var v1, length int
+	var err error
+	if p1 != "" {
+		length, err = strconv.Atoi(p1)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not an int", p1)
+		}
+		if length >= 64 {
+			return nil, fmt.Errorf("sxc length can only be a max of 63")
+		}
+	} else {
+		length = 16
+	}
+	if p2 != "" {
+		v1, err = strconv.Atoi(p2)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not an int", p2)
+		}
+	} else {
+		v1 = 16
+	}
+
+	return func() any {
+		var number = rng.rng.Intn(v1)
+
+		data := fmt.Sprintf("%d:%d", number, length)
+		//Can switch to non-cryptographic hashes for higher throughputs
+		hash := sha256.Sum256([]byte(data))
+		hashString := hex.EncodeToString(hash[:])
+
+		// Truncate the hash to the specified length
+		if length > len(hashString) {
+			return hashString
+		}
+		return hashString[:length]
+	}, nil
+}
+
+// getIpGen generates IPv4 addresses; each argument is the number of values
+// (1-256) its part of the address can have, counting up from 0, so a part
+// with 1 is always 0.
+func getIpGen(rng Rng, p1, p2, p3, p4 string) (func() any, error) {
+	var parts [4]int
+	for i, p := range []string{p1, p2, p3, p4} {
+		v, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not an int", p)
+		}
+		if v < 1 || v > 256 {
+			return nil, fmt.Errorf("%d values can't fit in a part of an address; it must be from 1 to 256", v)
+		}
+		parts[i] = v
+	}
+	return func() any {
+		return fmt.Sprintf("%d.%d.%d.%d", rng.Int(0, parts[0]), rng.Int(0, parts[1]), rng.Int(0, parts[2]), rng.Int(0, parts[3]))
+	}, nil
+}
+
+// globalUnicast6 is the range that /ip6 draws from, so its addresses look like real public ones.
+var globalUnicast6 = netip.MustParsePrefix("2000::/3")
+
+// getCIDRGen generates addresses within a network given in CIDR form, like 10.0.0.0/8 or fd00::/8.
+func getCIDRGen(rng Rng, args string) (func() any, error) {
+	prefix, err := netip.ParsePrefix(args)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a network in CIDR form like 10.0.0.0/8", args)
+	}
+	prefix = prefix.Masked()
+	return func() any { return randomAddrIn(rng, prefix).String() }, nil
+}
+
+// randomAddrIn returns an address whose network bits are those of prefix and whose host bits are random.
+func randomAddrIn(rng Rng, prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	bits := prefix.Bits()
+	for i := range b {
+		// the bits of this byte that belong to the network
+		netbits := min(max(bits-8*i, 0), 8)
+		mask := byte(0xff << (8 - netbits))
+		b[i] = b[i]&mask | byte(rng.Intn(256))&^mask
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// maxBase64Bytes is the most random bytes that /b64 will encode, which is
+// already far more than most backends will keep in one attribute.
+const maxBase64Bytes = 1 << 20
+
+// getBase64Gen generates base64-encoded random bytes, for testing how large
+// attributes are handled; the argument is the number of bytes before encoding.
+func getBase64Gen(rng Rng, args string) (func() any, error) {
+	n, err := strconv.Atoi(args)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a number of bytes", args)
+	}
+	if n < 1 || n > maxBase64Bytes {
+		return nil, fmt.Errorf("%d bytes is out of range; it must be from 1 to %d", n, maxBase64Bytes)
+	}
+	return func() any { return base64.StdEncoding.EncodeToString(rng.Bytes(n)) }, nil
+}
+
+// browserAgents are User-Agent strings of common browsers, roughly in order of
+// popularity, since /useragent chooses among them with a quadratic bias.
+var browserAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+	"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.6367.82 Mobile Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.67",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0",
+	"Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0.0.0 Mobile Safari/537.36",
+	"Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+	"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+}
+
+// botAgents are User-Agent strings of crawlers, monitors, and scripts.
+var botAgents = []string{
+	"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+	"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+	"curl/8.4.0",
+	"python-requests/2.31.0",
+	"Go-http-client/1.1",
+	"Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)",
+	"Mozilla/5.0+(compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)",
+	"Mozilla/5.0 (compatible; YandexBot/3.0; +http://yandex.com/bots)",
+}
+
+// userAgentMixes are the named fractions of /useragent values that come from bots.
+var userAgentMixes = map[string]float64{
+	"web":      0.1,
+	"bots":     0.6,
+	"browsers": 0,
+}
+
+// getUserAgentGen generates User-Agent strings from browsers and bots. The
+// argument is the name of a mix (web, the default, bots, or browsers) or the
+// fraction (0-1) of values that should come from bots.
+func getUserAgentGen(rng Rng, args string) (func() any, error) {
+	bots := userAgentMixes["web"]
+	if args != "" {
+		var ok bool
+		if bots, ok = userAgentMixes[args]; !ok {
+			f, err := strconv.ParseFloat(args, 64)
+			if err != nil || f < 0 || f > 1 {
+				return nil, fmt.Errorf("unknown mix %q; expected web, bots, browsers, or a fraction of bots from 0 to 1", args)
+			}
+			bots = f
+		}
+	}
+	return func() any {
+		if rng.Float(0, 1) < bots {
+			return rng.QuadraticChoice(botAgents)
+		}
+		return rng.QuadraticChoice(browserAgents)
+	}, nil
+}
+
+// parseRange parses the arguments of a rectangular numeric generator. With
+// no arguments the range is 0 to 100; with one it's from 0 to that, or from
+// that to 0 if it's negative; with two they're the min and max. Either bound
+// can be negative, and if they're the same the range is just that value.
+func parseRange[T int | float64](p1, p2 string, what string, parse func(string) (T, error)) (T, T, error) {
+	if p1 == "" && p2 == "" {
+		return 0, 100, nil
+	}
+	var v1, v2 T
+	var err error
+	if p1 != "" {
+		v1, err = parse(p1)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%s is not %s", p1, what)
+		}
+	}
+	if p2 == "" {
+		if v1 < 0 {
+			return v1, 0, nil
+		}
+		return 0, v1, nil
+	}
+	v2, err = parse(p2)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s is not %s", p2, what)
+	}
+	if v2 < v1 {
+		return 0, 0, fmt.Errorf("max %v must not be less than min %v", v2, v1)
+	}
+	return v1, v2, nil
+}
+
+func parseFloat(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
+
+func getIntGen(rng Rng, gentype, p1, p2 string) (func() any, error) {
+	if gentype != "ig" {
+		v1, v2, err := parseRange(p1, p2, "an int", strconv.Atoi)
+		if err != nil {
+			return nil, err
+		}
+		if v1 == v2 {
+			v := int64(v1)
+			return func() any { return v }, nil
+		}
+		return func() any { return rng.Int(v1, v2) }, nil
+	}
+	var v1, v2 int
+	var err error
+	if p1 == "" {
+		v1 = 0
+	} else {
+		v1, err = strconv.Atoi(p1)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not an int", p1)
+		}
+	}
+	if p2 == "" || p2 == "," {
+		v2 = v1
+		v1 = 0
+	} else {
+		v2, err = strconv.Atoi(p2)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not an int", p2[:1])
+		}
+	}
+	g1, g2 := gaussianDefaults(float64(v1), float64(v2))
+	return func() any { return rng.GaussianInt(g1, g2) }, nil
+}
+
+// getDurGen returns a generator of durations like "1.2s" or "340ms", as Go
+// writes them, of a whole number of milliseconds between the min and max.
+func getDurGen(rng Rng, p1, p2 string) (func() any, error) {
+	v1, v2, err := parseRange(p1, p2, "an int", strconv.Atoi)
+	if err != nil {
+		return nil, err
+	}
+	if v1 < 0 {
+		return nil, fmt.Errorf("min %d must not be negative", v1)
+	}
+	if v1 == v2 {
+		d := (time.Duration(v1) * time.Millisecond).String()
+		return func() any { return d }, nil
+	}
+	return func() any { return (time.Duration(rng.Int(v1, v2)) * time.Millisecond).String() }, nil
+}
+
+// getMoneyGen returns a generator of amounts of money in dollars, rounded to
+// whole cents, between p1 and p2: uniformly for money, or log-uniformly for
+// moneyl, so that small amounts are as likely as large ones in each order of
+// magnitude, as prices and payments tend to be.
+func getMoneyGen(rng Rng, gentype, p1, p2 string) (func() any, error) {
+	v1, v2, err := parseRange(p1, p2, "a number", func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+	if err != nil {
+		return nil, err
+	}
+	if v1 < 0 {
+		return nil, fmt.Errorf("min %v must not be negative", v1)
+	}
+	lo, hi := int(math.Ceil(v1*100)), int(math.Floor(v2*100))
+	if hi < lo {
+		return nil, fmt.Errorf("there are no whole cents between %v and %v", v1, v2)
+	}
+	if gentype == "money" {
+		return func() any { return float64(rng.Int(lo, hi)) / 100 }, nil
+	}
+	// a log scale can't start at 0, so it starts at a cent
+	lo = max(lo, 1)
+	loglo, loghi := math.Log(float64(lo)), math.Log(float64(hi))
+	return func() any {
+		cents := math.Round(math.Exp(rng.Float(loglo, loghi)))
+		return math.Min(math.Max(cents, float64(lo)), float64(hi)) / 100
+	}, nil
+}
+
+func getFloatGen(rng Rng, gentype, p1, p2 string) (func() any, error) {
+	if gentype != "fg" {
+		v1, v2, err := parseRange(p1, p2, "a number", parseFloat)
+		if err != nil {
+			return nil, err
+		}
+		if v1 == v2 {
+			return func() any { return v1 }, nil
+		}
+		return func() any { return rng.Float(v1, v2) }, nil
+	}
+	var v1, v2 float64
+	var err error
+	if p1 == "" {
+		v1 = 0
+	} else {
+		v1, err = strconv.ParseFloat(p1, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a number", p1)
+		}
+	}
+	if p2 == "" || p2 == "," {
+		v2 = v1
+		v1 = 0
+	} else {
+		v2, err = strconv.ParseFloat(p2, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a number", p2[:1])
+		}
+	}
+	g1, g2 := gaussianDefaults(v1, v2)
+	return func() any { return rng.GaussianInt(g1, g2) }, nil
+}
+
+func getURLGen(rng Rng, gentype, p1, p2 string) (func() any, error) {
+	var c1 int = 3
+	var c2 int = 10
+	var err error
+	if p1 != "" {
+		c1, err = strconv.Atoi(p1)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a number", p1)
+		}
+	}
+	if p2 != "" && p2 != "," {
+		c2, err = strconv.Atoi(p2)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a number", p2[:1])
+		}
+	}
+	path1words := getWordList(rng, c1, nouns)
+	path1 := func() string { return rng.Choice(path1words) }
+	path2 := func() string { return "" }
+	if c2 != 0 {
+		path2words := getWordList(rng, c2, adjectives)
+		path2 = func() string { return rng.Choice(path2words) }
+	}
+	if gentype == "uq" {
+		return func() any {
+			return "https://example.com/" + path1() + "/" + path2() + "?extra=" + rng.String(10)
+		}, nil
+	} else {
+		return func() any {
+			return "https://example.com/" + path1() + "/" + path2()
+		}, nil
+	}
+}
+
+// parseDependentFields parses the user fields that use a dependent generator
+// (and ignores all the others), returning them in order by name.
+func parseDependentFields(rng Rng, userfields map[string]string) ([]dependentField, error) {
+	var deps []dependentField
+	for name, value := range userfields {
+		matches := wordfield.FindStringSubmatch(value)
+		if matches == nil {
+			continue
+		}
+		depgen, ok := dependentGenerators[matches[1]]
+		if !ok {
+			continue
+		}
+		source, gen, err := depgen(rng, strings.TrimSpace(matches[2]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in user field %s=%s: %w", matches[1], name, value, err)
+		}
+		deps = append(deps, dependentField{name: name, source: source, gen: gen})
+	}
+	sort.Slice(deps, func(i, j int) bool { return deps[i].name < deps[j].name })
+	return deps, nil
+}
+
+// parseErrorFields returns the names of the user fields that use /iserror, in order.
+func parseErrorFields(userfields map[string]string) ([]string, error) {
+	var names []string
+	for name, value := range userfields {
+		matches := wordfield.FindStringSubmatch(value)
+		if matches == nil || matches[1] != errorGenerator {
+			continue
+		}
+		if strings.TrimSpace(matches[2]) != "" {
+			return nil, fmt.Errorf("invalid %s in user field %s=%s: it takes no arguments", errorGenerator, name, value)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// parseServiceFields returns the user fields that use /svc, in order by name.
+func parseServiceFields(userfields map[string]string) ([]serviceField, error) {
+	var fields []serviceField
+	for name, value := range userfields {
+		matches := wordfield.FindStringSubmatch(value)
+		if matches == nil || matches[1] != serviceGenerator {
+			continue
+		}
+		pattern := strings.TrimSpace(matches[2])
+		if !strings.Contains(pattern, servicePlaceholder) {
+			return nil, fmt.Errorf("invalid %s in user field %s=%s: the pattern needs a %s", serviceGenerator, name, value, servicePlaceholder)
+		}
+		fields = append(fields, serviceField{name: name, pattern: pattern})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+	return fields, nil
+}
+
+// parsePresence removes the presence suffixes from the field specs, and returns
+// the specs without them and the presence of each field that had one.
+func parsePresence(userfields map[string]string) (map[string]string, map[string]float64, error) {
+	specs := make(map[string]string, len(userfields))
+	presence := make(map[string]float64)
+	for name, value := range userfields {
+		matches := presenceSuffix.FindStringSubmatch(value)
+		if matches == nil {
+			specs[name] = value
+			continue
+		}
+		p, err := strconv.ParseFloat(matches[2], 64)
+		if err != nil || p < 0 || p > 1 {
+			return nil, nil, fmt.Errorf("invalid presence in user field %s=%s: it must be between 0 and 1", name, value)
+		}
+		specs[name] = matches[1]
+		presence[name] = p
+	}
+	return specs, presence, nil
+}
+
+// A traceField is a field whose generator keeps state for the length of a
+// trace; the Fielder starts it over at each root span.
+type traceField struct {
+	name string
+	gen  traceGen
+}
+
+type traceGen interface {
+	startTrace()
+	next() any
+}
+
+// traceScope is the prefix of a generator whose value is the same on every
+// span of a trace, like @/uuid for a request id.
+const traceScope = "@/"
+
+// parseTraceFields parses the user fields that use a per-trace generator,
+// including the trace-scoped ones (and ignores all the others), returning
+// them in order by name.
+func parseTraceFields(rng Rng, userfields map[string]string) ([]traceField, error) {
+	var tfs []traceField
+	for name, value := range userfields {
+		if spec, ok := strings.CutPrefix(value, "@"); ok && strings.HasPrefix(value, traceScope) {
+			fields, err := parseUserFields(rng, map[string]string{name: spec})
+			if err != nil {
+				return nil, err
+			}
+			gen, ok := fields[name]
+			if !ok {
+				return nil, fmt.Errorf("user field %s=%s can't be trace-scoped", name, value)
+			}
+			tfs = append(tfs, traceField{name: name, gen: &traceScoped{gen: gen}})
+			continue
+		}
+		matches := genfield.FindStringSubmatch(value)
+		if matches == nil || matches[1] != "seqts" {
+			continue
+		}
+		gen, err := getSeqTimestampsGen(rng, matches[2], matches[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid seqts in user field %s=%s: %w", name, value, err)
+		}
+		tfs = append(tfs, traceField{name: name, gen: gen})
+	}
+	sort.Slice(tfs, func(i, j int) bool { return tfs[i].name < tfs[j].name })
+	return tfs, nil
+}
+
+// traceScoped repeats a generator's value on every span of a trace, and
+// draws a new one for the next trace.
+type traceScoped struct {
+	mut   sync.Mutex
+	gen   func() any
+	value any // nil until it's drawn for the trace
+}
+
+func (t *traceScoped) startTrace() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.value = nil
+}
+
+func (t *traceScoped) next() any {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.value == nil {
+		t.value = t.gen()
+	}
+	return t.value
+}
+
+// seqTimestamps generates strictly increasing timestamps, in ms since the epoch,
+// within a trace. The first one is the time the trace started, and each one
+// after that is a random minDelta to maxDelta ms after the one before, so a
+// pipeline that reorders spans can be caught by comparing them.
+type seqTimestamps struct {
+	mut      sync.Mutex
+	rng      Rng
+	minDelta int64
+	maxDelta int64
+	started  bool
+	last     int64
+}
+
+// getSeqTimestampsGen parses the minimum and maximum delta in ms, which default to 1 and 10.
+func getSeqTimestampsGen(rng Rng, p1, p2 string) (*seqTimestamps, error) {
+	var minDelta, maxDelta int64 = 1, 10
+	var err error
+	if p1 != "" {
+		minDelta, err = strconv.ParseInt(p1, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not an int", p1)
+		}
+		maxDelta = max(maxDelta, minDelta)
+	}
+	if p2 != "" {
+		maxDelta, err = strconv.ParseInt(p2, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not an int", p2)
+		}
+	}
+	if minDelta < 1 || maxDelta < minDelta {
+		return nil, fmt.Errorf("deltas %d,%d must be at least 1 and in increasing order", minDelta, maxDelta)
+	}
+	return &seqTimestamps{rng: rng, minDelta: minDelta, maxDelta: maxDelta}, nil
+}
+
+// startTrace makes the next value the start of a new trace.
+func (s *seqTimestamps) startTrace() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.started = false
+}
+
+func (s *seqTimestamps) next() any {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if !s.started {
+		s.started = true
+		s.last = time.Now().UnixMilli()
+		return s.last
+	}
+	s.last += s.rng.Int(int(s.minDelta), int(s.maxDelta)+1)
+	return s.last
+}
+
+// httpFieldSpecs are the specs of the OTel HTTP attributes that --httpfields
+// adds, with the given percentages of 4xx and 5xx statuses (like "4,1"). The
+// method and path depend on the route, so they always go together.
+func httpFieldSpecs(statuses string) map[string]string {
+	return map[string]string{
+		"http.route":                "/httproute",
+		"http.request.method":       "/httpmethod http.route",
+		"url.path":                  "/httptarget http.route",
+		"http.response.status_code": "/st" + statuses,
+		"user_agent.original":       "/useragent",
+	}
+}
+
+// getHTTPRouteGen generates the given number (default 10) of REST-style route
+// templates, for collections (/api/widget), items (/api/widget/{id}), and
+// nested collections (/api/widget/{id}/gear), and chooses among them with a
+// quadratic bias, so a few routes get most of the traffic.
+func getHTTPRouteGen(rng Rng, args string) (func() any, error) {
+	n := 10
+	if args != "" {
+		var err error
+		if n, err = strconv.Atoi(args); err != nil || n < 1 {
+			return nil, fmt.Errorf("%s is not a valid number of routes", args)
+		}
+	}
+	routes := make([]string, n)
+	for i := range routes {
+		collection := "/api/" + rng.Choice(nouns)
+		switch i % 3 {
+		case 0:
+			routes[i] = collection
+		case 1:
+			routes[i] = collection + "/{id}"
+		default:
+			routes[i] = collection + "/{id}/" + rng.Choice(nouns)
+		}
+	}
+	return func() any { return rng.QuadraticChoice(routes) }, nil
+}
+
+// getHTTPMethodGen parses the name of a route field and returns a generator of
+// methods that suit the route: items are mostly read, but also updated and
+// deleted, and collections are read and added to.
+func getHTTPMethodGen(rng Rng, args string) (string, func(source any) any, error) {
+	if args == "" {
+		return "", nil, fmt.Errorf("the name of a route field is required")
+	}
+	return args, func(route any) any {
+		r := rng.Float(0, 1)
+		if strings.HasSuffix(fmt.Sprint(route), "{id}") {
+			switch {
+			case r < 0.7:
+				return "GET"
+			case r < 0.9:
+				return "PUT"
+			default:
+				return "DELETE"
+			}
+		}
+		if r < 0.8 {
+			return "GET"
+		}
+		return "POST"
+	}, nil
+}
+
+// getHTTPTargetGen parses the name of a route field and returns a generator of
+// paths that match the route, with each {id} replaced by a number.
+func getHTTPTargetGen(rng Rng, args string) (string, func(source any) any, error) {
+	if args == "" {
+		return "", nil, fmt.Errorf("the name of a route field is required")
+	}
+	return args, func(route any) any {
+		parts := strings.Split(fmt.Sprint(route), "{id}")
+		var b strings.Builder
+		for i, part := range parts {
+			if i > 0 {
+				b.WriteString(strconv.FormatInt(rng.Int(1, 100000), 10))
+			}
+			b.WriteString(part)
+		}
+		return b.String()
+	}, nil
+}
+
+// getLatencyGen parses "sourcefield [mean[,stddev[,factor]]]" and returns a generator
+// of gaussian durations in ms which are multiplied by factor when the source
+// field is a 5xx status code, the way failing requests often time out.
+func getLatencyGen(rng Rng, args string) (string, func(source any) any, error) {
+	source, params, _ := strings.Cut(args, " ")
+	if source == "" {
+		return "", nil, fmt.Errorf("the name of a status field is required")
+	}
+	vals := []float64{0, 0, 5}
+	if params = strings.TrimSpace(params); params != "" {
+		parts := strings.Split(params, ",")
+		if len(parts) > len(vals) {
+			return "", nil, fmt.Errorf("expected at most mean,stddev,factor but got %s", params)
+		}
+		for i, p := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil {
+				return "", nil, fmt.Errorf("%s is not a number", p)
+			}
+			vals[i] = v
+		}
+	}
+	mean, stddev := gaussianDefaults(vals[0], vals[1])
+	factor := vals[2]
+	return source, func(status any) any {
+		d := rng.Gaussian(mean, stddev)
+		if code := fmt.Sprint(status); len(code) == 3 && code[0] == '5' {
+			d *= factor
+		}
+		return int64(math.Max(d, 0))
+	}, nil
+}
+
+// getGeoGens returns generators for latitude and longitude within a bounding box
+// given as min lat, min lon, max lat, max lon; the default is the whole globe.
+func getGeoGens(rng Rng, p1, p2, p3, p4 string) (func() any, func() any, error) {
+	bounds := []float64{-90, -180, 90, 180}
+	given := 0
+	for i, p := range []string{p1, p2, p3, p4} {
+		if p == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s is not a number", p)
+		}
+		bounds[i] = v
+		given++
+	}
+	if given != 0 && given != 4 {
+		return nil, nil, fmt.Errorf("a bounding box needs all four of min lat, min lon, max lat, max lon")
+	}
+	minLat, minLon, maxLat, maxLon := bounds[0], bounds[1], bounds[2], bounds[3]
+	if minLat < -90 || maxLat > 90 || minLat > maxLat {
+		return nil, nil, fmt.Errorf("latitudes must be in order between -90 and 90")
+	}
+	if minLon < -180 || maxLon > 180 || minLon > maxLon {
+		return nil, nil, fmt.Errorf("longitudes must be in order between -180 and 180")
+	}
+	lat := func() any { return rng.Float(minLat, maxLat) }
+	lon := func() any { return rng.Float(minLon, maxLon) }
+	return lat, lon, nil
+}
+
+// getArrGen parses "type,length" (like s,5) and returns a generator of slices
+// of that length, for array-valued attributes. The type is s (words), i (ints
+// from 0 to 100), f (floats from 0 to 100), or b (bools); the length defaults to 3.
+func getArrGen(rng Rng, args string) (func() any, error) {
+	kind, lenstr, _ := strings.Cut(args, ",")
+	length := 3
+	if lenstr != "" {
+		var err error
+		length, err = strconv.Atoi(strings.TrimSpace(lenstr))
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("%s is not a valid length", lenstr)
+		}
+	}
+	switch strings.TrimSpace(kind) {
+	case "s", "":
+		return func() any {
+			a := make([]string, length)
+			for i := range a {
+				a[i] = rng.Choice(nouns)
+			}
+			return a
+		}, nil
+	case "i":
+		return func() any {
+			a := make([]int64, length)
+			for i := range a {
+				a[i] = rng.Int(0, 100)
+			}
+			return a
+		}, nil
+	case "f":
+		return func() any {
+			a := make([]float64, length)
+			for i := range a {
+				a[i] = rng.Float(0, 100)
+			}
+			return a
+		}, nil
+	case "b":
+		return func() any {
+			a := make([]bool, length)
+			for i := range a {
+				a[i] = rng.Bool()
+			}
+			return a
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown array type %s; expected s, i, f, or b", kind)
+	}
+}
+
+// defaultHistBoundaries are the upper bounds of /hist's buckets when none are
+// given, like those of a client-side histogram of latencies in milliseconds.
+var defaultHistBoundaries = []float64{5, 10, 25, 50, 100, 250, 500, 1000}
+
+// getHistGen parses "total,boundary,boundary..." (like 100 or 100,10,50,100)
+// and returns a generator of bucket counts that sum to the total, as an int
+// array attribute, to simulate a pre-aggregated histogram. The boundaries are
+// the buckets' ascending upper bounds, and there's a count for each of them;
+// the total defaults to 100, and the boundaries to defaultHistBoundaries. The
+// counts cluster around a different bucket each time.
+func getHistGen(rng Rng, args string) (func() any, error) {
+	total, boundaries, err := parseHistArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	n := len(boundaries)
+	spread := max(1, float64(n)/4)
+	return func() any {
+		peak := float64(rng.Intn(n))
+		weights := make([]float64, n)
+		sum := 0.0
+		for i := range weights {
+			d := (float64(i) - peak) / spread
+			weights[i] = math.Exp(-d*d/2) * rng.Float(0.5, 1.5)
+			sum += weights[i]
+		}
+		counts := make([]int64, n)
+		left := int64(total)
+		for i, w := range weights {
+			counts[i] = int64(float64(total) * w / sum)
+			left -= counts[i]
+		}
+		// what the rounding lost goes to random buckets
+		for ; left > 0; left-- {
+			counts[rng.Intn(n)]++
+		}
+		return counts
+	}, nil
+}
+
+// parseHistArgs parses the arguments of /hist into the total and the bucket
+// boundaries, with their defaults.
+func parseHistArgs(args string) (int, []float64, error) {
+	total := 100
+	boundaries := defaultHistBoundaries
+	if args == "" {
+		return total, boundaries, nil
+	}
+	parts := strings.Split(args, ",")
+	total, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || total < 0 {
+		return 0, nil, fmt.Errorf("%s is not a valid total", parts[0])
+	}
+	if len(parts) > 1 {
+		boundaries = make([]float64, 0, len(parts)-1)
+		for _, p := range parts[1:] {
+			b, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil {
+				return 0, nil, fmt.Errorf("%s is not a valid boundary", p)
+			}
+			if len(boundaries) > 0 && b <= boundaries[len(boundaries)-1] {
+				return 0, nil, fmt.Errorf("boundaries must be in ascending order")
+			}
+			boundaries = append(boundaries, b)
+		}
+	}
+	return total, boundaries, nil
+}
+
+// parseHistFields returns the bucket boundaries of each /hist field, by the
+// name it has in a span, so that the metrics signal can record it as a
+// histogram with the same buckets.
+func parseHistFields(userfields map[string]string) map[string][]float64 {
+	hists := make(map[string][]float64)
+	for name, value := range userfields {
+		matches := wordfield.FindStringSubmatch(value)
+		if matches == nil || matches[1] != "hist" {
+			continue
+		}
+		if _, boundaries, err := parseHistArgs(matches[2]); err == nil {
+			if m := keysplitter.FindStringSubmatch(name); m != nil {
+				name = m[2]
+			}
+			hists[name] = boundaries
+		}
+	}
+	return hists
+}
+
+// HistBoundaries returns the bucket boundaries of the /hist field with the
+// given name, if it is one.
+func (f *Fielder) HistBoundaries(name string) ([]float64, bool) {
+	boundaries, ok := f.histograms[name]
+	return boundaries, ok
+}
+
+// getEnumGen parses a list of value:weight pairs separated by commas and returns a generator
+// that chooses among the values in proportion to their weights. A missing weight counts as 1.
+func getEnumGen(rng Rng, args string) (func() any, error) {
+	if args == "" {
+		return nil, fmt.Errorf("enum requires at least one value")
+	}
+	var values []string
+	var cumulative []float64
+	total := 0.0
+	for _, pair := range strings.Split(args, ",") {
+		value, weightText := strings.TrimSpace(pair), "1"
+		if ix := strings.LastIndex(pair, ":"); ix >= 0 {
+			value, weightText = strings.TrimSpace(pair[:ix]), strings.TrimSpace(pair[ix+1:])
+		}
+		if value == "" {
+			return nil, fmt.Errorf("empty value in %q", pair)
+		}
+		weight, err := strconv.ParseFloat(weightText, 64)
+		if err != nil || weight < 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+			return nil, fmt.Errorf("%s is not a valid weight", weightText)
+		}
+		total += weight
+		values = append(values, value)
+		cumulative = append(cumulative, total)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("at least one weight must be greater than 0")
+	}
+	return func() any {
+		r := rng.Float(0, total)
+		for i, c := range cumulative {
+			if r < c {
+				return values[i]
+			}
+		}
+		return values[len(values)-1]
+	}, nil
+}
+
+// seqCounters holds the counters behind /seq fields, keyed by the field's
+// definition. Every generator goroutine builds its own Fielder, so the counters
+// live here in order to be shared by all of them for the life of the process.
+var seqCounters = struct {
+	sync.Mutex
+	m map[string]*atomic.Int64
+}{m: make(map[string]*atomic.Int64)}
+
+// getSeqGen returns a generator of the sequence start, start+step, start+2*step, ...
+// that never repeats or skips a value no matter how many goroutines use it.
+func getSeqGen(key, p1, p2 string) (func() any, error) {
+	var start, step int64 = 0, 1
+	var err error
+	if p1 != "" {
+		start, err = strconv.ParseInt(p1, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not an int", p1)
+		}
+	}
+	if p2 != "" {
+		step, err = strconv.ParseInt(p2, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not an int", p2)
+		}
+		if step == 0 {
+			return nil, fmt.Errorf("step cannot be 0")
+		}
+	}
+	seqCounters.Lock()
+	counter, ok := seqCounters.m[key]
+	if !ok {
+		counter = &atomic.Int64{}
+		counter.Store(start)
+		seqCounters.m[key] = counter
+	}
+	seqCounters.Unlock()
+	return func() any { return counter.Add(step) - step }, nil
+}
+
+func getKeyGen(rng Rng, p1, p2 string) (func() any, error) {
+	var cardinality, period int
+	var err error
+	if p1 == "" {
+		cardinality = 50
+	} else {
+		cardinality, err = strconv.Atoi(p1)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not an int", p1)
+		}
+		if cardinality > len(nouns) {
+			return nil, fmt.Errorf("cardinality %d cannot be more than %d", cardinality, len(nouns))
+		}
+	}
+	if p2 == "" || p2 == "," {
+		period = 60
+	} else {
+		period, err = strconv.Atoi(p2)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not an int", p2[:1])
+		}
+	}
+	ep := newPeriodicEligibility(rng, nouns[:cardinality], time.Duration(period)*time.Second)
+	startTime := time.Now()
+	return func() any { return ep.getEligibleWord(time.Since(startTime)) }, nil
+}
+
+type Fielder struct {
+	fields              map[string]func() any
+	dependents          []dependentField
+	errorFields         []string // the /iserror fields, which every span has
+	traceFields         []traceField
+	names               []string
+	keys                []string  // the intrinsic keys come first, then the optional ones
+	presence            []float64 // the probability of each optional key, in the same order
+	attributesPerSpan   int
+	intrinsicAttributes int
+	rng                 Rng
+	spanNames           *spanNamer
+	spanName            string // if set, every span gets this name, as when replaying
+	processID           int64  // if set, the process_id of every span instead of the real one
+	drift               schemaDrift
+	maxAttrLen          int                 // if set, the maximum length of a string value (--maxattrlen)
+	ellipsis            bool                // whether a truncated string ends with "..."
+	runLabel            string              // if set, the loadgen.run of every span (--runlabel)
+	cardinality         *cardinalityCounter // if set, counts the distinct values (--cardinalityreport)
+	rootFields          map[string]any      // if set, added to root spans as they are, even past maxAttrLen
+	attrDist            *attrCountDist      // if set, the number of each span's attributes is drawn from it (--attrdist)
+	fuzzRate            float64             // the fraction of values and keys that are edge cases (--fuzz)
+	roles               roleFields          // the fields of only root or leaf spans (--rootfield and --leaffield)
+	leaf                bool                // whether the span being created is a leaf
+	serviceFields       []serviceField      // the /svc fields, which every span has
+	jitterable          map[string]bool     // the fields whose values --jitter perturbs
+	jitter              float64             // the largest fraction that --jitter perturbs a value by
+	service             string              // the service of the span being created, for the /svc fields
+
+	// the bucket boundaries of the /hist fields, for the metrics signal
+	histograms map[string][]float64
+
+	// the seed the Fielder was created with, for the setters that draw from it
+	seed string
+
+	// the words of the random (--extra) fields' names, how they're written, and
+	// the value generators, so that SetKeyStyle can rename the fields
+	extraWords [][2]string
+	formatKey  func(adj, noun string) string
+	gens       []func() any
+}
+
+// parsedFields are the user fields (--fields) parsed into the parts a Fielder
+// is made of.
+type parsedFields struct {
+	userFields    map[string]string // the specs without their presence
+	presence      map[string]float64
+	fields        map[string]func() any
+	dependents    []dependentField
+	traceFields   []traceField
+	errorFields   []string
+	serviceFields []serviceField
+}
+
+// parseFields parses the user fields, drawing their generators from rng, and
+// returns the first error in any of them.
+func parseFields(rng Rng, userFields map[string]string) (*parsedFields, error) {
+	p := &parsedFields{}
+	var err error
+	if p.userFields, p.presence, err = parsePresence(userFields); err != nil {
+		return nil, err
+	}
+	if p.fields, err = parseUserFields(rng, p.userFields); err != nil {
+		return nil, err
+	}
+	if p.dependents, err = parseDependentFields(rng, p.userFields); err != nil {
+		return nil, err
+	}
+	if p.traceFields, err = parseTraceFields(rng, p.userFields); err != nil {
+		return nil, err
+	}
+	if p.errorFields, err = parseErrorFields(p.userFields); err != nil {
+		return nil, err
+	}
+	if p.serviceFields, err = parseServiceFields(p.userFields); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Fielder is an object that takes a name and generates a map of
+// fields based on using the name as a random seed.
+// It takes a set of field specifications that are used to generate the fields.
+// It also takes two counts: the number of fields to generate and the number of
+// service names to generate. The field names are randomly generated by
+// combining an adjective and a noun and are consistent for a given fielder.
+// The field values are randomly generated.
+// Fielder also includes the process_id, which is the real one unless it's set
+// with SetProcessID.
+// Each span gets intrinsicAttributes of the fields, and attributesPerSpan on
+// average, so there can't be more intrinsic ones; if there are fewer fields
+// than either, every span gets all of them.
+func NewFielder(seed string, userFields map[string]string, nextras, nservices int, attributesPerSpan int, intrinsicAttributes int) (*Fielder, error) {
+	if attributesPerSpan < 0 || intrinsicAttributes < 0 {
+		return nil, fmt.Errorf("apspan %d and iattributes %d must not be negative", attributesPerSpan, intrinsicAttributes)
+	}
+	if intrinsicAttributes > attributesPerSpan {
+		return nil, fmt.Errorf("iattributes %d must not be more than apspan %d", intrinsicAttributes, attributesPerSpan)
+	}
+	f := &Fielder{}
+	rng := NewRng(seed)
+	formatKey := keyStyles["wordpair"]
+	gens := rng.getValueGenerators()
+	parsed, err := parseFields(rng, userFields)
+	if err != nil {
+		return nil, err
+	}
+	userFields, presence, fields := parsed.userFields, parsed.presence, parsed.fields
+	var keys []string
+	jitterable := parseJitterFields(userFields)
+	extras := make([]string, 0, nextras)
+	extraWords := make([][2]string, 0, nextras)
+	for i := 0; i < nextras; i++ {
+		// the words are chosen the same way in every style, so only the spelling differs
+		words := [2]string{rng.Choice(adjectives), rng.Choice(nouns)}
+		fieldname := formatKey(words[0], words[1])
+		fields[fieldname] = gens[rng.Intn(len(gens))]
+		extras = append(extras, fieldname)
+		extraWords = append(extraWords, words)
+		jitterable[fieldname] = true
+	}
+	fields["process_id"] = f.getProcessID
+	// fields with a configured presence are always optional, so they go last
+	var configured []string
+	for k, _ := range fields {
+		if _, ok := presenceOf(presence, k); ok {
+			configured = append(configured, k)
+		} else {
+			keys = append(keys, k)
+		}
+	}
+	unconfigured := len(keys)
+	sort.Strings(configured)
+	keys = append(keys, configured...)
+	names := make([]string, nservices)
+	for i := 0; i < nservices; i++ {
+		names[i] = rng.Choice(spices)
+	}
+
+	var validAttributesPerSpan = int(math.Min(float64(attributesPerSpan), float64(len(fields))))
+	var validIntrinsicAttributes = int(math.Min(float64(intrinsicAttributes), float64(validAttributesPerSpan)))
+	validIntrinsicAttributes = min(validIntrinsicAttributes, unconfigured)
+
+	// the optional fields without a configured presence share the rest of the
+	// attributes per span, so that's how many of them appear on average
+	var defaultPresence float64
+	if n := unconfigured - validIntrinsicAttributes; n > 0 {
+		defaultPresence = math.Min(float64(validAttributesPerSpan-validIntrinsicAttributes)/float64(n), 1)
+	}
+	optional := make([]float64, 0, len(keys)-validIntrinsicAttributes)
+	for _, k := range keys[validIntrinsicAttributes:] {
+		p, ok := presenceOf(presence, k)
+		if !ok {
+			p = defaultPresence
+		}
+		optional = append(optional, p)
+	}
+	*f = Fielder{fields: fields, dependents: parsed.dependents, errorFields: parsed.errorFields, serviceFields: parsed.serviceFields, jitterable: jitterable, histograms: parseHistFields(userFields), traceFields: parsed.traceFields, names: names, keys: keys, presence: optional, attributesPerSpan: validAttributesPerSpan, intrinsicAttributes: validIntrinsicAttributes, rng: rng, seed: seed, extraWords: extraWords, formatKey: formatKey, gens: gens}
+	f.drift = newSchemaDrift(seed, extras, gens, formatKey)
+	return f, nil
+}
+
+// SetKeyStyle writes the names of the random (--extra) fields in the given
+// style (--keystyle) instead of as word pairs, which is what "" does. The
+// fields that drift adds are renamed too.
+func (f *Fielder) SetKeyStyle(style string) error {
+	if style == "" {
+		style = "wordpair"
+	}
+	formatKey, ok := keyStyles[style]
+	if !ok {
+		return fmt.Errorf("unknown key style %s", style)
+	}
+	renamed := make(map[string]string, len(f.extraWords))
+	extras := make([]string, 0, len(f.extraWords))
+	for _, words := range f.extraWords {
+		name := formatKey(words[0], words[1])
+		renamed[f.formatKey(words[0], words[1])] = name
+		extras = append(extras, name)
+	}
+	gens := make(map[string]func() any, len(renamed))
+	for old := range renamed {
+		gens[old] = f.fields[old]
+		delete(f.fields, old)
+		delete(f.jitterable, old)
+	}
+	for old, name := range renamed {
+		f.fields[name] = gens[old]
+		f.jitterable[name] = true
+	}
+	for i, k := range f.keys {
+		if name, ok := renamed[k]; ok {
+			f.keys[i] = name
+		}
+	}
+	start, every := f.drift.start, f.drift.every
+	f.drift = newSchemaDrift(f.seed, extras, f.gens, formatKey)
+	f.SetDrift(start, every)
+	f.formatKey = formatKey
+	return nil
+}
+
+// SetSpanNameTemplate names spans in the given style (--spannametemplate)
+// instead of for their service, which is what "" and "service" do.
+func (f *Fielder) SetSpanNameTemplate(style string) error {
+	spanNames, err := newSpanNamer(f.seed, style)
+	if err != nil {
+		return err
+	}
+	f.spanNames = spanNames
+	return nil
+}
+
+// SetProcessID makes pid the process_id of every span instead of the real one.
+func (f *Fielder) SetProcessID(pid int64) {
+	f.processID = pid
+}
+
+// SetMaxAttrLen truncates every string value, including each string of an
+// array, to at most n bytes; if ellipsis is set, a truncated one ends with
+// "..." within those n bytes. Zero means no limit.
+func (f *Fielder) SetMaxAttrLen(n int, ellipsis bool) {
+	f.maxAttrLen = n
+	f.ellipsis = ellipsis
+}
+
+// runLabelField is the field that --runlabel sets on every span.
+const runLabelField = "loadgen.run"
+
+// SetRunLabel gives every span a loadgen.run field with the label, so that a
+// run's traces can be told apart from others in a shared backend.
+func (f *Fielder) SetRunLabel(label string) {
+	f.runLabel = label
+}
+
+// SetCardinalityCounter has every span's values counted by c; a nil c counts
+// nothing.
+func (f *Fielder) SetCardinalityCounter(c *cardinalityCounter) {
+	f.cardinality = c
+}
+
+// setRootFields adds fields to every root span until it's called again; nil
+// removes them. The generator sets them for a single pathological trace.
+func (f *Fielder) setRootFields(fields map[string]any) {
+	f.rootFields = fields
+}
+
+// setService makes service the service of the spans the fielder makes fields
+// for until it's called again, for the /svc fields.
+func (f *Fielder) setService(service string) {
+	f.service = service
+}
+
+func (f *Fielder) getProcessID() any {
+	if f.processID != 0 {
+		return f.processID
+	}
+	return getProcessID()
+}
+
+// presenceOf returns the configured presence of the field with the given key.
+// The fields made by a geo generator with a name ending in .latlon share its presence.
+func presenceOf(presence map[string]float64, key string) (float64, bool) {
+	if p, ok := presence[key]; ok {
+		return p, true
+	}
+	for _, suffix := range []string{".lat", ".lon"} {
+		if prefix, ok := strings.CutSuffix(key, suffix); ok {
+			if p, ok := presence[prefix+".latlon"]; ok {
+				return p, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (f *Fielder) GetServiceName(n int) string {
+	return f.names[n%len(f.names)]
+}
+
+// GetSpanName returns a name for a span from the given service, in the style
+// chosen by --spannametemplate; by default, spans are named for their service.
+func (f *Fielder) GetSpanName(service string) string {
+	if f.spanName != "" {
+		return f.spanName
+	}
+	if f.spanNames == nil {
+		return service
+	}
+	return f.spanNames.name(service)
+}
+
+// spanNamesPerService is how many different operations each service has.
+const spanNamesPerService = 5
+
+// spanNameStyles generate operation names that look like those of real apps.
+var spanNameStyles = map[string]func(rng Rng) string{
+	// GET /api/widget or GET /api/widget/{id}
+	"http": func(rng Rng) string {
+		route := "/api/" + rng.Choice(nouns)
+		if rng.Bool() {
+			route += "/{id}"
+		}
+		return rng.Choice([]string{"GET", "GET", "POST", "PUT", "DELETE"}) + " " + route
+	},
+	// WidgetService/GetWidget, as gRPC names them
+	"rpc": func(rng Rng) string {
+		noun := titleCase(rng.Choice(nouns))
+		return noun + "Service/" + rng.Choice([]string{"Get", "List", "Create", "Update", "Delete"}) + noun
+	},
+	// SELECT widget, as OTel names database spans
+	"db": func(rng Rng) string {
+		return rng.Choice([]string{"SELECT", "SELECT", "INSERT", "UPDATE", "DELETE"}) + " " + rng.Choice(nouns)
+	},
+	// happy_widget
+	"word": func(rng Rng) string {
+		return rng.Choice(adjectives) + "_" + rng.Choice(nouns)
+	},
+}
+
+func titleCase(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// spanNamer gives each service a fixed set of operations and names each span
+// with one of them. The operations depend only on the seed and the service, so
+// every Fielder (and every run with the same seed) agrees on them. Senders
+// may share a Fielder between goroutines, so it's guarded by a mutex.
+type spanNamer struct {
+	mut   sync.Mutex
+	seed  string
+	style string
+	gen   func(rng Rng) string
+	rng   Rng
+	ops   map[string][]string
+}
+
+// newSpanNamer returns nil for the "service" style (or no style), which names spans for their service.
+func newSpanNamer(seed, style string) (*spanNamer, error) {
+	if style == "" || style == "service" {
+		return nil, nil
+	}
+	gen, ok := spanNameStyles[style]
+	if !ok {
+		return nil, fmt.Errorf("unknown span name style %s", style)
+	}
+	return &spanNamer{seed: seed, style: style, gen: gen, rng: NewRng(seed + "spannames"), ops: make(map[string][]string)}, nil
+}
+
+func (n *spanNamer) name(service string) string {
+	n.mut.Lock()
+	defer n.mut.Unlock()
+	ops, ok := n.ops[service]
+	if !ok {
+		rng := NewRng(n.seed + n.style + service)
+		ops = make([]string, spanNamesPerService)
+		for i := range ops {
+			ops[i] = n.gen(rng)
+		}
+		n.ops[service] = ops
+	}
+	return n.rng.Choice(ops)
+}
+
+// Searches for a field name that includes a level marker.
+// These markers look like "1.fieldname" and are used to
+// indicate that the field should be included at a specific
+// level in the trace, where 0 is the root.
+func (f *Fielder) atLevel(name string, level int) (string, bool) {
+	matches := keysplitter.FindStringSubmatch(name)
+	if len(matches) == 0 {
+		return name, true
+	}
+	keylevel, _ := strconv.Atoi(matches[1])
+	if keylevel == level {
+		return matches[2], true
+	}
+	return matches[2], false
+}
+
+// GetFields returns the fields of a span at the given level. It's for senders
+// that don't give spans an error status, so the /iserror fields are false.
+func (f *Fielder) GetFields(count int64, level int) map[string]any {
+	f.applyDrift()
+	fields := make(map[string]any)
+	if count != 0 {
+		fields["count"] = count
+	}
+	for key, v := range f.fields {
+		k, ok := f.atLevel(key, level)
+		if !ok {
+			continue
+		}
+		fields[k] = f.jittered(key, v())
+	}
+	f.addRoleFields(fields, level)
+	f.addTraceFields(fields, level)
+	f.addErrorFields(fields, level, false)
+	f.addServiceFields(fields, level)
+	f.addRunLabel(fields)
+	f.addDependents(fields, level)
+	f.truncate(fields)
+	f.addRootFields(fields, level)
+	if f.cardinality != nil {
+		f.cardinality.record(fields)
+	}
+	return fields
+}
+
+// addTraceFields adds the per-trace fields to values, which holds the fields
+// for a span at the given level; a root span (level 0) starts a new trace.
+// Spans are created in order as the generator descends, so their values
+// increase in that order.
+func (f *Fielder) addTraceFields(values map[string]any, level int) {
+	for _, tf := range f.traceFields {
+		if level == 0 {
+			tf.gen.startTrace()
+		}
+		if name, ok := f.atLevel(tf.name, level); ok {
+			values[name] = tf.gen.next()
+		}
+	}
+}
+
+// addErrorFields adds the /iserror fields to values, with whether the span has
+// an error status.
+func (f *Fielder) addErrorFields(values map[string]any, level int, isError bool) {
+	for _, name := range f.errorFields {
+		if name, ok := f.atLevel(name, level); ok {
+			values[name] = isError
+		}
+	}
+}
+
+// addServiceFields adds the /svc fields to values, with the service set by
+// setService in their patterns.
+func (f *Fielder) addServiceFields(values map[string]any, level int) {
+	for _, sf := range f.serviceFields {
+		if name, ok := f.atLevel(sf.name, level); ok {
+			values[name] = strings.ReplaceAll(sf.pattern, servicePlaceholder, f.service)
+		}
+	}
+}
+
+// addDependents adds the dependent fields to values, which holds the fields
+// already generated for a span at the given level. Dependent fields are generated
+// after all the others, in order by name, so one can depend on any ordinary field
+// or on a dependent field whose name sorts before its own. If a source field
+// isn't in values (because it wasn't chosen for this span), it's generated and
+// added as well so that the correlation is visible.
+func (f *Fielder) addDependents(values map[string]any, level int) {
+	for _, dep := range f.dependents {
+		name, ok := f.atLevel(dep.name, level)
+		if !ok {
+			continue
+		}
+		source, ok := values[dep.source]
+		if !ok {
+			if gen, found := f.fieldAt(dep.source, level); found {
+				source = gen()
+				values[dep.source] = source
+			}
+		}
+		values[name] = dep.gen(source)
+	}
+}
+
+// fieldAt returns the generator for the named field if it's included at the level.
+func (f *Fielder) fieldAt(name string, level int) (func() any, bool) {
+	if gen, ok := f.fields[strconv.Itoa(level)+"."+name]; ok {
+		return gen, true
+	}
+	gen, ok := f.fields[name]
+	return gen, ok
+}
+
+// addRunLabel adds the run label to values, if there is one.
+func (f *Fielder) addRunLabel(values map[string]any) {
+	if f.runLabel != "" {
+		values[runLabelField] = f.runLabel
+	}
+}
+
+// addRootFields adds the fields set by setRootFields to a root span (level 0).
+func (f *Fielder) addRootFields(values map[string]any, level int) {
+	if level != 0 {
+		return
+	}
+	for k, v := range f.rootFields {
+		values[k] = v
+	}
+}
+
+// truncate shortens the string values that are longer than maxAttrLen.
+func (f *Fielder) truncate(values map[string]any) {
+	if f.maxAttrLen <= 0 {
+		return
+	}
+	for name, value := range values {
+		switch v := value.(type) {
+		case string:
+			values[name] = truncateString(v, f.maxAttrLen, f.ellipsis)
+		case []string:
+			truncated := make([]string, len(v))
+			for i, s := range v {
+				truncated[i] = truncateString(s, f.maxAttrLen, f.ellipsis)
+			}
+			values[name] = truncated
+		}
+	}
+}
+
+// truncateString cuts s to at most n bytes, without splitting a character.
+func truncateString(s string, n int, ellipsis bool) string {
+	if len(s) <= n {
+		return s
+	}
+	suffix := ""
+	if ellipsis && n >= len("...") {
+		suffix = "..."
+	}
+	cut := n - len(suffix)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + suffix
+}
+
+// attributeFor converts a generated value to an attribute.
+func attributeFor(name string, value any) attribute.KeyValue {
+	switch v := value.(type) {
+	case int64:
+		return attribute.Int64(name, v)
+	case uint64:
+		return attribute.Int64(name, int64(v))
+	case float64:
+		return attribute.Float64(name, v)
+	case string:
+		return attribute.String(name, v)
+	case bool:
+		return attribute.Bool(name, v)
+	case []string:
+		return attribute.StringSlice(name, v)
+	case []int64:
+		return attribute.Int64Slice(name, v)
+	case []float64:
+		return attribute.Float64Slice(name, v)
+	case []bool:
+		return attribute.BoolSlice(name, v)
+	default:
+		panic(fmt.Sprintf("unknown type %T for %s -- implementation error in fielder.go", v, name))
+	}
+}
+
+// AddFields sets the fields of a span at the given level as its attributes;
+// isError is whether the sender gave it an error status, for the /iserror fields.
+func (f *Fielder) AddFields(span trace.Span, count int64, level int, isError bool) {
+	f.applyDrift()
+	attrs := make([]attribute.KeyValue, 0, 1+len(f.fields))
+
+	if count != 0 {
+		attrs = append(attrs, attribute.Int64("count", count))
+	}
+
+	values := make(map[string]any, f.attributesPerSpan)
+
+	// the intrinsic attributes are always present, and each optional one
+	// appears independently according to its presence, unless --attrdist
+	// chooses how many there are
+	keys := f.keys
+	if f.attrDist != nil {
+		keys = f.chooseKeys()
+	}
+	for i, key := range keys {
+		if f.attrDist == nil && i >= f.intrinsicAttributes && f.rng.Float(0, 1) >= f.presence[i-f.intrinsicAttributes] {
+			continue
+		}
+		name, ok := f.atLevel(key, level)
+		if !ok {
+			continue
+		}
+		values[name] = f.jittered(key, f.fields[key]())
+	}
+	f.addRoleFields(values, level)
+	f.addTraceFields(values, level)
+	f.addErrorFields(values, level, isError)
+	f.addServiceFields(values, level)
+	f.addRunLabel(values)
+	f.addDependents(values, level)
+	f.truncate(values)
+	f.fuzz(values)
+	f.addRootFields(values, level)
+	if f.cardinality != nil {
+		f.cardinality.record(values)
+	}
+	for name, value := range values {
+		attrs = append(attrs, attributeFor(name, value))
+	}
+	span.SetAttributes(attrs...)
+}
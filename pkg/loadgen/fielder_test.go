@@ -0,0 +1,1193 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_PeriodicEligibility_checkEligible(t *testing.T) {
+	words := strings.Split("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz", "")
+	notFound := map[string]struct{}{}
+	for _, w := range words {
+		notFound[w] = struct{}{}
+	}
+
+	pe := newPeriodicEligibility(NewRng("hello"), words, 60*time.Second)
+	t.Run("only some words show up for short period", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			for p := 0; p < 30; p++ {
+				word := pe.getEligibleWord(time.Duration(p) * time.Second)
+				delete(notFound, word)
+			}
+			if len(notFound) == 0 {
+				break
+			}
+		}
+		if len(notFound) != 0 {
+			t.Errorf("expected some words to be not found, got none")
+		}
+	})
+
+	t.Run("all eligible words show up with full period", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			for p := 0; p < 60; p++ {
+				word := pe.getEligibleWord(time.Duration(p) * time.Second)
+				delete(notFound, word)
+			}
+			if len(notFound) == 0 {
+				break
+			}
+		}
+		if len(notFound) > 0 {
+			t.Errorf("expected all words to be found, got %v", notFound)
+		}
+	})
+}
+
+func BenchmarkPeriodicEligibility(b *testing.B) {
+	for _, card := range []int{10, 50, 200} {
+		var words []string
+		for i := 0; i < card; i++ {
+			words = append(words, strconv.Itoa(i))
+		}
+		period := 61 * time.Second
+		pe := newPeriodicEligibility(NewRng("hello"), words, period)
+		for p := 0; p < 61; p += 10 {
+			b.Run(fmt.Sprintf("card_%02d_p_%02d", card, p), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					pe.getEligibleWord(time.Duration(p) * time.Second)
+				}
+			})
+		}
+	}
+}
+
+func Test_getEnumGen(t *testing.T) {
+	t.Run("distribution follows weights", func(t *testing.T) {
+		fields, err := parseUserFields(NewRng("enum"), map[string]string{"region": "/enum us-east:70,us-west:20,eu:10,never:0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := map[any]int{}
+		const n = 10000
+		for i := 0; i < n; i++ {
+			counts[fields["region"]()]++
+		}
+		for value, want := range map[string]float64{"us-east": 0.7, "us-west": 0.2, "eu": 0.1, "never": 0} {
+			got := float64(counts[value]) / n
+			if math.Abs(got-want) > 0.02 {
+				t.Errorf("%s: expected frequency %.2f, got %.3f", value, want, got)
+			}
+		}
+	})
+
+	t.Run("malformed input", func(t *testing.T) {
+		for _, spec := range []string{"/enum", "/enum a:-1", "/enum a:x", "/enum a:0,b:0", "/enum :5", "/enum a:1,,b:2", "/enum a:NaN", "/enum a:1,b:NaN"} {
+			if _, err := parseUserFields(NewRng("enum"), map[string]string{"f": spec}); err == nil {
+				t.Errorf("expected an error for %q", spec)
+			}
+		}
+	})
+}
+
+func Test_getHistGen(t *testing.T) {
+	for spec, want := range map[string]struct {
+		total   int64
+		buckets int
+	}{
+		"/hist":              {100, len(defaultHistBoundaries)},
+		"/hist 1000":         {1000, len(defaultHistBoundaries)},
+		"/hist 7,10,50,100":  {7, 3},
+		"/hist 0,1":          {0, 1},
+		"/hist 12345,0.5,10": {12345, 2},
+	} {
+		fields, err := parseUserFields(NewRng("hist"), map[string]string{"h": spec})
+		if err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		for i := 0; i < 100; i++ {
+			counts, ok := fields["h"]().([]int64)
+			if !ok || len(counts) != want.buckets {
+				t.Fatalf("%s: expected %d counts, got %v", spec, want.buckets, counts)
+			}
+			var sum int64
+			for _, c := range counts {
+				if c < 0 {
+					t.Errorf("%s: expected no negative counts, got %v", spec, counts)
+				}
+				sum += c
+			}
+			if sum != want.total {
+				t.Errorf("%s: expected the counts to sum to %d, got %v", spec, want.total, counts)
+			}
+		}
+	}
+
+	for _, spec := range []string{"/hist x", "/hist -1", "/hist 10,", "/hist 10,50,10", "/hist 10,5,5"} {
+		if _, err := parseUserFields(NewRng("hist"), map[string]string{"h": spec}); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
+
+func TestFielder_zipfWords(t *testing.T) {
+	// the frequency of the most common value, from 10000 samples
+	top := func(spec string) float64 {
+		fields, err := parseUserFields(NewRng("zipf"), map[string]string{"w": spec})
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := map[any]int{}
+		const n = 10000
+		for i := 0; i < n; i++ {
+			counts[fields["w"]()]++
+		}
+		if len(counts) > 50 {
+			t.Errorf("%s: expected at most 50 values, got %d", spec, len(counts))
+		}
+		most := 0
+		for _, c := range counts {
+			most = max(most, c)
+		}
+		return float64(most) / n
+	}
+	last := 0.0
+	for _, spec := range []string{"/sz50", "/sz50,2", "/sz50,4"} {
+		f := top(spec)
+		if f <= last {
+			t.Errorf("%s: expected the top value to be more common than %.3f, got %.3f", spec, last, f)
+		}
+		last = f
+	}
+
+	for _, spec := range []string{"/sz50,1", "/sz50,0.5", "/sz50,x"} {
+		if _, err := parseUserFields(NewRng("zipf"), map[string]string{"w": spec}); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
+
+func Test_getDurGen(t *testing.T) {
+	fields, err := parseUserFields(NewRng("dur"), map[string]string{"elapsed": "/dur10,5000", "fixed": "/dur250,250"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var long bool
+	for i := 0; i < 1000; i++ {
+		s := fields["elapsed"]().(string)
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			t.Fatalf("%q isn't a duration: %v", s, err)
+		}
+		if d < 10*time.Millisecond || d >= 5*time.Second {
+			t.Errorf("expected a duration from 10ms to 5s, got %s", s)
+		}
+		long = long || d >= time.Second
+	}
+	if !long {
+		t.Errorf("expected some durations in seconds")
+	}
+	if got := fields["fixed"](); got != "250ms" {
+		t.Errorf("expected 250ms, got %v", got)
+	}
+	for _, spec := range []string{"/dur-10", "/dur10,5", "/dur1.5"} {
+		if _, err := parseUserFields(NewRng("dur"), map[string]string{"elapsed": spec}); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
+
+func Test_getMoneyGen(t *testing.T) {
+	fields, err := parseUserFields(NewRng("money"), map[string]string{"amount": "/money1,9999", "price": "/moneyl1,9999", "tip": "/money0.5,0.75"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges := map[string][2]float64{"amount": {1, 9999}, "price": {1, 9999}, "tip": {0.5, 0.75}}
+	small := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		for name, r := range ranges {
+			v := fields[name]().(float64)
+			if v < r[0] || v > r[1] {
+				t.Errorf("expected %s from %v to %v, got %v", name, r[0], r[1], v)
+			}
+			if s := strconv.FormatFloat(v, 'f', -1, 64); strings.Contains(s, ".") && len(s)-strings.Index(s, ".")-1 > 2 {
+				t.Errorf("expected %s to be whole cents, got %s", name, s)
+			}
+			if v < 100 {
+				small[name]++
+			}
+		}
+	}
+	// log-uniformly, half of the amounts are under 100, and uniformly, 1%
+	if small["price"] < 400 || small["amount"] > 30 {
+		t.Errorf("expected about 500 small prices and 10 small amounts, got %d and %d", small["price"], small["amount"])
+	}
+	for _, spec := range []string{"/money-5,10", "/money10,5", "/moneyl-1", "/money0.001,0.009"} {
+		if _, err := parseUserFields(NewRng("money"), map[string]string{"amount": spec}); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
+
+func Test_getBase64Gen(t *testing.T) {
+	for _, n := range []int{1, 3, 512, maxBase64Bytes} {
+		fields, err := parseUserFields(NewRng("b64"), map[string]string{"payload": fmt.Sprintf("/b64 %d", n)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := fields["payload"]().(string)
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatalf("%d bytes: %q isn't valid base64: %v", n, s, err)
+		}
+		if len(b) != n {
+			t.Errorf("expected %d bytes, got %d", n, len(b))
+		}
+		if again := fields["payload"]().(string); n > 1 && again == s {
+			t.Errorf("expected different bytes each time for %d", n)
+		}
+	}
+	for _, spec := range []string{"/b64 0", "/b64 -1", "/b64 lots", fmt.Sprintf("/b64 %d", maxBase64Bytes+1)} {
+		if _, err := parseUserFields(NewRng("b64"), map[string]string{"payload": spec}); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+	// without a length, it's still a bool
+	fields, err := parseUserFields(NewRng("b64"), map[string]string{"flag": "/b64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["flag"]().(bool); !ok {
+		t.Errorf("expected /b64 to be a bool, got %T", fields["flag"]())
+	}
+}
+
+func Test_getUserAgentGen(t *testing.T) {
+	known := map[string]bool{}
+	for _, ua := range browserAgents {
+		known[ua] = false
+	}
+	for _, ua := range botAgents {
+		known[ua] = true
+	}
+	for spec, want := range map[string]float64{"/useragent": 0.1, "/useragent web": 0.1, "/useragent bots": 0.6, "/useragent browsers": 0, "/useragent 0.3": 0.3} {
+		fields, err := parseUserFields(NewRng("ua"), map[string]string{"ua": spec})
+		if err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		bots := 0
+		const n = 10000
+		for i := 0; i < n; i++ {
+			ua := fields["ua"]().(string)
+			isBot, ok := known[ua]
+			if !ok {
+				t.Fatalf("%s: unknown user agent %q", spec, ua)
+			}
+			if isBot {
+				bots++
+			}
+		}
+		if got := float64(bots) / n; math.Abs(got-want) > 0.02 {
+			t.Errorf("%s: expected a bot fraction of %.2f, got %.3f", spec, want, got)
+		}
+	}
+
+	for _, spec := range []string{"/useragent lots", "/useragent 1.5", "/useragent -0.1"} {
+		if _, err := parseUserFields(NewRng("ua"), map[string]string{"ua": spec}); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
+
+func TestFielder_httpFields(t *testing.T) {
+	fielder, err := NewFielder("http", httpFieldSpecs("10,5"), 0, 1, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routePattern := regexp.MustCompile(`^/api/[a-z]+(/\{id\}(/[a-z]+)?)?$`)
+	statuses := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		fields := fielder.GetFields(0, 0)
+		for _, key := range []string{"http.route", "http.request.method", "url.path", "http.response.status_code", "user_agent.original"} {
+			if _, ok := fields[key]; !ok {
+				t.Fatalf("expected %s in %v", key, fields)
+			}
+		}
+		route := fields["http.route"].(string)
+		if !routePattern.MatchString(route) {
+			t.Fatalf("%q doesn't look like a route", route)
+		}
+		// the path is the route with numbers for its ids
+		path := fields["url.path"].(string)
+		pathPattern := regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(route), `\{id\}`, `[0-9]+`) + "$")
+		if !pathPattern.MatchString(path) {
+			t.Errorf("path %q doesn't match route %q", path, route)
+		}
+		method := fields["http.request.method"].(string)
+		switch {
+		case strings.HasSuffix(route, "{id}") && method == "POST":
+			t.Errorf("%s isn't a method for an item like %s", method, route)
+		case !strings.HasSuffix(route, "{id}") && (method == "PUT" || method == "DELETE"):
+			t.Errorf("%s isn't a method for a collection like %s", method, route)
+		}
+		statuses[fields["http.response.status_code"].(string)[:1]]++
+	}
+	if statuses["2"] == 0 || statuses["4"] == 0 || statuses["5"] == 0 || statuses["5"] > statuses["4"] {
+		t.Errorf("expected mostly 2xx, then 4xx, then 5xx statuses, got %v", statuses)
+	}
+}
+
+func Test_numericRanges(t *testing.T) {
+	tests := []struct {
+		spec     string
+		min, max float64 // ints are less than max unless min == max
+		err      bool
+	}{
+		{spec: "/i", min: 0, max: 100},
+		{spec: "/i50", min: 0, max: 50},
+		{spec: "/i-50", min: -50, max: 0},
+		{spec: "/i-50,-10", min: -50, max: -10},
+		{spec: "/ir-5,5", min: -5, max: 5},
+		{spec: "/i5,5", min: 5, max: 5},
+		{spec: "/i0,0", min: 0, max: 0},
+		{spec: "/i0", min: 0, max: 0},
+		{spec: "/ir-7,-7", min: -7, max: -7},
+		{spec: "/i10,5", err: true},
+		{spec: "/i-10,-20", err: true},
+		{spec: "/i1.5", err: true},
+		{spec: "/f", min: 0, max: 100},
+		{spec: "/f-2.5", min: -2.5, max: 0},
+		{spec: "/f-100,100", min: -100, max: 100},
+		{spec: "/fr-0.5,-0.25", min: -0.5, max: -0.25},
+		{spec: "/f2.5,2.5", min: 2.5, max: 2.5},
+		{spec: "/fr-1,-1", min: -1, max: -1},
+		{spec: "/f3,-3", err: true},
+		{spec: "/f1-2", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			fields, err := parseUserFields(NewRng("range"), map[string]string{"n": tt.spec})
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error for %s", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			lo, hi := math.Inf(1), math.Inf(-1)
+			for i := 0; i < 1000; i++ {
+				var v float64
+				switch n := fields["n"]().(type) {
+				case int64:
+					v = float64(n)
+				case float64:
+					v = n
+				default:
+					t.Fatalf("unexpected %T", n)
+				}
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+			if tt.min == tt.max {
+				if lo != tt.min || hi != tt.max {
+					t.Errorf("expected always %v, got %v to %v", tt.min, lo, hi)
+				}
+				return
+			}
+			if lo < tt.min || hi >= tt.max {
+				t.Errorf("expected %v up to %v, got %v to %v", tt.min, tt.max, lo, hi)
+			}
+			if hi-lo < (tt.max-tt.min)/2 {
+				t.Errorf("expected values across %v to %v, got %v to %v", tt.min, tt.max, lo, hi)
+			}
+		})
+	}
+
+	for _, spec := range []string{"/ip0,1,1,1", "/ip1,1,1,257", "/ip-1,1,1,1"} {
+		if _, err := parseUserFields(NewRng("range"), map[string]string{"n": spec}); err == nil {
+			t.Errorf("expected an error for %s", spec)
+		}
+	}
+	fields, err := parseUserFields(NewRng("range"), map[string]string{"n": "/ip1,1,1,1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fields["n"](); got != "0.0.0.0" {
+		t.Errorf("expected /ip1,1,1,1 to always be 0.0.0.0, got %v", got)
+	}
+}
+
+func Test_getIpGens(t *testing.T) {
+	tests := []struct {
+		spec    string
+		network string
+	}{
+		{"/ip6", "2000::/3"},
+		{"/ipc 10.0.0.0/8", "10.0.0.0/8"},
+		{"/ipc 192.168.7.130/25", "192.168.7.128/25"},
+		{"/ipc fd00:1234::/32", "fd00:1234::/32"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			fields, err := parseUserFields(NewRng("ip"), map[string]string{"addr": tt.spec})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, network, _ := net.ParseCIDR(tt.network)
+			seen := map[string]bool{}
+			for i := 0; i < 1000; i++ {
+				s := fields["addr"]().(string)
+				ip := net.ParseIP(s)
+				if ip == nil {
+					t.Fatalf("%q isn't an ip address", s)
+				}
+				if !network.Contains(ip) {
+					t.Fatalf("%s is outside %s", s, tt.network)
+				}
+				seen[s] = true
+			}
+			if len(seen) < 100 {
+				t.Errorf("expected varied addresses, got only %d distinct", len(seen))
+			}
+		})
+	}
+	// the four-part form still works when the first part starts with 6
+	if fields, err := parseUserFields(NewRng("ip"), map[string]string{"addr": "/ip64,1,1,256"}); err != nil || net.ParseIP(fields["addr"]().(string)).To4() == nil {
+		t.Errorf("expected /ip64,1,1,256 to make IPv4 addresses, got error %v", err)
+	}
+	for _, spec := range []string{"/ipc", "/ipc 10.0.0/8", "/ipc 10.0.0.0/33", "/ipc banana"} {
+		if _, err := parseUserFields(NewRng("ip"), map[string]string{"addr": spec}); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
+
+func Test_getGeoGens(t *testing.T) {
+	tests := []struct {
+		spec                           string
+		minLat, minLon, maxLat, maxLon float64
+	}{
+		{"/geo", -90, -180, 90, 180},
+		{"/geo30,-120,45,-70", 30, -120, 45, -70},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			fields, err := parseUserFields(NewRng("geo"), map[string]string{"loc": tt.spec, "pickup.latlon": tt.spec})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := fields["pickup.latlon"]; ok {
+				t.Errorf("pickup.latlon should have been split into two fields")
+			}
+			for i := 0; i < 1000; i++ {
+				var lat, lon float64
+				if _, err := fmt.Sscanf(fields["loc"]().(string), "%f,%f", &lat, &lon); err != nil {
+					t.Fatal(err)
+				}
+				plat, plon := fields["pickup.lat"]().(float64), fields["pickup.lon"]().(float64)
+				for _, ll := range [][2]float64{{lat, lon}, {plat, plon}} {
+					if ll[0] < tt.minLat || ll[0] > tt.maxLat || ll[1] < tt.minLon || ll[1] > tt.maxLon {
+						t.Fatalf("%v is outside the bounding box", ll)
+					}
+				}
+			}
+		})
+	}
+
+	for _, spec := range []string{"/geo1,2", "/geo-100,0,10,10", "/geo10,0,5,10", "/geo0,0,10,200"} {
+		if _, err := parseUserFields(NewRng("geo"), map[string]string{"loc": spec}); err == nil {
+			t.Errorf("expected an error for %s", spec)
+		}
+	}
+}
+
+func Test_getSeqGen(t *testing.T) {
+	tests := []struct {
+		spec        string
+		start, step int64
+	}{
+		{"/seq", 0, 1},
+		{"/seq1000,2", 1000, 2},
+		{"/seq-5,-3", -5, -3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			const goroutines, each = 8, 500
+			values := make(chan int64, goroutines*each)
+			wg := &sync.WaitGroup{}
+			for g := 0; g < goroutines; g++ {
+				// each goroutine gets its own Fielder, as the trace generator's do
+				fields, err := parseUserFields(NewRng("seq"), map[string]string{"event_id": tt.spec})
+				if err != nil {
+					t.Fatal(err)
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < each; i++ {
+						values <- fields["event_id"]().(int64)
+					}
+				}()
+			}
+			wg.Wait()
+			close(values)
+
+			// the counters last for the whole process, so with -count the
+			// sequence may not begin at start; it must still be contiguous
+			seen := map[int64]bool{}
+			first := int64(math.MaxInt64)
+			for v := range values {
+				if (v-tt.start)%tt.step != 0 {
+					t.Fatalf("value %d isn't in the sequence", v)
+				}
+				idx := (v - tt.start) / tt.step
+				if seen[idx] {
+					t.Fatalf("value %d was generated twice", v)
+				}
+				seen[idx] = true
+				first = min(first, idx)
+			}
+			for i := first; i < first+goroutines*each; i++ {
+				if !seen[i] {
+					t.Fatalf("value %d is missing from the sequence", tt.start+i*tt.step)
+				}
+			}
+		})
+	}
+
+	if _, err := parseUserFields(NewRng("seq"), map[string]string{"event_id": "/seq1,0"}); err == nil {
+		t.Errorf("expected an error for a step of 0")
+	}
+}
+
+func TestRng_UUID(t *testing.T) {
+	fields, err := parseUserFields(NewRng("uuid"), map[string]string{"request_id": "/uuid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v4 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		id := fields["request_id"]().(string)
+		if !v4.MatchString(id) {
+			t.Fatalf("%s is not a version 4 UUID", id)
+		}
+		if seen[id] {
+			t.Fatalf("%s was generated twice", id)
+		}
+		seen[id] = true
+	}
+
+	// seeded runs must be reproducible
+	if NewRng("same").UUID() != NewRng("same").UUID() {
+		t.Errorf("UUIDs from the same seed differ")
+	}
+}
+
+func TestRng_ULID(t *testing.T) {
+	fields, err := parseUserFields(NewRng("ulid"), map[string]string{"id": "/ulid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+	prev := ""
+	for i := 0; i < 10; i++ {
+		id := fields["id"]().(string)
+		if !format.MatchString(id) {
+			t.Fatalf("%s is not a ULID", id)
+		}
+		if id <= prev {
+			t.Errorf("ULID %s doesn't sort after %s", id, prev)
+		}
+		prev = id
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	// the timestamp is encoded in the first 10 characters
+	ts := time.UnixMilli(1700000000000)
+	if got := NewRng("ulid").ULID(ts)[:10]; got != "01HF7YAT00" {
+		t.Errorf("unexpected timestamp encoding %s", got)
+	}
+}
+
+func TestFielder_dependentLatency(t *testing.T) {
+	userFields := map[string]string{
+		"http.status": "/st10,20",
+		"duration_ms": "/latency http.status 100,10,5",
+	}
+	// only 1 attribute per span, so the status usually isn't chosen for AddFields
+	fielder, err := NewFielder("latency", userFields, 5, 1, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fives, others []float64
+	for i := 0; i < 2000; i++ {
+		fields := fielder.GetFields(0, 0)
+		d := float64(fields["duration_ms"].(int64))
+		if strings.HasPrefix(fields["http.status"].(string), "5") {
+			fives = append(fives, d)
+		} else {
+			others = append(others, d)
+		}
+	}
+	mean := func(a []float64) float64 {
+		total := 0.0
+		for _, v := range a {
+			total += v
+		}
+		return total / float64(len(a))
+	}
+	if len(fives) == 0 || len(others) == 0 {
+		t.Fatalf("expected both 5xx and other statuses, got %d and %d", len(fives), len(others))
+	}
+	if mean(fives) < 3*mean(others) {
+		t.Errorf("5xx spans average %.0fms, expected much more than the %.0fms of the others", mean(fives), mean(others))
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background())
+	_, span := tp.Tracer("test").Start(context.Background(), "test")
+	fielder.AddFields(span, 0, 0, false)
+	span.End()
+	attrs := map[attribute.Key]bool{}
+	for _, kv := range exporter.GetSpans()[0].Attributes {
+		attrs[kv.Key] = true
+	}
+	if !attrs["duration_ms"] || !attrs["http.status"] {
+		t.Errorf("expected the dependent field and its source on the span, got %v", attrs)
+	}
+
+	if _, err := NewFielder("latency", map[string]string{"d": "/latency"}, 0, 1, 1, 0); err == nil {
+		t.Errorf("expected an error for a latency without a source field")
+	}
+}
+
+func TestFielder_arrayAttributes(t *testing.T) {
+	userFields := map[string]string{
+		"tags":    "/arr s,5",
+		"scores":  "/arr i,4",
+		"weights": "/arr f",
+		"flags":   "/arr b,2",
+	}
+	fielder, err := NewFielder("arr", userFields, 0, 1, 5, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := fielder.GetFields(0, 0)
+	if tags, ok := fields["tags"].([]string); !ok || len(tags) != 5 {
+		t.Errorf("expected 5 string tags, got %#v", fields["tags"])
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background())
+	_, span := tp.Tracer("test").Start(context.Background(), "test")
+	fielder.AddFields(span, 0, 0, false)
+	span.End()
+
+	types := map[string]attribute.Type{}
+	lengths := map[string]int{}
+	for _, kv := range exporter.GetSpans()[0].Attributes {
+		types[string(kv.Key)] = kv.Value.Type()
+		switch kv.Value.Type() {
+		case attribute.STRINGSLICE:
+			lengths[string(kv.Key)] = len(kv.Value.AsStringSlice())
+		case attribute.INT64SLICE:
+			lengths[string(kv.Key)] = len(kv.Value.AsInt64Slice())
+		case attribute.FLOAT64SLICE:
+			lengths[string(kv.Key)] = len(kv.Value.AsFloat64Slice())
+		case attribute.BOOLSLICE:
+			lengths[string(kv.Key)] = len(kv.Value.AsBoolSlice())
+		}
+	}
+	expected := map[string]struct {
+		typ    attribute.Type
+		length int
+	}{
+		"tags":    {attribute.STRINGSLICE, 5},
+		"scores":  {attribute.INT64SLICE, 4},
+		"weights": {attribute.FLOAT64SLICE, 3},
+		"flags":   {attribute.BOOLSLICE, 2},
+	}
+	for name, want := range expected {
+		if types[name] != want.typ || lengths[name] != want.length {
+			t.Errorf("%s: expected %v of length %d, got %v of length %d", name, want.typ, want.length, types[name], lengths[name])
+		}
+	}
+
+	for _, spec := range []string{"/arr x,3", "/arr s,-1", "/arr s,many"} {
+		if _, err := parseUserFields(NewRng("arr"), map[string]string{"a": spec}); err == nil {
+			t.Errorf("expected an error for %s", spec)
+		}
+	}
+}
+
+func TestRng_QuadraticChoice(t *testing.T) {
+	rng := NewRng("quadratic")
+	for i := 0; i < 100000; i++ {
+		if got := rng.QuadraticChoice(nil); got != "" {
+			t.Fatalf("expected an empty string from an empty slice, got %q", got)
+		}
+		if got := rng.QuadraticChoice([]string{"only"}); got != "only" {
+			t.Fatalf("expected the only element, got %q", got)
+		}
+		if got := rng.QuadraticChoice([]string{"a", "b"}); got != "a" && got != "b" {
+			t.Fatalf("unexpected choice %q", got)
+		}
+	}
+}
+
+func TestFielder_GetSpanName(t *testing.T) {
+	patterns := map[string]*regexp.Regexp{
+		"service": regexp.MustCompile(`^svc$`),
+		"http":    regexp.MustCompile(`^(GET|POST|PUT|DELETE) /api/[a-z]+(/\{id\})?$`),
+		"rpc":     regexp.MustCompile(`^([A-Z][a-z]+)Service/(Get|List|Create|Update|Delete)([A-Z][a-z]+)$`),
+		"db":      regexp.MustCompile(`^(SELECT|INSERT|UPDATE|DELETE) [a-z]+$`),
+		"word":    regexp.MustCompile(`^[a-z]+_[a-z]+$`),
+	}
+	for style, pattern := range patterns {
+		t.Run(style, func(t *testing.T) {
+			f1, err := NewFielder("names", map[string]string{}, 0, 1, 1, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := f1.SetSpanNameTemplate(style); err != nil {
+				t.Fatal(err)
+			}
+			f2, _ := NewFielder("names", map[string]string{}, 0, 1, 1, 0)
+			f2.SetSpanNameTemplate(style)
+			seen := map[string]bool{}
+			for i := 0; i < 200; i++ {
+				name := f1.GetSpanName("svc")
+				if !pattern.MatchString(name) {
+					t.Fatalf("%q doesn't look like a %s span name", name, style)
+				}
+				seen[name] = true
+			}
+			if len(seen) > spanNamesPerService {
+				t.Errorf("expected at most %d operations for a service, got %v", spanNamesPerService, seen)
+			}
+			// another Fielder with the same seed gives the service the same operations
+			for i := 0; i < 50; i++ {
+				if name := f2.GetSpanName("svc"); !seen[name] {
+					t.Errorf("%q isn't one of the service's operations %v", name, seen)
+				}
+			}
+		})
+	}
+	f, _ := NewFielder("names", map[string]string{}, 0, 1, 1, 0)
+	if err := f.SetSpanNameTemplate("bogus"); err == nil {
+		t.Errorf("expected an error for an unknown style")
+	}
+}
+
+func TestFielder_keyStyle(t *testing.T) {
+	patterns := map[string]*regexp.Regexp{
+		"wordpair": regexp.MustCompile(`^[a-z]+-[a-z]+$`),
+		"dotted":   regexp.MustCompile(`^service\.[a-z]+\.[a-z]+$`),
+		"snake":    regexp.MustCompile(`^[a-z]+_[a-z]+$`),
+		"camel":    regexp.MustCompile(`^[a-z]+[A-Z][a-z]+$`),
+	}
+	// the extra fields, without the ones every Fielder has
+	extras := func(f *Fielder) []string {
+		var keys []string
+		for _, k := range f.keys {
+			if k != "process_id" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	base, err := NewFielder("keys", map[string]string{}, 20, 1, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := len(extras(base))
+	for style, pattern := range patterns {
+		f1, _ := NewFielder("keys", map[string]string{}, 20, 1, 3, 3)
+		if err := f1.SetKeyStyle(style); err != nil {
+			t.Fatal(err)
+		}
+		keys := extras(f1)
+		if len(keys) != want {
+			t.Errorf("%s: expected %d fields, got %d", style, want, len(keys))
+		}
+		for _, k := range keys {
+			if !pattern.MatchString(k) {
+				t.Errorf("%q doesn't look like a %s key", k, style)
+			}
+			if _, ok := f1.fields[k]; !ok {
+				t.Errorf("%s: key %q has no field", style, k)
+			}
+		}
+		for _, step := range f1.drift.steps {
+			if !pattern.MatchString(step.key) {
+				t.Errorf("%q drifts, but doesn't look like a %s key", step.key, style)
+			}
+		}
+		f2, _ := NewFielder("keys", map[string]string{}, 20, 1, 3, 3)
+		f2.SetKeyStyle(style)
+		if !reflect.DeepEqual(keys, extras(f2)) {
+			t.Errorf("%s: expected the same keys for the same seed, got %v and %v", style, keys, extras(f2))
+		}
+	}
+	if err := base.SetKeyStyle("bogus"); err == nil {
+		t.Errorf("expected an error for an unknown key style")
+	}
+}
+
+func TestFielder_seqTimestamps(t *testing.T) {
+	f, err := NewFielder("seqts", map[string]string{"ts": "/seqts", "1.child_ts": "/seqts2,3"}, 0, 3, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a trace is a root span followed by its descendants, in the order they're created
+	trace := func(nspans int) []map[string]any {
+		spans := []map[string]any{f.GetFields(1, 0)}
+		for i := 1; i < nspans; i++ {
+			spans = append(spans, f.GetFields(0, 1+i%2))
+		}
+		return spans
+	}
+
+	before := time.Now().UnixMilli()
+	first := trace(200)
+	last := first[0]["ts"].(int64)
+	if last < before || last > time.Now().UnixMilli() {
+		t.Errorf("expected the root's timestamp %d to be when the trace started", last)
+	}
+	for _, span := range first[1:] {
+		ts := span["ts"].(int64)
+		if ts-last < 1 || ts-last > 10 {
+			t.Fatalf("expected %d to be 1-10ms after %d", ts, last)
+		}
+		last = ts
+	}
+	var childLast int64
+	for _, span := range first {
+		ts, ok := span["child_ts"]
+		if !ok {
+			continue
+		}
+		if childLast != 0 && (ts.(int64)-childLast < 2 || ts.(int64)-childLast > 3) {
+			t.Fatalf("expected %d to be 2-3ms after %d", ts, childLast)
+		}
+		childLast = ts.(int64)
+	}
+	if childLast == 0 {
+		t.Error("expected child_ts in the level 1 spans")
+	}
+	if _, ok := first[0]["child_ts"]; ok {
+		t.Error("expected no child_ts in the root span")
+	}
+
+	// 200 spans took the timestamps at least 199ms past now, but the next trace starts over
+	second := trace(3)
+	start := second[0]["ts"].(int64)
+	if start >= last || start > time.Now().UnixMilli() {
+		t.Errorf("expected the second trace to start at now, not %d (the first ended at %d)", start, last)
+	}
+	if ts := second[1]["ts"].(int64); ts <= start {
+		t.Errorf("expected %d to be after %d", ts, start)
+	}
+
+	for _, spec := range []string{"/seqts0", "/seqts5,2", "/seqtsx"} {
+		if _, err := NewFielder("seqts", map[string]string{"ts": spec}, 0, 1, 1, 1); err == nil {
+			t.Errorf("expected an error for %s", spec)
+		}
+	}
+}
+
+func TestFielder_attributesPerSpan(t *testing.T) {
+	// the number of fields on each of 1000 spans, from 20 extras and process_id
+	attrCounts := func(apspan, iattributes int) []int {
+		fielder, err := NewFielder("apspan", map[string]string{}, 20, 1, apspan, iattributes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exporter := tracetest.NewInMemoryExporter()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		defer tp.Shutdown(context.Background())
+		for i := 0; i < 1000; i++ {
+			_, span := tp.Tracer("test").Start(context.Background(), "test")
+			fielder.AddFields(span, 0, 0, false)
+			span.End()
+		}
+		var counts []int
+		for _, span := range exporter.GetSpans() {
+			counts = append(counts, len(span.Attributes))
+		}
+		return counts
+	}
+
+	// when all of them are intrinsic, every span has exactly that many
+	for _, n := range []int{0, 1, 5, 21} {
+		for _, count := range attrCounts(n, n) {
+			if count != n {
+				t.Fatalf("expected %d attributes on every span, got %d", n, count)
+			}
+		}
+	}
+	// past the number of fields, every span has all of them
+	for _, count := range attrCounts(30, 30) {
+		if count != 21 {
+			t.Fatalf("expected all 21 attributes on every span, got %d", count)
+		}
+	}
+	// otherwise, the others appear often enough to make up the average
+	total := 0
+	for _, count := range attrCounts(8, 2) {
+		if count < 2 {
+			t.Fatalf("expected at least the 2 intrinsic attributes, got %d", count)
+		}
+		total += count
+	}
+	if avg := float64(total) / 1000; math.Abs(avg-8) > 0.3 {
+		t.Errorf("expected 8 attributes per span on average, got %.2f", avg)
+	}
+
+	for _, n := range [][2]int{{3, 4}, {-1, 0}, {3, -1}} {
+		if _, err := NewFielder("apspan", map[string]string{}, 20, 1, n[0], n[1]); err == nil {
+			t.Errorf("expected an error for apspan %d and iattributes %d", n[0], n[1])
+		}
+	}
+}
+
+func TestFielder_attrCountDist(t *testing.T) {
+	// the mean, standard deviation, and range of the number of fields on each
+	// of 2000 spans, from 60 extras and process_id, with 2 intrinsic ones
+	attrCounts := func(spec string) (mean, stddev float64, least, most int) {
+		dist, err := parseAttrCountDist(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fielder, err := NewFielder("attrdist", map[string]string{}, 60, 1, 3, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fielder.SetAttrCountDist(dist)
+		exporter := tracetest.NewInMemoryExporter()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		defer tp.Shutdown(context.Background())
+		for i := 0; i < 2000; i++ {
+			_, span := tp.Tracer("test").Start(context.Background(), "test")
+			fielder.AddFields(span, 0, 0, false)
+			span.End()
+		}
+		least = math.MaxInt
+		var sum, sumsq float64
+		for _, span := range exporter.GetSpans() {
+			n := len(span.Attributes)
+			least, most = min(least, n), max(most, n)
+			sum += float64(n)
+			sumsq += float64(n * n)
+		}
+		mean = sum / 2000
+		return mean, math.Sqrt(sumsq/2000 - mean*mean), least, most
+	}
+
+	if mean, stddev, _, _ := attrCounts("gaussian:20,5"); math.Abs(mean-20) > 0.5 || math.Abs(stddev-5) > 0.5 {
+		t.Errorf("expected a mean of 20 and a standard deviation of 5, got %.2f and %.2f", mean, stddev)
+	}
+	if mean, _, least, most := attrCounts("uniform:5,50"); math.Abs(mean-27.5) > 1 || least != 5 || most != 50 {
+		t.Errorf("expected a mean of 27.5 from 5 to 50, got %.2f from %d to %d", mean, least, most)
+	}
+	// the counts are clamped to the intrinsic attributes and all of the fields
+	if _, _, least, most := attrCounts("gaussian:100,1"); least != 61 || most != 61 {
+		t.Errorf("expected all 61 attributes on every span, got %d to %d", least, most)
+	}
+	if _, _, least, most := attrCounts("uniform:0,1"); least != 2 || most != 2 {
+		t.Errorf("expected the 2 intrinsic attributes on every span, got %d to %d", least, most)
+	}
+
+	for _, spec := range []string{"gaussian", "poisson:3,4", "uniform:5", "uniform:50,5", "gaussian:x,1", "gaussian:-1,2"} {
+		if _, err := parseAttrCountDist(spec); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
+
+func TestFielder_presence(t *testing.T) {
+	userFields := map[string]string{
+		"rare":   "/i presence:0.1",
+		"common": "/sw5 presence:0.8",
+		"never":  "constant presence:0",
+	}
+	// 10 extras and process_id are unconfigured; 2 of them are intrinsic and the
+	// other 9 share the remaining 3 attributes per span
+	fielder, err := NewFielder("presence", userFields, 10, 1, 5, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background())
+	const nspans = 5000
+	for i := 0; i < nspans; i++ {
+		_, span := tp.Tracer("test").Start(context.Background(), "test")
+		fielder.AddFields(span, 0, 0, false)
+		span.End()
+	}
+	counts := map[string]int{}
+	for _, span := range exporter.GetSpans() {
+		for _, kv := range span.Attributes {
+			counts[string(kv.Key)]++
+		}
+	}
+
+	frequency := func(key string) float64 { return float64(counts[key]) / nspans }
+	for _, key := range fielder.keys[:fielder.intrinsicAttributes] {
+		if counts[key] != nspans {
+			t.Errorf("expected intrinsic %s in every span, got %d", key, counts[key])
+		}
+	}
+	expected := map[string]float64{"rare": 0.1, "common": 0.8, "never": 0}
+	for _, key := range fielder.keys[fielder.intrinsicAttributes:] {
+		if _, ok := expected[key]; !ok {
+			expected[key] = 3.0 / 9
+		}
+	}
+	for key, want := range expected {
+		if got := frequency(key); math.Abs(got-want) > 0.03 {
+			t.Errorf("expected %s in %.2f of spans, got %.3f", key, want, got)
+		}
+		if want > 0 && counts[key] == 0 {
+			t.Errorf("expected %s to appear", key)
+		}
+	}
+	if len(expected) != len(fielder.keys)-fielder.intrinsicAttributes {
+		t.Errorf("expected the configured fields to be optional, got keys %v", fielder.keys)
+	}
+
+	for _, spec := range []string{"/i presence:1.5", "/i presence:x"} {
+		if _, err := NewFielder("presence", map[string]string{"f": spec}, 0, 1, 1, 1); err == nil {
+			t.Errorf("expected an error for %s", spec)
+		}
+	}
+}
+
+func TestFielder_maxAttrLen(t *testing.T) {
+	userFields := map[string]string{
+		"long":  "/s100",
+		"words": "/arr s,5",
+		"const": "a constant that's longer than the limit",
+		"short": "/s4",
+		"num":   "/i",
+	}
+	for _, ellipsis := range []bool{false, true} {
+		fielder, err := NewFielder("maxattrlen", userFields, 10, 1, 16, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fielder.SetMaxAttrLen(8, ellipsis)
+
+		exporter := tracetest.NewInMemoryExporter()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		for i := 0; i < 20; i++ {
+			_, span := tp.Tracer("test").Start(context.Background(), "test")
+			fielder.AddFields(span, 0, 0, false)
+			span.End()
+		}
+		tp.Shutdown(context.Background())
+
+		check := func(name, s string) {
+			if len(s) > 8 {
+				t.Errorf("expected %s to be at most 8 bytes, got %q", name, s)
+			}
+		}
+		for _, span := range exporter.GetSpans() {
+			for _, kv := range span.Attributes {
+				switch kv.Value.Type() {
+				case attribute.STRING:
+					check(string(kv.Key), kv.Value.AsString())
+				case attribute.STRINGSLICE:
+					for _, s := range kv.Value.AsStringSlice() {
+						check(string(kv.Key), s)
+					}
+				}
+			}
+		}
+		fields := fielder.GetFields(0, 0)
+		for name, v := range fields {
+			if s, ok := v.(string); ok {
+				check(name, s)
+			}
+		}
+		if want := map[bool]string{false: "a consta", true: "a con..."}[ellipsis]; fields["const"] != want {
+			t.Errorf("expected the constant to be truncated to %q, got %q", want, fields["const"])
+		}
+		if len(fields["short"].(string)) != 4 {
+			t.Errorf("expected a short string to be left alone, got %q", fields["short"])
+		}
+	}
+
+	// a character isn't split
+	if got := truncateString("naïve", 3, false); got != "na" {
+		t.Errorf("expected the ï to be dropped whole, got %q", got)
+	}
+}
+
+func TestFielder_traceScoped(t *testing.T) {
+	userFields := map[string]string{"session_id": "@/sx16", "request_id": "@/uuid", "span_value": "/uuid", "handle": "@home"}
+	newFielder := func(service string) (*Fielder, error) {
+		return NewFielder("scoped"+service, userFields, 0, 3, 4, 4)
+	}
+	base, err := newFielder("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the services' Fielders share the base's trace fields, as they do in a generator
+	fielders := newServiceFielders(base, newFielder, NewLogger(0))
+	trace := func() []map[string]any {
+		return []map[string]any{
+			fielders.forService("frontend").GetFields(1, 0),
+			fielders.forService("backend").GetFields(0, 1),
+			fielders.forService("backend").GetFields(0, 2),
+		}
+	}
+
+	first, second := trace(), trace()
+	for _, key := range []string{"session_id", "request_id"} {
+		for _, span := range first[1:] {
+			if span[key] != first[0][key] {
+				t.Errorf("expected the same %s on every span of a trace, got %v and %v", key, first[0][key], span[key])
+			}
+		}
+		if second[0][key] == first[0][key] {
+			t.Errorf("expected a new %s for the next trace, got %v again", key, first[0][key])
+		}
+	}
+	if first[0]["span_value"] == first[1]["span_value"] {
+		t.Errorf("expected an ordinary field to differ between spans")
+	}
+	// only a generator can be trace-scoped; a constant can start with @
+	if first[0]["handle"] != "@home" {
+		t.Errorf("expected the constant @home, got %v", first[0]["handle"])
+	}
+
+	for _, spec := range []string{"@/seqts", "@/latency session_id", "@/nope"} {
+		if _, err := NewFielder("scoped", map[string]string{"session_id": "/sx16", "f": spec}, 0, 1, 2, 2); err == nil {
+			t.Errorf("expected an error for %s", spec)
+		}
+	}
+}
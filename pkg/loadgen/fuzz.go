@@ -0,0 +1,86 @@
+package loadgen
+
+import (
+	"math"
+	"strings"
+)
+
+// The edge cases that --fuzz substitutes for a span's values and keys, to
+// test a backend's validation. Every string is valid UTF-8, since OTLP
+// requires it and a single invalid one would fail a whole export.
+var (
+	fuzzStrings = []string{
+		"",                              // empty
+		strings.Repeat("x", 64<<10),     // very long
+		"naïve ☃ \U0001D11E",            // accents, a snowman, and a character outside the BMP
+		"\u202eright to left\u202c",     // bidirectional overrides
+		"\x00nul\x07bell\x1b[31mescape", // control characters
+		"line\nbreak\r\ttab",
+	}
+	fuzzFloats = []float64{math.NaN(), math.Inf(1), math.Inf(-1), math.Copysign(0, -1), math.SmallestNonzeroFloat64, math.MaxFloat64}
+	fuzzInts   = []int64{math.MinInt64, math.MaxInt64, 0, -1}
+	// a fuzzed key is the original with one of these added, or empty
+	fuzzKeySuffixes = []string{"\x00", " ", ".☃", "\t\n", "\u202e", "..", "."}
+)
+
+// SetFuzzRate makes each span's values and keys edge cases at the given rate
+// (--fuzz); see fuzz.
+func (f *Fielder) SetFuzzRate(rate float64) {
+	f.fuzzRate = rate
+}
+
+// fuzz replaces a fraction fuzzRate of the values with edge cases of the same
+// type, and independently, gives a fraction fuzzRate of the keys control or
+// Unicode characters, or makes them empty. A bool keeps its value, and a
+// slice becomes empty. The count and loadgen's own fields aren't changed.
+func (f *Fielder) fuzz(values map[string]any) {
+	if f.fuzzRate <= 0 {
+		return
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		if k != "count" && !strings.HasPrefix(k, "loadgen.") {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		if f.rng.Float(0, 1) < f.fuzzRate {
+			values[k] = fuzzValue(f.rng, values[k])
+		}
+		if f.rng.Float(0, 1) < f.fuzzRate {
+			v := values[k]
+			delete(values, k)
+			values[fuzzKey(f.rng, k)] = v
+		}
+	}
+}
+
+func fuzzValue(rng Rng, value any) any {
+	switch v := value.(type) {
+	case string:
+		return rng.Choice(fuzzStrings)
+	case float64:
+		return fuzzFloats[rng.Intn(len(fuzzFloats))]
+	case int64:
+		return fuzzInts[rng.Intn(len(fuzzInts))]
+	case uint64:
+		return uint64(math.MaxInt64)
+	case []string:
+		return []string{}
+	case []int64:
+		return []int64{}
+	case []float64:
+		return []float64{}
+	case []bool:
+		return []bool{}
+	default:
+		return v
+	}
+}
+
+func fuzzKey(rng Rng, key string) string {
+	if i := rng.Intn(len(fuzzKeySuffixes) + 1); i < int64(len(fuzzKeySuffixes)) {
+		return key + fuzzKeySuffixes[i]
+	}
+	return ""
+}
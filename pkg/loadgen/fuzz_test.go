@@ -0,0 +1,77 @@
+package loadgen
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestFielder_fuzz(t *testing.T) {
+	// none of the values are edge cases themselves
+	userFields := map[string]string{"str": "plain", "flt": "1.5", "num": "42"}
+	fielder, err := NewFielder("fuzz", userFields, 0, 1, 4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const rate, nspans = 0.1, 2000
+	fielder.SetFuzzRate(rate)
+	fielder.SetRunLabel("brave-widget")
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background())
+	for i := 0; i < nspans; i++ {
+		_, span := tp.Tracer("test").Start(context.Background(), "test")
+		fielder.AddFields(span, 1, 0, false)
+		span.End()
+	}
+
+	var values, keys, nans int
+	for _, span := range exporter.GetSpans() {
+		attrs := attribute.NewSet(span.Attributes...)
+		if v, _ := attrs.Value("count"); v.AsInt64() != 1 {
+			t.Errorf("expected the count to be left alone, got %v", span.Attributes)
+		}
+		if v, _ := attrs.Value(runLabelField); v.AsString() != "brave-widget" {
+			t.Errorf("expected %s to be left alone, got %v", runLabelField, span.Attributes)
+		}
+		for _, kv := range span.Attributes {
+			key := string(kv.Key)
+			if _, ok := userFields[key]; !ok && key != "process_id" && key != "count" && key != runLabelField {
+				keys++
+			}
+			switch key {
+			case "str":
+				if kv.Value.AsString() != "plain" {
+					values++
+				}
+			case "flt":
+				if f := kv.Value.AsFloat64(); f != 1.5 {
+					values++
+					if math.IsNaN(f) {
+						nans++
+					}
+				}
+			case "num":
+				if kv.Value.AsInt64() != 42 {
+					values++
+				}
+			}
+		}
+	}
+	// a value with a fuzzed key can't be recognized, so there are a few more
+	// fuzzed values than are counted; an empty key isn't exported at all
+	fields := float64(4 * nspans)
+	if got := float64(keys) / fields; math.Abs(got-rate*7/8) > 0.02 {
+		t.Errorf("expected about %.1f%% of the keys to be fuzzed, got %.1f%%", 100*rate*7/8, 100*got)
+	}
+	if got := float64(values) / (3 * nspans); math.Abs(got-rate*(1-rate)) > 0.02 {
+		t.Errorf("expected about %.1f%% of the values to be fuzzed, got %.1f%%", 100*rate*(1-rate), 100*got)
+	}
+	if nans == 0 {
+		t.Errorf("expected some NaN values")
+	}
+}
@@ -0,0 +1,905 @@
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"pgregory.net/rand"
+)
+
+// A Generator generates traces and sends the individual spans to the spans channel. Its
+// Generate method should be run in a goroutine, and generates a single trace,
+// taking opts.Duration to do so. Its TPS method returns the number of traces
+// per second it is currently generating.
+type Generator interface {
+	Generate(opts *Options, wg *sync.WaitGroup, stop chan struct{}, counter chan int64)
+	TPS() float64
+}
+
+// stopMut makes closeStop safe to call from several goroutines at once.
+var stopMut sync.Mutex
+
+// closeStop closes the stop channel unless it's already closed; generators,
+// the trace counter, and the signal handler can all decide it's time to stop.
+func closeStop(stop chan struct{}) {
+	stopMut.Lock()
+	defer stopMut.Unlock()
+	select {
+	case <-stop:
+	default:
+		close(stop)
+	}
+}
+
+type GeneratorState int
+
+const (
+	Starting GeneratorState = iota
+	Running
+	Stopping
+)
+
+type TraceGenerator struct {
+	depth      int
+	nspans     int
+	duration   time.Duration
+	busy       time.Duration // how long a generator takes to make each trace: the duration, or none with --synthetictime
+	synthetic  bool
+	newFielder func(seed string) (*Fielder, error) // makes the base Fielder, and each service's own one
+	chans      []chan struct{}
+	mut        sync.RWMutex
+	log        Logger
+	tracer     Sender
+	linkRate   float64
+	leafRate   float64       // the fraction of leaf spans that are calls to a database or cache
+	minSpan    time.Duration // the shortest a span's own time can be (--minspanduration)
+	recent     *recentSpans
+	tps        float64      // the target rate, which can change while running
+	sent       atomic.Int64 // not including warmup traces
+	warmup     int64
+	durations  *durationSampler
+	roots      *quantileSampler // nil unless root durations are set by percentile
+	burst      burstEnvelope
+	skipped    atomic.Int64 // traces skipped because the sender was backed up
+	processIDs []int64      // the synthetic process ids that generators take turns with (--processes)
+	nextPID    atomic.Int64
+	poisson    bool   // whether traces arrive as a Poisson process (--arrival) instead of periodically
+	seed       string // seeds each generator's arrivals, along with its number
+	started    atomic.Int64
+	rampShape  func(f float64) float64 // from --rampshape
+	pathoRate  float64                 // the fraction of traces that are pathological (--pathorate)
+	oversized  string                  // the value of a huge trace's oversized attribute, made once
+}
+
+// The dimensions of the pathological traces (--pathorate), which are meant to
+// find a backend's limits.
+const (
+	pathoDepth   = 1000
+	pathoWidth   = 10000
+	pathoAttrLen = 1 << 20
+	// maxPathoSpans is the most spans any pathological trace can have, whatever
+	// --nspans is, so that a generator can't run out of memory building one
+	maxPathoSpans = 10000
+)
+
+// pathoField is the root span field that marks a pathological trace with its
+// shape, and pathoAttrField is the oversized attribute of a huge one.
+const (
+	pathoField     = "loadgen.pathological"
+	pathoAttrField = "loadgen.oversized"
+)
+
+// A pathoShape overrides the depth and span count of a pathological trace;
+// a zero keeps the normal one.
+type pathoShape struct {
+	name   string
+	depth  int
+	nspans int
+	flat   bool // whether every other span is a child of the root
+	huge   bool // whether the root has the oversized attribute
+}
+
+var pathoShapes = []pathoShape{
+	{name: "deep", depth: pathoDepth, nspans: pathoDepth},
+	{name: "wide", depth: 2, nspans: pathoWidth, flat: true},
+	{name: "huge", huge: true},
+}
+
+// pathological returns the shape of the next trace, and false if it's a normal one.
+func (s *TraceGenerator) pathological() (pathoShape, bool) {
+	if s.pathoRate <= 0 || rand.Float64() >= s.pathoRate {
+		return pathoShape{}, false
+	}
+	return pathoShapes[rand.Intn(len(pathoShapes))], true
+}
+
+// backpressureThreshold is how full a sender's queue can get before the
+// generators start skipping traces.
+const backpressureThreshold = 0.8
+
+// rampExpRate is how sharply an exp ramp accelerates: the first half of the
+// ramp starts about 12% of the generators.
+const rampExpRate = 4
+
+// rampSteps is the number of equal jumps in a step ramp.
+const rampSteps = 4
+
+// rampShapes give the fraction of the target generators that should be
+// running a fraction f of the way through the ramp (--rampshape). A linear ramp
+// starts them at a steady pace, an exp ramp starts slowly and accelerates, and
+// a step ramp starts them in rampSteps jumps, the first one right away.
+var rampShapes = map[string]func(f float64) float64{
+	"linear": func(f float64) float64 { return f },
+	"exp":    func(f float64) float64 { return math.Expm1(rampExpRate*f) / math.Expm1(rampExpRate) },
+	"step":   func(f float64) float64 { return math.Ceil(f*rampSteps) / rampSteps },
+}
+
+// rampTarget is the number of generators that should be running after elapsed
+// of the ramp, on the way to target.
+func (s *TraceGenerator) rampTarget(elapsed, ramp time.Duration, target int) int {
+	if elapsed >= ramp {
+		return target
+	}
+	return int(math.Round(s.rampShape(float64(elapsed)/float64(ramp)) * float64(target)))
+}
+
+// burstEnvelope is a square wave that turns sending on for the first duty
+// fraction of each period and off for the rest (--burst). Its zero value is
+// always on.
+type burstEnvelope struct {
+	period time.Duration
+	duty   float64
+	start  time.Time
+}
+
+func newBurstEnvelope(opts *Options) (burstEnvelope, error) {
+	if !opts.Quantity.Burst {
+		return burstEnvelope{}, nil
+	}
+	if opts.Quantity.BurstPeriod <= 0 {
+		return burstEnvelope{}, fmt.Errorf("burst period %v must be positive", opts.Quantity.BurstPeriod)
+	}
+	if opts.Quantity.BurstDuty <= 0 || opts.Quantity.BurstDuty > 1 {
+		return burstEnvelope{}, fmt.Errorf("burst duty %v must be more than 0 and at most 1", opts.Quantity.BurstDuty)
+	}
+	return burstEnvelope{period: opts.Quantity.BurstPeriod, duty: opts.Quantity.BurstDuty}, nil
+}
+
+// on reports whether sending is on at time t; each period starts when the envelope starts.
+func (b burstEnvelope) on(t time.Time) bool {
+	if b.period <= 0 {
+		return true
+	}
+	phase := t.Sub(b.start) % b.period
+	return phase < time.Duration(b.duty*float64(b.period))
+}
+
+// arrivals times the starts of one generator's traces (--arrival). Periodic
+// ones come every interval; poisson ones come after exponentially distributed
+// waits with a mean of the interval, so that the generators together make a
+// Poisson process at the target TPS, with realistic clumps and gaps.
+type arrivals struct {
+	C      <-chan time.Time
+	ticker *time.Ticker
+	timer  *time.Timer
+	mean   float64
+	rng    Rng
+}
+
+func newArrivals(poisson bool, interval time.Duration, rng Rng) *arrivals {
+	if !poisson {
+		ticker := time.NewTicker(interval)
+		return &arrivals{C: ticker.C, ticker: ticker}
+	}
+	a := &arrivals{mean: float64(interval), rng: rng}
+	a.timer = time.NewTimer(a.wait())
+	a.C = a.timer.C
+	return a
+}
+
+func (a *arrivals) wait() time.Duration {
+	return time.Duration(a.rng.Exponential(a.mean))
+}
+
+// next schedules the arrival after the one that was just received.
+func (a *arrivals) next() {
+	if a.timer != nil {
+		a.timer.Reset(a.wait())
+	}
+}
+
+// setInterval changes the interval, from the next arrival on.
+func (a *arrivals) setInterval(interval time.Duration) {
+	if a.ticker != nil {
+		a.ticker.Reset(interval)
+	} else {
+		a.mean = float64(interval)
+	}
+}
+
+func (a *arrivals) stop() {
+	if a.ticker != nil {
+		a.ticker.Stop()
+	} else {
+		a.timer.Stop()
+	}
+}
+
+// make sure it implements Generator
+var _ Generator = (*TraceGenerator)(nil)
+
+// NewTraceGenerator creates a TraceGenerator that sends its traces to tsender.
+// Its Fielders are created by newFielder, or from opts if it's nil.
+func NewTraceGenerator(tsender Sender, newFielder func(seed string) (*Fielder, error), log Logger, opts *Options) (*TraceGenerator, error) {
+	if newFielder == nil {
+		factory, err := newFielderFactory(opts)
+		if err != nil {
+			return nil, err
+		}
+		newFielder = factory
+	}
+	roots, err := newRootDurations(opts)
+	if err != nil {
+		return nil, err
+	}
+	burst, err := newBurstEnvelope(opts)
+	if err != nil {
+		return nil, err
+	}
+	shape := opts.Quantity.RampShape
+	if shape == "" {
+		shape = "linear"
+	}
+	rampShape, ok := rampShapes[shape]
+	if !ok {
+		return nil, fmt.Errorf("unknown ramp shape %s", shape)
+	}
+	if opts.Format.Processes < 0 {
+		return nil, fmt.Errorf("processes %d must not be negative", opts.Format.Processes)
+	}
+	if opts.Format.MinSpanDuration < 0 {
+		return nil, fmt.Errorf("minspanduration %v must not be negative", opts.Format.MinSpanDuration)
+	}
+	if opts.Format.LinkRate < 0 || opts.Format.LinkRate > 1 {
+		return nil, fmt.Errorf("linkrate %v must be between 0 and 1", opts.Format.LinkRate)
+	}
+	if opts.Format.PathoRate < 0 || opts.Format.PathoRate > 1 {
+		return nil, fmt.Errorf("pathorate %v must be between 0 and 1", opts.Format.PathoRate)
+	}
+	var oversized string
+	if opts.Format.PathoRate > 0 {
+		oversized = strings.Repeat("x", pathoAttrLen)
+	}
+	busy := opts.Format.TraceTime
+	if opts.Format.SyntheticTime {
+		busy = 0
+	}
+	var processIDs []int64
+	if opts.Format.Processes > 0 {
+		processIDs = newProcessIDs(opts.Global.Seed, opts.Format.Processes)
+		if ngenerators := generatorsFor(float64(opts.Quantity.TPS), busy); ngenerators < opts.Format.Processes {
+			log.Warn("only %d generators will run, so only %d of the %d processes will send spans\n", ngenerators, ngenerators, opts.Format.Processes)
+		}
+	}
+	chans := make([]chan struct{}, 0)
+	return &TraceGenerator{
+		depth:      opts.Format.Depth,
+		nspans:     opts.Format.NSpans,
+		duration:   opts.Format.TraceTime,
+		busy:       busy,
+		synthetic:  opts.Format.SyntheticTime,
+		newFielder: newFielder,
+		chans:      chans,
+		log:        log,
+		tracer:     tsender,
+		linkRate:   opts.Format.LinkRate,
+		leafRate:   opts.Format.LeafRate,
+		minSpan:    opts.Format.MinSpanDuration,
+		recent:     newRecentSpans(16),
+		tps:        float64(opts.Quantity.TPS),
+		durations:  newDurationSampler(opts),
+		warmup:     opts.Quantity.Warmup,
+		roots:      roots,
+		burst:      burst,
+		processIDs: processIDs,
+		poisson:    opts.Quantity.Arrival == "poisson",
+		seed:       opts.Global.Seed,
+		rampShape:  rampShape,
+		pathoRate:  opts.Format.PathoRate,
+		oversized:  oversized,
+	}, nil
+}
+
+// serviceFielders gives each service its own Fielder, seeded by the service's
+// name, so that each service has a distinct set of field names and types that's
+// the same on every run. The Fielders are created as the services are first
+// seen, and they share the per-trace fields of the base Fielder (which also
+// names the services) so that a trace's values stay in order across services.
+// Like a Fielder, it belongs to a single generator goroutine.
+type serviceFielders struct {
+	base      *Fielder
+	create    func(service string) (*Fielder, error)
+	fielders  map[string]*Fielder
+	processID int64 // if set, the synthetic process_id of this generator's spans
+	log       Logger
+}
+
+// newServiceFielders returns serviceFielders that use base for every service if create is nil.
+func newServiceFielders(base *Fielder, create func(service string) (*Fielder, error), log Logger) *serviceFielders {
+	return &serviceFielders{base: base, create: create, fielders: make(map[string]*Fielder), log: log}
+}
+
+func (f *serviceFielders) forService(service string) *Fielder {
+	if fielder, ok := f.fielders[service]; ok {
+		fielder.setService(service)
+		return fielder
+	}
+	fielder := f.base
+	if f.create != nil {
+		sf, err := f.create(service)
+		if err != nil {
+			// the base Fielder was made from the same specs, so this shouldn't happen
+			f.log.Error("unable to create fields for %s, using the defaults: %v\n", service, err)
+		} else {
+			sf.traceFields = f.base.traceFields
+			sf.SetProcessID(f.processID)
+			fielder = sf
+		}
+	}
+	f.fielders[service] = fielder
+	fielder.setService(service)
+	return fielder
+}
+
+// setProcessID gives all of the services' spans the same synthetic process_id.
+func (f *serviceFielders) setProcessID(pid int64) {
+	f.processID = pid
+	f.base.SetProcessID(pid)
+	for _, fielder := range f.fielders {
+		fielder.SetProcessID(pid)
+	}
+}
+
+// newFielders creates the base Fielder of a generator goroutine, and the
+// serviceFielders that give each service its own one.
+func (s *TraceGenerator) newFielders() (*serviceFielders, error) {
+	base, err := s.newFielder(s.seed)
+	if err != nil {
+		return nil, err
+	}
+	return newServiceFielders(base, s.serviceFielder, s.log), nil
+}
+
+// serviceFielder creates a service's own Fielder, seeded by its name.
+func (s *TraceGenerator) serviceFielder(service string) (*Fielder, error) {
+	return s.newFielder(s.seed + service)
+}
+
+// splitSpanCounts decides how many spans there will be at this level and
+// divides nspans among them; each entry is the number of spans in the subtree
+// of that peer, including itself. The entries always sum to nspans and each
+// one is at least 1.
+func splitSpanCounts(nspans int, depth int) []int {
+	spansAtThisLevel := 1
+	if nspans > depth {
+		// there is some chance that this level will have multiple spans based on the difference
+		// between nspans and depth. (but we'll override this if it's a root span)
+		// nspans-depth is always at least 1 here, so Intn is safe
+		spansAtThisLevel = 1 + rand.Intn(nspans-depth)
+	}
+
+	spancounts := make([]int, 0, spansAtThisLevel)
+	if spansAtThisLevel == 1 {
+		// if there's only one span, give it all the counts
+		spancounts = append(spancounts, nspans)
+	} else {
+		// split the counts among the spans at this level
+		// we take a random portion of the counts for each span, then put the leftovers in a random span
+		count := nspans
+		spansPerPeer := nspans / spansAtThisLevel // always at least 1
+		for i := 0; i < spansAtThisLevel; i++ {
+			spancounts = append(spancounts, rand.Intn(spansPerPeer)+1)
+			count -= spancounts[i]
+		}
+		// count can't go negative because no peer took more than spansPerPeer
+		spancounts[rand.Intn(spansAtThisLevel)] += count
+	}
+	return spancounts
+}
+
+// durationSampler draws the durations used to subdivide a trace from the
+// distribution chosen by --durationdist.
+type durationSampler struct {
+	rng  *lockedRng
+	dist string
+}
+
+func newDurationSampler(opts *Options) *durationSampler {
+	return &durationSampler{rng: newLockedRng(opts.Global.Seed + "durations"), dist: opts.Format.DurationDist}
+}
+
+// fraction returns a number between 0 and 1. It's uniform for "uniform"; the others
+// are mostly small with an occasional large value, capped at 1.
+func (d *durationSampler) fraction() float64 {
+	var f float64
+	switch d.dist {
+	case "uniform":
+		return d.rng.Float(0, 1)
+	case "exponential":
+		f = d.rng.Exponential(0.1)
+	default:
+		// pareto, with the shape of the 80/20 rule
+		f = d.rng.Pareto(0.02, 1.16)
+	}
+	return math.Min(f, 1)
+}
+
+// slice returns a random duration that is at most 1/n of timeRemaining;
+// it returns 0 rather than panicking if there's no time left to divide.
+func (d *durationSampler) slice(timeRemaining time.Duration, n int) time.Duration {
+	max := timeRemaining / time.Duration(n)
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(d.fraction() * float64(max))
+}
+
+// quantilePoint is a duration and the fraction of traces expected to be shorter than it.
+type quantilePoint struct {
+	q float64
+	d time.Duration
+}
+
+// quantileSampler draws durations that match a set of target percentiles. It
+// picks a uniform quantile and interpolates between the neighbouring points on
+// a log scale, which suits durations that span orders of magnitude. The ends are
+// pinned at a tenth of the lowest target and twice the highest one.
+type quantileSampler struct {
+	rng    *lockedRng
+	points []quantilePoint
+}
+
+// newRootDurations returns a sampler for --p50, --p95, and --p99, or nil if none of them are set.
+// The ones that are set must not decrease.
+func newRootDurations(opts *Options) (*quantileSampler, error) {
+	targets := []quantilePoint{
+		{0.50, opts.Format.P50},
+		{0.95, opts.Format.P95},
+		{0.99, opts.Format.P99},
+	}
+	var points []quantilePoint
+	for _, t := range targets {
+		if t.d < 0 {
+			return nil, fmt.Errorf("p%.0f duration %v can't be negative", t.q*100, t.d)
+		}
+		if t.d == 0 {
+			continue
+		}
+		if len(points) > 0 && t.d < points[len(points)-1].d {
+			return nil, fmt.Errorf("p%.0f duration %v is less than the p%.0f duration %v", t.q*100, t.d, points[len(points)-1].q*100, points[len(points)-1].d)
+		}
+		points = append(points, t)
+	}
+	if len(points) == 0 {
+		return nil, nil
+	}
+	return newQuantileSampler(opts.Global.Seed+"roots", points), nil
+}
+
+// newQuantileSampler expects at least one point, in increasing order of quantile and duration.
+func newQuantileSampler(seed string, points []quantilePoint) *quantileSampler {
+	all := make([]quantilePoint, 0, len(points)+2)
+	all = append(all, quantilePoint{0, points[0].d / 10})
+	all = append(all, points...)
+	all = append(all, quantilePoint{1, points[len(points)-1].d * 2})
+	return &quantileSampler{rng: newLockedRng(seed), points: all}
+}
+
+func (s *quantileSampler) sample() time.Duration {
+	u := s.rng.Float(0, 1)
+	for i := 1; i < len(s.points); i++ {
+		lo, hi := s.points[i-1], s.points[i]
+		if u > hi.q {
+			continue
+		}
+		if lo.d <= 0 || hi.q == lo.q {
+			return hi.d
+		}
+		frac := (u - lo.q) / (hi.q - lo.q)
+		logd := math.Log(float64(lo.d)) + frac*(math.Log(float64(hi.d))-math.Log(float64(lo.d)))
+		return time.Duration(math.Exp(logd))
+	}
+	return s.points[len(s.points)-1].d
+}
+
+// generate_spans generates a list of spans with the given depth and spancount
+// it is recursive and expects spans[0] to be the root span
+// - level is the current depth of this span where 0 is the root span
+// - depth is the maximum depth (nesting level) of a trace -- how much deeper this trace will go
+// - nspans is the number of spans in a trace.
+// If nspans is less than depth, the trace will be truncated at nspans.
+// If nspans is greater than depth, some of the children will have siblings.
+func (s *TraceGenerator) generate_spans(ctx context.Context, clock *traceClock, fielders *serviceFielders, level int, depth int, nspans int, timeRemaining time.Duration) {
+	if depth <= 0 || nspans <= 0 {
+		return
+	}
+
+	spancounts := splitSpanCounts(nspans, depth)
+	spansAtThisLevel := len(spancounts)
+
+	durationRemaining := s.durations.slice(timeRemaining, nspans+1)
+	durationPerChild := (timeRemaining - durationRemaining) / time.Duration(spansAtThisLevel)
+
+	for i := 0; i < spansAtThisLevel; i++ {
+		// a span lasts for at least the second half of its time (the first is
+		// before it starts), so one that would be shorter than --minspanduration
+		// borrows the difference from its later siblings, as long as they have
+		// it to spare
+		durationThisSpan := max(durationRemaining/time.Duration(spansAtThisLevel-i), 2*s.minSpan)
+		durationRemaining -= durationThisSpan
+		clock.sleep(durationThisSpan / 2)
+		service := fielders.base.GetServiceName(depth)
+		spanctx, times := clock.start(ctx)
+		leaf := depth == 1 || spancounts[i] == 1
+		if leaf && s.leafRate > 0 && rand.Float64() < s.leafRate {
+			spanctx = contextWithExternalCall(spanctx)
+		}
+		fielder := fielders.forService(service)
+		fielder.setLeaf(leaf)
+		childctx, span := s.tracer.CreateSpan(spanctx, service, level, fielder)
+		fielder.setLeaf(false)
+		s.generate_spans(childctx, clock, fielders, level+1, depth-1, spancounts[i]-1, durationPerChild)
+		clock.sleep(durationThisSpan / 2)
+		clock.end(times)
+		span.Send()
+	}
+}
+
+func (s *TraceGenerator) generate_root(fielders *serviceFielders, count int64, depth int, nspans int, timeRemaining time.Duration) {
+	ctx := context.Background()
+	if s.linkRate > 0 && rand.Float64() < s.linkRate {
+		if sc, ok := s.recent.pick(); ok {
+			ctx = contextWithLink(ctx, sc)
+		}
+	}
+	shape, pathological := s.pathological()
+	if pathological {
+		if shape.depth > 0 {
+			depth, nspans = shape.depth, shape.nspans
+		}
+		nspans = min(nspans, maxPathoSpans)
+	}
+	clock := newTraceClock(s.synthetic)
+	ctx, times := clock.start(ctx)
+	service := fielders.base.GetServiceName(depth)
+	rootFielder := fielders.forService(service)
+	if pathological {
+		fields := map[string]any{pathoField: shape.name}
+		if shape.huge {
+			fields[pathoAttrField] = s.oversized
+		}
+		rootFielder.setRootFields(fields)
+		defer rootFielder.setRootFields(nil)
+	}
+	ctx, root := s.tracer.CreateTrace(ctx, service, rootFielder, count)
+	if count > s.warmup {
+		s.sent.Add(1)
+	}
+	s.recent.add(trace.SpanContextFromContext(ctx))
+	if s.roots != nil {
+		// the children subdivide whatever the root gets, so they stay proportional
+		timeRemaining = s.roots.sample()
+	}
+	thisSpanDuration := max(s.durations.slice(timeRemaining, nspans+1), s.minSpan)
+	childDuration := max(timeRemaining-thisSpanDuration, 0)
+
+	clock.sleep(thisSpanDuration / 2)
+	if shape.flat {
+		// splitSpanCounts would put only some of them at the one level below
+		for i := 1; i < nspans; i++ {
+			s.generate_spans(ctx, clock, fielders, 1, 1, 1, childDuration/time.Duration(nspans-1))
+		}
+	} else {
+		s.generate_spans(ctx, clock, fielders, 1, depth-1, nspans-1, childDuration)
+	}
+	clock.sleep(thisSpanDuration / 2)
+	clock.end(times)
+	root.Send()
+}
+
+// startGenerator adds a generator goroutine. Its stop channel is added to chans
+// before it starts so that the count of generators is never behind.
+func (s *TraceGenerator) startGenerator(wg *sync.WaitGroup, counter chan int64) {
+	s.mut.Lock()
+	stop := make(chan struct{})
+	s.chans = append(s.chans, stop)
+	s.mut.Unlock()
+	wg.Add(1)
+	go s.generator(wg, counter, stop)
+}
+
+// stopGenerator stops the oldest generator goroutine; it returns false if there weren't any.
+func (s *TraceGenerator) stopGenerator() bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if len(s.chans) == 0 {
+		return false
+	}
+	close(s.chans[0])
+	s.chans = s.chans[1:]
+	return true
+}
+
+// generator is a single goroutine that generates traces and sends them to the spans channel.
+// It runs until the stop channel is closed.
+// The trace time is determined by the duration, and as soon as one trace is sent the next one is started.
+func (s *TraceGenerator) generator(wg *sync.WaitGroup, counter chan int64, stop chan struct{}) {
+	s.mut.RLock()
+	depth := s.depth
+	nspans := s.nspans
+	duration := s.duration
+	s.mut.RUnlock()
+
+	n := s.started.Add(1)
+	interval := s.interval()
+	arrivals := newArrivals(s.poisson, interval, NewRng(fmt.Sprintf("%s-arrivals-%d", s.seed, n)))
+	var pid int64
+	if len(s.processIDs) > 0 {
+		// the generators take turns, so as long as there are enough of them every process is used
+		pid = s.processIDs[(s.nextPID.Add(1)-1)%int64(len(s.processIDs))]
+	}
+	pool := &fieldersPool{create: func() *serviceFielders {
+		fielders, err := s.newFielders()
+		if err != nil {
+			s.log.Fatal("unable to create fields as specified: %s\n", err)
+		}
+		if pid != 0 {
+			fielders.setProcessID(pid)
+		}
+		return fielders
+	}}
+	// poisson arrivals don't wait for the last trace to finish, so the traces
+	// in flight are finished before the generator is
+	var inflight sync.WaitGroup
+	defer wg.Done()
+	defer inflight.Wait()
+	for {
+		select {
+		case <-stop:
+			arrivals.stop()
+			return
+		case <-arrivals.C:
+			// a lone generator's interval follows the target TPS
+			if iv := s.interval(); iv != interval {
+				interval = iv
+				arrivals.setInterval(interval)
+			}
+			arrivals.next()
+			// shed load while the sender catches up, rather than block partway through a trace
+			if s.backedUp() {
+				s.skipped.Add(1)
+				continue
+			}
+			// generate a trace if we haven't been stopped by the counter
+			select {
+			case count := <-counter:
+				fielders := pool.get()
+				if !s.poisson {
+					s.generate_root(fielders, count, depth, nspans, duration)
+					pool.put(fielders)
+					continue
+				}
+				inflight.Add(1)
+				go func() {
+					defer inflight.Done()
+					s.generate_root(fielders, count, depth, nspans, duration)
+					pool.put(fielders)
+				}()
+			default:
+				// do nothing, we're done, and the stop will be caught by the outer select
+			}
+		}
+	}
+}
+
+// fieldersPool holds a generator's serviceFielders that aren't in use. A
+// periodic generator only ever needs one, but with poisson arrivals its traces
+// overlap, and each one needs its own, since they aren't safe to share.
+type fieldersPool struct {
+	mut    sync.Mutex
+	free   []*serviceFielders
+	create func() *serviceFielders
+}
+
+func (p *fieldersPool) get() *serviceFielders {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if len(p.free) == 0 {
+		return p.create()
+	}
+	f := p.free[len(p.free)-1]
+	p.free = p.free[:len(p.free)-1]
+	return f
+}
+
+func (p *fieldersPool) put(f *serviceFielders) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.free = append(p.free, f)
+}
+
+// backedUp returns true if the sender's queue is nearly full.
+func (s *TraceGenerator) backedUp() bool {
+	qr, ok := s.tracer.(QueueReporter)
+	return ok && qr.QueueFullness() >= backpressureThreshold
+}
+
+// Skipped returns the number of traces skipped because the sender was backed up.
+func (s *TraceGenerator) Skipped() int64 {
+	return s.skipped.Load()
+}
+
+// generatorsFor is the number of generator goroutines needed for the TPS when
+// each trace takes duration. If that's less than one, a single generator runs
+// and idles between traces (see TraceGenerator.interval).
+func generatorsFor(tps float64, duration time.Duration) int {
+	if tps <= 0 {
+		return 0
+	}
+	return max(1, int(tps*duration.Seconds()+0.5)) // make sure we don't get bit by floating point rounding
+}
+
+// targetGenerators is the number of generator goroutines needed for the target
+// TPS, or 0 during the off phase of a burst.
+func (s *TraceGenerator) targetGenerators() int {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	if !s.burst.on(time.Now()) {
+		return 0
+	}
+	return generatorsFor(s.tps, s.busy)
+}
+
+// interval is the time between the starts of each generator's traces: the
+// trace time, unless the TPS is too low for even one generator to be busy
+// (as it always is with --synthetictime), in which case the single generator
+// starts a trace every 1/TPS.
+func (s *TraceGenerator) interval() time.Duration {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	if s.tps > 0 && s.tps*s.busy.Seconds() < 1 {
+		return time.Duration(float64(time.Second) / s.tps)
+	}
+	return s.duration
+}
+
+func (s *TraceGenerator) activeGenerators() int {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return len(s.chans)
+}
+
+// Generate starts generator goroutines over the ramp time, in the shape chosen by
+// --rampshape, until there are enough for the target TPS, runs until the runtime
+// expires, and then stops them one per tick. If the target changes while running,
+// generators are added or removed one per tick.
+func (s *TraceGenerator) Generate(opts *Options, wg *sync.WaitGroup, stop chan struct{}, counter chan int64) {
+	defer wg.Done()
+	s.mut.Lock()
+	s.burst.start = time.Now()
+	rampStart := s.burst.start
+	s.mut.Unlock()
+	defer func() {
+		if skipped := s.skipped.Load(); skipped > 0 {
+			s.log.Warn("skipped %d traces because the sender's queue was nearly full\n", skipped)
+		}
+		// without sleeping, the rate is what's being measured
+		if elapsed := time.Since(rampStart); s.synthetic && elapsed > 0 {
+			sent := s.sent.Load()
+			s.log.Warn("generated %d traces in %v, %.1f per second\n", sent, elapsed.Round(time.Millisecond), float64(sent)/elapsed.Seconds())
+		}
+	}()
+	ngenerators := float64(s.targetGenerators())
+	uSgeneratorInterval := float64(opts.Quantity.RampTime.Microseconds()) / ngenerators
+	generatorInterval := time.Duration(uSgeneratorInterval) * time.Microsecond
+	if generatorInterval <= 0 {
+		// there's no ramp time (or no generators yet), but a ticker needs an interval
+		generatorInterval = time.Millisecond
+	}
+
+	s.log.Info("ngenerators: %f interval: %s\n", ngenerators, generatorInterval)
+	if interval := s.interval(); s.synthetic {
+		s.log.Info("with synthetic time, one generator will start a trace every %s\n", interval)
+	} else if interval != opts.Format.TraceTime {
+		s.log.Info("the TPS is less than one trace per trace time, so one generator will start a trace every %s\n", interval)
+	}
+	state := Starting
+
+	ticker := time.NewTicker(generatorInterval)
+	defer ticker.Stop()
+
+	// Create a long timer but stop it immediately so that we have a valid channel.
+	// We'll Reset it in the Starting state if they specified a max time.
+	stopTimer := time.NewTimer(time.Hour)
+	stopTimer.Stop()
+
+	for {
+		select {
+		case <-stop:
+			s.log.Info("stopping generators from stop signal\n")
+			state = Stopping
+			s.mut.Lock()
+			for _, ch := range s.chans {
+				close(ch)
+			}
+			s.mut.Unlock()
+			return
+		case <-ticker.C:
+			switch state {
+			case Starting:
+				target := s.targetGenerators()
+				if s.activeGenerators() >= target {
+					s.log.Info("all generators started, switching to Running state\n")
+					// if they want a timer, start it now
+					if opts.Quantity.RunTime > 0 {
+						// could have used AfterFunc, but we're already in a goroutine with a select
+						// and it would have required a mutex to protect the state
+						stopTimer.Reset(opts.Quantity.RunTime)
+						defer stopTimer.Stop()
+					}
+					// and change to run state
+					state = Running
+				} else {
+					// follow the ramp's shape, which can call for several at once
+					for want := s.rampTarget(time.Since(rampStart), opts.Quantity.RampTime, target); s.activeGenerators() < want; {
+						s.log.Debug("starting new generator\n")
+						s.startGenerator(wg, counter)
+					}
+				}
+			case Running:
+				// follow any change to the target TPS, and the bursts
+				active, target := s.activeGenerators(), s.targetGenerators()
+				if active < target {
+					s.log.Debug("starting new generator for new TPS\n")
+					s.startGenerator(wg, counter)
+				} else if active > target {
+					s.log.Debug("killing off a generator for new TPS\n")
+					s.stopGenerator()
+				}
+			case Stopping:
+				if !s.stopGenerator() {
+					closeStop(stop)
+					return
+				}
+				s.log.Debug("killing off a generator\n")
+			}
+		case <-stopTimer.C:
+			s.log.Info("stopping generators from timer\n")
+			state = Stopping
+		}
+	}
+}
+
+// SetTPS changes the target rate; Generate adds or removes generators to match.
+func (s *TraceGenerator) SetTPS(tps float64) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.tps = tps
+}
+
+// Status reports the target rate, the number of generator goroutines, and the number of traces sent.
+func (s *TraceGenerator) Status() GeneratorStatus {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return GeneratorStatus{TPS: s.tps, Generators: len(s.chans), Traces: s.sent.Load()}
+}
+
+func (s *TraceGenerator) TPS() float64 {
+	return 1.0 / s.interval().Seconds()
+}
@@ -0,0 +1,153 @@
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"pgregory.net/rand"
+)
+
+// severities maps the level names accepted by --severitydist to OTLP severity
+// numbers; each is the first number of its range of four.
+var severities = map[string]int{
+	"trace": 1,
+	"debug": 5,
+	"info":  9,
+	"warn":  13,
+	"error": 17,
+	"fatal": 21,
+}
+
+// severityNames are the OTLP severity texts, one per range of four numbers, in order.
+var severityNames = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// severityText returns the OTLP severity text of a severity number: the name of
+// the range it's in, so that a number between the named levels (like 10) has
+// the text of the nearest lower one (INFO). The numbers run from 1 to 24; 0
+// is unspecified.
+func severityText(n int) string {
+	if n < 1 {
+		return "UNSPECIFIED"
+	}
+	return severityNames[min((n-1)/4, len(severityNames)-1)]
+}
+
+// connectives are used to join the two halves of a generated log message
+var connectives = []string{"in", "near", "under", "with", "without", "for", "after", "before"}
+
+// Sentence returns a short, vaguely log-like message built from the word lists.
+func (r Rng) Sentence() string {
+	first := r.Choice(adjectives)
+	return strings.ToUpper(first[:1]) + first[1:] + " " + r.Choice(nouns) + " " +
+		r.Choice(connectives) + " " + r.Choice(adjectives) + " " + r.Choice(nouns)
+}
+
+// getSeverityGen parses a list of level:weight pairs (like info:80,error:20) and
+// returns a generator that chooses a severity number and its text according to
+// the weights. A level can also be a severity number from 1 to 24 (like 10:5).
+func getSeverityGen(rng Rng, spec string) (func() (int, string), error) {
+	numbers := make(map[string]int)
+	for _, pair := range strings.Split(spec, ",") {
+		level, _, _ := strings.Cut(pair, ":")
+		level = strings.TrimSpace(level)
+		if n, ok := severities[level]; ok {
+			numbers[level] = n
+			continue
+		}
+		n, err := strconv.Atoi(level)
+		if err != nil {
+			return nil, fmt.Errorf("unknown severity level %q", level)
+		}
+		if n < 1 || n > 24 {
+			return nil, fmt.Errorf("severity number %d must be from 1 to 24", n)
+		}
+		numbers[level] = n
+	}
+	levels, err := getEnumGen(rng, spec)
+	if err != nil {
+		return nil, err
+	}
+	return func() (int, string) {
+		n := numbers[levels().(string)]
+		return n, severityText(n)
+	}, nil
+}
+
+// randomTraceIDs returns a random trace and span id, like the ones an SDK would create.
+func randomTraceIDs() (trace.TraceID, trace.SpanID) {
+	var tid trace.TraceID
+	var sid trace.SpanID
+	for i := range tid {
+		tid[i] = byte(rand.Intn(256))
+	}
+	for i := range sid {
+		sid[i] = byte(rand.Intn(256))
+	}
+	return tid, sid
+}
+
+// LogGenerator generates log records instead of traces. Each record gets a
+// generated message, a severity drawn from the --severitydist distribution,
+// and a random trace and span id so that logs-to-traces linking can be tested.
+type LogGenerator struct {
+	tps      float64
+	fielder  *Fielder
+	rng      Rng
+	severity func() (int, string)
+	log      Logger
+	sender   LogSender
+}
+
+// make sure it implements Generator
+var _ Generator = (*LogGenerator)(nil)
+
+func NewLogGenerator(lsender LogSender, fielder *Fielder, log Logger, opts *Options) (*LogGenerator, error) {
+	rng := NewRng(opts.Global.Seed)
+	severity, err := getSeverityGen(rng, opts.Format.SeverityDist)
+	if err != nil {
+		return nil, fmt.Errorf("invalid severity distribution %s: %w", opts.Format.SeverityDist, err)
+	}
+	return &LogGenerator{
+		tps:      float64(opts.Quantity.TPS),
+		fielder:  fielder,
+		rng:      rng,
+		severity: severity,
+		log:      log,
+		sender:   lsender,
+	}, nil
+}
+
+func (l *LogGenerator) record(count int64) LogRecord {
+	tid, sid := randomTraceIDs()
+	severityNumber, severityText := l.severity()
+	return LogRecord{
+		Time:           time.Now(),
+		TraceID:        tid,
+		SpanID:         sid,
+		SeverityNumber: severityNumber,
+		SeverityText:   severityText,
+		Body:           l.rng.Sentence(),
+		Fields:         l.fielder.GetFields(count, 0),
+	}
+}
+
+// Generate sends a log record every 1/TPS seconds until the stop channel is
+// closed, the counter stops handing out counts, or the runtime expires.
+func (l *LogGenerator) Generate(opts *Options, wg *sync.WaitGroup, stop chan struct{}, counter chan int64) {
+	defer wg.Done()
+	ctx := context.Background()
+	runAtTPS(l.log, l.tps, opts.Quantity.RunTime, stop, counter, func(count int64) {
+		service := l.fielder.GetServiceName(int(count))
+		l.fielder.setService(service)
+		l.sender.SendLog(ctx, service, l.record(count))
+	})
+}
+
+func (l *LogGenerator) TPS() float64 {
+	return l.tps
+}
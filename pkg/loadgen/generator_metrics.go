@@ -0,0 +1,107 @@
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MetricGenerator generates metric data points instead of traces. Each tick
+// records one data point per numeric field from the Fielder, attributed to one
+// of the Fielder's service names, so the --depth option controls the number of
+// distinct services (and therefore the cardinality of the series).
+type MetricGenerator struct {
+	tps     float64
+	fielder *Fielder
+	log     Logger
+	sender  MetricSender
+}
+
+// make sure it implements Generator
+var _ Generator = (*MetricGenerator)(nil)
+
+// maxMetricHistTotal is the largest /hist total the metrics signal takes. The
+// otel API can't record a bucket's count at once, so each data point records
+// every one of the values a /hist field counts.
+const maxMetricHistTotal = 1000
+
+// checkMetricHists returns an error for a /hist field whose total is more than
+// maxMetricHistTotal.
+func checkMetricHists(userfields map[string]string) error {
+	for name, value := range userfields {
+		matches := wordfield.FindStringSubmatch(value)
+		if matches == nil || matches[1] != "hist" {
+			continue
+		}
+		if total, _, err := parseHistArgs(matches[2]); err == nil && total > maxMetricHistTotal {
+			return fmt.Errorf("the /hist total of %s must not be more than %d for metrics, not %d", name, maxMetricHistTotal, total)
+		}
+	}
+	return nil
+}
+
+func NewMetricGenerator(msender MetricSender, fielder *Fielder, log Logger, opts *Options) *MetricGenerator {
+	return &MetricGenerator{
+		tps:     float64(opts.Quantity.TPS),
+		fielder: fielder,
+		log:     log,
+		sender:  msender,
+	}
+}
+
+// Generate records a data point every 1/TPS seconds until the stop channel is
+// closed, the counter stops handing out counts, or the runtime expires.
+func (m *MetricGenerator) Generate(opts *Options, wg *sync.WaitGroup, stop chan struct{}, counter chan int64) {
+	defer wg.Done()
+	ctx := context.Background()
+	runAtTPS(m.log, m.tps, opts.Quantity.RunTime, stop, counter, func(count int64) {
+		service := m.fielder.GetServiceName(int(count))
+		m.fielder.setService(service)
+		m.sender.RecordMetrics(ctx, service, m.fielder, count)
+	})
+}
+
+// runAtTPS calls emit once every 1/tps seconds with a count from the counter until
+// the stop channel is closed, the counter stops handing out counts, or the runtime
+// (if any) expires, in which case it closes stop itself. It's used by the generators
+// whose output doesn't have any duration, unlike traces.
+func runAtTPS(log Logger, tps float64, runtime time.Duration, stop chan struct{}, counter chan int64, emit func(count int64)) {
+	if tps <= 0 {
+		log.Error("a TPS of at least 1 is required\n")
+		return
+	}
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / tps))
+	defer ticker.Stop()
+
+	// as in TraceGenerator, a stopped timer gives us a valid channel if there's no runtime
+	stopTimer := time.NewTimer(time.Hour)
+	stopTimer.Stop()
+	if runtime > 0 {
+		stopTimer.Reset(runtime)
+		defer stopTimer.Stop()
+	}
+
+	for {
+		select {
+		case <-stop:
+			log.Info("stopping generator from stop signal\n")
+			return
+		case <-stopTimer.C:
+			log.Info("stopping generator from timer\n")
+			closeStop(stop)
+			return
+		case <-ticker.C:
+			select {
+			case count := <-counter:
+				emit(count)
+			default:
+				// the counter is done; the stop will be caught by the outer select
+			}
+		}
+	}
+}
+
+func (m *MetricGenerator) TPS() float64 {
+	return m.tps
+}
@@ -0,0 +1,958 @@
+package loadgen
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingSender is a Sender that just remembers what it was asked to create.
+type recordingSender struct {
+	mut    sync.Mutex
+	traces int
+	spans  []int            // the level of each span created (0 for roots)
+	roots  []map[string]any // the fields of each root span
+}
+
+var _ Sender = (*recordingSender)(nil)
+
+func (r *recordingSender) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.traces++
+	r.spans = append(r.spans, 0)
+	r.roots = append(r.roots, fielder.GetFields(count, 0))
+	return ctx, DummySendable{}
+}
+
+func (r *recordingSender) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.spans = append(r.spans, level)
+	return ctx, DummySendable{}
+}
+
+func (r *recordingSender) Close() {}
+
+func newTestFielder(t testing.TB, depth int) *Fielder {
+	t.Helper()
+	fielder, err := NewFielder("test", map[string]string{}, 0, depth, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fielder
+}
+
+func Test_splitSpanCounts(t *testing.T) {
+	tests := []struct {
+		name   string
+		nspans int
+		depth  int
+	}{
+		{"fewer spans than depth", 2, 5},
+		{"spans equal depth", 3, 3},
+		{"one more span than depth", 4, 3},
+		{"many more spans than depth", 50, 3},
+		{"single span", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 1000; i++ {
+				counts := splitSpanCounts(tt.nspans, tt.depth)
+				total := 0
+				for _, c := range counts {
+					if c < 1 {
+						t.Fatalf("got a span count of %d in %v", c, counts)
+					}
+					total += c
+				}
+				if total != tt.nspans {
+					t.Fatalf("counts %v sum to %d, expected %d", counts, total, tt.nspans)
+				}
+			}
+		})
+	}
+}
+
+func TestTraceGenerator_generate_root(t *testing.T) {
+	tests := []struct {
+		name     string
+		nspans   int
+		depth    int
+		duration time.Duration
+	}{
+		{"fewer spans than depth", 2, 5, time.Millisecond},
+		{"spans equal depth", 3, 3, time.Millisecond},
+		{"one more span than depth", 4, 3, time.Millisecond},
+		{"no spans", 0, 3, time.Millisecond},
+		{"no duration", 10, 3, 0},
+		{"tiny duration", 10, 3, 5 * time.Nanosecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &recordingSender{}
+			opts := newOptions()
+			opts.Format.Depth = tt.depth
+			opts.Format.NSpans = tt.nspans
+			opts.Format.TraceTime = tt.duration
+			gen, _ := NewTraceGenerator(sender, nil, NewLogger(0), opts)
+			for i := 0; i < 20; i++ {
+				gen.generate_root(newServiceFielders(newTestFielder(t, tt.depth), gen.serviceFielder, gen.log), 1, tt.depth, tt.nspans, tt.duration)
+			}
+			maxSpans := tt.nspans
+			if maxSpans < 1 {
+				maxSpans = 1
+			}
+			if sender.traces != 20 {
+				t.Errorf("expected 20 traces, got %d", sender.traces)
+			}
+			if len(sender.spans) > 20*maxSpans {
+				t.Errorf("expected at most %d spans, got %d", 20*maxSpans, len(sender.spans))
+			}
+			for _, level := range sender.spans {
+				if level >= tt.depth && level != 0 {
+					t.Errorf("span at level %d exceeds depth %d", level, tt.depth)
+				}
+			}
+		})
+	}
+}
+
+func TestTraceGenerator_serviceFields(t *testing.T) {
+	opts := newOptions()
+	opts.Global.Seed = "test"
+	opts.Format.Depth = 3
+	opts.Format.Extra = 8
+	opts.Format.AttributesPerSpan = 8
+	keysFor := func(service string) []string {
+		gen, err := NewTraceGenerator(&recordingSender{}, nil, NewLogger(0), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fielders := newServiceFielders(newTestFielder(t, 3), gen.serviceFielder, gen.log)
+		fielder := fielders.forService(service)
+		if fielders.forService(service) != fielder {
+			t.Errorf("expected %s to keep its fielder", service)
+		}
+		keys := make([]string, len(fielder.keys))
+		copy(keys, fielder.keys)
+		sort.Strings(keys)
+		return keys
+	}
+
+	a, b := keysFor("svcA"), keysFor("svcB")
+	if reflect.DeepEqual(a, b) {
+		t.Errorf("expected different fields for different services, got %v for both", a)
+	}
+	if again := keysFor("svcA"); !reflect.DeepEqual(a, again) {
+		t.Errorf("expected the same fields for the same service, got %v and %v", a, again)
+	}
+}
+
+func TestTraceGenerator_processes(t *testing.T) {
+	opts := newOptions()
+	opts.Global.Seed = "test"
+	opts.Format.Depth = 1
+	opts.Format.NSpans = 1
+	opts.Format.TraceTime = 20 * time.Millisecond
+	opts.Format.Processes = 3
+	opts.Quantity.TPS = 250 // 5 generators
+	opts.Quantity.TraceCount = 100
+	opts.Quantity.RampTime = time.Millisecond
+	sender := &recordingSender{}
+	gen, err := NewTraceGenerator(sender, func(string) (*Fielder, error) { return newTestFielder(t, 1), nil }, NewLogger(0), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runGenerator(gen, opts)
+
+	pids := make(map[int64]bool)
+	for _, fields := range sender.roots {
+		pid := fields["process_id"].(int64)
+		if pid == getProcessID() {
+			t.Fatalf("expected a synthetic process_id, got the real one")
+		}
+		pids[pid] = true
+	}
+	if len(pids) != opts.Format.Processes {
+		t.Errorf("expected %d distinct process_ids, got %v", opts.Format.Processes, pids)
+	}
+
+	opts.Format.Processes = -1
+	if _, err := NewTraceGenerator(sender, nil, NewLogger(0), opts); err == nil {
+		t.Error("expected an error for negative processes")
+	}
+}
+
+func TestTraceGenerator_links(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background())
+	sender := &SenderOTel{tracer: tp.Tracer("test")}
+
+	opts := newOptions()
+	opts.Format.Depth = 1
+	opts.Format.NSpans = 1
+	opts.Format.LinkRate = 0.5
+	gen, _ := NewTraceGenerator(sender, nil, NewLogger(0), opts)
+	fielders := newServiceFielders(newTestFielder(t, 1), gen.serviceFielder, gen.log)
+	const ntraces = 1000
+	for i := 0; i < ntraces; i++ {
+		gen.generate_root(fielders, int64(i+1), 1, 1, 0)
+	}
+
+	seen := map[trace.TraceID]bool{}
+	linked := 0
+	// the syncer exports spans in the order they ended, which is creation order here
+	for _, span := range exporter.GetSpans() {
+		for _, link := range span.Links {
+			linked++
+			if !seen[link.SpanContext.TraceID()] {
+				t.Errorf("link references trace %s which wasn't generated earlier", link.SpanContext.TraceID())
+			}
+			if len(link.Attributes) != 1 || link.Attributes[0].Value.AsString() != linkRelationship {
+				t.Errorf("unexpected link attributes %v", link.Attributes)
+			}
+		}
+		seen[span.SpanContext.TraceID()] = true
+	}
+	if linked < ntraces*4/10 || linked > ntraces*6/10 {
+		t.Errorf("expected about %d linked roots, got %d", ntraces/2, linked)
+	}
+
+	for _, rate := range []float64{-0.1, 1.5} {
+		opts.Format.LinkRate = rate
+		if _, err := NewTraceGenerator(sender, nil, NewLogger(0), opts); err == nil {
+			t.Errorf("expected an error for a linkrate of %v", rate)
+		}
+	}
+}
+
+// spanTimesSender keeps the synthetic times of every span.
+type spanTimesSender struct {
+	recordingSender
+	times []*spanTimes
+}
+
+func (s *spanTimesSender) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	s.times = append(s.times, spanTimesFromContext(ctx))
+	return s.recordingSender.CreateTrace(ctx, name, fielder, count)
+}
+
+func (s *spanTimesSender) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	s.times = append(s.times, spanTimesFromContext(ctx))
+	return s.recordingSender.CreateSpan(ctx, name, level, fielder)
+}
+
+func TestTraceGenerator_minSpanDuration(t *testing.T) {
+	// the shortest span of 20 traces of up to 50 spans in 10ms
+	const depth = 5
+	shortest := func(floor time.Duration) time.Duration {
+		sender := &spanTimesSender{}
+		opts := newOptions()
+		opts.Format.Depth = depth
+		opts.Format.NSpans = 50
+		opts.Format.TraceTime = 10 * time.Millisecond
+		opts.Format.SyntheticTime = true
+		opts.Format.MinSpanDuration = floor
+		gen, err := NewTraceGenerator(sender, nil, NewLogger(0), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 20; i++ {
+			gen.generate_root(newServiceFielders(newTestFielder(t, depth), gen.serviceFielder, gen.log), 1, depth, 50, opts.Format.TraceTime)
+		}
+		if len(sender.times) < 20*depth {
+			t.Fatalf("expected at least %d spans, got %d", 20*depth, len(sender.times))
+		}
+		least := time.Duration(math.MaxInt64)
+		for _, times := range sender.times {
+			least = min(least, times.end.Sub(times.start))
+		}
+		return least
+	}
+
+	if d := shortest(0); d >= time.Millisecond {
+		t.Errorf("expected some spans shorter than 1ms without a floor, got %v", d)
+	}
+	if d := shortest(time.Millisecond); d < time.Millisecond {
+		t.Errorf("expected no span shorter than 1ms, got %v", d)
+	}
+
+	opts := newOptions()
+	opts.Format.MinSpanDuration = -time.Millisecond
+	if _, err := NewTraceGenerator(&recordingSender{}, nil, NewLogger(0), opts); err == nil {
+		t.Errorf("expected an error for a negative minspanduration")
+	}
+}
+
+func TestTraceGenerator_pathological(t *testing.T) {
+	const ntraces, rate = 300, 0.1
+	sender := &recordingSender{}
+	opts := newOptions()
+	opts.Format.Depth = 3
+	opts.Format.NSpans = 3
+	opts.Format.TraceTime = time.Second
+	opts.Format.SyntheticTime = true
+	opts.Format.PathoRate = rate
+	gen, err := NewTraceGenerator(sender, nil, NewLogger(0), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < ntraces; i++ {
+		gen.generate_root(newServiceFielders(newTestFielder(t, opts.Format.Depth), gen.serviceFielder, gen.log), 1, opts.Format.Depth, opts.Format.NSpans, opts.Format.TraceTime)
+	}
+
+	// the spans of each trace follow its root
+	var spans, deepest []int
+	for _, level := range sender.spans {
+		if level == 0 {
+			spans = append(spans, 0)
+			deepest = append(deepest, 0)
+		}
+		spans[len(spans)-1]++
+		deepest[len(deepest)-1] = max(deepest[len(deepest)-1], level)
+	}
+	shapes := make(map[string]int)
+	for i, root := range sender.roots {
+		shape, ok := root[pathoField].(string)
+		if !ok {
+			if spans[i] > opts.Format.NSpans || deepest[i] >= opts.Format.Depth {
+				t.Errorf("expected a normal trace, got %d spans %d deep", spans[i], deepest[i]+1)
+			}
+			continue
+		}
+		shapes[shape]++
+		switch shape {
+		case "deep":
+			if deepest[i] != pathoDepth-1 {
+				t.Errorf("expected a deep trace to be %d levels deep, got %d", pathoDepth, deepest[i]+1)
+			}
+		case "wide":
+			if spans[i] != pathoWidth || deepest[i] != 1 {
+				t.Errorf("expected a wide trace to have %d spans under its root, got %d spans %d deep", pathoWidth, spans[i], deepest[i]+1)
+			}
+		case "huge":
+			if v, _ := root[pathoAttrField].(string); len(v) != pathoAttrLen {
+				t.Errorf("expected a huge trace to have a %d byte attribute, got %d bytes", pathoAttrLen, len(v))
+			}
+		default:
+			t.Errorf("unexpected shape %s", shape)
+		}
+	}
+	total := shapes["deep"] + shapes["wide"] + shapes["huge"]
+	if expected := ntraces * rate; float64(total) < expected/3 || float64(total) > expected*2 {
+		t.Errorf("expected about %.0f pathological traces, got %d: %v", expected, total, shapes)
+	}
+	for _, shape := range pathoShapes {
+		if shapes[shape.name] == 0 {
+			t.Errorf("expected some %s traces, got %v", shape.name, shapes)
+		}
+	}
+
+	opts.Format.PathoRate = 1.5
+	if _, err := NewTraceGenerator(&recordingSender{}, nil, NewLogger(0), opts); err == nil {
+		t.Errorf("expected an error for a pathorate over 1")
+	}
+}
+
+func TestTraceGenerator_syntheticSpeed(t *testing.T) {
+	// without sleeping, a trace of a second takes no time at all to make
+	const ntraces = 200
+	sender := &recordingSender{}
+	opts := newOptions()
+	opts.Format.Depth = 3
+	opts.Format.NSpans = 10
+	opts.Format.TraceTime = time.Second
+	opts.Format.SyntheticTime = true
+	gen, err := NewTraceGenerator(sender, nil, NewLogger(0), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fielders := newServiceFielders(newTestFielder(t, opts.Format.Depth), gen.serviceFielder, gen.log)
+	begin := time.Now()
+	for i := 0; i < ntraces; i++ {
+		gen.generate_root(fielders, int64(i+1), opts.Format.Depth, opts.Format.NSpans, opts.Format.TraceTime)
+	}
+	if perTrace := time.Since(begin) / ntraces; perTrace >= time.Millisecond {
+		t.Errorf("expected each trace to take less than 1ms to make, took %v", perTrace)
+	}
+	if sender.traces != ntraces {
+		t.Errorf("expected %d traces, got %d", ntraces, sender.traces)
+	}
+}
+
+// recordingMetricSender counts the data points it's asked to record.
+type recordingMetricSender struct {
+	mut        sync.Mutex
+	datapoints int
+	services   map[string]int
+}
+
+func (r *recordingMetricSender) RecordMetrics(ctx context.Context, service string, fielder *Fielder, count int64) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.datapoints++
+	r.services[service]++
+}
+
+func TestMetricGenerator_Generate(t *testing.T) {
+	sender := &recordingMetricSender{services: map[string]int{}}
+	opts := newOptions()
+	// with no count, the runtime stops it, after TPS x runtime data points
+	opts.Quantity.TPS = 200
+	opts.Quantity.RunTime = 250 * time.Millisecond
+	gen := NewMetricGenerator(sender, newTestFielder(t, 3), NewLogger(0), opts)
+
+	start := time.Now()
+	runGenerator(gen, opts)
+	elapsed := time.Since(start)
+
+	// a ticker drops ticks rather than catching up, so a slow machine sends fewer
+	if sender.datapoints > 50 || sender.datapoints < 30 {
+		t.Errorf("expected about 50 data points in %v at %d TPS, got %d", opts.Quantity.RunTime, opts.Quantity.TPS, sender.datapoints)
+	}
+	if elapsed < opts.Quantity.RunTime || elapsed > 4*opts.Quantity.RunTime {
+		t.Errorf("expected to run for %v, ran for %v", opts.Quantity.RunTime, elapsed)
+	}
+	if len(sender.services) < 2 {
+		t.Errorf("expected data points from several services, got %v", sender.services)
+	}
+}
+
+func TestCheckMetricHists(t *testing.T) {
+	if err := checkMetricHists(map[string]string{"ok": "/hist 1000", "default": "/hist", "other": "/i"}); err != nil {
+		t.Error(err)
+	}
+	// each data point records every value, so a big total is rejected
+	if err := checkMetricHists(map[string]string{"1.big": "/hist 1000000"}); err == nil {
+		t.Error("expected an error for a /hist total over the limit")
+	}
+}
+
+// runGenerator runs gen the way main does, with a trace counter limited by opts.
+func runGenerator(gen Generator, opts *Options) {
+	stop := make(chan struct{})
+	counter := make(chan int64)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		if _, stopped := TraceCounter(NewLogger(0), opts.Quantity.TraceCount, 0, counter, stop); !stopped {
+			time.Sleep(20 * time.Millisecond)
+			closeStop(stop)
+		}
+	}()
+	go gen.Generate(opts, wg, stop, counter)
+	wg.Wait()
+}
+
+// recordingLogSender remembers the log records it's asked to send.
+type recordingLogSender struct {
+	mut     sync.Mutex
+	records []LogRecord
+}
+
+func (r *recordingLogSender) SendLog(ctx context.Context, service string, record LogRecord) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.records = append(r.records, record)
+}
+
+func TestLogGenerator_Generate(t *testing.T) {
+	sender := &recordingLogSender{}
+	opts := newOptions()
+	opts.Quantity.TPS = 200
+	opts.Quantity.TraceCount = 30
+	opts.Format.SeverityDist = "info:70,error:30"
+	gen, err := NewLogGenerator(sender, newTestFielder(t, 3), NewLogger(0), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runGenerator(gen, opts)
+
+	if len(sender.records) != 30 {
+		t.Fatalf("expected 30 log records, got %d", len(sender.records))
+	}
+	for _, rec := range sender.records {
+		if !rec.TraceID.IsValid() || !rec.SpanID.IsValid() {
+			t.Errorf("record is missing trace or span id: %+v", rec)
+		}
+		if !(rec.SeverityNumber == 9 && rec.SeverityText == "INFO") && !(rec.SeverityNumber == 17 && rec.SeverityText == "ERROR") {
+			t.Errorf("unexpected severity %d %s", rec.SeverityNumber, rec.SeverityText)
+		}
+		if rec.Body == "" {
+			t.Errorf("record has an empty body")
+		}
+	}
+}
+
+func Test_getSeverityGen(t *testing.T) {
+	for _, spec := range []string{"info:1,loud:2", "", "info:-1", "0:1", "25:1"} {
+		if _, err := getSeverityGen(NewRng("test"), spec); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+
+	gen, err := getSeverityGen(NewRng("test"), "fatal:1,10:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[int]string)
+	for i := 0; i < 100; i++ {
+		n, text := gen()
+		seen[n] = text
+	}
+	if want := map[int]string{21: "FATAL", 10: "INFO"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("expected %v, got %v", want, seen)
+	}
+}
+
+func Test_severityText(t *testing.T) {
+	tests := map[int]string{
+		0: "UNSPECIFIED", 1: "TRACE", 4: "TRACE", 5: "DEBUG", 8: "DEBUG", 9: "INFO", 12: "INFO",
+		13: "WARN", 16: "WARN", 17: "ERROR", 20: "ERROR", 21: "FATAL", 24: "FATAL",
+	}
+	for n, want := range tests {
+		if got := severityText(n); got != want {
+			t.Errorf("expected severity %d to be %s, got %s", n, want, got)
+		}
+	}
+	for name, n := range severities {
+		if got := severityText(n); got != strings.ToUpper(name) {
+			t.Errorf("expected %s (%d) to be %s, got %s", name, n, strings.ToUpper(name), got)
+		}
+	}
+}
+
+func TestDurationSampler_tail(t *testing.T) {
+	// ratio returns p99/p50 of the durations sliced from many traces
+	ratio := func(dist string) float64 {
+		opts := newOptions()
+		opts.Format.DurationDist = dist
+		d := newDurationSampler(opts)
+		const n = 10000
+		durations := make([]time.Duration, n)
+		for i := range durations {
+			durations[i] = d.slice(time.Second, 1)
+			if durations[i] < 0 || durations[i] > time.Second {
+				t.Fatalf("%s slice %v is outside the time remaining", dist, durations[i])
+			}
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		return float64(durations[n*99/100]) / float64(durations[n/2])
+	}
+	uniform, exponential, pareto := ratio("uniform"), ratio("exponential"), ratio("pareto")
+	if uniform > 2.5 {
+		t.Errorf("uniform p99/p50 is %.1f, expected about 2", uniform)
+	}
+	if exponential < 2*uniform {
+		t.Errorf("exponential p99/p50 is %.1f, expected well above uniform's %.1f", exponential, uniform)
+	}
+	if pareto < 5*uniform {
+		t.Errorf("pareto p99/p50 is %.1f, expected much more than uniform's %.1f", pareto, uniform)
+	}
+}
+
+func TestQuantileSampler_percentiles(t *testing.T) {
+	opts := newOptions()
+	opts.Format.P50 = 200 * time.Millisecond
+	opts.Format.P95 = 800 * time.Millisecond
+	opts.Format.P99 = 2 * time.Second
+	roots, err := newRootDurations(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 20000
+	durations := make([]time.Duration, n)
+	for i := range durations {
+		durations[i] = roots.sample()
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	for _, target := range []struct {
+		q float64
+		d time.Duration
+	}{{0.50, opts.Format.P50}, {0.95, opts.Format.P95}, {0.99, opts.Format.P99}} {
+		got := durations[int(target.q*n)]
+		if ratio := float64(got) / float64(target.d); ratio < 0.9 || ratio > 1.1 {
+			t.Errorf("p%.0f is %v, expected within 10%% of %v", target.q*100, got, target.d)
+		}
+	}
+}
+
+func Test_newRootDurations(t *testing.T) {
+	opts := newOptions()
+	if roots, err := newRootDurations(opts); roots != nil || err != nil {
+		t.Errorf("expected no sampler without percentiles, got %v, %v", roots, err)
+	}
+	opts.Format.P50 = time.Second
+	opts.Format.P95 = 500 * time.Millisecond
+	if _, err := newRootDurations(opts); err == nil {
+		t.Errorf("expected an error when p95 is less than p50")
+	}
+	opts.Format.P95 = 0
+	opts.Format.P99 = 3 * time.Second
+	if roots, err := newRootDurations(opts); roots == nil || err != nil {
+		t.Errorf("expected a sampler for p50 and p99, got %v, %v", roots, err)
+	}
+}
+
+// timedSender records when each trace starts.
+type timedSender struct {
+	recordingSender
+	starts []time.Time
+}
+
+func (s *timedSender) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	s.mut.Lock()
+	s.starts = append(s.starts, time.Now())
+	s.mut.Unlock()
+	return ctx, DummySendable{}
+}
+
+func TestTraceGenerator_burst(t *testing.T) {
+	opts := newOptions()
+	opts.Format.Depth = 1
+	opts.Format.NSpans = 1
+	opts.Format.TraceTime = 10 * time.Millisecond
+	opts.Quantity.TPS = 400
+	opts.Quantity.RampTime = time.Millisecond
+	opts.Quantity.Burst = true
+	opts.Quantity.BurstPeriod = 200 * time.Millisecond
+	opts.Quantity.BurstDuty = 0.25
+	sender := &timedSender{}
+	gen, err := NewTraceGenerator(sender, func(string) (*Fielder, error) { return newTestFielder(t, 1), nil }, NewLogger(0), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	counter := make(chan int64)
+	wg := &sync.WaitGroup{}
+	go TraceCounter(NewLogger(0), 0, 0, counter, stop)
+	wg.Add(1)
+	go gen.Generate(opts, wg, stop, counter)
+	time.Sleep(5 * opts.Quantity.BurstPeriod)
+	closeStop(stop)
+	wg.Wait()
+
+	// the generators take a tick or two to follow the envelope, so ignore the edges of each phase
+	sender.mut.Lock()
+	defer sender.mut.Unlock()
+	var on, off int
+	for _, ts := range sender.starts {
+		phase := ts.Sub(gen.burst.start) % opts.Quantity.BurstPeriod
+		switch {
+		case phase > 5*time.Millisecond && phase < 45*time.Millisecond:
+			on++
+		case phase > 60*time.Millisecond && phase < 195*time.Millisecond:
+			off++
+		}
+	}
+	onRate := float64(on) / (5 * 0.040)
+	offRate := float64(off) / (5 * 0.135)
+	if onRate < 200 || onRate > 600 {
+		t.Errorf("expected about 400 traces per second during bursts, got %.0f", onRate)
+	}
+	if offRate > onRate/20 {
+		t.Errorf("expected almost no traces between bursts, got %.0f per second (vs %.0f)", offRate, onRate)
+	}
+
+	opts.Quantity.BurstDuty = 1.5
+	if _, err := NewTraceGenerator(sender, nil, NewLogger(0), opts); err == nil {
+		t.Error("expected an error for a duty over 1")
+	}
+}
+
+func TestTraceGenerator_arrivals(t *testing.T) {
+	// the coefficient of variation (stddev / mean) of the gaps between the starts of traces
+	cv := func(arrival string) (float64, int) {
+		opts := newOptions()
+		opts.Global.Seed = "arrivals"
+		opts.Format.Depth = 1
+		opts.Format.NSpans = 1
+		opts.Format.TraceTime = 10 * time.Millisecond
+		opts.Quantity.TPS = 100 // a single generator
+		opts.Quantity.RampTime = time.Millisecond
+		opts.Quantity.Arrival = arrival
+		sender := &timedSender{}
+		gen, err := NewTraceGenerator(sender, func(string) (*Fielder, error) { return newTestFielder(t, 1), nil }, NewLogger(0), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stop := make(chan struct{})
+		counter := make(chan int64)
+		wg := &sync.WaitGroup{}
+		go TraceCounter(NewLogger(0), 0, 0, counter, stop)
+		wg.Add(1)
+		go gen.Generate(opts, wg, stop, counter)
+		time.Sleep(time.Second)
+		closeStop(stop)
+		wg.Wait()
+
+		sender.mut.Lock()
+		defer sender.mut.Unlock()
+		var gaps []float64
+		for i := 1; i < len(sender.starts); i++ {
+			gaps = append(gaps, float64(sender.starts[i].Sub(sender.starts[i-1])))
+		}
+		var mean, variance float64
+		for _, g := range gaps {
+			mean += g / float64(len(gaps))
+		}
+		for _, g := range gaps {
+			variance += (g - mean) * (g - mean) / float64(len(gaps))
+		}
+		return math.Sqrt(variance) / mean, len(sender.starts)
+	}
+
+	if c, n := cv("periodic"); c > 0.25 || n < 75 {
+		t.Errorf("expected about 100 evenly spaced traces, got %d with a CV of %.2f", n, c)
+	}
+	if c, n := cv("poisson"); c < 0.6 || c > 1.4 || n < 60 || n > 150 {
+		t.Errorf("expected about 100 traces with a CV near 1, got %d with a CV of %.2f", n, c)
+	}
+}
+
+func TestTraceGenerator_lowTPS(t *testing.T) {
+	opts := newOptions()
+	opts.Format.Depth = 1
+	opts.Format.NSpans = 1
+	opts.Format.TraceTime = 10 * time.Millisecond
+	opts.Quantity.TPS = 1 // a hundredth of a generator
+	opts.Quantity.RampTime = time.Millisecond
+	sender := &timedSender{}
+	gen, err := NewTraceGenerator(sender, func(string) (*Fielder, error) { return newTestFielder(t, 1), nil }, NewLogger(0), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	counter := make(chan int64)
+	wg := &sync.WaitGroup{}
+	go TraceCounter(NewLogger(0), 0, 0, counter, stop)
+	wg.Add(1)
+	go gen.Generate(opts, wg, stop, counter)
+	time.Sleep(2500 * time.Millisecond)
+	if n := gen.Status().Generators; n != 1 {
+		t.Errorf("expected a single generator, got %d", n)
+	}
+	closeStop(stop)
+	wg.Wait()
+
+	sender.mut.Lock()
+	defer sender.mut.Unlock()
+	if len(sender.starts) != 2 {
+		t.Fatalf("expected a trace each second, got %d in 2.5s", len(sender.starts))
+	}
+	if gap := sender.starts[1].Sub(sender.starts[0]); gap < 900*time.Millisecond || gap > 1100*time.Millisecond {
+		t.Errorf("expected the traces to be a second apart, got %s", gap)
+	}
+}
+
+func TestTraceGenerator_rampShape(t *testing.T) {
+	// the generators running just after the ticks 30%, 60%, and 90% of the way through the ramp
+	tests := []struct {
+		shape string
+		want  []int
+	}{
+		{"linear", []int{3, 6, 9}},
+		{"exp", []int{0, 2, 7}},
+		{"step", []int{5, 8, 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shape, func(t *testing.T) {
+			opts := newOptions()
+			opts.Format.Depth = 1
+			opts.Format.NSpans = 1
+			opts.Format.TraceTime = 100 * time.Millisecond
+			opts.Quantity.TPS = 100                         // 10 generators
+			opts.Quantity.RampTime = 800 * time.Millisecond // a tick every 80ms
+			opts.Quantity.RampShape = tt.shape
+			gen, err := NewTraceGenerator(&timedSender{}, func(string) (*Fielder, error) { return newTestFielder(t, 1), nil }, NewLogger(0), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stop := make(chan struct{})
+			counter := make(chan int64)
+			wg := &sync.WaitGroup{}
+			go TraceCounter(NewLogger(0), 0, 0, counter, stop)
+			wg.Add(1)
+			start := time.Now()
+			go gen.Generate(opts, wg, stop, counter)
+			var got []int
+			for _, at := range []time.Duration{260, 500, 740} {
+				time.Sleep(at*time.Millisecond - time.Since(start))
+				got = append(got, gen.Status().Generators)
+			}
+			closeStop(stop)
+			wg.Wait()
+
+			// the samples can be a tick off either way
+			for i := range got {
+				if got[i] < tt.want[i]-1 || got[i] > tt.want[i]+1 {
+					t.Errorf("expected about %v generators, got %v", tt.want, got)
+					break
+				}
+			}
+		})
+	}
+
+	opts := newOptions()
+	opts.Quantity.RampShape = "sine"
+	if _, err := NewTraceGenerator(&timedSender{}, nil, NewLogger(0), opts); err == nil {
+		t.Error("expected an error for an unknown ramp shape")
+	}
+}
+
+// backedUpSender is a sender with a queue that's full on every other check,
+// so that the generators skip traces without anything depending on timing.
+type backedUpSender struct {
+	recordingSender
+	checks atomic.Int64
+}
+
+func (s *backedUpSender) QueueFullness() float64 {
+	if s.checks.Add(1)%2 == 1 {
+		return 1
+	}
+	return 0
+}
+
+func TestTraceGenerator_backpressure(t *testing.T) {
+	opts := newOptions()
+	opts.Format.Depth = 3
+	opts.Format.NSpans = 3
+	opts.Format.SyntheticTime = true
+	opts.Quantity.TPS = 1000
+	sender := &backedUpSender{}
+	gen, err := NewTraceGenerator(sender, func(string) (*Fielder, error) { return newTestFielder(t, 3), nil }, NewLogger(0), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	counter := make(chan int64)
+	wg := &sync.WaitGroup{}
+	go func() {
+		TraceCounter(NewLogger(0), 20, 0, counter, stop)
+		closeStop(stop)
+	}()
+	wg.Add(1)
+	go gen.Generate(opts, wg, stop, counter)
+	wg.Wait()
+
+	sender.mut.Lock()
+	defer sender.mut.Unlock()
+	if sender.traces != 20 {
+		t.Fatalf("expected 20 traces, got %d", sender.traces)
+	}
+	// a full queue is seen before each trace is started
+	if skipped := gen.skipped.Load(); skipped < 20 {
+		t.Errorf("expected at least 20 skipped traces, got %d", skipped)
+	}
+	// the queue is only checked before a trace, so no trace is cut short
+	for i := 0; i < len(sender.spans); i += 3 {
+		if trace := sender.spans[i:min(i+3, len(sender.spans))]; !slices.Equal(trace, []int{0, 1, 2}) {
+			t.Errorf("expected a whole trace of 3 spans, got levels %v", trace)
+		}
+	}
+}
+
+// serviceSender records the service and fields of every span.
+type serviceSender struct {
+	recordingSender
+	services []string
+	fields   []map[string]any
+}
+
+func (s *serviceSender) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	s.services = append(s.services, name)
+	s.fields = append(s.fields, fielder.GetFields(count, 0))
+	return ctx, DummySendable{}
+}
+
+func (s *serviceSender) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	s.services = append(s.services, name)
+	s.fields = append(s.fields, fielder.GetFields(0, level))
+	return ctx, DummySendable{}
+}
+
+func TestTraceGenerator_serviceTemplate(t *testing.T) {
+	sender := &serviceSender{}
+	opts := newOptions()
+	opts.Format.Depth = 4
+	opts.Format.NSpans = 6
+	opts.Format.TraceTime = time.Second
+	opts.Format.SyntheticTime = true
+	opts.Fields["queue"] = "/svc orders-{service}-queue"
+	gen, err := NewTraceGenerator(sender, nil, NewLogger(0), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := NewFielder(opts.Global.Seed, opts.Fields, 0, opts.Format.Depth, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fielders := newServiceFielders(base, gen.serviceFielder, gen.log)
+	for i := 0; i < 10; i++ {
+		gen.generate_root(fielders, int64(i+1), opts.Format.Depth, opts.Format.NSpans, opts.Format.TraceTime)
+	}
+
+	services := make(map[string]bool)
+	for i, service := range sender.services {
+		services[service] = true
+		if want := "orders-" + service + "-queue"; sender.fields[i]["queue"] != want {
+			t.Errorf("expected queue %q on a span from %s, got %v", want, service, sender.fields[i]["queue"])
+		}
+	}
+	if len(services) < 2 {
+		t.Errorf("expected spans from several services, got %v", services)
+	}
+
+	if _, err := NewFielder("svc", map[string]string{"queue": "/svc orders"}, 0, 1, 1, 1); err == nil {
+		t.Error("expected an error for a pattern without {service}")
+	}
+}
+
+func TestTraceGenerator_serviceFieldersConfigured(t *testing.T) {
+	sender := &serviceSender{}
+	opts := newOptions()
+	opts.Format.Depth = 4
+	opts.Format.NSpans = 6
+	opts.Format.SyntheticTime = true
+	opts.Format.RunLabel = "nightly"
+	gen, err := NewTraceGenerator(sender, nil, NewLogger(0), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fielders, err := gen.newFielders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		gen.generate_root(fielders, int64(i+1), opts.Format.Depth, opts.Format.NSpans, opts.Format.TraceTime)
+	}
+	// each service's own Fielder is made by the same factory as the base one
+	services := make(map[string]bool)
+	for i, service := range sender.services {
+		services[service] = true
+		if label := sender.fields[i][runLabelField]; label != "nightly" {
+			t.Errorf("expected the run label on a span from %s, got %v", service, label)
+		}
+	}
+	if len(services) < 2 {
+		t.Errorf("expected spans from several services, got %v", services)
+	}
+}
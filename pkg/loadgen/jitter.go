@@ -0,0 +1,49 @@
+package loadgen
+
+import "math"
+
+// jitterGenerators are the generators of random numbers whose values --jitter
+// perturbs; the others (like /st or /seq) have values that mean something
+// exactly, so they're left alone.
+var jitterGenerators = map[string]bool{"i": true, "ir": true, "ig": true, "f": true, "fr": true, "fg": true}
+
+// parseJitterFields returns the names of the user fields whose values --jitter
+// perturbs: the random ints and floats, but not the constant ones, like /i5,5.
+func parseJitterFields(userfields map[string]string) map[string]bool {
+	names := make(map[string]bool)
+	for name, value := range userfields {
+		matches := genfield.FindStringSubmatch(value)
+		if matches == nil || !jitterGenerators[matches[1]] {
+			continue
+		}
+		if p1, p2 := matches[2], matches[3]; p1 != "" && p1 == p2 && matches[1] != "ig" && matches[1] != "fg" {
+			continue
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// SetJitter perturbs each value of the random numeric fields (and the --extra
+// fields) by a random factor of up to the fraction either way (--jitter), so
+// that they don't repeat exactly; zero turns it off.
+func (f *Fielder) SetJitter(fraction float64) {
+	f.jitter = fraction
+}
+
+// jittered returns the value of the field with the given key, perturbed if
+// it's a number from one of the generators that --jitter applies to.
+func (f *Fielder) jittered(key string, value any) any {
+	if f.jitter <= 0 || !f.jitterable[key] {
+		return value
+	}
+	factor := f.rng.Float(1-f.jitter, 1+f.jitter)
+	switch v := value.(type) {
+	case int64:
+		return int64(math.Round(float64(v) * factor))
+	case float64:
+		return v * factor
+	default:
+		return v
+	}
+}
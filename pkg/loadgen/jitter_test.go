@@ -0,0 +1,72 @@
+package loadgen
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFielder_jitter(t *testing.T) {
+	// the base values are nearly constant, so anything outside them is jitter
+	userFields := map[string]string{"num": "/i1000,1001", "flt": "/f10,10.001", "const": "/i5,5", "lit": "7"}
+	const jitter, nspans = 0.05, 1000
+	for _, tt := range []struct {
+		name   string
+		jitter float64
+	}{
+		{"off", 0},
+		{"on", jitter},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fielder, err := NewFielder("jitter", userFields, 0, 1, 4, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fielder.SetJitter(tt.jitter)
+			var nums, flts int
+			for i := 0; i < nspans; i++ {
+				fields := fielder.GetFields(1, 0)
+				num := fields["num"].(int64)
+				if num < 1000 || num > 1001 {
+					nums++
+				}
+				if float64(num) < math.Floor(1000*(1-jitter)) || float64(num) > math.Ceil(1001*(1+jitter)) {
+					t.Fatalf("num %d is outside %v of the base values", num, jitter)
+				}
+				flt := fields["flt"].(float64)
+				if flt < 10 || flt > 10.001 {
+					flts++
+				}
+				if flt < 10*(1-jitter) || flt > 10.001*(1+jitter) {
+					t.Fatalf("flt %v is outside %v of the base values", flt, jitter)
+				}
+				if fields["const"] != int64(5) || fields["lit"] != int64(7) {
+					t.Fatalf("expected the constants to be left alone, got %v and %v", fields["const"], fields["lit"])
+				}
+			}
+			if tt.jitter == 0 && (nums != 0 || flts != 0) {
+				t.Errorf("expected no jittered values, got %d ints and %d floats", nums, flts)
+			}
+			// nearly every value moves by more than the width of the base range
+			if tt.jitter != 0 && (nums < nspans/2 || flts < nspans/2) {
+				t.Errorf("expected most values to be jittered, got %d ints and %d floats of %d", nums, flts, nspans)
+			}
+		})
+	}
+}
+
+func Test_parseJitterFields(t *testing.T) {
+	userFields := map[string]string{
+		"int": "/i10", "ir": "/ir1,5", "ig": "/ig50,10", "flt": "/f", "fg": "/fg5,5",
+		"const": "/i5,5", "fconst": "/f2,2", "str": "/s", "seq": "/seq", "lit": "7",
+	}
+	want := map[string]bool{"int": true, "ir": true, "ig": true, "flt": true, "fg": true}
+	got := parseJitterFields(userFields)
+	if len(got) != len(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	for name := range want {
+		if !got[name] {
+			t.Errorf("expected %s to be jittered, got %v", name, got)
+		}
+	}
+}
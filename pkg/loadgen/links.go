@@ -0,0 +1,63 @@
+package loadgen
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+	"pgregory.net/rand"
+)
+
+// linkRelationship is the value of the attribute that describes why a root span
+// links to an earlier trace.
+const linkRelationship = "follows_from"
+
+// recentSpans is a small ring buffer of the span contexts of recently created
+// root spans; new roots can link to one of them to simulate async or batch work.
+type recentSpans struct {
+	mut   sync.Mutex
+	spans []trace.SpanContext
+	next  int
+}
+
+func newRecentSpans(size int) *recentSpans {
+	return &recentSpans{spans: make([]trace.SpanContext, 0, size)}
+}
+
+// add remembers sc, overwriting the oldest entry once the buffer is full.
+// Invalid span contexts (from senders that don't produce OTel spans) are ignored.
+func (r *recentSpans) add(sc trace.SpanContext) {
+	if !sc.IsValid() {
+		return
+	}
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if len(r.spans) < cap(r.spans) {
+		r.spans = append(r.spans, sc)
+		return
+	}
+	r.spans[r.next] = sc
+	r.next = (r.next + 1) % len(r.spans)
+}
+
+// pick returns a random entry from the buffer, or false if it's empty.
+func (r *recentSpans) pick() (trace.SpanContext, bool) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if len(r.spans) == 0 {
+		return trace.SpanContext{}, false
+	}
+	return r.spans[rand.Intn(len(r.spans))], true
+}
+
+type linkKey struct{}
+
+// contextWithLink asks the sender to link the next root span to sc.
+func contextWithLink(ctx context.Context, sc trace.SpanContext) context.Context {
+	return context.WithValue(ctx, linkKey{}, sc)
+}
+
+func linkFromContext(ctx context.Context) (trace.SpanContext, bool) {
+	sc, ok := ctx.Value(linkKey{}).(trace.SpanContext)
+	return sc, ok
+}
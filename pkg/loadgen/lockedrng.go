@@ -0,0 +1,52 @@
+package loadgen
+
+import "sync"
+
+// lockedRng is an Rng that can be shared by goroutines, like those of a sender
+// or a generator.
+type lockedRng struct {
+	mut sync.Mutex
+	rng Rng
+}
+
+func newLockedRng(seed string) *lockedRng {
+	return &lockedRng{rng: NewRng(seed)}
+}
+
+func (r *lockedRng) Intn(n int) int64 {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.rng.Intn(n)
+}
+
+func (r *lockedRng) Choice(a []string) string {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.rng.Choice(a)
+}
+
+func (r *lockedRng) Float(min, max float64) float64 {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.rng.Float(min, max)
+}
+
+func (r *lockedRng) Exponential(mean float64) float64 {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.rng.Exponential(mean)
+}
+
+func (r *lockedRng) Pareto(xm, alpha float64) float64 {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.rng.Pareto(xm, alpha)
+}
+
+// withLock calls f, which draws from the Rng itself (as a generator made from
+// it does), with r locked.
+func withLock[T any](r *lockedRng, f func() T) T {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return f()
+}
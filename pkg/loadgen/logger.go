@@ -0,0 +1,108 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Logger interface {
+	Printf(format string, v ...interface{})
+	Info(format string, v ...interface{})
+	Debug(format string, v ...interface{})
+	Warn(format string, v ...interface{})
+	Error(format string, v ...interface{})
+	Fatal(format string, v ...interface{})
+}
+
+// The log levels, from the most severe to the least. A logger writes the
+// messages at its own level and the levels before it; LevelQuiet writes
+// nothing but fatal errors.
+const (
+	LevelQuiet = iota - 1
+	LevelError
+	LevelWarn
+	LevelInfo
+	LevelDebug
+)
+
+var levelNames = map[int]string{
+	LevelError: "error",
+	LevelWarn:  "warn",
+	LevelInfo:  "info",
+	LevelDebug: "debug",
+}
+
+// logger writes log messages to stderr, as text or as lines of JSON. Printf
+// isn't a log message; it's the output of the print sender, so it always goes
+// to stdout as it is.
+type logger struct {
+	level int
+	json  bool
+	mut   sync.Mutex
+	out   io.Writer
+}
+
+// NewLogger returns a Logger that writes the messages at or above level as text.
+func NewLogger(level int) Logger {
+	return &logger{level: level, out: os.Stderr}
+}
+
+// NewJSONLogger returns a Logger that writes the messages at or above level as
+// JSON objects with time, level, and msg keys, one per line.
+func NewJSONLogger(level int) Logger {
+	return &logger{level: level, json: true, out: os.Stderr}
+}
+
+type logEntry struct {
+	Time  string `json:"time"`
+	Level string `json:"level"`
+	Msg   string `json:"msg"`
+}
+
+func (l *logger) log(level int, name string, format string, v ...interface{}) {
+	if level > l.level && name != "fatal" {
+		return
+	}
+	msg := fmt.Sprintf(format, v...)
+	if l.json {
+		b, _ := json.Marshal(logEntry{
+			Time:  time.Now().UTC().Format(time.RFC3339Nano),
+			Level: name,
+			Msg:   strings.TrimRight(msg, "\n"),
+		})
+		msg = string(b) + "\n"
+	}
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	io.WriteString(l.out, msg)
+}
+
+func (l *logger) Error(format string, v ...interface{}) {
+	l.log(LevelError, levelNames[LevelError], format, v...)
+}
+
+func (l *logger) Fatal(format string, v ...interface{}) {
+	l.log(LevelError, "fatal", format, v...)
+	os.Exit(1)
+}
+
+func (l *logger) Printf(format string, v ...interface{}) {
+	fmt.Printf(format, v...)
+}
+
+func (l *logger) Warn(format string, v ...interface{}) {
+	l.log(LevelWarn, levelNames[LevelWarn], format, v...)
+}
+
+func (l *logger) Info(format string, v ...interface{}) {
+	l.log(LevelInfo, levelNames[LevelInfo], format, v...)
+}
+
+func (l *logger) Debug(format string, v ...interface{}) {
+	l.log(LevelDebug, levelNames[LevelDebug], format, v...)
+}
@@ -0,0 +1,61 @@
+package loadgen
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLogger_levels(t *testing.T) {
+	tests := []struct {
+		level int
+		want  []string
+	}{
+		{LevelQuiet, nil},
+		{LevelError, []string{"e"}},
+		{LevelWarn, []string{"w", "e"}},
+		{LevelInfo, []string{"i", "w", "e"}},
+		{LevelDebug, []string{"d", "i", "w", "e"}},
+	}
+	for _, tt := range tests {
+		buf := &bytes.Buffer{}
+		l := &logger{level: tt.level, out: buf}
+		l.Debug("d\n")
+		l.Info("i\n")
+		l.Warn("w\n")
+		l.Error("e\n")
+		if got := strings.Fields(buf.String()); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("level %d: expected %v, got %v", tt.level, tt.want, got)
+		}
+	}
+}
+
+func TestLogger_json(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := &logger{level: LevelInfo, json: true, out: buf}
+	l.Debug("hidden\n")
+	l.Info("sent %d traces\n", 3)
+	l.Warn("a \"quoted\"\nmessage\n")
+	l.Error("failed: %v\n", "oops")
+
+	var levels []string
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var entry map[string]string
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("line %q isn't JSON: %v", scanner.Text(), err)
+		}
+		if entry["time"] == "" {
+			t.Errorf("expected a time in %v", entry)
+		}
+		levels = append(levels, entry["level"])
+		if entry["level"] == "info" && entry["msg"] != "sent 3 traces" {
+			t.Errorf("unexpected message %q", entry["msg"])
+		}
+	}
+	if strings.Join(levels, ",") != "info,warn,error" {
+		t.Errorf("expected info, warn, and error lines, got %v", levels)
+	}
+}
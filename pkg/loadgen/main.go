@@ -0,0 +1,940 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/goware/urlx"
+	"github.com/jessevdk/go-flags"
+	"gopkg.in/yaml.v3"
+)
+
+var ResourceLibrary = "loadgen"
+var ResourceVersion = "dev"
+
+type Options struct {
+	Telemetry struct {
+		Host        string   `long:"host" description:"the url of the host to receive the telemetry (or honeycomb, dogfood, local)" default:"honeycomb"`
+		Hosts       string   `long:"hosts" description:"for otel traces only, a pool of collectors like a:4317,b:4317,c:4317 to send to instead of --host; the traces take turns among them" yaml:",omitempty"`
+		Insecure    bool     `long:"insecure" description:"use this for insecure http (not https) connections" yaml:",omitempty"`
+		Dataset     string   `long:"dataset" description:"if set, sends all traces to the given dataset; otherwise, sends them to the dataset named for the service" env:"HONEYCOMB_DATASET"`
+		Suffix      string   `long:"datasetsuffix" description:"appended to the dataset of all telemetry (--dataset, or the service's), so that each run lands in datasets of its own; without a value, a dash and a CI job's short commit sha, or the time" optional:"yes" optional-value:"auto" yaml:",omitempty"`
+		APIKey      string   `long:"apikey" description:"the honeycomb API key(*)" env:"HONEYCOMB_API_KEY" yaml:"-"`
+		HostName    string   `long:"hostname" description:"for otel only, the host.name resource attribute (defaults to this machine's hostname)" yaml:",omitempty"`
+		Region      string   `long:"region" description:"for otel only, the cloud.region resource attribute" yaml:",omitempty"`
+		Environment string   `long:"environment" description:"for otel only, the deployment.environment resource attribute" yaml:",omitempty"`
+		Resource    []string `long:"resource" description:"for otel only, an extra resource attribute as key=value; can be repeated" yaml:",omitempty"`
+		Headers     []string `long:"header" description:"for otel only, an extra header to send with each export as key=value, like an auth token or tenant id; can be repeated" yaml:",omitempty"`
+		TracesPath  string   `long:"tracespath" description:"for otel over http (protobuf or json) only, the URL path that traces are posted to, if not /v1/traces" yaml:",omitempty"`
+		TLSCert     string   `long:"tlscert" description:"for otel traces and logs, a PEM client certificate file to present to the collector (needs --tlskey)" yaml:",omitempty"`
+		TLSKey      string   `long:"tlskey" description:"for otel traces and logs, the PEM private key file for --tlscert" yaml:",omitempty"`
+		TLSCA       string   `long:"tlsca" description:"for otel traces and logs, a PEM file of CA certificates to verify the collector with, instead of the system's" yaml:",omitempty"`
+		SkipHealth  bool     `long:"skiphealthcheck" description:"for the otel and honeycomb senders, start sending without first checking that the host can be connected to" yaml:",omitempty"`
+	} `group:"Telemetry Options"`
+	Format struct {
+		Depth               int           `long:"depth" description:"the nesting depth of each trace" default:"3"`
+		AttributesPerSpan   int           `long:"apspan" yaml:"apspan" description:"the number of attributes per span" default:"3"`
+		IntrinsicAttributes int           `long:"iattributes" yaml:"iattributes" description:"the number of attributes that every span has, up to --apspan; the rest appear on some spans, so that they average --apspan" default:"3"`
+		AttrDist            string        `long:"attrdist" description:"for otel only, draw the number of attributes of each span from a distribution, like gaussian:20,8 or uniform:5,50, instead of using --apspan; it's at least --iattributes and at most the number of fields" yaml:",omitempty"`
+		NSpans              int           `long:"nspans" description:"the total number of spans in a trace" default:"3"`
+		Extra               int           `long:"extra" description:"the number of random fields in a span beyond the standard ones" default:"0" yaml:",omitempty"`
+		TraceTime           time.Duration `long:"tracetime" description:"the duration of a trace" default:"1s"`
+		P50                 time.Duration `long:"p50" description:"if set, the median root span duration; traces no longer all take tracetime" default:"0s" yaml:",omitempty"`
+		P95                 time.Duration `long:"p95" description:"if set, the 95th percentile root span duration" default:"0s" yaml:",omitempty"`
+		P99                 time.Duration `long:"p99" description:"if set, the 99th percentile root span duration" default:"0s" yaml:",omitempty"`
+		MinSpanDuration     time.Duration `long:"minspanduration" description:"if set, no span is shorter than this, like 1ms, since some backends reject or round down very short spans; a trace that's too short for all of its spans takes longer instead" default:"0s" yaml:",omitempty"`
+		SeverityDist        string        `long:"severitydist" description:"for logs only, the weights of each log severity (trace, debug, info, warn, error, fatal, or a severity number from 1 to 24)" default:"debug:10,info:70,warn:15,error:5"`
+		DurationDist        string        `long:"durationdist" description:"how span durations are drawn when subdividing a trace; pareto has a realistic long tail" choice:"uniform" choice:"exponential" choice:"pareto" default:"pareto"`
+		SpanNameTemplate    string        `long:"spannametemplate" description:"how spans are named: for their service, or like operations of an http, rpc, or db client, or with random words" choice:"service" choice:"http" choice:"rpc" choice:"db" choice:"word" default:"service"`
+		KeyStyle            string        `long:"keystyle" description:"how the names of random (--extra) fields are written: big-apple, service.big.apple, big_apple, or bigApple" choice:"wordpair" choice:"dotted" choice:"snake" choice:"camel" default:"wordpair"`
+		EventRate           float64       `long:"eventrate" description:"for otel only, the fraction (0-1) of child spans that get informational span events like cache_miss or retry" default:"0" yaml:",omitempty"`
+		EventsPerSpan       int           `long:"eventsperspan" description:"for otel only, the number of events a span gets when it gets any" default:"3"`
+		StatusDist          string        `long:"statusdist" description:"for otel only, the weights of the status of the spans below the root: ok, error, or unset, which leaves the status unset" default:"ok:90,error:10"`
+		StatusMessages      []string      `long:"statusmessage" description:"for otel only, a message for the error status of a span, chosen at random instead of its exception's message; can be repeated" yaml:",omitempty"`
+		LeafRate            float64       `long:"leafrate" description:"for otel only, the fraction (0-1) of leaf spans that are calls to a database or cache, with db.system and the other database attributes, instead of internal work" default:"0" yaml:",omitempty"`
+		SQLTables           int           `long:"sqltables" description:"for otel only, with --leafrate, the number of distinct tables in the SQL statements of database calls, like the first argument of /sql" default:"10"`
+		SQLColumns          int           `long:"sqlcolumns" description:"for otel only, with --leafrate, the number of distinct columns in the SQL statements of database calls, like the second argument of /sql" default:"8"`
+		LinkRate            float64       `long:"linkrate" description:"for otel only, the fraction (0-1) of root spans that carry a span link to a recent trace" default:"0" yaml:",omitempty"`
+		PathoRate           float64       `long:"pathorate" description:"the fraction (0-1) of traces that are pathological: 1000 levels deep, 10000 spans wide, or with a 1MiB attribute, to test a backend's limits" default:"0" yaml:",omitempty"`
+		HTTPFields          string        `long:"httpfields" description:"add a correlated set of OTel HTTP attributes: http.route, and a http.request.method and url.path that suit it, http.response.status_code, and user_agent.original; the optional value is the percentages of 4xx and 5xx statuses" optional:"yes" optional-value:"4,1" yaml:",omitempty"`
+		RootFields          []string      `long:"rootfield" description:"a field that only root spans have, as name=spec with the same specs as the positional fields; can be repeated" yaml:",omitempty"`
+		LeafFields          []string      `long:"leaffield" description:"a field that only leaf spans (those without children) have, as name=spec; can be repeated" yaml:",omitempty"`
+		Drift               time.Duration `long:"drift" description:"if set, every this often one of the random (--extra) fields changes type or a new field appears, up to 6 times, the same way for a given seed" default:"0s" yaml:",omitempty"`
+		Fuzz                float64       `long:"fuzz" description:"for otel only, the fraction (0-1) of span values that are replaced with edge cases like empty or very long strings, NaN, or control characters, and of keys that get control or Unicode characters, to test a backend's validation" default:"0" yaml:",omitempty"`
+		Jitter              float64       `long:"jitter" description:"if set, the percentage (0-100) by which each value of the random numeric fields (like /i or /fg) and --extra fields is perturbed at random, so that values don't repeat exactly while keeping their distribution" default:"0" yaml:",omitempty"`
+		MaxAttrLen          int           `long:"maxattrlen" description:"if set, the maximum length in bytes of a string field; longer ones are truncated, to test a backend's limit" default:"0" yaml:",omitempty"`
+		AttrEllipsis        bool          `long:"attrellipsis" description:"with --maxattrlen, end a truncated string with ... (within the limit)" yaml:",omitempty"`
+		Processes           int           `long:"processes" description:"if set, give each generator one of this many synthetic process_ids instead of the real one, to simulate a fleet of loadgen processes" default:"0" yaml:",omitempty"`
+		Baggage             string        `long:"baggage" description:"for otel only, W3C baggage entries like user.id=123,tenant=acme that every trace starts with and its spans inherit" yaml:",omitempty"`
+		BaggageAttrs        bool          `long:"baggageattrs" description:"for otel only, with --baggage, also copy the baggage to every span's attributes" yaml:",omitempty"`
+		Canary              float64       `long:"canary" description:"for otel only, the fraction (0-1) of each service's spans that have a canary service.version, one minor release above its stable one" default:"0" yaml:",omitempty"`
+		DupSpanRate         float64       `long:"dupspanrate" description:"for otel traces only, the fraction (0-1) of spans that are exported a second time with the same ids and times, as if a client retried, to test deduplication" default:"0" yaml:",omitempty"`
+		IDSpace             string        `long:"idspace" description:"for otel only, if set, trace and span ids are derived from it, the seed, and each trace's number, so that runs with the same ones send the same ids, to test deduplication" yaml:",omitempty"`
+		ClockSkew           time.Duration `long:"clockskew" description:"for otel only, if set, each service's clock is off by a stable offset of up to this much either way, chosen by the seed and its name, and its span times are shifted by it" default:"0s" yaml:",omitempty"`
+		SyntheticTime       bool          `long:"synthetictime" description:"for otel only, give spans the start and end times they would have had instead of sleeping through them, so that one generator can send many traces per second; some end times are in the future" yaml:",omitempty"`
+		RunLabel            string        `long:"runlabel" description:"the loadgen.run field of every span, to tell this run's traces apart from others; by default, a random word pair" yaml:",omitempty"`
+		TraceState          string        `long:"tracestate" description:"for otel only, W3C tracestate entries like vendor=value,other=123 that every trace starts with" yaml:",omitempty"`
+	} `group:"Trace Format Options"`
+	Quantity struct {
+		TPS         int           `long:"tps" description:"the maximum number of traces to generate per second" default:"1"`
+		SpanRate    float64       `long:"targetspanrate" description:"for traces, if set, the TPS is adjusted every second to send this many spans per second, whatever the number of spans in a trace; --tps is where it starts" default:"0" yaml:",omitempty"`
+		TPSCap      float64       `long:"tpscap" description:"if set, the most traces that are started per second, however many generators there are; any more wait their turn" default:"0" yaml:",omitempty"`
+		DriftTol    float64       `long:"drifttolerance" description:"for traces, if set, warn when the achieved TPS, averaged over --driftwindow seconds, stays more than this percentage from the target for that long, as when the backend is throttling the sender" default:"0" yaml:",omitempty"`
+		DriftWindow int           `long:"driftwindow" description:"with --drifttolerance, the number of one-second samples to average over, and that must all be outside the tolerance" default:"5"`
+		TraceCount  int64         `long:"tracecount" description:"the maximum number of traces (or spans, with --countby=spans) to generate (0 means no limit, but if neither runtime nor maxbytes is specified defaults to 1)" default:"0" yaml:",omitempty"`
+		Arrival     string        `long:"arrival" description:"how the starts of traces are spaced: evenly, or as a Poisson process, with exponentially distributed gaps that average 1/TPS" choice:"periodic" choice:"poisson" default:"periodic"`
+		CountBy     string        `long:"countby" description:"for traces, whether tracecount limits the number of traces or the total number of spans in them" choice:"traces" choice:"spans" default:"traces"`
+		Warmup      int64         `long:"warmup" description:"the number of traces to send before measuring; they're sent in addition to tracecount and aren't included in the reported counts" default:"0" yaml:",omitempty"`
+		RunTime     time.Duration `long:"runtime" description:"the maximum time to spend generating traces at max TPS (0 means no limit)" default:"0s" yaml:",omitempty"`
+		RampTime    time.Duration `long:"ramptime" description:"duration to spend ramping up or down to the desired TPS" default:"1s"`
+		RampShape   string        `long:"rampshape" description:"how generators are added over the ramp time: at a steady pace, slowly and then faster and faster, or in 4 equal steps" choice:"linear" choice:"exp" choice:"step" default:"linear"`
+		Burst       bool          `long:"burst" description:"for traces, send in bursts: at the full TPS for part of each burst period, and not at all for the rest" yaml:",omitempty"`
+		BurstPeriod time.Duration `long:"burstperiod" description:"with --burst, the length of one on/off cycle" default:"10s"`
+		BurstDuty   float64       `long:"burstduty" description:"with --burst, the fraction (0-1) of each period spent sending" default:"0.5"`
+	} `group:"Quantity Options"`
+	Output struct {
+		Sender         string        `long:"sender" description:"type of sender" default:"honeycomb"`
+		Tee            string        `long:"tee" description:"for traces, also send every span through this sender, like print, to see what's being sent" yaml:",omitempty"`
+		Signal         string        `long:"signal" description:"the kind of telemetry to generate; metrics and logs are only supported by the otel, print, and dummy senders" choice:"traces" choice:"metrics" choice:"logs" default:"traces"`
+		Protocol       string        `long:"protocol" description:"for otel only, protocol to use" choice:"grpc" choice:"protobuf" choice:"json" default:"grpc"`
+		SampleRate     float64       `long:"samplerate" description:"for otel traces only, the fraction (0-1) of traces that are recorded and exported, chosen by trace id; child spans follow their parent's decision" default:"1"`
+		PrintFormat    string        `long:"printformat" description:"for print only, how spans are printed; json prints each one as a compact object on its own line" choice:"text" choice:"json" default:"text"`
+		ZipkinURL      string        `long:"zipkinurl" description:"for zipkin only, the url of the endpoint that receives v2 JSON spans" default:"http://localhost:9411/api/v2/spans"`
+		DatadogURL     string        `long:"datadogurl" description:"for datadog only, the url of the agent; traces are posted to its /v0.4/traces endpoint" default:"http://localhost:8126"`
+		OutFile        string        `long:"outfile" description:"for csv and otlpfile only, the file to write the spans to (- for stdout)" default:"-"`
+		BatchSize      int           `long:"batchsize" description:"the maximum number of spans or log records sent in a single request (0 means 100, or for otel traces, the SDK's 512)" default:"0" yaml:",omitempty"`
+		BatchQueue     int           `long:"batchqueue" description:"for otel traces only, the number of ended spans each tracer provider can queue for export (0 means the SDK's 2048)" default:"0" yaml:",omitempty"`
+		BatchTimeout   time.Duration `long:"batchtimeout" description:"for otel traces only, the longest a partial batch of spans waits before it's exported (0 means the SDK's 5s)" default:"0s" yaml:",omitempty"`
+		FlushInterval  time.Duration `long:"flushinterval" description:"for honeycombdirect and otel logs, how often a partial batch is sent" default:"1s"`
+		MaxBytesPerSec int           `long:"maxbytespersec" description:"for traces, limits the estimated bytes sent per second, slowing the generators down if needed (0 means no limit)" default:"0" yaml:",omitempty"`
+		Retries        int           `long:"retries" description:"for otel, zipkin, datadog, and honeycombdirect, the number of times to retry a failed send before dropping its data; the otel exporters instead retry for as long as that many retries would take, and don't log each one" default:"3"`
+		RetryBackoff   time.Duration `long:"retrybackoff" description:"the wait before the first retry; it doubles for each retry after that" default:"100ms"`
+		Replay         string        `long:"replay" description:"for traces, re-sends the spans in a file written by --sender=print --printformat=json through the otel or print sender at the configured tps, instead of generating new ones" yaml:",omitempty"`
+		SummaryJSON    string        `long:"summaryjson" description:"for traces, at the end of the run, write the traces and spans sent, errors, traces dropped, duration, and achieved TPS to this file as JSON" yaml:",omitempty"`
+		Progress       time.Duration `long:"progressinterval" description:"for generated traces, how often to log (at info level) the time elapsed, traces sent, achieved TPS, and send errors so far (0 means never)" default:"10s"`
+		Cardinality    bool          `long:"cardinalityreport" description:"at the end of the run, report how many distinct values each field had (counting up to 10000 of each)" yaml:",omitempty"`
+		MaxBytes       int64         `long:"maxbytes" description:"for traces, stops once the estimated bytes sent reach this many, finishing the traces in flight (0 means no limit)" default:"0" yaml:",omitempty"`
+		MaxErrors      int64         `long:"maxerrors" description:"for otel, zipkin, datadog, and honeycombdirect, stops with a nonzero exit status once this many sends have failed (0 means no limit)" default:"0" yaml:",omitempty"`
+	} `group:"Output Options"`
+	Global struct {
+		LogLevel    string `long:"loglevel" description:"level of logging" choice:"debug" choice:"info" choice:"warn" choice:"error" default:"warn"`
+		Quiet       bool   `long:"quiet" description:"don't log anything but fatal errors (overrides --loglevel)"`
+		LogJSON     bool   `long:"logjson" description:"log each message to stderr as a line of JSON"`
+		DebugPort   int    `long:"debugport" description:"port to listen on for pprof(*)" default:"-1" yaml:"-"`
+		ControlPort int    `long:"controlport" description:"port to listen on for POST /tps and GET /status, to change the TPS while running(*)" default:"-1" yaml:"-"`
+		Seed        string `long:"seed" description:"string seed for random number generator (defaults to dataset name)" yaml:",omitempty"`
+		Config      string `long:"config" description:"name of config file to load(*)" default:"" yaml:"-"`
+		WriteCfg    string `long:"writecfg" description:"write effective YAML config to the specified output file and quit(*)" default:"" yaml:"-"`
+		Validate    bool   `long:"validate" description:"check the field specs and print sample values without sending anything, then quit(*)" yaml:"-"`
+	} `group:"Global Options"`
+	Fields     map[string]string `yaml:"fields,omitempty"`
+	Profiles   []Profile         `yaml:"profiles,omitempty"`
+	Exceptions []Exception       `yaml:"exceptions,omitempty"`
+	apihost    *url.URL
+	apihosts   []*url.URL // from --hosts, if it's set
+
+	cardinality *cardinalityCounter // shared by every profile's fielders, with --cardinalityreport
+}
+
+// A Profile describes one kind of trace in a blended workload; profiles can only be
+// specified in a config file. Each profile runs its own generators with its own
+// fields; anything a profile doesn't set is inherited from the top-level options,
+// and its fields are added to the top-level fields.
+type Profile struct {
+	Name      string            `yaml:"name"`
+	Depth     int               `yaml:"depth,omitempty"`
+	NSpans    int               `yaml:"nspans,omitempty"`
+	Extra     int               `yaml:"extra,omitempty"`
+	TraceTime time.Duration     `yaml:"tracetime,omitempty"`
+	TPS       int               `yaml:"tps,omitempty"`
+	Fields    map[string]string `yaml:"fields,omitempty"`
+}
+
+func newOptions() *Options {
+	return &Options{Fields: make(map[string]string)}
+}
+
+func (o *Options) CopyStarredFieldsFrom(other *Options) {
+	o.Telemetry.APIKey = other.Telemetry.APIKey
+	o.Global.DebugPort = other.Global.DebugPort
+	o.Global.ControlPort = other.Global.ControlPort
+	o.Global.Config = other.Global.Config
+	o.Global.WriteCfg = other.Global.WriteCfg
+	o.Global.Validate = other.Global.Validate
+}
+
+// ProfileOptions returns a copy of the options for each profile, or just the
+// options themselves if no profiles were specified. Each profile's random seed
+// includes its name so that profiles get different random fields.
+func (o *Options) ProfileOptions() []*Options {
+	if len(o.Profiles) == 0 {
+		return []*Options{o}
+	}
+	popts := make([]*Options, 0, len(o.Profiles))
+	for _, p := range o.Profiles {
+		po := *o
+		po.Profiles = nil
+		po.Global.Seed = o.Global.Seed + p.Name
+		if p.Depth != 0 {
+			po.Format.Depth = p.Depth
+		}
+		if p.NSpans != 0 {
+			po.Format.NSpans = p.NSpans
+		}
+		if p.Extra != 0 {
+			po.Format.Extra = p.Extra
+		}
+		if p.TraceTime != 0 {
+			po.Format.TraceTime = p.TraceTime
+		}
+		if p.TPS != 0 {
+			po.Quantity.TPS = p.TPS
+		}
+		po.Fields = make(map[string]string, len(o.Fields)+len(p.Fields))
+		for k, v := range o.Fields {
+			po.Fields[k] = v
+		}
+		for k, v := range p.Fields {
+			po.Fields[k] = v
+		}
+		popts = append(popts, &po)
+	}
+	return popts
+}
+
+// defaultBatchSize is the --batchsize of the senders that batch, other than otel traces.
+const defaultBatchSize = 100
+
+// BatchSize returns --batchsize, or def if it isn't set.
+func (o *Options) BatchSize(def int) int {
+	if o.Output.BatchSize > 0 {
+		return o.Output.BatchSize
+	}
+	return def
+}
+
+func (o *Options) DebugLevel() int {
+	if o.Global.Quiet {
+		return LevelQuiet
+	}
+	switch o.Global.LogLevel {
+	case "debug":
+		return LevelDebug
+	case "info":
+		return LevelInfo
+	case "warn":
+		return LevelWarn
+	case "error":
+		return LevelError
+	default:
+		return LevelError
+	}
+}
+
+// Logger returns a logger at the level and in the format the options ask for.
+func (o *Options) Logger() Logger {
+	if o.Global.LogJSON {
+		return NewJSONLogger(o.DebugLevel())
+	}
+	return NewLogger(o.DebugLevel())
+}
+
+// parses the host information and returns a cleaned-up version to make
+// it easier to make sure that things are properly specified
+// exits if it can't make sense of it
+func parseHost(log Logger, host string, insecure bool) *url.URL {
+	switch host {
+	case "honeycomb":
+		host = "https://api.honeycomb.io:443"
+	case "dogfood":
+		host = "https://api-dogfood.honeycomb.io:443"
+	case "local":
+		host = "http://localhost:8889"
+	default:
+	}
+
+	// if the scheme is not specified, fall back to the value of the insecure flag
+	defaultScheme := "https"
+	if insecure {
+		defaultScheme = "http"
+	}
+	u, err := urlx.ParseWithDefaultScheme(host, defaultScheme)
+	if err != nil {
+		log.Fatal("unable to parse host: %s\n", err)
+	}
+	port := u.Port()
+	if port == "" {
+		u.Host = fmt.Sprintf("%s:4317", u.Host) // default GRPC port
+	}
+	return u
+}
+
+// healthCheckTimeout is how long checkEndpoint waits to connect.
+const healthCheckTimeout = 5 * time.Second
+
+// checkEndpoint makes sure that something is listening at the host before the
+// run starts, by opening a TCP connection to it, since otherwise the spans are
+// silently lost. A connection is what both gRPC and HTTP need first, so it
+// doesn't send anything.
+func checkEndpoint(host *url.URL, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", host.Host, timeout)
+	if err != nil {
+		return fmt.Errorf("unable to connect to %s: %w", host.Host, err)
+	}
+	return conn.Close()
+}
+
+// healthCheck exits unless every host can be connected to, or --skiphealthcheck
+// is set.
+func healthCheck(log Logger, opts *Options, hosts ...*url.URL) {
+	if opts.Telemetry.SkipHealth {
+		return
+	}
+	for _, host := range hosts {
+		if err := checkEndpoint(host, healthCheckTimeout); err != nil {
+			log.Fatal("%v; check the host, or use --skiphealthcheck to send anyway\n", err)
+		}
+	}
+}
+
+func ReadConfig(log Logger, opts *Options, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(f)
+	err = dec.Decode(opts)
+	if err != nil {
+		return err
+	}
+	log.Info("read config from %s\n", filename)
+	return nil
+}
+
+func WriteConfig(log Logger, opts *Options, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(f)
+	err = enc.Encode(opts)
+	if err != nil {
+		return err
+	}
+	log.Info("wrote config to %s\n", filename)
+	return nil
+}
+
+// fieldEnvPrefix starts the names of environment variables that set fields.
+const fieldEnvPrefix = "LOADGEN_FIELD_"
+
+// envFields returns the fields set by environment variables like
+// LOADGEN_FIELD_name=spec in environ, which is in the form of os.Environ.
+// The field's name is the rest of the variable's name, as it is.
+func envFields(environ []string) map[string]string {
+	fields := make(map[string]string)
+	for _, kv := range environ {
+		name, spec, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		if name, ok = strings.CutPrefix(name, fieldEnvPrefix); ok && name != "" {
+			fields[name] = spec
+		}
+	}
+	return fields
+}
+
+// validateFields parses each field spec, including the ones in profiles, and
+// prints three sample values and the type of each one. It reports every spec
+// that fails rather than stopping at the first, and returns true if they all parsed.
+func validateFields(w io.Writer, opts *Options) bool {
+	ok := true
+	for _, popts := range opts.ProfileOptions() {
+		if len(opts.Profiles) > 0 {
+			fmt.Fprintf(w, "profile seed %s:\n", popts.Global.Seed)
+		}
+		names := make([]string, 0, len(popts.Fields))
+		for name := range popts.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		rng := NewRng(popts.Global.Seed)
+		for _, name := range names {
+			spec := popts.Fields[name]
+			parsed, err := parseFields(rng, map[string]string{name: spec})
+			if err != nil {
+				fmt.Fprintf(w, "  %s=%s: ERROR %v\n", name, spec, err)
+				ok = false
+				continue
+			}
+			for _, sf := range parsed.serviceFields {
+				fmt.Fprintf(w, "  %s=%s: string [%s] (for a service named frontend)\n", name, spec, strings.ReplaceAll(sf.pattern, servicePlaceholder, "frontend"))
+			}
+			for _, tf := range parsed.traceFields {
+				samples := []any{tf.gen.next(), tf.gen.next(), tf.gen.next()}
+				fmt.Fprintf(w, "  %s=%s: %T %v (per trace)\n", name, spec, samples[0], samples)
+			}
+			for _, dep := range parsed.dependents {
+				// sample the source field too, if it's one of the fields; otherwise it's missing
+				source := func() any { return nil }
+				if sourceSpec, found := popts.Fields[dep.source]; found {
+					if sourceFields, err := parseFields(rng, map[string]string{dep.source: sourceSpec}); err == nil && sourceFields.fields[dep.source] != nil {
+						source = sourceFields.fields[dep.source]
+					}
+				}
+				samples := []any{dep.gen(source()), dep.gen(source()), dep.gen(source())}
+				fmt.Fprintf(w, "  %s=%s: %T %v (depends on %s)\n", name, spec, samples[0], samples, dep.source)
+			}
+			// some generators (like geo with .latlon) make several fields from one spec
+			generated := make([]string, 0, len(parsed.fields))
+			for gname := range parsed.fields {
+				generated = append(generated, gname)
+			}
+			sort.Strings(generated)
+			for _, gname := range generated {
+				gen := parsed.fields[gname]
+				samples := []any{gen(), gen(), gen()}
+				fmt.Fprintf(w, "  %s=%s: %T %v\n", gname, spec, samples[0], samples)
+			}
+		}
+	}
+	return ok
+}
+
+// newSender creates the sender with the given name.
+func newSender(log Logger, name string, opts *Options) Sender {
+	factory, ok := senderFactories[name]
+	if !ok {
+		log.Fatal("unknown sender %s\n", name)
+	}
+	sender, err := factory(log, opts)
+	if err != nil {
+		log.Fatal("unable to create the %s sender: %v\n", name, err)
+	}
+	return sender
+}
+
+// newFielderFactory checks the options that shape the fields and returns a
+// function that creates a Fielder from them with the given seed, so that a
+// generator's base Fielder and each service's own one are set up the same way.
+// All of its Fielders drift from the same start.
+func newFielderFactory(opts *Options) (func(seed string) (*Fielder, error), error) {
+	if opts.Format.MaxAttrLen < 0 {
+		return nil, fmt.Errorf("maxattrlen %d must not be negative", opts.Format.MaxAttrLen)
+	}
+	attrDist, err := parseAttrCountDist(opts.Format.AttrDist)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Format.Fuzz < 0 || opts.Format.Fuzz > 1 {
+		return nil, fmt.Errorf("fuzz %v must be between 0 and 1", opts.Format.Fuzz)
+	}
+	if opts.Format.Jitter < 0 || opts.Format.Jitter > 100 {
+		return nil, fmt.Errorf("jitter %v must be between 0 and 100", opts.Format.Jitter)
+	}
+	rootFields, err := parseRoleFields("rootfield", opts.Format.RootFields)
+	if err != nil {
+		return nil, err
+	}
+	leafFields, err := parseRoleFields("leaffield", opts.Format.LeafFields)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	return func(seed string) (*Fielder, error) {
+		fielder, err := NewFielder(seed, opts.Fields, opts.Format.Extra, opts.Format.Depth, opts.Format.AttributesPerSpan, opts.Format.IntrinsicAttributes)
+		if err != nil {
+			return nil, err
+		}
+		if err := fielder.SetKeyStyle(opts.Format.KeyStyle); err != nil {
+			return nil, err
+		}
+		fielder.SetDrift(start, opts.Format.Drift)
+		fielder.SetMaxAttrLen(opts.Format.MaxAttrLen, opts.Format.AttrEllipsis)
+		fielder.SetRunLabel(opts.Format.RunLabel)
+		fielder.SetCardinalityCounter(opts.cardinality)
+		fielder.SetAttrCountDist(attrDist)
+		fielder.SetFuzzRate(opts.Format.Fuzz)
+		fielder.SetJitter(opts.Format.Jitter / 100)
+		if err := fielder.SetRoleFields(rootFields, leafFields); err != nil {
+			return nil, err
+		}
+		if err := fielder.SetSpanNameTemplate(opts.Format.SpanNameTemplate); err != nil {
+			return nil, err
+		}
+		return fielder, nil
+	}, nil
+}
+
+// newGenerator creates the generator for the requested signal, with fielders
+// built from opts; it returns an error if the options are invalid or the sender
+// doesn't support the signal.
+func newGenerator(log Logger, sender Sender, opts *Options) (Generator, error) {
+	newFielder, err := newFielderFactory(opts)
+	if err != nil {
+		return nil, err
+	}
+	switch opts.Output.Signal {
+	case "metrics":
+		msender, ok := sender.(MetricSender)
+		if !ok {
+			return nil, fmt.Errorf("the %s sender can't send metrics", opts.Output.Sender)
+		}
+		if err := checkMetricHists(opts.Fields); err != nil {
+			return nil, err
+		}
+		fielder, err := newFielder(opts.Global.Seed)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create fields as specified: %w", err)
+		}
+		return NewMetricGenerator(msender, fielder, log, opts), nil
+	case "logs":
+		lsender, ok := sender.(LogSender)
+		if !ok {
+			return nil, fmt.Errorf("the %s sender can't send logs", opts.Output.Sender)
+		}
+		fielder, err := newFielder(opts.Global.Seed)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create fields as specified: %w", err)
+		}
+		generator, err := NewLogGenerator(lsender, fielder, log, opts)
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate logs: %w", err)
+		}
+		return generator, nil
+	default:
+		generator, err := NewTraceGenerator(sender, newFielder, log, opts)
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate traces: %w", err)
+		}
+		return generator, nil
+	}
+}
+
+// checkOptions returns an error for the options that are out of range or that
+// don't go together, whatever the sender; the field options are checked when
+// the generator is created.
+func checkOptions(opts *Options) error {
+	if (len(opts.Format.RootFields) > 0 || len(opts.Format.LeafFields) > 0) && opts.Output.Signal != "traces" {
+		return fmt.Errorf("--rootfield and --leaffield can only be used for traces")
+	}
+	if opts.Output.Retries < 0 || opts.Output.RetryBackoff < 0 {
+		return fmt.Errorf("retries %d and retrybackoff %v must not be negative", opts.Output.Retries, opts.Output.RetryBackoff)
+	}
+	if opts.Output.MaxBytes < 0 {
+		return fmt.Errorf("maxbytes %d must not be negative", opts.Output.MaxBytes)
+	}
+	if opts.Quantity.TPSCap < 0 {
+		return fmt.Errorf("tpscap %v must not be negative", opts.Quantity.TPSCap)
+	}
+	if opts.Quantity.SpanRate < 0 {
+		return fmt.Errorf("targetspanrate %v must not be negative", opts.Quantity.SpanRate)
+	}
+	if opts.Quantity.DriftTol < 0 || opts.Quantity.DriftWindow < 1 {
+		return fmt.Errorf("drifttolerance %v must not be negative, and driftwindow %d must be at least 1", opts.Quantity.DriftTol, opts.Quantity.DriftWindow)
+	}
+	if opts.Output.Progress < 0 {
+		return fmt.Errorf("progressinterval %v must not be negative", opts.Output.Progress)
+	}
+	return nil
+}
+
+// Main runs the loadgen command with the process's arguments; it exits the
+// process if it fails.
+func Main() {
+	cmdopts := newOptions()
+
+	parser := flags.NewParser(cmdopts, flags.Default)
+	parser.Usage = `[OPTIONS] [FIELD=VALUE]...
+
+	loadgen generates telemetry loads for performance testing, load testing, and
+	functionality testing. It allows you to specify the number of spans in a trace,
+	the depth (nesting level) of traces, the duration of traces, as well as the
+	number of fields in spans. It supports setting the number of traces per second
+	it generates, and can generate a specific quanity of traces, or run for a
+	specific amount of time, or both. It can also control the speed at which it
+	ramps up and down to the target rate.
+
+	It can generate OTLP or Honeycomb-formatted traces, and send them to Honeycomb
+	or (for OTLP) to any OTel agent. It can also send Zipkin v2 JSON to a Zipkin
+	endpoint, or msgpack traces to a Datadog agent, or write spans to a CSV file.
+
+	You can specify fields to be added to each span. Each field should be specified as
+	FIELD=VALUE. The value can be a constant (and will be sent as the appropriate type),
+	or a generator function starting with /.
+	Allowed generators are /i, /ir, /ig, /f, /fr, /fg, /s, /sx, /sw, /sq, /sz, /b, /k, optionally
+	followed by a single number or a comma-separated pair of numbers.
+	Example generators:
+		- /s -- alphanumeric string of length 16
+		- /sx32 -- hex string of 32 characters
+		- /sw12 -- pronounceable words with cardinality 12 with rectangular distribution
+		- /sq4 -- pronounceable words with cardinality 4 with quadratic distribution
+		- /sz100,1.5 -- pronounceable words with cardinality 100 with zipfian distribution of skew 1.5
+		- /ir100 -- int in a range of 0 to 100
+		- /fg50,30 -- float in a gaussian distribution with mean 50 and stddev 30
+		- /b33.3 -- boolean, true or false -- probability of true is 33.3% (default 50%)
+		- /u -- https url-like, no query string, two path segments; default cardinality is 10/10 but can be changed like /u3,20
+		- /uq -- as /u above, but with query string containing a random key word with a completely random value
+		- /st -- an http status code by default reflecting 95% 200s, 4% 400s, 1% 500s. 400s and 500s can be changed like /st10,0.1.
+		- /k50,60 -- an intermittent key field with total cardinality 50, but decreasing key frequency. All keys only arrive after 60 seconds
+		- /ip6 -- a public IPv6 address; "/ipc 10.0.0.0/8" -- an address within the given network
+		- /geo -- a "lat,lon" string; /geo30,-120,45,-70 limits it to a bounding box of min lat, min lon, max lat, max lon
+		- /uuid -- a random (version 4) UUID; /ulid -- a ULID, which sorts by time
+		- /dur10,5000 -- a duration string like "1.2s" or "340ms", from 10ms to 5s
+		- /money1,9999 -- an amount in dollars and cents, like 123.45; /moneyl1,9999 makes small amounts more common
+		- /sql -- a parameterized SQL statement on one of 10 tables with 8 columns; /sql3,5 uses 3 tables with 5 columns
+		- /seq -- 0, 1, 2, ... across the whole run; /seq1000,2 starts at 1000 and counts by 2
+		- /seqts -- ms timestamps that increase by 1-10ms with each span of a trace; /seqts5,50 changes the range
+		- @/uuid -- any generator prefixed with @ is drawn once per trace and repeated on all of its spans
+		- "/enum a:70,b:30" -- one of the listed values, chosen according to the weights (note the space)
+		- /useragent -- a browser's or bot's User-Agent string; "/useragent bots" is mostly bots, "/useragent 0.3" is 30% bots
+		- "/b64 512" -- 512 random bytes, base64-encoded, to test large attributes (up to 1MiB; note the space)
+		- "/arr s,5" -- an array of 5 words (or i, f, or b for ints, floats, or bools)
+		- "/hist 100,10,50" -- bucket counts that sum to 100, for boundaries 10 and 50 (note the space)
+		- "/latency http.status 100,10,5" -- a duration in ms, 5 times longer when http.status is a 5xx
+		- /iserror -- true on the spans that have an error status (otel only)
+		- "/svc {service}-queue" -- the pattern with the span's service in place of {service}
+
+	Field names can be alphanumeric with underscores. If a field name is prefixed with
+	a number and a dot (e.g. 1.foo=bar) the field will only be injected into spans at
+	that level of nesting (where 0 is the root span).
+
+	Fields can also be specified in the config file as key/value pairs under the "fields" key,
+	or in environment variables like LOADGEN_FIELD_name=spec. Fields on the command line
+	take precedence over the environment, which takes precedence over the config file.
+
+	Options can be set in a config file, or on the command line; to specify them in the
+	config file, specify it on the command line with "--config=FILENAME". The config file
+	format is YAML; see "example.yml" for an example.
+
+	Note: If a config file is used, it MUST be used for all options, except for the ones
+	marked in the help text with (*) -- these fields CANNOT be set in the config file.
+
+	For more detail, see https://github.com/honeycombio/loadgen/
+	`
+
+	// the senders are registered rather than listed in the options
+	for _, name := range []string{"sender", "tee"} {
+		parser.FindOptionByLongName(name).Choices = senderNames
+	}
+
+	// read the command line and envvars into cmdargs
+	args, err := parser.Parse()
+	if err != nil {
+		switch flagsErr := err.(type) {
+		case *flags.Error:
+			if flagsErr.Type == flags.ErrHelp {
+				os.Exit(0)
+			}
+		}
+		log.Fatalf("error reading command line: %v", err)
+	}
+
+	// until we've read the config file, log as the command line says
+	log := cmdopts.Logger()
+
+	opts := newOptions()
+	if cmdopts.Global.Config != "" {
+		if err := ReadConfig(log, opts, cmdopts.Global.Config); err != nil {
+			log.Fatal("err %v -- unable to read config file %s\n", err, cmdopts.Global.Config)
+		}
+		opts.CopyStarredFieldsFrom(cmdopts)
+	} else {
+		opts = cmdopts // we don't have to read from a file
+	}
+
+	// fields from the environment override the config file's
+	for name, spec := range envFields(os.Environ()) {
+		opts.Fields[name] = spec
+	}
+
+	// split the args into opts.Fields, potentially overwriting
+	for _, arg := range args {
+		s := strings.SplitN(arg, "=", 2)
+		if len(s) < 2 {
+			log.Fatal("field `%s` missing required '='\n", s)
+		}
+		opts.Fields[s[0]] = s[1]
+	}
+
+	if opts.Global.WriteCfg != "" {
+		err := WriteConfig(log, opts, opts.Global.WriteCfg)
+		if err != nil {
+			log.Fatal("unable to write config: %s\n", err)
+		}
+		os.Exit(0)
+	}
+
+	// fields given explicitly take precedence over the --httpfields ones
+	if opts.Format.HTTPFields != "" {
+		for name, spec := range httpFieldSpecs(opts.Format.HTTPFields) {
+			if _, ok := opts.Fields[name]; !ok {
+				opts.Fields[name] = spec
+			}
+		}
+	}
+
+	if opts.Global.Validate {
+		if !validateFields(os.Stdout, opts) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if opts.Global.DebugPort > 0 {
+		go func() {
+			http.ListenAndServe(fmt.Sprintf("localhost:%d", opts.Global.DebugPort), nil)
+		}()
+	}
+
+	// if we're not given a trace count or a runtime, send only 1 trace
+	// (unless we're replaying, which stops at the end of the file, or
+	// limiting the bytes, which stops at the limit)
+	if opts.Quantity.TraceCount == 0 && opts.Quantity.RunTime == 0 && opts.Output.Replay == "" && opts.Output.MaxBytes == 0 {
+		opts.Quantity.TraceCount = 1
+	}
+
+	log = opts.Logger()
+
+	opts.apihost = parseHost(log, opts.Telemetry.Host, opts.Telemetry.Insecure)
+	if opts.Telemetry.Hosts != "" {
+		if opts.Output.Sender != "otel" || opts.Output.Signal != "traces" {
+			log.Fatal("--hosts can only be used with the otel sender for traces\n")
+		}
+		for _, host := range strings.Split(opts.Telemetry.Hosts, ",") {
+			opts.apihosts = append(opts.apihosts, parseHost(log, strings.TrimSpace(host), opts.Telemetry.Insecure))
+		}
+	}
+	if opts.Format.SyntheticTime && opts.Output.Sender != "otel" {
+		log.Fatal("--synthetictime can only be used with the otel sender\n")
+	}
+	if opts.Format.Fuzz != 0 && opts.Output.Sender != "otel" {
+		log.Fatal("--fuzz can only be used with the otel sender\n")
+	}
+	if err := checkOptions(opts); err != nil {
+		log.Fatal("%v\n", err)
+	}
+
+	if opts.Format.RunLabel == "" {
+		opts.Format.RunLabel = NewRng(fmt.Sprint(time.Now().UnixNano())).WordPair()
+	}
+	log.Info("run label: %s\n", opts.Format.RunLabel)
+	if opts.Telemetry.Suffix == "auto" {
+		opts.Telemetry.Suffix = autoDatasetSuffix(os.Getenv, time.Now())
+	}
+	if opts.Output.Cardinality {
+		opts.cardinality = newCardinalityCounter()
+	}
+
+	log.Info("host: %s, dataset: %s, apikey: ...%4.4s\n", opts.apihost.String(), resolveDataset(opts, "(per service)"), opts.Telemetry.APIKey)
+
+	sender := newSender(log, opts.Output.Sender, opts)
+
+	if _, ok := sender.(ErrorCounter); opts.Output.MaxErrors > 0 && !ok {
+		log.Fatal("the %s sender doesn't count send errors, so --maxerrors can't be used\n", opts.Output.Sender)
+	}
+
+	// create a stop channel so we can shut down gracefully
+	stop := make(chan struct{})
+
+	// with --maxbytes, the sender adds up the sizes of the spans it serializes
+	// and stops everything
+	if opts.Output.MaxBytes > 0 {
+		if opts.Output.Signal != "traces" {
+			log.Fatal("--maxbytes can only be used for traces\n")
+		}
+		sender = NewSenderBudget(sender, log, opts.Output.MaxBytes, stop)
+	}
+
+	if opts.Output.Tee != "" {
+		if opts.Output.Signal != "traces" {
+			log.Fatal("--tee can only be used for traces\n")
+		}
+		if opts.Output.Tee == opts.Output.Sender {
+			log.Fatal("--tee must be a different sender than --sender\n")
+		}
+		sender = NewSenderTee(sender, newSender(log, opts.Output.Tee, opts))
+	}
+
+	if opts.Output.MaxBytesPerSec > 0 {
+		if opts.Output.Signal != "traces" {
+			log.Fatal("--maxbytespersec can only be used for traces\n")
+		}
+		sender = NewSenderLimited(sender, log, opts.Output.MaxBytesPerSec)
+	}
+
+	if opts.Output.Signal == "traces" {
+		// report how steadily traces were started, compared to the target TPS
+		tps := opts.Quantity.TPS
+		if opts.Output.Replay == "" && len(opts.Profiles) > 0 {
+			tps = 0
+			for _, popts := range opts.ProfileOptions() {
+				tps += popts.Quantity.TPS
+			}
+		}
+		sender = NewSenderTimed(sender, log, tps)
+	}
+
+	var summarized *SenderSummarized
+	if opts.Output.SummaryJSON != "" || opts.Quantity.SpanRate != 0 {
+		if opts.Output.Signal != "traces" {
+			log.Fatal("--summaryjson and --targetspanrate can only be used for traces\n")
+		}
+		summarized = NewSenderSummarized(sender, opts.Quantity.Warmup)
+		sender = summarized
+	}
+
+	// with --countby=spans, the sender counts the spans and stops everything,
+	// and the trace counter only hands out counts
+	maxTraces := opts.Quantity.TraceCount
+	if opts.Quantity.CountBy == "spans" {
+		if opts.Output.Signal != "traces" {
+			log.Fatal("--countby=spans can only be used for traces\n")
+		}
+		if maxTraces > 0 {
+			sender = NewSenderCounted(sender, log, maxTraces, opts.Quantity.Warmup, stop)
+		}
+		maxTraces = 0
+	}
+	// and a waitgroup so we can wait for everything to finish
+	wg := &sync.WaitGroup{}
+
+	// catch ctrl-c or SIGTERM and close the stop channel, and exit on a second one;
+	// we don't want a wait group for this one, or we'll never exit
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, os.Interrupt, syscall.SIGTERM)
+	go watchSignals(log, sigch, stop, os.Exit)
+
+	// Start the trace counter to keep track of how many traces we've sent and
+	// stop the generator when we've reached the limit. We don't want to close
+	// counterChan until we're done with everything else because the generators
+	// block on it and we want that.
+	wg.Add(1)
+	counterChan := make(chan int64)
+	defer close(counterChan)
+	go func() {
+		if _, stopped := TraceCounter(log, maxTraces, opts.Quantity.Warmup, counterChan, stop); !stopped {
+			// give the senders a chance to finish sending
+			time.Sleep(1 * time.Second)
+			closeStop(stop)
+		}
+		wg.Done()
+	}()
+
+	// with --tpscap, the counts are handed out no faster than that
+	counts := counterChan
+	if opts.Quantity.TPSCap > 0 {
+		counts = make(chan int64)
+		go LimitCounts(counterChan, counts, opts.Quantity.TPSCap, stop)
+	}
+
+	// stop early if the endpoint looks to be down
+	errorsExceeded := make(chan bool, 1)
+	if opts.Output.MaxErrors > 0 {
+		counter := sender.(ErrorCounter)
+		go func() {
+			errorsExceeded <- watchErrors(log, counter, opts.Output.MaxErrors, stop, 100*time.Millisecond)
+		}()
+	} else {
+		errorsExceeded <- false
+	}
+
+	// start a load generator for each profile to create spans and send them,
+	// or a replayer to re-send captured ones
+	var controllers []TPSController
+	var skippers []Skipper
+	start := time.Now()
+	if opts.Output.Replay != "" {
+		if opts.Output.Signal != "traces" {
+			log.Fatal("only traces can be replayed\n")
+		}
+		if opts.Output.Sender != "otel" && opts.Output.Sender != "print" {
+			log.Fatal("--replay can only be used with the otel and print senders\n")
+		}
+		replayer, err := NewReplayer(sender, log, opts)
+		if err != nil {
+			log.Fatal("unable to replay traces: %s\n", err)
+		}
+		wg.Add(1)
+		go replayer.Generate(opts, wg, stop, counts)
+	} else {
+		for _, popts := range opts.ProfileOptions() {
+			generator, err := newGenerator(log, sender, popts)
+			if err != nil {
+				log.Fatal("%v\n", err)
+			}
+			if c, ok := generator.(TPSController); ok {
+				controllers = append(controllers, c)
+			}
+			if s, ok := generator.(Skipper); ok {
+				skippers = append(skippers, s)
+			}
+			wg.Add(1)
+			go generator.Generate(popts, wg, stop, counts)
+		}
+	}
+
+	if opts.Quantity.SpanRate > 0 {
+		if len(controllers) == 0 {
+			log.Fatal("--targetspanrate can't be used with --replay\n")
+		}
+		go newSpanRateController(opts.Quantity.SpanRate, summarized.counts, controllers, log).run(time.Second, stop)
+	}
+
+	if opts.Quantity.DriftTol > 0 {
+		if len(controllers) == 0 || opts.Quantity.Burst {
+			log.Fatal("--drifttolerance can't be used with --replay or --burst\n")
+		}
+		go newTPSDriftWatcher(opts.Quantity.DriftTol/100, opts.Quantity.DriftWindow, controllers, log).run(time.Second, opts.Quantity.RampTime, stop)
+	}
+
+	if opts.Output.Progress > 0 && len(controllers) > 0 {
+		errors, _ := sender.(ErrorCounter)
+		go newProgressReporter(controllers, errors, log, start).run(opts.Output.Progress, stop)
+	}
+
+	if opts.Global.ControlPort > 0 {
+		go func() {
+			handler := newControlHandler(log, controllers)
+			if err := http.ListenAndServe(fmt.Sprintf("localhost:%d", opts.Global.ControlPort), handler); err != nil {
+				log.Error("control port: %v\n", err)
+			}
+		}()
+	}
+
+	// wait for things to finish
+	wg.Wait()
+	sender.Close()
+	if opts.cardinality != nil {
+		opts.cardinality.report(os.Stderr)
+	}
+	if opts.Output.SummaryJSON != "" {
+		var dropped int64
+		for _, s := range skippers {
+			dropped += s.Skipped()
+		}
+		var rejected int64
+		if rejecter, ok := sender.(RejectCounter); ok {
+			rejected = rejecter.RejectedSpans()
+		}
+		if err := writeSummary(opts.Output.SummaryJSON, summarized.summary(time.Since(start), dropped, rejected)); err != nil {
+			log.Error("unable to write the summary to %s: %v\n", opts.Output.SummaryJSON, err)
+		}
+	}
+	if <-errorsExceeded {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,166 @@
+package loadgen
+
+import (
+	"bytes"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOptions_ProfileOptions(t *testing.T) {
+	opts := newOptions()
+	opts.Format.Depth = 3
+	opts.Format.NSpans = 5
+	opts.Quantity.TPS = 10
+	opts.Fields["shared"] = "1"
+	opts.Fields["overridden"] = "top"
+
+	if popts := opts.ProfileOptions(); len(popts) != 1 || popts[0] != opts {
+		t.Fatalf("expected the original options without profiles")
+	}
+
+	opts.Profiles = []Profile{
+		{Name: "deep", Depth: 10, Fields: map[string]string{"overridden": "deep"}},
+		{Name: "busy", TPS: 100},
+	}
+	popts := opts.ProfileOptions()
+	if len(popts) != 2 {
+		t.Fatalf("expected 2 profiles, got %d", len(popts))
+	}
+	deep, busy := popts[0], popts[1]
+	if deep.Format.Depth != 10 || deep.Format.NSpans != 5 || deep.Quantity.TPS != 10 {
+		t.Errorf("deep profile didn't inherit correctly: %+v %+v", deep.Format, deep.Quantity)
+	}
+	if busy.Format.Depth != 3 || busy.Quantity.TPS != 100 {
+		t.Errorf("busy profile didn't inherit correctly: %+v %+v", busy.Format, busy.Quantity)
+	}
+	if deep.Fields["shared"] != "1" || deep.Fields["overridden"] != "deep" || busy.Fields["overridden"] != "top" {
+		t.Errorf("unexpected profile fields %v %v", deep.Fields, busy.Fields)
+	}
+	if opts.Fields["overridden"] != "top" {
+		t.Errorf("profile fields leaked into the top-level options")
+	}
+	if deep.Global.Seed == busy.Global.Seed {
+		t.Errorf("profiles should have different seeds")
+	}
+}
+
+func TestProfiles_rates(t *testing.T) {
+	opts := newOptions()
+	opts.Format.Depth = 1
+	opts.Format.NSpans = 1
+	opts.Format.TraceTime = 50 * time.Millisecond
+	opts.Format.AttributesPerSpan = 3
+	opts.Format.IntrinsicAttributes = 3
+	opts.Quantity.RampTime = 10 * time.Millisecond
+	opts.Quantity.RunTime = 600 * time.Millisecond
+	opts.Output.Signal = "traces"
+	opts.Profiles = []Profile{
+		{Name: "fast", TPS: 80, Fields: map[string]string{"profile": "fast"}},
+		{Name: "slow", TPS: 20, Fields: map[string]string{"profile": "slow"}},
+	}
+
+	sender := &recordingSender{}
+	stop := make(chan struct{})
+	counter := make(chan int64)
+	wg := &sync.WaitGroup{}
+	go TraceCounter(NewLogger(0), 0, 0, counter, stop)
+	for _, popts := range opts.ProfileOptions() {
+		generator, err := newGenerator(NewLogger(0), sender, popts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go generator.Generate(popts, wg, stop, counter)
+	}
+	wg.Wait()
+
+	counts := map[any]int{}
+	for _, fields := range sender.roots {
+		counts[fields["profile"]]++
+	}
+	if counts["slow"] == 0 || counts["fast"] < 2*counts["slow"] {
+		t.Errorf("expected the fast profile to send about 4x the traces of the slow one, got %v", counts)
+	}
+}
+
+func Test_validateFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]string
+		ok     bool
+		output []string
+	}{
+		{"good specs", map[string]string{"count": "/i50,100", "name": "/sw", "answer": "42"}, true,
+			[]string{"count=/i50,100: int64 [", "name=/sw: string [", "answer=42: int64 [42 42 42]"}},
+		{"reversed range", map[string]string{"count": "/i100,50", "name": "/sw"}, false,
+			[]string{"count=/i100,50: ERROR", "name=/sw: string ["}},
+		{"unknown generator", map[string]string{"bad": "/zz"}, false,
+			[]string{"bad=/zz: ERROR"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := newOptions()
+			opts.Fields = tt.fields
+			var out bytes.Buffer
+			if ok := validateFields(&out, opts); ok != tt.ok {
+				t.Errorf("expected %v, got %v:\n%s", tt.ok, ok, out.String())
+			}
+			for _, want := range tt.output {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("expected output to contain %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func Test_envFields(t *testing.T) {
+	t.Setenv("LOADGEN_FIELD_status", "/sw5")
+	t.Setenv("LOADGEN_FIELD_answer", "42")
+	t.Setenv("LOADGEN_FIELD_", "/i") // no name
+	t.Setenv("LOADGEN_FIELDS", "/b")
+	fields := envFields(os.Environ())
+	want := map[string]string{"status": "/sw5", "answer": "42"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("expected %v, got %v", want, fields)
+	}
+
+	fielder, err := NewFielder("test", fields, 0, 1, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := fielder.GetFields(0, 0)
+	if _, ok := values["status"].(string); !ok {
+		t.Errorf("expected a string status field, got %v", values)
+	}
+	if values["answer"] != int64(42) {
+		t.Errorf("expected answer to be 42, got %v", values["answer"])
+	}
+}
+
+func Test_checkEndpoint(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := &url.URL{Scheme: "http", Host: l.Addr().String()}
+	if err := checkEndpoint(open, time.Second); err != nil {
+		t.Errorf("expected %s to be reachable, got %v", open.Host, err)
+	}
+
+	// once the port is closed, the check fails at once instead of timing out
+	l.Close()
+	begin := time.Now()
+	if err := checkEndpoint(open, 5*time.Second); err == nil {
+		t.Errorf("expected an error for the closed port %s", open.Host)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("expected the check to fail fast, took %v", elapsed)
+	}
+}
@@ -0,0 +1,77 @@
+package loadgen
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceIDsKey marks the context of a trace whose ids are derived from its
+// count, and counts its spans, so that each one's id is derived from its
+// place in the trace.
+type traceIDsKey struct{}
+
+type traceIDs struct {
+	count int64
+	spans atomic.Int64
+}
+
+// seededIDs is an IDGenerator for the otel sender's tracer providers that
+// derives a trace's ids from the seed, the id space (--idspace), and the
+// trace's count, so that runs with the same ones produce the same ids, to test
+// deduplication. Since the spans of a trace are created in the same order every
+// time, each span's id is derived from the trace's id and its place in the
+// trace. Spans outside of a counted trace, like replayed ones, get random ids.
+type seededIDs struct {
+	space string
+}
+
+var _ sdktrace.IDGenerator = (*seededIDs)(nil)
+
+func newSeededIDs(seed, space string) *seededIDs {
+	if space == "" {
+		return nil
+	}
+	return &seededIDs{space: seed + "/" + space}
+}
+
+// contextFor marks the context of a new trace with the given count; a nil
+// seededIDs, or a trace without a count, leaves it unmarked.
+func (g *seededIDs) contextFor(ctx context.Context, count int64) context.Context {
+	if g == nil || count <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, traceIDsKey{}, &traceIDs{count: count})
+}
+
+func (g *seededIDs) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	var tid trace.TraceID
+	if ids, ok := ctx.Value(traceIDsKey{}).(*traceIDs); ok {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", g.space, ids.count)))
+		copy(tid[:], sum[:])
+	} else {
+		rand.Read(tid[:])
+	}
+	return tid, g.NewSpanID(ctx, tid)
+}
+
+func (g *seededIDs) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	var sid trace.SpanID
+	ids, ok := ctx.Value(traceIDsKey{}).(*traceIDs)
+	if !ok {
+		rand.Read(sid[:])
+		return sid
+	}
+	var b [len(traceID) + 8]byte
+	copy(b[:], traceID[:])
+	binary.BigEndian.PutUint64(b[len(traceID):], uint64(ids.spans.Add(1)))
+	sum := sha256.Sum256(b[:])
+	copy(sid[:], sum[:])
+	return sid
+}
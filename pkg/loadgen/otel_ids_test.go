@@ -0,0 +1,69 @@
+package loadgen
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSeededIDs(t *testing.T) {
+	// run sends 5 traces of a root and two children, and returns the ids of
+	// their spans; traces with a count of 0 aren't counted
+	run := func(space string, counted bool) []string {
+		exporter := tracetest.NewInMemoryExporter()
+		sender := &SenderOTel{opts: newOptions(), exceptions: newExceptionGen("test", nil), exporters: []*sharedExporter{{SpanExporter: exporter, log: NewLogger(0)}}, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0), ids: newSeededIDs("seed", space)}
+		fielder := newTestFielder(t, 3)
+		for i := 1; i <= 5; i++ {
+			count := int64(i)
+			if !counted {
+				count = 0
+			}
+			ctx, root := sender.CreateTrace(context.Background(), "frontend", fielder, count)
+			childctx, child := sender.CreateSpan(ctx, "frontend", 1, fielder)
+			_, grandchild := sender.CreateSpan(childctx, "backend", 2, fielder)
+			grandchild.Send()
+			child.Send()
+			root.Send()
+		}
+		for _, tp := range sender.providers {
+			tp.ForceFlush(context.Background())
+		}
+		var ids []string
+		for _, span := range exporter.GetSpans() {
+			if !span.SpanContext.IsValid() {
+				t.Fatalf("expected valid ids, got %v", span.SpanContext)
+			}
+			ids = append(ids, span.SpanContext.TraceID().String()+"/"+span.SpanContext.SpanID().String())
+		}
+		// each service has its own provider, and they're flushed in no particular order
+		sort.Strings(ids)
+		return ids
+	}
+
+	first := run("dedup", true)
+	if len(first) != 5*4 {
+		t.Fatalf("expected 20 spans, got %d", len(first))
+	}
+	if again := run("dedup", true); !reflect.DeepEqual(first, again) {
+		t.Errorf("expected the same ids for the same seed and space, got %v and %v", first, again)
+	}
+	if other := run("other", true); reflect.DeepEqual(first, other) {
+		t.Errorf("expected different ids for another space")
+	}
+	if random := run("dedup", false); reflect.DeepEqual(random, run("dedup", false)) {
+		t.Errorf("expected random ids for traces without a count")
+	}
+	// the spans of a trace share its id, and no two have the same span id
+	traces, spans := map[string]bool{}, map[string]bool{}
+	for _, id := range first {
+		traces[id[:32]] = true
+		spans[id[33:]] = true
+	}
+	if len(traces) != 5 || len(spans) != 20 {
+		t.Errorf("expected 5 trace ids and 20 span ids, got %d and %d", len(traces), len(spans))
+	}
+}
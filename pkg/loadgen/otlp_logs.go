@@ -0,0 +1,293 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpLogExporter batches log records and exports them with OTLP. The OTel SDK
+// for logs isn't one of our dependencies, so this builds the export requests
+// from the OTLP protobuf types directly; that also lets it support http/json,
+// which the trace exporters don't.
+type otlpLogExporter struct {
+	protocol  string
+	url       string
+	headers   map[string]string
+	client    *http.Client
+	retry     retryPolicy
+	conn      *grpc.ClientConn
+	logs      collogspb.LogsServiceClient
+	dataset   string
+	suffix    string // --datasetsuffix
+	scope     *commonpb.InstrumentationScope
+	resource  []*commonpb.KeyValue // added to service.name on every resource
+	batchSize int
+	mut       sync.Mutex
+	batch     []pendingLog
+	dropped   int
+	errors    atomic.Int64
+	stop      chan struct{}
+	done      chan struct{}
+	log       Logger
+}
+
+// newOTLPLogExporter creates a log exporter; if tlsConfig isn't nil, it's used
+// for the connection to the collector.
+func newOTLPLogExporter(log Logger, opts *Options, headers map[string]string, resourceAttrs []attribute.KeyValue, tlsConfig *tls.Config) (*otlpLogExporter, error) {
+	batchSize := opts.BatchSize(defaultBatchSize)
+	e := &otlpLogExporter{
+		protocol:  opts.Output.Protocol,
+		headers:   headers,
+		dataset:   opts.Telemetry.Dataset,
+		suffix:    opts.Telemetry.Suffix,
+		scope:     &commonpb.InstrumentationScope{Name: ResourceLibrary, Version: ResourceVersion},
+		resource:  otlpResourceAttributes(resourceAttrs),
+		batchSize: batchSize,
+		retry:     newRetryPolicy(log, opts),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		log:       log,
+	}
+	switch e.protocol {
+	case "grpc":
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		creds := credentials.NewTLS(tlsConfig)
+		if opts.Telemetry.Insecure {
+			creds = insecure.NewCredentials()
+		}
+		conn, err := grpc.NewClient(opts.apihost.Host, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, err
+		}
+		e.conn = conn
+		e.logs = collogspb.NewLogsServiceClient(conn)
+	case "protobuf", "json":
+		e.url = otelTracesFromURL(opts.apihost) + "/v1/logs"
+		e.client = &http.Client{Timeout: 10 * time.Second}
+		if tlsConfig != nil {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.TLSClientConfig = tlsConfig
+			e.client.Transport = transport
+		}
+	default:
+		return nil, fmt.Errorf("unknown protocol: %s", e.protocol)
+	}
+	interval := opts.Output.FlushInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	go e.flushPeriodically(interval)
+	return e, nil
+}
+
+// pendingLog is a converted record waiting to be exported, along with the service that generated it.
+type pendingLog struct {
+	service string
+	record  *logspb.LogRecord
+}
+
+// otlpValue converts a generated field value to an OTLP attribute value.
+func otlpValue(value any) *commonpb.AnyValue {
+	switch v := value.(type) {
+	case int64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}
+	case float64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+	case bool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+	case string:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}
+	case []string:
+		return otlpArray(v)
+	case []int64:
+		return otlpArray(v)
+	case []float64:
+		return otlpArray(v)
+	case []bool:
+		return otlpArray(v)
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(v)}}
+	}
+}
+
+func otlpArray[T any](values []T) *commonpb.AnyValue {
+	arr := &commonpb.ArrayValue{Values: make([]*commonpb.AnyValue, len(values))}
+	for i, v := range values {
+		arr.Values[i] = otlpValue(v)
+	}
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: arr}}
+}
+
+func otlpResourceAttributes(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	kvs := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		kvs = append(kvs, otlpKeyValue(string(kv.Key), kv.Value.AsString()))
+	}
+	return kvs
+}
+
+func otlpKeyValue(key string, value any) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: otlpValue(value)}
+}
+
+// emit converts a record and adds it to the current batch, exporting the batch if it's full.
+func (e *otlpLogExporter) emit(service string, record LogRecord) {
+	attrs := make([]*commonpb.KeyValue, 0, len(record.Fields)+1)
+	attrs = append(attrs, otlpKeyValue("service", service))
+	for k, v := range record.Fields {
+		attrs = append(attrs, otlpKeyValue(k, v))
+	}
+	tid, sid := record.TraceID, record.SpanID
+	lr := &logspb.LogRecord{
+		TimeUnixNano:         uint64(record.Time.UnixNano()),
+		ObservedTimeUnixNano: uint64(record.Time.UnixNano()),
+		SeverityNumber:       logspb.SeverityNumber(record.SeverityNumber),
+		SeverityText:         record.SeverityText,
+		Body:                 otlpValue(record.Body),
+		Attributes:           attrs,
+		TraceId:              tid[:],
+		SpanId:               sid[:],
+	}
+
+	e.mut.Lock()
+	e.batch = append(e.batch, pendingLog{service: service, record: lr})
+	if len(e.batch) < e.batchSize {
+		e.mut.Unlock()
+		return
+	}
+	batch := e.batch
+	e.batch = nil
+	e.mut.Unlock()
+	e.export(batch)
+}
+
+func (e *otlpLogExporter) flush() {
+	e.mut.Lock()
+	batch := e.batch
+	e.batch = nil
+	e.mut.Unlock()
+	e.export(batch)
+}
+
+func (e *otlpLogExporter) flushPeriodically(interval time.Duration) {
+	defer close(e.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			e.flush()
+		}
+	}
+}
+
+// request builds an export request with one resource per dataset, so that
+// without a --dataset each service's logs go to a dataset of their own.
+func (e *otlpLogExporter) request(batch []pendingLog) *collogspb.ExportLogsServiceRequest {
+	req := &collogspb.ExportLogsServiceRequest{}
+	byDataset := make(map[string]*logspb.ScopeLogs)
+	for _, p := range batch {
+		dataset := datasetName(e.dataset, e.suffix, p.service)
+		sl, ok := byDataset[dataset]
+		if !ok {
+			sl = &logspb.ScopeLogs{Scope: e.scope}
+			byDataset[dataset] = sl
+			req.ResourceLogs = append(req.ResourceLogs, &logspb.ResourceLogs{
+				Resource: &resourcepb.Resource{Attributes: append([]*commonpb.KeyValue{
+					otlpKeyValue("service.name", dataset),
+				}, e.resource...)},
+				ScopeLogs: []*logspb.ScopeLogs{sl},
+			})
+		}
+		sl.LogRecords = append(sl.LogRecords, p.record)
+	}
+	return req
+}
+
+func (e *otlpLogExporter) export(batch []pendingLog) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req := e.request(batch)
+	var err error
+	if e.logs != nil {
+		err = e.retry.do("log export", func() error {
+			_, err := e.logs.Export(metadata.NewOutgoingContext(ctx, metadata.New(e.headers)), req)
+			return err
+		})
+	} else {
+		err = e.post(ctx, req)
+	}
+	if err != nil {
+		e.log.Error("unable to export %d log records: %v\n", len(batch), err)
+		e.errors.Add(1)
+		e.mut.Lock()
+		e.dropped += len(batch)
+		e.mut.Unlock()
+		return
+	}
+	e.log.Debug("exported %d log records\n", len(batch))
+}
+
+func (e *otlpLogExporter) post(ctx context.Context, req *collogspb.ExportLogsServiceRequest) error {
+	var body []byte
+	var err error
+	contentType := "application/x-protobuf"
+	if e.protocol == "json" {
+		contentType = "application/json"
+		body, err = protojson.Marshal(req)
+	} else {
+		body, err = proto.Marshal(req)
+	}
+	if err != nil {
+		return err
+	}
+	return e.retry.postWithRetry(e.client, "log export", func() (*http.Request, error) {
+		hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		hreq.Header.Set("Content-Type", contentType)
+		for k, v := range e.headers {
+			hreq.Header.Set(k, v)
+		}
+		return hreq, nil
+	})
+}
+
+// shutdown stops the periodic flush and exports anything that's left.
+func (e *otlpLogExporter) shutdown() {
+	close(e.stop)
+	<-e.done
+	e.flush()
+	if e.conn != nil {
+		e.conn.Close()
+	}
+	if e.dropped > 0 {
+		e.log.Warn("dropped %d log records that couldn't be exported\n", e.dropped)
+	}
+}
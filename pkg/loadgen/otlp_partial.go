@@ -0,0 +1,38 @@
+package loadgen
+
+import (
+	"regexp"
+	"strconv"
+	"sync/atomic"
+)
+
+// partialSuccessPattern matches the error that the otlptrace exporters hand to
+// the OTel error handler when a collector accepts a request but rejects some
+// of its spans. The error's type is internal to the exporters, so its message
+// is all there is to go on.
+var partialSuccessPattern = regexp.MustCompile(`(?s)^OTLP partial success: (.*) \((\d+) spans rejected\)$`)
+
+// partialSuccesses is the OTel error handler of the otel sender. It counts the
+// spans that collectors rejected in partial successes, and logs their messages
+// along with any other errors from the SDK, which would otherwise go to the
+// standard logger.
+type partialSuccesses struct {
+	rejected atomic.Int64
+	log      Logger
+}
+
+func newPartialSuccesses(log Logger) *partialSuccesses {
+	return &partialSuccesses{log: log}
+}
+
+// Handle implements otel.ErrorHandler.
+func (p *partialSuccesses) Handle(err error) {
+	matches := partialSuccessPattern.FindStringSubmatch(err.Error())
+	if matches == nil {
+		p.log.Error("otel: %v\n", err)
+		return
+	}
+	n, _ := strconv.ParseInt(matches[2], 10, 64)
+	p.rejected.Add(n)
+	p.log.Error("collector rejected %d spans: %s\n", n, matches[1])
+}
@@ -0,0 +1,73 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSenderOTel_partialSuccess(t *testing.T) {
+	// a collector that rejects 2 spans of every request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{
+			PartialSuccess: &coltracepb.ExportTracePartialSuccess{RejectedSpans: 2, ErrorMessage: "timestamps too old"},
+		})
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	opts := newOptions()
+	opts.Telemetry.Insecure = true
+	opts.Output.Protocol = "protobuf"
+	opts.apihost, _ = url.Parse(server.URL)
+	traceExporter, err := newTraceExporter(opts, opts.apihost, nil, newRetryPolicy(NewLogger(0), opts), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial := newPartialSuccesses(NewLogger(0))
+	otel.SetErrorHandler(partial)
+	exporter := &sharedExporter{SpanExporter: traceExporter, host: opts.apihost.Host, log: NewLogger(0)}
+	sender := &SenderOTel{opts: opts, exceptions: newExceptionGen("test", nil), exporters: []*sharedExporter{exporter}, partial: partial, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0)}
+
+	fielder := newTestFielder(t, 2)
+	const ntraces = 3
+	for i := 0; i < ntraces; i++ {
+		ctx, root := sender.CreateTrace(context.Background(), "svc", fielder, int64(i+1))
+		_, child := sender.CreateSpan(ctx, "svc", 1, fielder)
+		child.Send()
+		root.Send()
+		// a request for each trace
+		for _, tp := range sender.providers {
+			tp.ForceFlush(context.Background())
+		}
+	}
+
+	if n := sender.RejectedSpans(); n != 2*ntraces {
+		t.Errorf("expected %d rejected spans, got %d", 2*ntraces, n)
+	}
+	if n := exporter.exported.Load(); n != 2*ntraces {
+		t.Errorf("expected %d spans exported, got %d", 2*ntraces, n)
+	}
+	if n := sender.SendErrors(); n != 0 {
+		t.Errorf("expected a partial success not to be an error, got %d errors", n)
+	}
+}
+
+func TestPartialSuccesses_Handle(t *testing.T) {
+	p := newPartialSuccesses(NewLogger(0))
+	p.Handle(errors.New("OTLP partial success: bad\n(attributes) (7 spans rejected)"))
+	p.Handle(errors.New("OTLP partial success: empty message (3 log records rejected)"))
+	p.Handle(errors.New("connection refused"))
+	if n := p.rejected.Load(); n != 7 {
+		t.Errorf("expected only the 7 rejected spans to be counted, got %d", n)
+	}
+}
@@ -0,0 +1,53 @@
+package loadgen
+
+import "time"
+
+// progressReporter logs a line every so often with how far along a run is
+// (--progressinterval), as a heartbeat for long runs whose logs are being
+// watched: the time since it started, the traces the generators have started,
+// the TPS achieved since the last line, and the sends that have failed.
+type progressReporter struct {
+	controllers []TPSController
+	errors      ErrorCounter // nil if the sender doesn't count them
+	log         Logger
+
+	start time.Time
+	last  time.Time
+	sent  int64 // the traces sent as of the last line
+}
+
+func newProgressReporter(controllers []TPSController, errors ErrorCounter, log Logger, start time.Time) *progressReporter {
+	return &progressReporter{controllers: controllers, errors: errors, log: log, start: start, last: start}
+}
+
+// step logs the progress as of now.
+func (p *progressReporter) step(now time.Time) {
+	var sent int64
+	for _, c := range p.controllers {
+		sent += c.Status().Traces
+	}
+	var tps float64
+	if elapsed := now.Sub(p.last).Seconds(); elapsed > 0 {
+		tps = float64(sent-p.sent) / elapsed
+	}
+	var errors int64
+	if p.errors != nil {
+		errors = p.errors.SendErrors()
+	}
+	p.log.Info("progress: %v elapsed, %d traces sent, %.1f TPS, %d errors\n", now.Sub(p.start).Round(time.Second), sent, tps, errors)
+	p.sent, p.last = sent, now
+}
+
+// run logs the progress every interval until stop is closed.
+func (p *progressReporter) run(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			p.step(now)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package loadgen
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fixedErrors counts a fixed number of send errors.
+type fixedErrors int64
+
+func (e fixedErrors) SendErrors() int64 { return int64(e) }
+
+func TestProgressReporter_step(t *testing.T) {
+	c := &fixedController{tps: 100}
+	buf := &bytes.Buffer{}
+	start := time.Now()
+	p := newProgressReporter([]TPSController{c}, fixedErrors(3), &logger{level: LevelInfo, out: buf}, start)
+	c.sent = 50
+	p.step(start.Add(time.Second))
+	c.sent = 250
+	p.step(start.Add(3 * time.Second))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 progress lines, got %q", buf.String())
+	}
+	for i, want := range []string{"1s elapsed, 50 traces sent, 50.0 TPS, 3 errors", "3s elapsed, 250 traces sent, 100.0 TPS, 3 errors"} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("expected line %d to contain %q, got %q", i, want, lines[i])
+		}
+	}
+
+	// it's logged at info level
+	buf.Reset()
+	p = newProgressReporter([]TPSController{c}, nil, &logger{level: LevelWarn, out: buf}, start)
+	p.step(start.Add(time.Second))
+	if buf.Len() != 0 {
+		t.Errorf("expected no progress at warn level, got %q", buf.String())
+	}
+}
+
+func TestProgressReporter_run(t *testing.T) {
+	opts := newOptions()
+	opts.Format.Depth = 1
+	opts.Format.NSpans = 1
+	opts.Format.TraceTime = 10 * time.Millisecond
+	opts.Quantity.TPS = 100
+	opts.Quantity.RampTime = 10 * time.Millisecond
+	sender := &recordingSender{}
+	gen, err := NewTraceGenerator(sender, func(string) (*Fielder, error) { return newTestFielder(t, 1), nil }, NewLogger(0), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	counter := make(chan int64)
+	wg := &sync.WaitGroup{}
+	go TraceCounter(NewLogger(0), 0, 0, counter, stop)
+	wg.Add(1)
+	go gen.Generate(opts, wg, stop, counter)
+	out := &lockedBuffer{}
+	log := &logger{level: LevelInfo, out: out}
+	go newProgressReporter([]TPSController{gen}, nil, log, time.Now()).run(20*time.Millisecond, stop)
+	defer func() {
+		closeStop(stop)
+		wg.Wait()
+	}()
+
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if strings.Contains(out.String(), "progress:") {
+			return
+		}
+	}
+	t.Errorf("expected a progress line, got %q", out.String())
+}
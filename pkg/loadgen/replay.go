@@ -0,0 +1,287 @@
+package loadgen
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// capturedSpan is a span read from a capture, with its times as offsets from
+// the start of its trace.
+type capturedSpan struct {
+	service  string
+	name     string
+	start    time.Duration
+	end      time.Duration
+	fields   map[string]any
+	children []*capturedSpan
+}
+
+// capturedTrace is a root span and its descendants.
+type capturedTrace struct {
+	root *capturedSpan
+}
+
+// readCapturedTraces reads the output of --sender=print --printformat=json and
+// rebuilds each trace from the span ids, in the order the traces first appear.
+// Lines that aren't JSON objects (like the print sender's summary) are skipped.
+// A span whose parent isn't in the capture is treated as a child of the root,
+// and a trace without a root span is an error.
+func readCapturedTraces(r io.Reader) ([]*capturedTrace, error) {
+	type captured struct {
+		printedSpan
+		start, end time.Time
+	}
+	var order []string
+	byTrace := make(map[string][]*captured)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var c captured
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		if err := dec.Decode(&c.printedSpan); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineno, err)
+		}
+		var err error
+		if c.start, err = time.Parse(time.RFC3339Nano, c.StartTime); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineno, err)
+		}
+		if c.end, err = time.Parse(time.RFC3339Nano, c.EndTime); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineno, err)
+		}
+		if _, ok := byTrace[c.TraceId]; !ok {
+			order = append(order, c.TraceId)
+		}
+		byTrace[c.TraceId] = append(byTrace[c.TraceId], &c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	traces := make([]*capturedTrace, 0, len(order))
+	for _, traceID := range order {
+		spans := byTrace[traceID]
+		var root *captured
+		traceStart := spans[0].start
+		for _, c := range spans {
+			if root == nil && c.ParentId == "" {
+				root = c
+			}
+			if c.start.Before(traceStart) {
+				traceStart = c.start
+			}
+		}
+		if root == nil {
+			return nil, fmt.Errorf("trace %s has no root span", traceID)
+		}
+
+		bySpan := make(map[string]*capturedSpan, len(spans))
+		for _, c := range spans {
+			fields := make(map[string]any, len(c.Fields))
+			for k, v := range c.Fields {
+				fields[k] = capturedValue(v)
+			}
+			bySpan[c.SpanId] = &capturedSpan{
+				service: c.Service,
+				name:    c.Name,
+				start:   c.start.Sub(traceStart),
+				end:     c.end.Sub(traceStart),
+				fields:  fields,
+			}
+		}
+		rootSpan := bySpan[root.SpanId]
+		for _, c := range spans {
+			if c == root {
+				continue
+			}
+			parent, ok := bySpan[c.ParentId]
+			if !ok {
+				parent = rootSpan
+			}
+			parent.children = append(parent.children, bySpan[c.SpanId])
+		}
+		for _, s := range bySpan {
+			sort.SliceStable(s.children, func(i, j int) bool { return s.children[i].start < s.children[j].start })
+		}
+		traces = append(traces, &capturedTrace{root: rootSpan})
+	}
+	return traces, nil
+}
+
+// capturedValue converts a value decoded from JSON back to the type the field
+// generators produce; whole numbers become int64, and arrays are typed by their
+// first element.
+func capturedValue(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		if len(v) == 0 {
+			return []string{}
+		}
+		switch capturedValue(v[0]).(type) {
+		case int64:
+			a := make([]int64, len(v))
+			for i := range v {
+				a[i], _ = capturedValue(v[i]).(int64)
+			}
+			return a
+		case float64:
+			a := make([]float64, len(v))
+			for i := range v {
+				switch n := capturedValue(v[i]).(type) {
+				case int64:
+					a[i] = float64(n)
+				case float64:
+					a[i] = n
+				}
+			}
+			return a
+		case bool:
+			a := make([]bool, len(v))
+			for i := range v {
+				a[i], _ = v[i].(bool)
+			}
+			return a
+		default:
+			a := make([]string, len(v))
+			for i := range v {
+				a[i] = fmt.Sprint(v[i])
+			}
+			return a
+		}
+	case nil:
+		return ""
+	default:
+		return v
+	}
+}
+
+// newReplayFielder returns a Fielder that always produces the captured span's
+// name and all of its fields, with their captured values.
+func newReplayFielder(s *capturedSpan) *Fielder {
+	fields := make(map[string]func() any, len(s.fields))
+	keys := make([]string, 0, len(s.fields))
+	for k, v := range s.fields {
+		fields[k] = func() any { return v }
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return &Fielder{
+		fields:              fields,
+		keys:                keys,
+		attributesPerSpan:   len(keys),
+		intrinsicAttributes: len(keys),
+		spanName:            s.name,
+	}
+}
+
+// Replayer is a Generator that re-sends captured traces through a live sender
+// instead of generating new ones. It starts one trace every 1/TPS seconds, in
+// the order they were captured, and stops once it has started them all. Each
+// trace's spans are given explicit times at the same offsets from the start of
+// the trace as when they were captured, as with --synthetictime, so their
+// timestamps are rebased to now; nothing sleeps, and a trace is sent as soon as
+// it's started.
+type Replayer struct {
+	tps    float64
+	traces []*capturedTrace
+	log    Logger
+	sender Sender
+}
+
+// make sure it implements Generator
+var _ Generator = (*Replayer)(nil)
+
+func NewReplayer(tsender Sender, log Logger, opts *Options) (*Replayer, error) {
+	f, err := os.Open(opts.Output.Replay)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	traces, err := readCapturedTraces(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", opts.Output.Replay, err)
+	}
+	log.Info("replaying %d traces from %s\n", len(traces), opts.Output.Replay)
+	return &Replayer{
+		tps:    float64(opts.Quantity.TPS),
+		traces: traces,
+		log:    log,
+		sender: tsender,
+	}, nil
+}
+
+// Generate replays the traces until they've all been started, the stop channel
+// is closed, the counter stops handing out counts, or the runtime expires. The
+// traces are sent one at a time in its goroutine, so the sender is never called
+// concurrently.
+func (r *Replayer) Generate(opts *Options, wg *sync.WaitGroup, stop chan struct{}, counter chan int64) {
+	defer wg.Done()
+	if len(r.traces) == 0 {
+		r.log.Warn("there are no traces to replay\n")
+		closeStop(stop)
+		return
+	}
+	next := 0
+	runAtTPS(r.log, r.tps, opts.Quantity.RunTime, stop, counter, func(count int64) {
+		if next == len(r.traces) {
+			// a tick can still be taken once the stop is closed
+			return
+		}
+		r.replay(r.traces[next])
+		next++
+		if next == len(r.traces) {
+			r.log.Info("all %d traces replayed\n", next)
+			closeStop(stop)
+		}
+	})
+}
+
+// replay sends a trace, one span at a time, with the spans' captured offsets
+// from now as their times.
+func (r *Replayer) replay(t *capturedTrace) {
+	start := time.Now()
+	ctx, root := r.sender.CreateTrace(withCapturedTimes(context.Background(), start, t.root), t.root.service, newReplayFielder(t.root), 0)
+	r.replayChildren(ctx, start, t.root, 1)
+	root.Send()
+}
+
+// replayChildren sends the descendants of parent, each one after its own
+// descendants, as the generator does.
+func (r *Replayer) replayChildren(ctx context.Context, start time.Time, parent *capturedSpan, level int) {
+	for _, child := range parent.children {
+		childctx, span := r.sender.CreateSpan(withCapturedTimes(ctx, start, child), child.service, level, newReplayFielder(child))
+		r.replayChildren(childctx, start, child, level+1)
+		span.Send()
+	}
+}
+
+// withCapturedTimes asks the sender to give the next span the captured span's
+// times, as offsets from start.
+func withCapturedTimes(ctx context.Context, start time.Time, s *capturedSpan) context.Context {
+	return contextWithSpanTimes(ctx, &spanTimes{start: start.Add(s.start), end: start.Add(s.end)})
+}
+
+func (r *Replayer) TPS() float64 {
+	return r.tps
+}
@@ -0,0 +1,188 @@
+package loadgen
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readPrintedSpans parses the output of the print sender with --printformat=json.
+func readPrintedSpans(t *testing.T, out string) []printedSpan {
+	t.Helper()
+	var spans []printedSpan
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		var span printedSpan
+		if err := json.Unmarshal(scanner.Bytes(), &span); err != nil {
+			t.Fatalf("line %q isn't JSON: %v", scanner.Text(), err)
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+func TestReplayer_roundTrip(t *testing.T) {
+	opts := newOptions()
+	opts.Output.PrintFormat = "json"
+	opts.Quantity.TPS = 100
+	fielder, err := NewFielder("replay", map[string]string{"n": "/i", "f": "/f", "s": "/sx8", "b": "/b", "a": "/arr i,3"}, 0, 3, 6, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fielder.SetSpanNameTemplate("http"); err != nil {
+		t.Fatal(err)
+	}
+
+	// capture a trace with a root, two children, and a grandchild
+	capture := captureStdout(t, func() {
+		sender := NewSenderPrint(NewLogger(0), opts)
+		ctx, root := sender.CreateTrace(context.Background(), "svc", fielder, 1)
+		ctx1, child1 := sender.CreateSpan(ctx, "svc1", 1, fielder)
+		_, grandchild := sender.CreateSpan(ctx1, "svc2", 2, fielder)
+		grandchild.Send()
+		child1.Send()
+		_, child2 := sender.CreateSpan(ctx, "svc3", 1, fielder)
+		child2.Send()
+		root.Send()
+	})
+	original := readPrintedSpans(t, capture)
+
+	traces, err := readCapturedTraces(strings.NewReader(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(traces) != 1 {
+		t.Fatalf("expected 1 trace, got %d", len(traces))
+	}
+
+	replayed := readPrintedSpans(t, captureStdout(t, func() {
+		r := &Replayer{tps: 100, traces: traces, log: NewLogger(0), sender: NewSenderPrint(NewLogger(0), opts)}
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		stop := make(chan struct{})
+		counter := make(chan int64, 1)
+		counter <- 1
+		r.Generate(opts, wg, stop, counter)
+	}))
+	if len(replayed) != len(original) {
+		t.Fatalf("expected %d spans, got %d", len(original), len(replayed))
+	}
+
+	// the ids are new, so match spans up by their position in the tree
+	shape := func(spans []printedSpan) map[string]printedSpan {
+		byID := make(map[string]printedSpan)
+		for _, s := range spans {
+			byID[s.SpanId] = s
+		}
+		paths := make(map[string]printedSpan)
+		for _, s := range spans {
+			path := s.Service
+			for p := s; p.ParentId != ""; {
+				p = byID[p.ParentId]
+				path = p.Service + "/" + path
+			}
+			paths[path] = s
+		}
+		return paths
+	}
+	want, got := shape(original), shape(replayed)
+	for path, w := range want {
+		g, ok := got[path]
+		if !ok {
+			t.Errorf("no replayed span at %s in %v", path, got)
+			continue
+		}
+		if g.Name != w.Name {
+			t.Errorf("%s: expected name %s, got %s", path, w.Name, g.Name)
+		}
+		if !reflect.DeepEqual(g.Fields, w.Fields) {
+			t.Errorf("%s: expected fields %v, got %v", path, w.Fields, g.Fields)
+		}
+		if g.TraceId == w.TraceId {
+			t.Errorf("%s: expected a new trace id", path)
+		}
+	}
+}
+
+func TestReplayer_rebasesTimestamps(t *testing.T) {
+	capture := `{"Service":"svc","Name":"root","TraceId":"t1","SpanId":"r","ParentId":"","StartTime":"2020-01-01T00:00:00Z","EndTime":"2020-01-01T00:00:00.1Z","Fields":{"count":1}}
+{"Service":"svc","Name":"child","TraceId":"t1","SpanId":"c","ParentId":"r","StartTime":"2020-01-01T00:00:00.05Z","EndTime":"2020-01-01T00:00:00.08Z","Fields":{"x":1.5}}
+sender sent 1 traces with 2 spans
+`
+	traces, err := readCapturedTraces(strings.NewReader(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := traces[0].root
+	if root.end != 100*time.Millisecond || len(root.children) != 1 {
+		t.Fatalf("unexpected root %+v", root)
+	}
+	child := root.children[0]
+	if child.start != 50*time.Millisecond || child.end != 80*time.Millisecond {
+		t.Errorf("unexpected child offsets %v-%v", child.start, child.end)
+	}
+	if _, ok := root.fields["count"].(int64); !ok {
+		t.Errorf("expected an int64 count, got %T", root.fields["count"])
+	}
+
+	opts := newOptions()
+	opts.Output.PrintFormat = "json"
+	before := time.Now()
+	replayed := readPrintedSpans(t, captureStdout(t, func() {
+		r := &Replayer{traces: traces, log: NewLogger(0), sender: NewSenderPrint(NewLogger(0), opts)}
+		r.replay(traces[0])
+	}))
+	after := time.Now()
+	if len(replayed) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(replayed))
+	}
+	times := func(s printedSpan) (time.Time, time.Time) {
+		start, _ := time.Parse(time.RFC3339Nano, s.StartTime)
+		end, _ := time.Parse(time.RFC3339Nano, s.EndTime)
+		return start, end
+	}
+	cstart, cend := times(replayed[0])
+	rstart, rend := times(replayed[1])
+	if rstart.Before(before) || rstart.After(after) {
+		t.Errorf("expected the root to start between %v and %v, not %v", before, after, rstart)
+	}
+	if d := cstart.Sub(rstart); d != 50*time.Millisecond {
+		t.Errorf("expected the child to start 50ms into the trace, got %v", d)
+	}
+	if d := cend.Sub(cstart); d != 30*time.Millisecond {
+		t.Errorf("expected the child to take 30ms, got %v", d)
+	}
+	if d := rend.Sub(rstart); d != 100*time.Millisecond {
+		t.Errorf("expected the root to take 100ms, got %v", d)
+	}
+}
+
+func TestReplayer_stopsAfterLastTrace(t *testing.T) {
+	capture := `{"Service":"svc","Name":"root","TraceId":"t1","SpanId":"r","ParentId":"","StartTime":"2020-01-01T00:00:00Z","EndTime":"2020-01-01T00:00:00.1Z","Fields":{}}
+`
+	traces, err := readCapturedTraces(strings.NewReader(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// replaying takes longer than a tick, so ticks are pending once the stop is
+	// closed, and the counter still has counts, as it does without --tracecount
+	for i := 0; i < 20; i++ {
+		sender := &slowSender{delay: 5 * time.Millisecond}
+		r := &Replayer{tps: 1000, traces: traces, log: NewLogger(0), sender: sender}
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		counter := make(chan int64, 10)
+		for n := int64(1); n <= 10; n++ {
+			counter <- n
+		}
+		r.Generate(newOptions(), wg, make(chan struct{}), counter)
+		if sender.traces != 1 {
+			t.Fatalf("expected the trace to be replayed once, got %d", sender.traces)
+		}
+	}
+}
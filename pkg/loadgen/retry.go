@@ -0,0 +1,113 @@
+package loadgen
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+)
+
+// retryPolicy retries failed sends with exponential backoff.
+type retryPolicy struct {
+	retries int
+	backoff time.Duration
+	log     Logger
+}
+
+func newRetryPolicy(log Logger, opts *Options) retryPolicy {
+	return retryPolicy{
+		retries: opts.Output.Retries,
+		backoff: opts.Output.RetryBackoff,
+		log:     log,
+	}
+}
+
+// doubled returns twice the wait, or the longest duration if that overflows.
+func doubled(wait time.Duration) time.Duration {
+	if wait > math.MaxInt64/2 {
+		return math.MaxInt64
+	}
+	return wait * 2
+}
+
+// maxInterval is the wait before the last retry.
+func (p retryPolicy) maxInterval() time.Duration {
+	wait := p.backoff
+	for i := 1; i < p.retries && wait < math.MaxInt64; i++ {
+		wait = doubled(wait)
+	}
+	return wait
+}
+
+// maxElapsed is the total time spent waiting if every retry is needed; it's
+// how the policy is expressed to exporters that don't count their retries.
+// However many retries there are, it's at most the longest duration.
+func (p retryPolicy) maxElapsed() time.Duration {
+	var total time.Duration
+	wait := p.backoff
+	for i := 0; i < p.retries && wait > 0; i++ {
+		if total > math.MaxInt64-wait {
+			return math.MaxInt64
+		}
+		total += wait
+		wait = doubled(wait)
+	}
+	return total
+}
+
+// statusError is returned for an unsuccessful HTTP response.
+type statusError struct {
+	status string
+	code   int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("endpoint returned %s", e.status)
+}
+
+// retryable reports whether the error might go away if the request is sent again.
+func (e statusError) retryable() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= 500
+}
+
+// do calls send until it succeeds or has been retried p.retries times, waiting
+// backoff, 2*backoff, 4*backoff, ... between attempts, and returns the last error.
+// Responses that can't succeed on a retry (like a 400) are not retried.
+func (p retryPolicy) do(what string, send func() error) error {
+	wait := p.backoff
+	for attempt := 0; ; attempt++ {
+		err := send()
+		if err == nil {
+			return nil
+		}
+		var se statusError
+		if attempt >= p.retries || (errors.As(err, &se) && !se.retryable()) {
+			return err
+		}
+		p.log.Debug("retrying %s in %v after error: %v\n", what, wait, err)
+		time.Sleep(wait)
+		wait = doubled(wait)
+	}
+}
+
+// postWithRetry sends the requests made by newRequest, retrying transient failures according to the policy.
+func (p retryPolicy) postWithRetry(client *http.Client, what string, newRequest func() (*http.Request, error)) error {
+	return p.do(what, func() error {
+		req, err := newRequest()
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode >= 300 {
+			return statusError{status: resp.Status, code: resp.StatusCode}
+		}
+		return nil
+	})
+}
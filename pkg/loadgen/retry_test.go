@@ -0,0 +1,117 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyTransport fails the first failures requests with a 503, then succeeds.
+type flakyTransport struct {
+	failures int
+	requests int
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.requests++
+	status := http.StatusAccepted
+	if f.requests <= f.failures {
+		status = http.StatusServiceUnavailable
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func TestSenderZipkin_retry(t *testing.T) {
+	tests := []struct {
+		name     string
+		retries  int
+		requests int
+		dropped  int
+	}{
+		{"succeeds after retries", 3, 3, 0},
+		{"too few retries", 1, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := newOptions()
+			opts.Output.Retries = tt.retries
+			opts.Output.RetryBackoff = time.Millisecond
+			opts.Output.BatchSize = 10
+			sender := NewSenderZipkin(NewLogger(0), opts)
+			transport := &flakyTransport{failures: 2}
+			sender.client.Transport = transport
+
+			fielder := newTestFielder(t, 1)
+			ctx, root := sender.CreateTrace(context.Background(), "svc", fielder, 1)
+			_, child := sender.CreateSpan(ctx, "svc", 1, fielder)
+			child.Send()
+			root.Send()
+			sender.Close()
+
+			if transport.requests != tt.requests {
+				t.Errorf("expected %d requests, got %d", tt.requests, transport.requests)
+			}
+			if sender.dropped != tt.dropped {
+				t.Errorf("expected %d dropped spans, got %d", tt.dropped, sender.dropped)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_do(t *testing.T) {
+	policy := retryPolicy{retries: 5, backoff: time.Millisecond, log: NewLogger(0)}
+	attempts := 0
+	err := policy.do("test", func() error {
+		attempts++
+		return statusError{status: "400 Bad Request", code: http.StatusBadRequest}
+	})
+	if err == nil || attempts != 1 {
+		t.Errorf("a 400 shouldn't be retried; got %d attempts and error %v", attempts, err)
+	}
+
+	attempts = 0
+	start := time.Now()
+	err = policy.do("test", func() error {
+		attempts++
+		return errors.New("connection refused")
+	})
+	if err == nil || attempts != 6 {
+		t.Errorf("expected 6 attempts and an error, got %d and %v", attempts, err)
+	}
+	if elapsed := time.Since(start); elapsed < policy.maxElapsed() {
+		t.Errorf("expected to wait at least %v, waited %v", policy.maxElapsed(), elapsed)
+	}
+}
+
+func TestRetryPolicy_limits(t *testing.T) {
+	for _, tt := range []struct {
+		retries              int
+		backoff              time.Duration
+		maxInterval, elapsed time.Duration
+	}{
+		{0, 100 * time.Millisecond, 100 * time.Millisecond, 0},
+		{3, 100 * time.Millisecond, 400 * time.Millisecond, 700 * time.Millisecond},
+		{3, 0, 0, 0},
+		// the waits stop doubling rather than overflowing
+		{100, time.Second, math.MaxInt64, math.MaxInt64},
+		{1 << 30, time.Hour, math.MaxInt64, math.MaxInt64},
+	} {
+		policy := retryPolicy{retries: tt.retries, backoff: tt.backoff, log: NewLogger(0)}
+		if got := policy.maxInterval(); got != tt.maxInterval {
+			t.Errorf("%d retries from %v: expected a last wait of %v, got %v", tt.retries, tt.backoff, tt.maxInterval, got)
+		}
+		if got := policy.maxElapsed(); got != tt.elapsed {
+			t.Errorf("%d retries from %v: expected to wait %v in all, got %v", tt.retries, tt.backoff, tt.elapsed, got)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package loadgen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// roleFields are the fields that only some spans have because of their place
+// in a trace, rather than their level: the root of each trace (--rootfield)
+// and the spans without children (--leaffield).
+type roleFields struct {
+	root map[string]func() any
+	leaf map[string]func() any
+}
+
+// parseRoleFields splits --rootfield or --leaffield specs of the form
+// name=spec into a map of field specs.
+func parseRoleFields(flag string, specs []string) (map[string]string, error) {
+	fields := make(map[string]string, len(specs))
+	for _, kv := range specs {
+		name, spec, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s `%s` should be name=spec", flag, kv)
+		}
+		fields[name] = spec
+	}
+	return fields, nil
+}
+
+// SetRoleFields gives every root span the root fields and every leaf span the
+// leaf fields, which are specified like the ordinary ones. A span is a leaf if
+// the generator marks it as one with setLeaf, so a root is never a leaf, even
+// in a trace with a single span.
+func (f *Fielder) SetRoleFields(root, leaf map[string]string) error {
+	rootGens, err := parseUserFields(f.rng, root)
+	if err != nil {
+		return err
+	}
+	leafGens, err := parseUserFields(f.rng, leaf)
+	if err != nil {
+		return err
+	}
+	f.roles = roleFields{root: rootGens, leaf: leafGens}
+	return nil
+}
+
+// setLeaf marks the spans the fielder makes fields for as leaves until it's
+// called again. The generator sets it around creating a span without children.
+func (f *Fielder) setLeaf(leaf bool) {
+	f.leaf = leaf
+}
+
+// addRoleFields adds the root fields to a root span (level 0), and the leaf
+// fields to a span marked as a leaf.
+func (f *Fielder) addRoleFields(values map[string]any, level int) {
+	gens := f.roles.leaf
+	if level == 0 {
+		gens = f.roles.root
+	} else if !f.leaf {
+		return
+	}
+	for k, gen := range gens {
+		values[k] = gen()
+	}
+}
@@ -0,0 +1,101 @@
+package loadgen
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type spanIDKey struct{}
+
+// roleSender records the fields of every span, and which spans have children.
+type roleSender struct {
+	mut      sync.Mutex
+	levels   []int
+	fields   []map[string]any
+	children []int
+}
+
+func (r *roleSender) add(ctx context.Context, level int, fields map[string]any) context.Context {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if parent, ok := ctx.Value(spanIDKey{}).(int); ok {
+		r.children[parent]++
+	}
+	r.levels = append(r.levels, level)
+	r.fields = append(r.fields, fields)
+	r.children = append(r.children, 0)
+	return context.WithValue(ctx, spanIDKey{}, len(r.levels)-1)
+}
+
+func (r *roleSender) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	return r.add(ctx, 0, fielder.GetFields(count, 0)), DummySendable{}
+}
+
+func (r *roleSender) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	return r.add(ctx, level, fielder.GetFields(0, level)), DummySendable{}
+}
+
+func (r *roleSender) Close() {}
+
+func TestFielder_roleFields(t *testing.T) {
+	sender := &roleSender{}
+	opts := newOptions()
+	opts.Format.Depth = 4
+	opts.Format.NSpans = 12
+	opts.Format.TraceTime = time.Second
+	opts.Format.SyntheticTime = true
+	gen, err := NewTraceGenerator(sender, nil, NewLogger(0), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fielder := newTestFielder(t, opts.Format.Depth)
+	if err := fielder.SetRoleFields(map[string]string{"tenant": "/sq5", "plan": "gold"}, map[string]string{"db.rows": "/i100"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		gen.generate_root(newServiceFielders(fielder, nil, gen.log), 1, opts.Format.Depth, opts.Format.NSpans, opts.Format.TraceTime)
+	}
+
+	var leaves, middles int
+	for i, fields := range sender.fields {
+		_, hasTenant := fields["tenant"]
+		_, hasPlan := fields["plan"]
+		_, hasRows := fields["db.rows"]
+		switch {
+		case sender.levels[i] == 0:
+			if !hasTenant || fields["plan"] != "gold" || hasRows {
+				t.Errorf("expected a root to have only the root fields, got %v", fields)
+			}
+		case sender.children[i] == 0:
+			leaves++
+			if hasTenant || hasPlan || !hasRows {
+				t.Errorf("expected a leaf at level %d to have only the leaf fields, got %v", sender.levels[i], fields)
+			}
+		default:
+			middles++
+			if hasTenant || hasPlan || hasRows {
+				t.Errorf("expected a span with children to have no role fields, got %v", fields)
+			}
+		}
+	}
+	if leaves == 0 || middles == 0 {
+		t.Errorf("expected both leaves and spans with children, got %d and %d", leaves, middles)
+	}
+}
+
+func Test_parseRoleFields(t *testing.T) {
+	fields, err := parseRoleFields("rootfield", []string{"tenant=/sq5", "url=http://x?a=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields["tenant"] != "/sq5" || fields["url"] != "http://x?a=b" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	for _, bad := range []string{"tenant", "=/sk"} {
+		if _, err := parseRoleFields("rootfield", []string{bad}); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package loadgen
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Span struct {
+	ServiceName string
+	TraceId     string
+	SpanId      string
+	ParentId    string
+	Duration    time.Duration
+	StartTime   time.Time
+	EndTime     time.Time
+	Fields      map[string]interface{}
+}
+
+func (s *Span) IsRootSpan() bool {
+	return s.ParentId == ""
+}
+
+type ObsoleteSender interface {
+	Run(wg *sync.WaitGroup, spans chan *Span, stop chan struct{})
+}
+
+type Sendable interface {
+	Send()
+}
+
+// resolveDataset returns the dataset that telemetry from the given service
+// should be sent to: the --dataset option if it's set, and otherwise a dataset
+// named for the service, either way with the --datasetsuffix.
+func resolveDataset(opts *Options, service string) string {
+	return datasetName(opts.Telemetry.Dataset, opts.Telemetry.Suffix, service)
+}
+
+// datasetName is resolveDataset for the senders that keep the dataset and
+// suffix instead of the options.
+func datasetName(dataset, suffix, service string) string {
+	if dataset == "" {
+		dataset = service
+	}
+	return dataset + suffix
+}
+
+// autoDatasetSuffix is the --datasetsuffix when it's given without a value: the
+// short commit sha of a CI job, from the variables GitHub Actions, GitLab, and
+// CircleCI set, or else the time, so that each run has datasets of its own.
+func autoDatasetSuffix(getenv func(string) string, now time.Time) string {
+	for _, name := range []string{"GITHUB_SHA", "CI_COMMIT_SHA", "CIRCLE_SHA1"} {
+		if sha := getenv(name); sha != "" {
+			if len(sha) > 7 {
+				sha = sha[:7]
+			}
+			return "-" + sha
+		}
+	}
+	return "-" + now.UTC().Format("20060102-150405")
+}
+
+type Sender interface {
+	CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable)
+	CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable)
+	Close()
+}
+
+// A MetricSender records data points derived from a Fielder's fields; it's used
+// instead of the trace methods when generating metrics.
+type MetricSender interface {
+	RecordMetrics(ctx context.Context, service string, fielder *Fielder, count int64)
+}
+
+// A LogRecord is a single generated log entry; its trace and span ids let
+// backends correlate it with a trace.
+type LogRecord struct {
+	Time           time.Time
+	TraceID        trace.TraceID
+	SpanID         trace.SpanID
+	SeverityNumber int
+	SeverityText   string
+	Body           string
+	Fields         map[string]interface{}
+}
+
+// A LogSender sends log records; it's used instead of the trace methods when generating logs.
+type LogSender interface {
+	SendLog(ctx context.Context, service string, record LogRecord)
+}
+
+// An ErrorCounter is a sender that counts the sends that failed even after
+// retrying; --maxerrors uses it to stop early when the endpoint is down.
+type ErrorCounter interface {
+	SendErrors() int64
+}
+
+// A RejectCounter is a sender that counts the spans that were delivered but
+// rejected by the backend, as in an OTLP partial success.
+type RejectCounter interface {
+	RejectedSpans() int64
+}
+
+// A QueueReporter is a sender that queues spans to send them in the background.
+// QueueFullness returns how full its queue is, from 0 (empty) to 1 (full); the
+// trace generators skip traces while it's nearly full, rather than have
+// sending block partway through a trace.
+type QueueReporter interface {
+	QueueFullness() float64
+}
+
+// senderWrapper is embedded by the senders that wrap another one, to pass
+// through its error count, rejected spans, and queue fullness.
+type senderWrapper struct {
+	Sender
+}
+
+// SendErrors passes through the wrapped sender's error count, if it keeps one.
+func (w senderWrapper) SendErrors() int64 {
+	return sendErrors(w.Sender)
+}
+
+// RejectedSpans passes through the wrapped sender's rejected spans, if it counts them.
+func (w senderWrapper) RejectedSpans() int64 {
+	return rejectedSpans(w.Sender)
+}
+
+// QueueFullness passes through the wrapped sender's queue fullness, if it has a queue.
+func (w senderWrapper) QueueFullness() float64 {
+	return queueFullness(w.Sender)
+}
+
+// sendErrors returns the sender's error count, or 0 if it doesn't keep one.
+func sendErrors(sender Sender) int64 {
+	if ec, ok := sender.(ErrorCounter); ok {
+		return ec.SendErrors()
+	}
+	return 0
+}
+
+// rejectedSpans returns the sender's rejected spans, or 0 if it doesn't count them.
+func rejectedSpans(sender Sender) int64 {
+	if rc, ok := sender.(RejectCounter); ok {
+		return rc.RejectedSpans()
+	}
+	return 0
+}
+
+// queueFullness returns how full the sender's queue is, or 0 if it doesn't have one.
+func queueFullness(sender Sender) float64 {
+	if qr, ok := sender.(QueueReporter); ok {
+		return qr.QueueFullness()
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package loadgen
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// SenderBudget wraps another Sender to add up the estimated bytes of every span
+// it sends, and closes the stop channel once they reach max (--maxbytes). The
+// sizes are estimated the same way as for --maxbytespersec, from the spans the
+// wrapped sender serializes. The traces that are in flight when it stops are
+// still finished, so somewhat more than max bytes are sent; the total is
+// reported when it's closed.
+type SenderBudget struct {
+	senderWrapper
+	max   int64
+	total atomic.Int64
+	stop  chan struct{}
+	log   Logger
+}
+
+// make sure it implements Sender
+var _ Sender = (*SenderBudget)(nil)
+
+func NewSenderBudget(sender Sender, log Logger, max int64, stop chan struct{}) *SenderBudget {
+	return &SenderBudget{senderWrapper: senderWrapper{sender}, max: max, stop: stop, log: log}
+}
+
+func (t *SenderBudget) add(n int) {
+	total := t.total.Add(int64(n))
+	if total >= t.max && total-int64(n) < t.max {
+		t.log.Info("sent an estimated %d bytes, stopping\n", total)
+		closeStop(t.stop)
+	}
+}
+
+type budgetSendable struct {
+	Sendable
+	budget   *SenderBudget
+	estimate int
+}
+
+// Size passes through the wrapped span's size, or the estimate if it can't
+// report one, so that --maxbytespersec sees the same size.
+func (s budgetSendable) Size() int {
+	if sz, ok := s.Sendable.(sizer); ok {
+		return sz.Size()
+	}
+	return s.estimate
+}
+
+func (s budgetSendable) Send() {
+	size := s.Size()
+	s.Sendable.Send()
+	s.budget.add(size)
+}
+
+func (t *SenderBudget) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	ctx, root := t.Sender.CreateTrace(ctx, name, fielder, count)
+	return ctx, budgetSendable{Sendable: root, budget: t, estimate: fielder.estimatedSize()}
+}
+
+func (t *SenderBudget) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	ctx, span := t.Sender.CreateSpan(ctx, name, level, fielder)
+	return ctx, budgetSendable{Sendable: span, budget: t, estimate: fielder.estimatedSize()}
+}
+
+func (t *SenderBudget) Close() {
+	t.Sender.Close()
+	t.log.Warn("byte budget exiting after an estimated %d bytes (limit %d)\n", t.total.Load(), t.max)
+}
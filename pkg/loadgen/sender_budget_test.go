@@ -0,0 +1,82 @@
+package loadgen
+
+import (
+	"context"
+	"testing"
+)
+
+// sizedSendable is a span that reports a fixed size.
+type sizedSendable struct {
+	DummySendable
+	size int
+}
+
+func (s sizedSendable) Size() int { return s.size }
+
+// sizedSender makes spans of a fixed size.
+type sizedSender struct {
+	recordingSender
+	size int
+}
+
+func (s *sizedSender) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	ctx, _ = s.recordingSender.CreateTrace(ctx, name, fielder, count)
+	return ctx, sizedSendable{size: s.size}
+}
+
+func (s *sizedSender) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	ctx, _ = s.recordingSender.CreateSpan(ctx, name, level, fielder)
+	return ctx, sizedSendable{size: s.size}
+}
+
+func TestSenderBudget(t *testing.T) {
+	const spansPerTrace, maxBytes = 3, 10000
+	// run makes traces of spansPerTrace spans until it's stopped, like a generator
+	run := func(sender Sender, stop chan struct{}) {
+		fielder := newTestFielder(t, 1)
+		for count := int64(1); ; count++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			ctx, root := sender.CreateTrace(context.Background(), "svc", fielder, count)
+			for i := 1; i < spansPerTrace; i++ {
+				_, child := sender.CreateSpan(ctx, "svc", 1, fielder)
+				child.Send()
+			}
+			root.Send()
+		}
+	}
+
+	t.Run("sized", func(t *testing.T) {
+		const size = 150
+		stop := make(chan struct{})
+		sizer := &sizedSender{size: size}
+		sender := NewSenderBudget(sizer, NewLogger(0), maxBytes, stop)
+		run(sender, stop)
+		// the trace in flight when the limit was reached is finished
+		total := sender.total.Load()
+		if total < maxBytes || total >= maxBytes+spansPerTrace*size {
+			t.Errorf("expected to stop within a trace of %d bytes, got %d", maxBytes, total)
+		}
+		if got := int64(len(sizer.spans) * size); got != total {
+			t.Errorf("expected %d bytes for the %d spans sent, got %d", got, len(sizer.spans), total)
+		}
+	})
+
+	t.Run("estimated", func(t *testing.T) {
+		stop := make(chan struct{})
+		recorder := &recordingSender{}
+		sender := NewSenderBudget(recorder, NewLogger(0), maxBytes, stop)
+		run(sender, stop)
+		size := newTestFielder(t, 1).estimatedSize()
+		total := sender.total.Load()
+		if total < maxBytes || total >= maxBytes+int64(spansPerTrace*size) {
+			t.Errorf("expected to stop within a trace of %d bytes, got %d", maxBytes, total)
+		}
+		if got := int64(len(recorder.spans) * size); got != total {
+			t.Errorf("expected %d bytes for the %d spans sent, got %d", got, len(recorder.spans), total)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package loadgen
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// warmupKey marks the context of a warmup trace, whose spans aren't counted.
+type warmupKey struct{}
+
+// SenderCounted wraps another Sender to count every span that passes through
+// it, and closes the stop channel once it has counted max of them (--countby
+// spans). The traces that are in flight when it stops are still finished, so a
+// few more spans than max are sent; the total is reported when it's closed.
+// The spans of the first warmup traces aren't counted.
+type SenderCounted struct {
+	senderWrapper
+	max    int64
+	warmup int64
+	count  atomic.Int64
+	stop   chan struct{}
+	log    Logger
+}
+
+// make sure it implements Sender
+var _ Sender = (*SenderCounted)(nil)
+
+func NewSenderCounted(sender Sender, log Logger, max int64, warmup int64, stop chan struct{}) *SenderCounted {
+	return &SenderCounted{senderWrapper: senderWrapper{sender}, max: max, warmup: warmup, stop: stop, log: log}
+}
+
+func (t *SenderCounted) add() {
+	if t.count.Add(1) == t.max {
+		t.log.Info("sent %d spans, stopping\n", t.max)
+		closeStop(t.stop)
+	}
+}
+
+func (t *SenderCounted) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	// replayed traces have no count, and are never warmup traces
+	if count > 0 && count <= t.warmup {
+		ctx = context.WithValue(ctx, warmupKey{}, true)
+	} else {
+		t.add()
+	}
+	return t.Sender.CreateTrace(ctx, name, fielder, count)
+}
+
+func (t *SenderCounted) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	if ctx.Value(warmupKey{}) == nil {
+		t.add()
+	}
+	return t.Sender.CreateSpan(ctx, name, level, fielder)
+}
+
+func (t *SenderCounted) Close() {
+	t.Sender.Close()
+	t.log.Warn("span counter exiting after %d spans (limit %d)\n", t.count.Load(), t.max)
+}
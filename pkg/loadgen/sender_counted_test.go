@@ -0,0 +1,74 @@
+package loadgen
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCountBy(t *testing.T) {
+	const spansPerTrace = 3
+	// run takes counts and makes traces of spansPerTrace spans until it's
+	// stopped, like a generator, and returns the number of spans that had been
+	// made when the stop channel was closed, and the total
+	run := func(sender Sender, counter chan int64, stop chan struct{}) (int, int) {
+		fielder := newTestFielder(t, 1)
+		stoppedAt, total := 0, 0
+		span := func() {
+			total++
+			select {
+			case <-stop:
+				if stoppedAt == 0 {
+					stoppedAt = total
+				}
+			default:
+			}
+		}
+		for {
+			select {
+			case <-stop:
+				return stoppedAt, total
+			case count := <-counter:
+				ctx, root := sender.CreateTrace(context.Background(), "svc", fielder, count)
+				span()
+				for i := 1; i < spansPerTrace; i++ {
+					_, child := sender.CreateSpan(ctx, "svc", 1, fielder)
+					span()
+					child.Send()
+				}
+				root.Send()
+			}
+		}
+	}
+
+	t.Run("traces", func(t *testing.T) {
+		counter := make(chan int64)
+		stop := make(chan struct{})
+		go func() {
+			TraceCounter(NewLogger(0), 4, 0, counter, stop)
+			closeStop(stop)
+		}()
+		_, total := run(&recordingSender{}, counter, stop)
+		if total != 4*spansPerTrace {
+			t.Errorf("expected 4 traces of %d spans, got %d spans", spansPerTrace, total)
+		}
+	})
+
+	t.Run("spans", func(t *testing.T) {
+		counter := make(chan int64)
+		stop := make(chan struct{})
+		go TraceCounter(NewLogger(0), 0, 2, counter, stop)
+		recorder := &recordingSender{}
+		sender := NewSenderCounted(recorder, NewLogger(0), 10, 2, stop)
+		stoppedAt, total := run(sender, counter, stop)
+		// the 2 warmup traces aren't counted, and the trace in flight is finished
+		if stoppedAt != 2*spansPerTrace+10 {
+			t.Errorf("expected to stop at the 10th span after warmup, not after %d spans", stoppedAt)
+		}
+		if total != 6*spansPerTrace {
+			t.Errorf("expected the last trace to be finished, got %d spans", total)
+		}
+		if got := sender.count.Load(); got != 4*spansPerTrace {
+			t.Errorf("expected %d counted spans, got %d", 4*spansPerTrace, got)
+		}
+	})
+}
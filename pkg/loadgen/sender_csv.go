@@ -0,0 +1,171 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// make sure it implements Sender
+var _ Sender = (*SenderCSV)(nil)
+
+// csvColumns are the columns every span has, before the columns of its fields.
+var csvColumns = []string{"trace_id", "span_id", "parent_id", "service", "name", "start", "duration_ms"}
+
+type csvRow struct {
+	tinfo    *traceInfo
+	service  string
+	name     string
+	start    time.Time
+	duration time.Duration
+	fields   map[string]any
+}
+
+type CSVSendable struct {
+	row    csvRow
+	sender *SenderCSV
+}
+
+func (s *CSVSendable) Send() {
+	s.row.duration = time.Since(s.row.start)
+	s.sender.add(s.row)
+}
+
+// SenderCSV writes each span as a row of a CSV file, for analysis in a
+// spreadsheet. Each service has its own fields, and optional fields are only
+// in some spans, so the columns aren't known until every span has been seen;
+// the spans are held until it's closed, and then the header is the intrinsic
+// columns followed by every field name in order, and a span's missing fields
+// are empty cells.
+type SenderCSV struct {
+	out      io.WriteCloser
+	filename string
+	mut      sync.Mutex
+	rows     []csvRow
+	names    map[string]bool
+	traces   int
+	log      Logger
+}
+
+type CSVKey string
+
+func NewSenderCSV(log Logger, opts *Options) *SenderCSV {
+	var out io.WriteCloser = os.Stdout
+	filename := opts.Output.OutFile
+	if filename != "" && filename != "-" {
+		f, err := os.Create(filename)
+		if err != nil {
+			log.Fatal("unable to create %s: %v\n", filename, err)
+		}
+		out = f
+	} else {
+		filename = "stdout"
+	}
+	return &SenderCSV{out: out, filename: filename, names: make(map[string]bool), log: log}
+}
+
+func (t *SenderCSV) add(row csvRow) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.rows = append(t.rows, row)
+	for name := range row.fields {
+		t.names[name] = true
+	}
+}
+
+// csvValue formats a field's value for a cell; arrays are written as JSON.
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []string, []int64, []float64, []bool:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Close writes the header and the rows, and closes the file.
+func (t *SenderCSV) Close() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	names := make([]string, 0, len(t.names))
+	for name := range t.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := csv.NewWriter(t.out)
+	w.Write(append(append([]string{}, csvColumns...), names...))
+	record := make([]string, len(csvColumns)+len(names))
+	for _, row := range t.rows {
+		record[0] = row.tinfo.TraceId
+		record[1] = row.tinfo.SpanId
+		record[2] = row.tinfo.ParentId
+		record[3] = row.service
+		record[4] = row.name
+		record[5] = row.start.Format(time.RFC3339Nano)
+		record[6] = strconv.FormatFloat(float64(row.duration)/float64(time.Millisecond), 'f', 3, 64)
+		for i, name := range names {
+			v, ok := row.fields[name]
+			if !ok {
+				record[len(csvColumns)+i] = ""
+				continue
+			}
+			record[len(csvColumns)+i] = csvValue(v)
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		t.log.Error("unable to write %s: %v\n", t.filename, err)
+	}
+	if t.out != os.Stdout {
+		if err := t.out.Close(); err != nil {
+			t.log.Error("unable to close %s: %v\n", t.filename, err)
+		}
+	}
+	t.log.Warn("sender wrote %d traces with %d spans and %d columns to %s\n", t.traces, len(t.rows), len(csvColumns)+len(names), t.filename)
+}
+
+func (t *SenderCSV) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	t.mut.Lock()
+	t.traces++
+	t.mut.Unlock()
+	tinfo := &traceInfo{TraceId: randID(16), SpanId: randID(8)}
+	ctx = context.WithValue(ctx, CSVKey("trace"), tinfo)
+	return ctx, &CSVSendable{
+		row: csvRow{
+			tinfo:   tinfo,
+			service: name,
+			name:    fielder.GetSpanName(name),
+			start:   time.Now(),
+			fields:  fielder.GetFields(count, 0),
+		},
+		sender: t,
+	}
+}
+
+func (t *SenderCSV) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	parent := ctx.Value(CSVKey("trace")).(*traceInfo)
+	tinfo := &traceInfo{TraceId: parent.TraceId, SpanId: randID(8), ParentId: parent.SpanId}
+	ctx = context.WithValue(ctx, CSVKey("trace"), tinfo)
+	return ctx, &CSVSendable{
+		row: csvRow{
+			tinfo:   tinfo,
+			service: name,
+			name:    fielder.GetSpanName(name),
+			start:   time.Now(),
+			fields:  fielder.GetFields(0, level),
+		},
+		sender: t,
+	}
+}
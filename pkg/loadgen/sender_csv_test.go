@@ -0,0 +1,79 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSenderCSV(t *testing.T) {
+	opts := newOptions()
+	opts.Output.OutFile = filepath.Join(t.TempDir(), "spans.csv")
+	sender := NewSenderCSV(NewLogger(0), opts)
+	root, err := NewFielder("csv", map[string]string{"answer": "42", "tags": "/arr s,2"}, 0, 1, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a different service's fields, which the root's spans don't have
+	other, err := NewFielder("csv", map[string]string{"ratio": "0.5"}, 0, 1, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		ctx, rootSpan := sender.CreateTrace(context.Background(), "svc", root, int64(i+1))
+		_, child := sender.CreateSpan(ctx, "other", 1, other)
+		child.Send()
+		rootSpan.Send()
+	}
+	sender.Close()
+
+	f, err := os.Open(opts.Output.OutFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// the reader fails if any row has a different number of cells than the header
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 7 {
+		t.Fatalf("expected a header and 6 rows, got %d records", len(records))
+	}
+	header := records[0]
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+	// the intrinsic columns, and answer, tags, count, process_id, and ratio
+	if want := len(csvColumns) + 5; len(header) != want {
+		t.Errorf("expected %d columns, got %d: %v", want, len(header), header)
+	}
+	spans := map[string][]string{}
+	for _, row := range records[1:] {
+		spans[row[columns["span_id"]]] = row
+	}
+	for _, row := range records[1:] {
+		switch row[columns["service"]] {
+		case "svc":
+			if row[columns["answer"]] != "42" || row[columns["ratio"]] != "" || row[columns["parent_id"]] != "" {
+				t.Errorf("unexpected root row %v", row)
+			}
+			if tags := row[columns["tags"]]; tags[0] != '[' {
+				t.Errorf("expected the array to be written as JSON, got %s", tags)
+			}
+		case "other":
+			parent, ok := spans[row[columns["parent_id"]]]
+			if !ok || parent[columns["trace_id"]] != row[columns["trace_id"]] {
+				t.Errorf("child row %v doesn't belong to a root in its trace", row)
+			}
+			if row[columns["ratio"]] != "0.5" || row[columns["answer"]] != "" {
+				t.Errorf("unexpected child row %v", row)
+			}
+		default:
+			t.Errorf("unexpected service in %v", row)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// make sure it implements Sender
+var _ Sender = (*SenderDatadog)(nil)
+
+// datadogSpan follows the span model of the Datadog agent's v0.4 trace API.
+type datadogSpan struct {
+	TraceID  uint64             `msgpack:"trace_id"`
+	SpanID   uint64             `msgpack:"span_id"`
+	ParentID uint64             `msgpack:"parent_id"`
+	Name     string             `msgpack:"name"`
+	Resource string             `msgpack:"resource"`
+	Service  string             `msgpack:"service"`
+	Type     string             `msgpack:"type"`
+	Start    int64              `msgpack:"start"`    // nanoseconds since the epoch
+	Duration int64              `msgpack:"duration"` // nanoseconds
+	Error    int32              `msgpack:"error"`
+	Meta     map[string]string  `msgpack:"meta,omitempty"`
+	Metrics  map[string]float64 `msgpack:"metrics,omitempty"`
+}
+
+type datadogKey string
+
+type DatadogSendable struct {
+	span      datadogSpan
+	startTime time.Time
+	sender    *SenderDatadog
+}
+
+func (s *DatadogSendable) Send() {
+	s.span.Start = s.startTime.UnixNano()
+	s.span.Duration = time.Since(s.startTime).Nanoseconds()
+	s.sender.enqueue(s.span)
+}
+
+// SenderDatadog converts spans to the Datadog model and POSTs them in batches
+// as msgpack to the v0.4 trace endpoint of a Datadog agent. The agent expects
+// the spans of a trace to arrive together, so each trace is held until its
+// root span is sent, which is always after its children.
+type SenderDatadog struct {
+	url        string
+	batchSize  int
+	client     *http.Client
+	retry      retryPolicy
+	mut        sync.Mutex
+	pending    map[uint64][]datadogSpan // spans of traces whose root hasn't been sent
+	batch      [][]datadogSpan
+	batchSpans int
+	tracecount int
+	nspans     int
+	dropped    int
+	errors     atomic.Int64
+	log        Logger
+}
+
+func NewSenderDatadog(log Logger, opts *Options) *SenderDatadog {
+	batchSize := opts.BatchSize(defaultBatchSize)
+	return &SenderDatadog{
+		url:       strings.TrimSuffix(opts.Output.DatadogURL, "/") + "/v0.4/traces",
+		batchSize: batchSize,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		retry:     newRetryPolicy(log, opts),
+		pending:   make(map[uint64][]datadogSpan),
+		log:       log,
+	}
+}
+
+// Close sends the batch that's waiting, along with the spans of any traces
+// that weren't finished.
+func (t *SenderDatadog) Close() {
+	t.mut.Lock()
+	batch := t.batch
+	for _, spans := range t.pending {
+		batch = append(batch, spans)
+	}
+	t.batch = nil
+	t.pending = make(map[uint64][]datadogSpan)
+	t.batchSpans = 0
+	t.mut.Unlock()
+	t.post(batch)
+	t.log.Warn("sender sent %d traces with %d spans\n", t.tracecount, t.nspans)
+	if t.dropped > 0 {
+		t.log.Warn("sender dropped %d spans that couldn't be sent\n", t.dropped)
+	}
+}
+
+func (t *SenderDatadog) enqueue(span datadogSpan) {
+	t.mut.Lock()
+	spans := append(t.pending[span.TraceID], span)
+	if span.ParentID != 0 {
+		t.pending[span.TraceID] = spans
+		t.mut.Unlock()
+		return
+	}
+	delete(t.pending, span.TraceID)
+	t.batch = append(t.batch, spans)
+	t.batchSpans += len(spans)
+	if t.batchSpans < t.batchSize {
+		t.mut.Unlock()
+		return
+	}
+	batch := t.batch
+	t.batch = nil
+	t.batchSpans = 0
+	t.mut.Unlock()
+	t.post(batch)
+}
+
+func (t *SenderDatadog) post(batch [][]datadogSpan) {
+	if len(batch) == 0 {
+		return
+	}
+	nspans := 0
+	for _, spans := range batch {
+		nspans += len(spans)
+	}
+	body, err := msgpack.Marshal(batch)
+	if err != nil {
+		t.log.Error("unable to marshal datadog traces: %v\n", err)
+		return
+	}
+	err = t.retry.postWithRetry(t.client, "datadog traces", func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/msgpack")
+		req.Header.Set("X-Datadog-Trace-Count", strconv.Itoa(len(batch)))
+		return req, nil
+	})
+	if err != nil {
+		t.log.Error("unable to send %d datadog spans: %v\n", nspans, err)
+		t.errors.Add(1)
+		t.mut.Lock()
+		t.dropped += nspans
+		t.mut.Unlock()
+		return
+	}
+	t.log.Debug("sent %d datadog traces with %d spans\n", len(batch), nspans)
+}
+
+// SendErrors returns the number of batches that couldn't be sent.
+func (t *SenderDatadog) SendErrors() int64 {
+	return t.errors.Load()
+}
+
+// datadogTags divides generated fields into Datadog's string meta and numeric metrics.
+func datadogTags(fields map[string]any) (map[string]string, map[string]float64) {
+	meta := make(map[string]string)
+	metrics := make(map[string]float64)
+	for k, v := range fields {
+		switch v := v.(type) {
+		case int64:
+			metrics[k] = float64(v)
+		case uint64:
+			metrics[k] = float64(v)
+		case float64:
+			metrics[k] = v
+		case string:
+			meta[k] = v
+		default:
+			meta[k] = fmt.Sprint(v)
+		}
+	}
+	return meta, metrics
+}
+
+// datadogID returns a random nonzero id; 0 means "no parent" to Datadog.
+func datadogID() uint64 {
+	for {
+		if id := rand.Uint64(); id != 0 {
+			return id
+		}
+	}
+}
+
+func (t *SenderDatadog) newSendable(service string, name string, traceID uint64, parentID uint64, fields map[string]any) *DatadogSendable {
+	meta, metrics := datadogTags(fields)
+	return &DatadogSendable{
+		span: datadogSpan{
+			TraceID:  traceID,
+			SpanID:   datadogID(),
+			ParentID: parentID,
+			Name:     name,
+			Resource: name,
+			Service:  service,
+			Type:     "custom",
+			Meta:     meta,
+			Metrics:  metrics,
+		},
+		startTime: time.Now(),
+		sender:    t,
+	}
+}
+
+func (t *SenderDatadog) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	t.mut.Lock()
+	t.tracecount++
+	t.nspans++
+	t.mut.Unlock()
+	ds := t.newSendable(name, fielder.GetSpanName(name), datadogID(), 0, fielder.GetFields(count, 0))
+	ctx = context.WithValue(ctx, datadogKey("span"), &ds.span)
+	return ctx, ds
+}
+
+func (t *SenderDatadog) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	t.mut.Lock()
+	t.nspans++
+	t.mut.Unlock()
+	parent := ctx.Value(datadogKey("span")).(*datadogSpan)
+	ds := t.newSendable(name, fielder.GetSpanName(name), parent.TraceID, parent.SpanID, fielder.GetFields(0, level))
+	ctx = context.WithValue(ctx, datadogKey("span"), &ds.span)
+	return ctx, ds
+}
@@ -0,0 +1,74 @@
+package loadgen
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestSenderDatadog_payload(t *testing.T) {
+	var posted [][]map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0.4/traces" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/msgpack" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		if count := r.Header.Get("X-Datadog-Trace-Count"); count != "1" {
+			t.Errorf("expected a trace count of 1, got %q", count)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := msgpack.Unmarshal(body, &posted); err != nil {
+			t.Errorf("unable to decode posted traces: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	opts := newOptions()
+	opts.Output.DatadogURL = server.URL
+	opts.Output.BatchSize = 10
+	sender := NewSenderDatadog(NewLogger(0), opts)
+	fielder, err := NewFielder("test", map[string]string{"answer": "42", "word": "hello", "ratio": "0.5"}, 0, 1, 4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, root := sender.CreateTrace(context.Background(), "svc", fielder, 1)
+	_, child := sender.CreateSpan(ctx, "svc", 1, fielder)
+	child.Send()
+	root.Send()
+	sender.Close()
+
+	if len(posted) != 1 || len(posted[0]) != 2 {
+		t.Fatalf("expected 1 trace of 2 spans, got %v", posted)
+	}
+	childSpan, rootSpan := posted[0][0], posted[0][1]
+	for _, key := range []string{"trace_id", "span_id", "parent_id", "name", "resource", "service", "start", "duration", "meta", "metrics"} {
+		if _, ok := childSpan[key]; !ok {
+			t.Errorf("span is missing key %s: %v", key, childSpan)
+		}
+	}
+	if childSpan["trace_id"] != rootSpan["trace_id"] || childSpan["parent_id"] != rootSpan["span_id"] {
+		t.Errorf("child is not linked to root: %v", posted)
+	}
+	if rootSpan["parent_id"] != uint64(0) {
+		t.Errorf("root span should have a parent_id of 0, got %v", rootSpan["parent_id"])
+	}
+	if rootSpan["service"] != "svc" || rootSpan["resource"] != "svc" {
+		t.Errorf("expected service and resource svc, got %v and %v", rootSpan["service"], rootSpan["resource"])
+	}
+	if start, ok := rootSpan["start"].(int64); !ok || start <= 0 {
+		t.Errorf("expected a start time in ns, got %v", rootSpan["start"])
+	}
+	if meta := rootSpan["meta"].(map[string]any); meta["word"] != "hello" {
+		t.Errorf("expected meta word=hello, got %v", meta)
+	}
+	metrics := rootSpan["metrics"].(map[string]any)
+	if metrics["answer"] != 42.0 || metrics["ratio"] != 0.5 || metrics["count"] != 1.0 {
+		t.Errorf("expected numeric fields in metrics, got %v", metrics)
+	}
+}
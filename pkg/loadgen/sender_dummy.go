@@ -1,4 +1,4 @@
-package main
+package loadgen
 
 import (
 	"context"
@@ -46,17 +46,29 @@ func (s DummySendable) Send() {
 type SenderDummy struct {
 	tracecount int
 	nspans     int
+	datapoints int
+	logs       int
 	log        Logger
 }
 
-// make sure it implements Sender
+// make sure it implements Sender, MetricSender, and LogSender
 var _ Sender = (*SenderDummy)(nil)
+var _ MetricSender = (*SenderDummy)(nil)
+var _ LogSender = (*SenderDummy)(nil)
 
 func NewSenderDummy(log Logger, opts *Options) Sender {
 	return &SenderDummy{log: log}
 }
 
 func (t *SenderDummy) Close() {
+	if t.logs > 0 {
+		t.log.Warn("sender sent %d log records\n", t.logs)
+		return
+	}
+	if t.datapoints > 0 {
+		t.log.Warn("sender sent %d metric data points\n", t.datapoints)
+		return
+	}
 	t.log.Warn("sender sent %d traces with %d spans\n", t.tracecount, t.nspans)
 }
 
@@ -70,3 +82,11 @@ func (t *SenderDummy) CreateSpan(ctx context.Context, name string, level int, fi
 	t.nspans++
 	return ctx, DummySendable{}
 }
+
+func (t *SenderDummy) RecordMetrics(ctx context.Context, service string, fielder *Fielder, count int64) {
+	t.datapoints++
+}
+
+func (t *SenderDummy) SendLog(ctx context.Context, service string, record LogRecord) {
+	t.logs++
+}
@@ -0,0 +1,55 @@
+package loadgen
+
+import (
+	"context"
+
+	"github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/honeycombio/beeline-go/trace"
+)
+
+type SenderHoneycomb struct {
+	opts *Options
+}
+
+// make sure it implements Sender
+var _ Sender = (*SenderHoneycomb)(nil)
+
+func NewSenderHoneycomb(log Logger, opts *Options) *SenderHoneycomb {
+	healthCheck(log, opts, opts.apihost)
+	beeline.Init(beeline.Config{
+		WriteKey:    opts.Telemetry.APIKey,
+		APIHost:     opts.apihost.String(),
+		ServiceName: resolveDataset(opts, ResourceLibrary),
+		Debug:       opts.DebugLevel() >= LevelDebug,
+	})
+	return &SenderHoneycomb{opts: opts}
+}
+
+func (t *SenderHoneycomb) Close() {
+	beeline.Close()
+}
+
+func (t *SenderHoneycomb) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	// beeline sets the dataset per trace, not per span, so the whole trace lands
+	// in the dataset for the root's service
+	ctx, tr := trace.NewTrace(ctx, &propagation.PropagationContext{Dataset: resolveDataset(t.opts, name)})
+	// a beeline span is already a Sendable
+	root := tr.GetRootSpan()
+	root.AddField("name", fielder.GetSpanName(name))
+	root.AddField("service.name", name)
+	for k, v := range fielder.GetFields(count, 0) {
+		root.AddField(k, v)
+	}
+	return ctx, root
+}
+
+func (t *SenderHoneycomb) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	// a beeline span is already a Sendable
+	ctx, span := beeline.StartSpan(ctx, fielder.GetSpanName(name))
+	span.AddField("service.name", name)
+	for k, v := range fielder.GetFields(0, level) {
+		span.AddField(k, v)
+	}
+	return ctx, span
+}
@@ -0,0 +1,198 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// make sure it implements Sender
+var _ Sender = (*SenderHoneycombDirect)(nil)
+
+// honeycombEvent is one event in the body of a Honeycomb batch request.
+type honeycombEvent struct {
+	Time string         `json:"time"`
+	Data map[string]any `json:"data"`
+}
+
+type honeycombDirectKey string
+
+type HoneycombDirectSendable struct {
+	dataset   string
+	fields    map[string]any
+	startTime time.Time
+	sender    *SenderHoneycombDirect
+}
+
+func (s *HoneycombDirectSendable) Send() {
+	s.fields["duration_ms"] = float64(time.Since(s.startTime).Microseconds()) / 1000
+	s.sender.enqueue(s.dataset, honeycombEvent{Time: s.startTime.Format(time.RFC3339Nano), Data: s.fields})
+}
+
+// SenderHoneycombDirect builds Honeycomb events itself, without the beeline,
+// and POSTs them as JSON to the batch API, so the payload is fully under our
+// control. Events are batched per dataset, and each batch is sent when it's
+// full or when the flush interval expires.
+type SenderHoneycombDirect struct {
+	opts       *Options
+	apiURL     string
+	batchSize  int
+	client     *http.Client
+	retry      retryPolicy
+	mut        sync.Mutex
+	batches    map[string][]honeycombEvent
+	tracecount int
+	nspans     int
+	dropped    int
+	errors     atomic.Int64
+	stop       chan struct{}
+	done       chan struct{}
+	log        Logger
+}
+
+func NewSenderHoneycombDirect(log Logger, opts *Options) *SenderHoneycombDirect {
+	healthCheck(log, opts, opts.apihost)
+	batchSize := opts.BatchSize(defaultBatchSize)
+	interval := opts.Output.FlushInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	t := &SenderHoneycombDirect{
+		opts:      opts,
+		apiURL:    otelTracesFromURL(opts.apihost),
+		batchSize: batchSize,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		retry:     newRetryPolicy(log, opts),
+		batches:   make(map[string][]honeycombEvent),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		log:       log,
+	}
+	go t.flushPeriodically(interval)
+	return t
+}
+
+func (t *SenderHoneycombDirect) flushPeriodically(interval time.Duration) {
+	defer close(t.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			t.flush()
+		}
+	}
+}
+
+func (t *SenderHoneycombDirect) flush() {
+	t.mut.Lock()
+	batches := t.batches
+	t.batches = make(map[string][]honeycombEvent)
+	t.mut.Unlock()
+	for dataset, batch := range batches {
+		t.post(dataset, batch)
+	}
+}
+
+func (t *SenderHoneycombDirect) Close() {
+	close(t.stop)
+	<-t.done
+	t.flush()
+	t.log.Warn("sender sent %d traces with %d spans\n", t.tracecount, t.nspans)
+	if t.dropped > 0 {
+		t.log.Warn("sender dropped %d spans that couldn't be sent\n", t.dropped)
+	}
+}
+
+func (t *SenderHoneycombDirect) enqueue(dataset string, event honeycombEvent) {
+	t.mut.Lock()
+	t.batches[dataset] = append(t.batches[dataset], event)
+	if len(t.batches[dataset]) < t.batchSize {
+		t.mut.Unlock()
+		return
+	}
+	batch := t.batches[dataset]
+	delete(t.batches, dataset)
+	t.mut.Unlock()
+	t.post(dataset, batch)
+}
+
+func (t *SenderHoneycombDirect) post(dataset string, batch []honeycombEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		t.log.Error("unable to marshal honeycomb events: %v\n", err)
+		return
+	}
+	target := t.apiURL + "/1/batch/" + url.PathEscape(dataset)
+	err = t.retry.postWithRetry(t.client, "honeycomb events", func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Honeycomb-Team", t.opts.Telemetry.APIKey)
+		return req, nil
+	})
+	if err != nil {
+		t.log.Error("unable to send %d honeycomb events: %v\n", len(batch), err)
+		t.errors.Add(1)
+		t.mut.Lock()
+		t.dropped += len(batch)
+		t.mut.Unlock()
+		return
+	}
+	t.log.Debug("sent %d honeycomb events to %s\n", len(batch), dataset)
+}
+
+// SendErrors returns the number of batches that couldn't be sent.
+func (t *SenderHoneycombDirect) SendErrors() int64 {
+	return t.errors.Load()
+}
+
+func (t *SenderHoneycombDirect) newSendable(service string, name string, traceID string, parentID string, fields map[string]any) *HoneycombDirectSendable {
+	fields["name"] = name
+	fields["service.name"] = service
+	fields["trace.trace_id"] = traceID
+	fields["trace.span_id"] = randID(8)
+	if parentID != "" {
+		fields["trace.parent_id"] = parentID
+	}
+	return &HoneycombDirectSendable{
+		dataset:   resolveDataset(t.opts, service),
+		fields:    fields,
+		startTime: time.Now(),
+		sender:    t,
+	}
+}
+
+func (t *SenderHoneycombDirect) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	t.mut.Lock()
+	t.tracecount++
+	t.nspans++
+	t.mut.Unlock()
+	hs := t.newSendable(name, fielder.GetSpanName(name), randID(16), "", fielder.GetFields(count, 0))
+	// the trace goes to the root's dataset, as with the beeline
+	ctx = context.WithValue(ctx, honeycombDirectKey("span"), hs)
+	return ctx, hs
+}
+
+func (t *SenderHoneycombDirect) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	t.mut.Lock()
+	t.nspans++
+	t.mut.Unlock()
+	parent := ctx.Value(honeycombDirectKey("span")).(*HoneycombDirectSendable)
+	hs := t.newSendable(name, fielder.GetSpanName(name), parent.fields["trace.trace_id"].(string), parent.fields["trace.span_id"].(string), fielder.GetFields(0, level))
+	hs.dataset = parent.dataset
+	ctx = context.WithValue(ctx, honeycombDirectKey("span"), hs)
+	return ctx, hs
+}
@@ -0,0 +1,75 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSenderHoneycombDirect(t *testing.T) {
+	var mut sync.Mutex
+	var paths []string
+	var events []honeycombEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Honeycomb-Team"); got != "testkey" {
+			t.Errorf("expected api key header testkey, got %q", got)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("expected json content type, got %q", got)
+		}
+		var batch []honeycombEvent
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("unable to decode batch: %v", err)
+		}
+		mut.Lock()
+		paths = append(paths, r.URL.Path)
+		events = append(events, batch...)
+		mut.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	opts := newOptions()
+	opts.apihost, _ = url.Parse(server.URL)
+	opts.Telemetry.APIKey = "testkey"
+	opts.Output.BatchSize = 10
+	opts.Output.FlushInterval = time.Hour
+	sender := NewSenderHoneycombDirect(NewLogger(0), opts)
+
+	fielder := newTestFielder(t, 1)
+	ctx, root := sender.CreateTrace(context.Background(), "svc", fielder, 1)
+	_, child := sender.CreateSpan(ctx, "svc", 1, fielder)
+	child.Send()
+	root.Send()
+	sender.Close()
+
+	if len(paths) != 1 || paths[0] != "/1/batch/svc" {
+		t.Fatalf("expected one request to /1/batch/svc, got %v", paths)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	childData, rootData := events[0].Data, events[1].Data
+	if _, err := time.Parse(time.RFC3339Nano, events[0].Time); err != nil {
+		t.Errorf("event time isn't RFC3339: %v", err)
+	}
+	if rootData["trace.trace_id"] != childData["trace.trace_id"] {
+		t.Errorf("spans should share a trace id: %v, %v", rootData["trace.trace_id"], childData["trace.trace_id"])
+	}
+	if childData["trace.parent_id"] != rootData["trace.span_id"] {
+		t.Errorf("child's parent should be the root: %v, %v", childData["trace.parent_id"], rootData["trace.span_id"])
+	}
+	if _, ok := rootData["trace.parent_id"]; ok {
+		t.Errorf("root shouldn't have a parent id")
+	}
+	for _, key := range []string{"name", "service.name", "duration_ms"} {
+		if _, ok := rootData[key]; !ok {
+			t.Errorf("expected %s in event data, got %v", key, rootData)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package loadgen
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// spanOverhead is a rough estimate of the serialized size of a span without its
+// attributes: ids, timestamps, status, and the like.
+const spanOverhead = 64
+
+// A sizer is a Sendable that can estimate how many bytes it'll take to send it.
+type sizer interface {
+	Size() int
+}
+
+// attributeSize estimates the serialized size of an attribute.
+func attributeSize(kv attribute.KeyValue) int {
+	size := len(kv.Key)
+	switch kv.Value.Type() {
+	case attribute.STRING:
+		size += len(kv.Value.AsString())
+	case attribute.BOOL:
+		size++
+	case attribute.STRINGSLICE:
+		for _, v := range kv.Value.AsStringSlice() {
+			size += len(v)
+		}
+	case attribute.BOOLSLICE:
+		size += len(kv.Value.AsBoolSlice())
+	case attribute.INT64SLICE:
+		size += 8 * len(kv.Value.AsInt64Slice())
+	case attribute.FLOAT64SLICE:
+		size += 8 * len(kv.Value.AsFloat64Slice())
+	default:
+		size += 8
+	}
+	return size
+}
+
+func (s OTelSendable) Size() int {
+	ro, ok := s.Span.(sdktrace.ReadOnlySpan)
+	if !ok {
+		return spanOverhead
+	}
+	size := spanOverhead + len(ro.Name())
+	for _, kv := range ro.Attributes() {
+		size += attributeSize(kv)
+	}
+	for _, e := range ro.Events() {
+		size += len(e.Name) + 8
+		for _, kv := range e.Attributes {
+			size += attributeSize(kv)
+		}
+	}
+	// events that are still waiting to be added when the span ends
+	for _, e := range s.events {
+		size += len(e.name) + 8
+		for _, kv := range e.attrs {
+			size += attributeSize(kv)
+		}
+	}
+	return size
+}
+
+func (s *ZipkinSendable) Size() int {
+	size := spanOverhead + len(s.span.Name)
+	for k, v := range s.span.Tags {
+		size += len(k) + len(v)
+	}
+	return size
+}
+
+// estimatedSize is used for spans that can't report their size; it assumes
+// attributesPerSpan fields with 8-byte values.
+func (f *Fielder) estimatedSize() int {
+	size := spanOverhead
+	for i := 0; i < f.attributesPerSpan && i < len(f.keys); i++ {
+		size += len(f.keys[i]) + 8
+	}
+	return size
+}
+
+// tokenBucket limits a rate of bytes per second. It starts empty and holds at
+// most a tenth of a second's worth of tokens, so that the average rate never
+// exceeds the limit and short bursts only exceed it by a little.
+type tokenBucket struct {
+	mut    sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	start  time.Time
+	total  int64
+}
+
+func newTokenBucket(bytesPerSec int) *tokenBucket {
+	now := time.Now()
+	burst := float64(bytesPerSec) / 10
+	return &tokenBucket{rate: float64(bytesPerSec), burst: burst, last: now, start: now}
+}
+
+// wait blocks until n bytes can be sent. Callers reserve their tokens before
+// sleeping, so concurrent callers queue up behind each other rather than all
+// waking at once.
+func (b *tokenBucket) wait(n int) {
+	b.mut.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	b.total += int64(n)
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mut.Unlock()
+	time.Sleep(delay)
+}
+
+// achieved returns the average rate since the bucket was created.
+func (b *tokenBucket) achieved() float64 {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return float64(b.total) / time.Since(b.start).Seconds()
+}
+
+// SenderLimited wraps another Sender to cap the bytes per second it sends
+// (--maxbytespersec). Sending a span waits until the budget allows it, which
+// blocks the generator that sent it, so the generators back off.
+type SenderLimited struct {
+	senderWrapper
+	bucket *tokenBucket
+	log    Logger
+}
+
+// make sure it implements Sender
+var _ Sender = (*SenderLimited)(nil)
+
+func NewSenderLimited(sender Sender, log Logger, bytesPerSec int) *SenderLimited {
+	return &SenderLimited{senderWrapper: senderWrapper{sender}, bucket: newTokenBucket(bytesPerSec), log: log}
+}
+
+type limitedSendable struct {
+	Sendable
+	bucket   *tokenBucket
+	estimate int
+}
+
+func (s limitedSendable) Send() {
+	size := s.estimate
+	if sz, ok := s.Sendable.(sizer); ok {
+		size = sz.Size()
+	}
+	s.bucket.wait(size)
+	s.Sendable.Send()
+}
+
+func (t *SenderLimited) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	ctx, root := t.Sender.CreateTrace(ctx, name, fielder, count)
+	return ctx, limitedSendable{Sendable: root, bucket: t.bucket, estimate: fielder.estimatedSize()}
+}
+
+func (t *SenderLimited) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	ctx, span := t.Sender.CreateSpan(ctx, name, level, fielder)
+	return ctx, limitedSendable{Sendable: span, bucket: t.bucket, estimate: fielder.estimatedSize()}
+}
+
+func (t *SenderLimited) Close() {
+	t.Sender.Close()
+	t.log.Warn("sent an estimated %.0f bytes per second (limit %.0f)\n", t.bucket.achieved(), t.bucket.rate)
+}
@@ -0,0 +1,39 @@
+package loadgen
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSenderLimited_rate(t *testing.T) {
+	const budget = 20000
+	sender := NewSenderLimited(&recordingSender{}, NewLogger(0), budget)
+	fielder := newTestFielder(t, 3)
+
+	// several generators send as fast as they can for a while
+	wg := &sync.WaitGroup{}
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				ctx, root := sender.CreateTrace(context.Background(), "svc", fielder, 1)
+				_, span := sender.CreateSpan(ctx, "svc", 1, fielder)
+				span.Send()
+				root.Send()
+			}
+		}()
+	}
+	wg.Wait()
+
+	achieved := sender.bucket.achieved()
+	if achieved > budget*1.1 {
+		t.Errorf("achieved %.0f bytes per second, more than the budget of %d", achieved, budget)
+	}
+	if achieved < budget/2 {
+		t.Errorf("achieved only %.0f bytes per second; the limiter is too strict", achieved)
+	}
+}
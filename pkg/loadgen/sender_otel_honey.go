@@ -0,0 +1,1030 @@
+package loadgen
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/honeycombio/otel-config-go/otelconfig"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+// make sure it implements Sender, MetricSender, LogSender, and RejectCounter
+var _ Sender = (*SenderOTel)(nil)
+var _ MetricSender = (*SenderOTel)(nil)
+var _ LogSender = (*SenderOTel)(nil)
+var _ RejectCounter = (*SenderOTel)(nil)
+
+type OTelSendable struct {
+	trace.Span
+	caller   trace.Span // for a call between services, the caller's half, which ends last
+	start    time.Time
+	events   []spanEvent     // added when the span ends
+	exporter *sharedExporter // counts the span as queued, if it's set
+	times    *spanTimes      // with --synthetictime, when the span ends
+
+	// the --clockskew offsets of the span's service and the caller's
+	skew, callerSkew time.Duration
+}
+
+func (s OTelSendable) Send() {
+	now := s.times.endTime()
+	if len(s.events) > 0 {
+		addSpanEvents(s.Span, s.events, s.start.Add(s.skew), now.Add(s.skew))
+	}
+	s.end(s.Span, now.Add(s.skew))
+	if s.caller != nil {
+		if s.times == nil {
+			now = time.Now()
+		}
+		s.end(s.caller, now.Add(s.callerSkew))
+	}
+}
+
+func (s OTelSendable) end(span trace.Span, at time.Time) {
+	if s.exporter != nil && span.SpanContext().IsSampled() {
+		s.exporter.queued.Add(1)
+	}
+	span.End(trace.WithTimestamp(at))
+}
+
+// clockSkew gives each service a clock that's off by a stable offset of up to
+// max either way (--clockskew), chosen by the seed and the service's name, and
+// its spans' times are shifted by it. A nil clockSkew has no offsets.
+type clockSkew struct {
+	seed    string
+	max     time.Duration
+	offsets sync.Map // service name -> time.Duration
+}
+
+func newClockSkew(seed string, max time.Duration) (*clockSkew, error) {
+	if max < 0 {
+		return nil, fmt.Errorf("clockskew %v must not be negative", max)
+	}
+	if max == 0 {
+		return nil, nil
+	}
+	return &clockSkew{seed: seed, max: max}, nil
+}
+
+func (c *clockSkew) offset(service string) time.Duration {
+	if c == nil {
+		return 0
+	}
+	if off, ok := c.offsets.Load(service); ok {
+		return off.(time.Duration)
+	}
+	rng := NewRng(c.seed + service + "clockskew")
+	off := time.Duration(rng.Float(-1, 1) * float64(c.max))
+	c.offsets.Store(service, off)
+	return off
+}
+
+// serviceVersions gives each service a stable service.version, chosen by the
+// seed and the service's name, and a fraction of its spans (--canary) a higher
+// canary version, as if some of its instances were running the next release.
+// Which spans are canaries is drawn from the seed too, so a run with the same
+// seed has the same number of them. A nil serviceVersions has no versions.
+type serviceVersions struct {
+	seed     string
+	canary   float64
+	versions sync.Map   // service name -> [2]string, the stable and canary versions
+	rng      *lockedRng // draws the canary spans
+}
+
+func newServiceVersions(seed string, canary float64) (*serviceVersions, error) {
+	if canary < 0 || canary > 1 {
+		return nil, fmt.Errorf("canary %v must be between 0 and 1", canary)
+	}
+	return &serviceVersions{seed: seed, canary: canary, rng: newLockedRng(seed + "canary")}, nil
+}
+
+// of returns the service's stable and canary versions.
+func (v *serviceVersions) of(service string) (stable, canary string) {
+	if vs, ok := v.versions.Load(service); ok {
+		pair := vs.([2]string)
+		return pair[0], pair[1]
+	}
+	rng := NewRng(v.seed + service + "version")
+	major, minor, patch := rng.Int(1, 4), rng.Int(0, 12), rng.Int(0, 20)
+	pair := [2]string{fmt.Sprintf("%d.%d.%d", major, minor, patch), fmt.Sprintf("%d.%d.0", major, minor+1)}
+	v.versions.Store(service, pair)
+	return pair[0], pair[1]
+}
+
+// version returns the service.version of one of the service's spans.
+func (v *serviceVersions) version(service string) string {
+	if v == nil {
+		return ""
+	}
+	stable, canary := v.of(service)
+	if v.canary > 0 && v.rng.Float(0, 1) < v.canary {
+		return canary
+	}
+	return stable
+}
+
+type SenderOTel struct {
+	tracer     trace.Tracer
+	opts       *Options
+	exceptions *exceptionGen
+	statuses   *statusGen // from --statusdist, or nil for a tenth of the spans to be errors
+	events     *spanEventGen
+	externals  *externalCallGen
+	meter      metric.Meter
+	logs       *otlpLogExporter
+	shutdown   func()
+
+	// instruments are created on demand, one per field name
+	mut         sync.Mutex
+	datapoints  metric.Int64Counter
+	intGauges   map[string]metric.Int64Gauge
+	floatGauges map[string]metric.Float64Gauge
+	histograms  map[string]metric.Float64Histogram
+	log         Logger
+
+	// spans are exported through a tracer provider per dataset, so that without
+	// a --dataset each service has its own service.name (and so its own dataset);
+	// with --hosts, there's an exporter per collector, and each one has its own
+	// providers, so the traces take turns among them
+	exporters []*sharedExporter
+	next      atomic.Int64
+	partial   *partialSuccesses // the spans the collectors rejected, or nil
+	providers map[providerKey]*sdktrace.TracerProvider
+	resource  *resource.Resource // everything but service.name, which depends on the dataset
+	batch     otelBatch
+
+	// with --samplerate, the providers only record a fraction of the traces
+	sampleRate float64
+	sampler    sdktrace.Sampler // nil to record everything
+	traces     atomic.Int64
+	sampled    atomic.Int64
+
+	traceState trace.TraceState // from --tracestate, for every root span
+	skew       *clockSkew       // from --clockskew, or nil
+	versions   *serviceVersions // with --canary, or nil
+	ids        *seededIDs       // from --idspace, or nil for the SDK's random ids
+
+	// from --baggage, for every trace, and --baggageattrs
+	baggage      baggage.Baggage
+	baggageAttrs bool
+
+	runLabel string // from --runlabel, for the spans without fields
+}
+
+// otelBatch tunes the batch span processor of each tracer provider
+// (--batchsize, --batchqueue, and --batchtimeout); a zero value leaves the
+// SDK's default.
+type otelBatch struct {
+	size    int
+	queue   int
+	timeout time.Duration
+}
+
+func newOTelBatch(opts *Options) (otelBatch, error) {
+	b := otelBatch{
+		size:    opts.BatchSize(sdktrace.DefaultMaxExportBatchSize),
+		queue:   opts.Output.BatchQueue,
+		timeout: opts.Output.BatchTimeout,
+	}
+	if b.queue < 0 {
+		return b, fmt.Errorf("--batchqueue must not be negative, not %d", b.queue)
+	}
+	if b.timeout < 0 {
+		return b, fmt.Errorf("--batchtimeout must not be negative, not %v", b.timeout)
+	}
+	if b.queue == 0 {
+		b.queue = sdktrace.DefaultMaxQueueSize
+	}
+	if b.timeout == 0 {
+		b.timeout = sdktrace.DefaultScheduleDelay * time.Millisecond
+	}
+	if b.size > b.queue {
+		// the SDK would do the same, but this way the log says so
+		b.size = b.queue
+	}
+	return b, nil
+}
+
+// queueSize is the number of spans a provider can queue.
+func (b otelBatch) queueSize() int {
+	if b.queue > 0 {
+		return b.queue
+	}
+	return sdktrace.DefaultMaxQueueSize
+}
+
+// options returns the options for a batch span processor. A full queue blocks
+// instead of dropping spans, which keeps the count of queued spans right; see
+// QueueFullness.
+func (b otelBatch) options() []sdktrace.BatchSpanProcessorOption {
+	opts := []sdktrace.BatchSpanProcessorOption{sdktrace.WithBlocking()}
+	if b.size > 0 {
+		opts = append(opts, sdktrace.WithMaxExportBatchSize(b.size))
+	}
+	if b.queue > 0 {
+		opts = append(opts, sdktrace.WithMaxQueueSize(b.queue))
+	}
+	if b.timeout > 0 {
+		opts = append(opts, sdktrace.WithBatchTimeout(b.timeout))
+	}
+	return opts
+}
+
+// samplingPriority marks the spans of traces that were kept by --samplerate,
+// and sampleRateField tells the receiver how many traces each one stands for.
+const (
+	samplingPriority = "sampling.priority"
+	sampleRateField  = "SampleRate"
+)
+
+// newSampler returns a sampler that keeps the given fraction of traces by
+// their trace ids, and otherwise follows the decision of a span's parent, or
+// nil if every trace should be kept.
+func newSampler(rate float64) (sdktrace.Sampler, error) {
+	if rate <= 0 || rate > 1 {
+		return nil, fmt.Errorf("--samplerate must be more than 0 and at most 1, not %v", rate)
+	}
+	if rate == 1 {
+		return nil, nil
+	}
+	return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(rate)), nil
+}
+
+// sharedExporter lets several tracer providers use one exporter, which
+// SenderOTel shuts down after all the providers. It also counts the spans
+// that couldn't be exported even after retrying, the spans that have ended
+// but are still waiting in the providers' queues, and the traces sent to it.
+// With --dupspanrate, it exports some of the spans a second time.
+type sharedExporter struct {
+	sdktrace.SpanExporter
+	host       string  // the collector it exports to, for reporting
+	dupRate    float64 // the fraction of spans that are exported twice
+	exported   atomic.Int64
+	dropped    atomic.Int64
+	errors     atomic.Int64
+	queued     atomic.Int64
+	traces     atomic.Int64
+	duplicated atomic.Int64
+	log        Logger
+}
+
+// providerKey identifies the tracer provider for a dataset on one exporter.
+type providerKey struct {
+	exporter *sharedExporter
+	dataset  string
+}
+
+// serviceKey holds the service of the span in a context, so that its children
+// can tell whether they're in another service.
+type serviceKey struct{}
+
+// asyncCallRate is the fraction of calls between services that are made
+// through a queue, as a PRODUCER and CONSUMER pair, instead of as a CLIENT
+// and SERVER pair.
+const asyncCallRate = 0.1
+
+// exporterKey holds the exporter that a trace was sent to in its context, so
+// that all of its spans go to the same collector.
+type exporterKey struct{}
+
+func (e *sharedExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.queued.Add(-int64(len(spans)))
+	if err := e.export(ctx, spans); err != nil {
+		return err
+	}
+	// like a client that retries after a response is lost, send some of the
+	// same spans again in another request, with their ids and times unchanged
+	var dups []sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		if e.dupRate > 0 && rand.Float64() < e.dupRate {
+			dups = append(dups, span)
+		}
+	}
+	if len(dups) == 0 {
+		return nil
+	}
+	e.duplicated.Add(int64(len(dups)))
+	return e.export(ctx, dups)
+}
+
+func (e *sharedExporter) export(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	if err != nil {
+		e.log.Error("unable to export %d spans: %v\n", len(spans), err)
+		e.dropped.Add(int64(len(spans)))
+		e.errors.Add(1)
+	} else {
+		e.exported.Add(int64(len(spans)))
+	}
+	return err
+}
+
+func (e *sharedExporter) Shutdown(context.Context) error { return nil }
+
+// newTLSConfig returns the TLS configuration given by --tlscert, --tlskey, and
+// --tlsca, or nil if none of them are set. A client certificate is presented
+// to the collector for mutual TLS, and a CA file replaces the system's roots.
+func newTLSConfig(opts *Options) (*tls.Config, error) {
+	t := opts.Telemetry
+	if t.TLSCert == "" && t.TLSKey == "" && t.TLSCA == "" {
+		return nil, nil
+	}
+	if t.Insecure {
+		return nil, errors.New("--insecure can't be used with --tlscert, --tlskey, or --tlsca")
+	}
+	if (t.TLSCert == "") != (t.TLSKey == "") {
+		return nil, errors.New("--tlscert and --tlskey must be used together")
+	}
+	cfg := &tls.Config{}
+	if t.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(t.TLSCert, t.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate %s with key %s: %w", t.TLSCert, t.TLSKey, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if t.TLSCA != "" {
+		pem, err := os.ReadFile(t.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA certificates: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no CA certificates found in %s", t.TLSCA)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// newTraceExporter creates an OTLP trace exporter for the collector at host
+// which retries according to the policy; the exporters retry for a length of
+// time rather than a number of times, so they're given the time that all the
+// retries would take. If tlsConfig isn't nil, it's used for the connection to
+// the collector.
+func newTraceExporter(opts *Options, host *url.URL, headers map[string]string, policy retryPolicy, tlsConfig *tls.Config) (sdktrace.SpanExporter, error) {
+	ctx := context.Background()
+	retry := otlptracegrpc.RetryConfig{
+		Enabled:         policy.retries > 0,
+		InitialInterval: policy.backoff,
+		MaxInterval:     policy.maxInterval(),
+		MaxElapsedTime:  policy.maxElapsed(),
+	}
+	if opts.Output.Protocol == "grpc" {
+		grpcOpts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(host.Host),
+			otlptracegrpc.WithHeaders(headers),
+			otlptracegrpc.WithRetry(retry),
+		}
+		if opts.Telemetry.Insecure {
+			grpcOpts = append(grpcOpts, otlptracegrpc.WithInsecure())
+		}
+		if tlsConfig != nil {
+			grpcOpts = append(grpcOpts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+		}
+		return otlptracegrpc.New(ctx, grpcOpts...)
+	}
+	// the http exporter only speaks protobuf, so json is sent that way too
+	httpOpts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(host.Host),
+		otlptracehttp.WithHeaders(headers),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig(retry)),
+	}
+	if opts.Telemetry.Insecure {
+		httpOpts = append(httpOpts, otlptracehttp.WithInsecure())
+	}
+	if tlsConfig != nil {
+		httpOpts = append(httpOpts, otlptracehttp.WithTLSClientConfig(tlsConfig))
+	}
+	if opts.Telemetry.TracesPath != "" {
+		httpOpts = append(httpOpts, otlptracehttp.WithURLPath(opts.Telemetry.TracesPath))
+	}
+	return otlptracehttp.New(ctx, httpOpts...)
+}
+
+func otelTracesFromURL(u *url.URL) string {
+	target := fmt.Sprintf("%s://%s", u.Scheme, u.Host)
+	return target
+}
+
+type OtelLogger struct {
+	Logger
+}
+
+func (l OtelLogger) Debugf(format string, args ...interface{}) {
+	l.Logger.Debug(format, args...)
+}
+
+func (l OtelLogger) Fatalf(format string, args ...interface{}) {
+	l.Logger.Fatal(format, args...)
+}
+
+// resourceAttributes returns the resource attributes given by --hostname,
+// --region, --environment, and --resource; host.name defaults to the name of
+// this machine so that the Resource looks like one from a real app.
+func resourceAttributes(opts *Options) ([]attribute.KeyValue, error) {
+	var attrs []attribute.KeyValue
+	hostname := opts.Telemetry.HostName
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	if hostname != "" {
+		attrs = append(attrs, attribute.String("host.name", hostname))
+	}
+	if opts.Telemetry.Region != "" {
+		attrs = append(attrs, attribute.String("cloud.region", opts.Telemetry.Region))
+	}
+	if opts.Telemetry.Environment != "" {
+		attrs = append(attrs, attribute.String("deployment.environment", opts.Telemetry.Environment))
+	}
+	for _, kv := range opts.Telemetry.Resource {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("resource attribute `%s` should be key=value", kv)
+		}
+		attrs = append(attrs, attribute.String(key, value))
+	}
+	return attrs, nil
+}
+
+// otelHeaders returns the headers sent with each export: the Honeycomb API key,
+// and then any given by --header, which can override it.
+func otelHeaders(opts *Options) (map[string]string, error) {
+	headers := map[string]string{
+		"x-honeycomb-team": opts.Telemetry.APIKey,
+	}
+	for _, kv := range opts.Telemetry.Headers {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("header `%s` should be key=value", kv)
+		}
+		headers[key] = value
+	}
+	return headers, nil
+}
+
+// newResource combines the SDK's default resource (which describes the SDK itself) with the given attributes.
+func newResource(attrs []attribute.KeyValue) (*resource.Resource, error) {
+	return resource.Merge(resource.Default(), resource.NewSchemaless(attrs...))
+}
+
+func NewSenderOTel(log Logger, opts *Options) *SenderOTel {
+	var protocol otelconfig.Protocol
+	switch opts.Output.Protocol {
+	case "grpc":
+		protocol = otelconfig.ProtocolGRPC
+	case "protobuf":
+		protocol = otelconfig.ProtocolHTTPProto
+	case "json":
+		protocol = otelconfig.ProtocolHTTPJSON
+	default:
+		log.Fatal("unknown protocol: %s", opts.Output.Protocol)
+	}
+
+	headers, err := otelHeaders(opts)
+	if err != nil {
+		log.Fatal("%v\n", err)
+	}
+	if opts.Telemetry.TracesPath != "" && protocol == otelconfig.ProtocolGRPC {
+		log.Fatal("--tracespath can only be used with the protobuf or json protocols\n")
+	}
+	attrs, err := resourceAttributes(opts)
+	if err != nil {
+		log.Fatal("%v\n", err)
+	}
+	res, err := newResource(attrs)
+	if err != nil {
+		log.Fatal("unable to create resource: %v\n", err)
+	}
+	tlsConfig, err := newTLSConfig(opts)
+	if err != nil {
+		log.Fatal("%v\n", err)
+	}
+	if tlsConfig != nil && opts.Output.Signal == "metrics" {
+		// otelconfig creates the metrics exporter, and it has no TLS options
+		log.Fatal("--tlscert, --tlskey, and --tlsca can't be used for metrics\n")
+	}
+	// traces go to --hosts, if it's set, and metrics and logs go to --host
+	if len(opts.apihosts) > 0 {
+		healthCheck(log, opts, opts.apihosts...)
+	} else {
+		healthCheck(log, opts, opts.apihost)
+	}
+	resourceMap := make(map[string]string, len(attrs))
+	for _, kv := range attrs {
+		resourceMap[string(kv.Key)] = kv.Value.AsString()
+	}
+	otelshutdown, err := otelconfig.ConfigureOpenTelemetry(
+		otelconfig.WithExporterProtocol(protocol),
+		otelconfig.WithServiceName(resolveDataset(opts, ResourceLibrary)),
+		otelconfig.WithTracesExporterEndpoint(otelTracesFromURL(opts.apihost)),
+		otelconfig.WithTracesExporterInsecure(opts.Telemetry.Insecure),
+		// traces are exported by the sender itself; see tracerFor
+		otelconfig.WithTracesEnabled(false),
+		otelconfig.WithMetricsEnabled(opts.Output.Signal == "metrics"),
+		otelconfig.WithMetricsExporterEndpoint(otelTracesFromURL(opts.apihost)),
+		otelconfig.WithMetricsExporterInsecure(opts.Telemetry.Insecure),
+		otelconfig.WithLogLevel(opts.Global.LogLevel),
+		otelconfig.WithLogger(OtelLogger{log}),
+		otelconfig.WithHeaders(headers),
+		otelconfig.WithResourceAttributes(resourceMap),
+	)
+	if err != nil {
+		log.Fatal("failure configuring otel: %v", err)
+	}
+	var logs *otlpLogExporter
+	if opts.Output.Signal == "logs" {
+		logs, err = newOTLPLogExporter(log, opts, headers, attrs, tlsConfig)
+		if err != nil {
+			log.Fatal("failure configuring otel logs: %v", err)
+		}
+	}
+	sampler, err := newSampler(opts.Output.SampleRate)
+	if err != nil {
+		log.Fatal("%v\n", err)
+	}
+	traceState, err := trace.ParseTraceState(opts.Format.TraceState)
+	if err != nil {
+		log.Fatal("invalid --tracestate: %v\n", err)
+	}
+	bag, err := baggage.Parse(opts.Format.Baggage)
+	if err != nil {
+		log.Fatal("invalid --baggage: %v\n", err)
+	}
+	skew, err := newClockSkew(opts.Global.Seed, opts.Format.ClockSkew)
+	if err != nil {
+		log.Fatal("%v\n", err)
+	}
+	versions, err := newServiceVersions(opts.Global.Seed, opts.Format.Canary)
+	if err != nil {
+		log.Fatal("%v\n", err)
+	}
+	batch, err := newOTelBatch(opts)
+	if err != nil {
+		log.Fatal("%v\n", err)
+	}
+	statuses, err := newStatusGen(opts.Global.Seed, opts.Format.StatusDist, opts.Format.StatusMessages)
+	if err != nil {
+		log.Fatal("%v\n", err)
+	}
+	externals, err := newExternalCallGen(opts.Global.Seed, opts.Format.SQLTables, opts.Format.SQLColumns)
+	if err != nil {
+		log.Fatal("%v\n", err)
+	}
+	if opts.Format.DupSpanRate < 0 || opts.Format.DupSpanRate > 1 {
+		log.Fatal("dupspanrate %v must be between 0 and 1\n", opts.Format.DupSpanRate)
+	}
+	var exporters []*sharedExporter
+	var partial *partialSuccesses
+	if opts.Output.Signal == "traces" {
+		// a collector's partial successes only reach the global error handler
+		partial = newPartialSuccesses(log)
+		otel.SetErrorHandler(partial)
+		log.Info("otel batches of up to %d spans, with a queue of %d, exported at least every %v\n", batch.size, batch.queue, batch.timeout)
+		hosts := opts.apihosts
+		if len(hosts) == 0 {
+			hosts = []*url.URL{opts.apihost}
+		}
+		for _, host := range hosts {
+			traceExporter, err := newTraceExporter(opts, host, headers, newRetryPolicy(log, opts), tlsConfig)
+			if err != nil {
+				log.Fatal("failure configuring otel traces for %s: %v", host, err)
+			}
+			exporters = append(exporters, &sharedExporter{SpanExporter: traceExporter, host: host.Host, dupRate: opts.Format.DupSpanRate, log: log})
+		}
+	}
+	return &SenderOTel{
+		tracer:     otel.Tracer(ResourceLibrary, trace.WithInstrumentationVersion(ResourceVersion)),
+		opts:       opts,
+		exceptions: newExceptionGen(opts.Global.Seed, opts.Exceptions),
+		statuses:   statuses,
+		events:     newSpanEventGen(opts),
+		externals:  externals,
+		meter:      otel.Meter(ResourceLibrary, metric.WithInstrumentationVersion(ResourceVersion)),
+		logs:       logs,
+		shutdown:   otelshutdown,
+		log:        log,
+		exporters:  exporters,
+		partial:    partial,
+		providers:  make(map[providerKey]*sdktrace.TracerProvider),
+		resource:   res,
+		batch:      batch,
+		sampleRate: opts.Output.SampleRate,
+		sampler:    sampler,
+		traceState: traceState,
+		skew:       skew,
+		versions:   versions,
+		ids:        newSeededIDs(opts.Global.Seed, opts.Format.IDSpace),
+
+		baggage:      bag,
+		baggageAttrs: opts.Format.BaggageAttrs,
+
+		runLabel: opts.Format.RunLabel,
+	}
+}
+
+// nextExporter returns the exporter for a new trace; with --hosts, they take
+// turns. It returns nil if spans aren't exported by the sender.
+func (t *SenderOTel) nextExporter() *sharedExporter {
+	if len(t.exporters) == 0 {
+		return nil
+	}
+	return t.exporters[(t.next.Add(1)-1)%int64(len(t.exporters))]
+}
+
+// exporterFor returns the exporter that the trace of the span in ctx was sent to.
+func (t *SenderOTel) exporterFor(ctx context.Context) *sharedExporter {
+	if e, ok := ctx.Value(exporterKey{}).(*sharedExporter); ok {
+		return e
+	}
+	if len(t.exporters) == 0 {
+		return nil
+	}
+	return t.exporters[0]
+}
+
+// tracerFor returns the tracer to use for spans from the given service that
+// are sent through the given exporter.
+func (t *SenderOTel) tracerFor(exporter *sharedExporter, service string) trace.Tracer {
+	if exporter == nil {
+		return t.tracer
+	}
+	dataset := resolveDataset(t.opts, service)
+	key := providerKey{exporter: exporter, dataset: dataset}
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	tp, ok := t.providers[key]
+	if !ok {
+		base := t.resource
+		if base == nil {
+			base = resource.Default()
+		}
+		res, err := resource.Merge(base, resource.NewSchemaless(attribute.String("service.name", dataset)))
+		if err != nil {
+			t.log.Error("unable to create resource for %s: %v\n", dataset, err)
+			return t.tracer
+		}
+		tpOpts := []sdktrace.TracerProviderOption{
+			sdktrace.WithBatcher(exporter, t.batch.options()...),
+			sdktrace.WithResource(res),
+		}
+		if t.sampler != nil {
+			tpOpts = append(tpOpts, sdktrace.WithSampler(t.sampler))
+		}
+		if t.ids != nil {
+			tpOpts = append(tpOpts, sdktrace.WithIDGenerator(t.ids))
+		}
+		tp = sdktrace.NewTracerProvider(tpOpts...)
+		t.providers[key] = tp
+	}
+	return tp.Tracer(ResourceLibrary, trace.WithInstrumentationVersion(ResourceVersion))
+}
+
+func (t *SenderOTel) Close() {
+	if t.logs != nil {
+		t.logs.shutdown()
+	}
+	if len(t.exporters) > 0 {
+		ctx := context.Background()
+		for _, tp := range t.providers {
+			tp.Shutdown(ctx)
+		}
+		var exported, dropped, duplicated int64
+		for _, e := range t.exporters {
+			e.SpanExporter.Shutdown(ctx)
+			exported += e.exported.Load()
+			dropped += e.dropped.Load()
+			duplicated += e.duplicated.Load()
+			if len(t.exporters) > 1 {
+				t.log.Warn("sender sent %d traces to %s\n", e.traces.Load(), e.host)
+			}
+		}
+		if dropped > 0 {
+			t.log.Warn("sender dropped %d spans that couldn't be exported\n", dropped)
+		}
+		if duplicated > 0 {
+			t.log.Warn("sender exported %d spans a second time\n", duplicated)
+		}
+		if rejected := t.RejectedSpans(); rejected > 0 {
+			t.log.Warn("collectors accepted %d of the %d spans exported, and rejected %d\n", exported-rejected, exported, rejected)
+		}
+		if traces := t.traces.Load(); t.sampler != nil && traces > 0 {
+			sampled := t.sampled.Load()
+			t.log.Warn("sender sampled %d of %d traces (%.1f%%, target %.1f%%)\n",
+				sampled, traces, 100*float64(sampled)/float64(traces), 100*t.sampleRate)
+		}
+	}
+	t.shutdown()
+}
+
+// QueueFullness returns how full the queue of spans waiting to be exported is.
+// Each provider has its own queue, but they all drain through their exporter,
+// so the total for an exporter is compared with the size of one queue. With
+// --hosts, it's the fullest exporter's, since the traces take turns.
+func (t *SenderOTel) QueueFullness() float64 {
+	var fullest float64
+	for _, e := range t.exporters {
+		fullest = max(fullest, float64(e.queued.Load())/float64(t.batch.queueSize()))
+	}
+	return fullest
+}
+
+// RejectedSpans returns the number of spans that collectors rejected in
+// partial successes, though they accepted the requests that held them.
+func (t *SenderOTel) RejectedSpans() int64 {
+	if t.partial == nil {
+		return 0
+	}
+	return t.partial.rejected.Load()
+}
+
+// SendErrors returns the number of trace and log exports that failed.
+func (t *SenderOTel) SendErrors() int64 {
+	var errs int64
+	for _, e := range t.exporters {
+		errs += e.errors.Load()
+	}
+	if t.logs != nil {
+		errs += t.logs.errors.Load()
+	}
+	return errs
+}
+
+func (t *SenderOTel) SendLog(ctx context.Context, service string, record LogRecord) {
+	t.logs.emit(service, record)
+}
+
+// CreateTrace starts a root span, which handles a request as a SERVER, or, if
+// it links to an earlier trace, consumes that trace's work as a CONSUMER.
+func (t *SenderOTel) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	startOpts := []trace.SpanStartOption{trace.WithSpanKind(trace.SpanKindServer)}
+	if sc, ok := linkFromContext(ctx); ok {
+		startOpts = append(startOpts, trace.WithSpanKind(trace.SpanKindConsumer), trace.WithLinks(trace.Link{
+			SpanContext: sc,
+			Attributes:  []attribute.KeyValue{attribute.String("link.relationship", linkRelationship)},
+		}))
+	}
+	if t.traceState.Len() > 0 {
+		// the SDK gives a new span the tracestate of its parent, and a parent
+		// without ids doesn't keep the root from being a root
+		ctx = trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{TraceState: t.traceState}))
+	}
+	exporter := t.nextExporter()
+	if exporter != nil {
+		exporter.traces.Add(1)
+		ctx = context.WithValue(ctx, exporterKey{}, exporter)
+	}
+	if t.baggage.Len() > 0 {
+		// the children's contexts inherit it from the root's
+		ctx = baggage.ContextWithBaggage(ctx, t.baggage)
+	}
+	ctx = context.WithValue(ctx, serviceKey{}, name)
+	ctx = t.ids.contextFor(ctx, count)
+	times := spanTimesFromContext(ctx)
+	skew := t.skew.offset(name)
+	startOpts = append(startOpts, trace.WithTimestamp(times.startTime().Add(skew)))
+	ctx, root := t.tracerFor(exporter, name).Start(ctx, fielder.GetSpanName(name), startOpts...)
+	t.traces.Add(1)
+	if root.SpanContext().IsSampled() {
+		t.sampled.Add(1)
+	}
+	t.addSamplingFields(root)
+	t.addServiceVersion(root, name)
+	t.addBaggageFields(ctx, root)
+	fielder.AddFields(root, count, 0, false)
+	var ots OTelSendable
+	ots.Span = root
+	ots.exporter = exporter
+	ots.skew = skew
+	ots.times = times
+	ots.Span.SetStatus(codes.Ok, "Everything's good")
+	return ctx, ots
+}
+
+// CreateSpan starts a span for work within the same service as its parent, as
+// INTERNAL. For a call to another service, it starts a pair of spans, so that
+// a backend can infer the graph of services: the caller's CLIENT span, and the
+// callee's SERVER span as its child, which gets the fields; a few calls are
+// async, as a PRODUCER and a CONSUMER instead.
+func (t *SenderOTel) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	if isExternalCall(ctx) {
+		return t.createExternalCall(ctx, name)
+	}
+	exporter := t.exporterFor(ctx)
+	spanName := fielder.GetSpanName(name)
+	kind := trace.SpanKindInternal
+	times := spanTimesFromContext(ctx)
+	now := times.startTime()
+	skew := t.skew.offset(name)
+	var caller trace.Span
+	var callerSkew time.Duration
+	if parent, ok := ctx.Value(serviceKey{}).(string); ok && parent != name {
+		callerKind, calleeKind := trace.SpanKindClient, trace.SpanKindServer
+		if rand.Float64() < asyncCallRate {
+			callerKind, calleeKind = trace.SpanKindProducer, trace.SpanKindConsumer
+		}
+		callerSkew = t.skew.offset(parent)
+		ctx, caller = t.tracerFor(exporter, parent).Start(ctx, spanName, trace.WithSpanKind(callerKind),
+			trace.WithAttributes(attribute.String("peer.service", name)), trace.WithTimestamp(now.Add(callerSkew)))
+		t.addSamplingFields(caller)
+		t.addServiceVersion(caller, parent)
+		t.addRunLabel(caller)
+		t.addBaggageFields(ctx, caller)
+		kind = calleeKind
+	}
+	ctx = context.WithValue(ctx, serviceKey{}, name)
+	ctx, span := t.tracerFor(exporter, name).Start(ctx, spanName, trace.WithSpanKind(kind), trace.WithTimestamp(now.Add(skew)))
+	// an unset status is left alone, rather than set to codes.Unset
+	status, statusMessage := t.statuses.next()
+	isError := status == codes.Error
+	if isError {
+		typ, message, stacktrace := t.exceptions.next()
+		span.AddEvent("exception", trace.WithAttributes(
+			attribute.KeyValue{Key: "exception.type", Value: attribute.StringValue(typ)},
+			attribute.KeyValue{Key: "exception.message", Value: attribute.StringValue(message)},
+			attribute.KeyValue{Key: "exception.stacktrace", Value: attribute.StringValue(stacktrace)},
+			attribute.KeyValue{Key: "exception.escaped", Value: attribute.BoolValue(false)},
+		))
+		if statusMessage == "" {
+			statusMessage = message
+		}
+		span.SetStatus(codes.Error, statusMessage)
+		if caller != nil {
+			caller.SetStatus(codes.Error, statusMessage)
+		}
+	} else if status == codes.Ok {
+		span.SetStatus(codes.Ok, "Everything's good")
+		if caller != nil {
+			caller.SetStatus(codes.Ok, "Everything's good")
+		}
+	}
+	t.addSamplingFields(span)
+	t.addServiceVersion(span, name)
+	t.addBaggageFields(ctx, span)
+	fielder.AddFields(span, 0, level, isError)
+	ots := OTelSendable{Span: span, caller: caller, start: now, events: t.events.next(), exporter: exporter, skew: skew, callerSkew: callerSkew, times: times}
+	return ctx, ots
+}
+
+// createExternalCall starts a leaf span for a call to a database or cache
+// (--leafrate). It's a CLIENT span in the service that makes the call, which is
+// the parent's, and it has the call's attributes instead of the fields.
+func (t *SenderOTel) createExternalCall(ctx context.Context, name string) (context.Context, Sendable) {
+	if parent, ok := ctx.Value(serviceKey{}).(string); ok {
+		name = parent
+	}
+	exporter := t.exporterFor(ctx)
+	call := t.externals.next()
+	times := spanTimesFromContext(ctx)
+	now := times.startTime()
+	skew := t.skew.offset(name)
+	ctx, span := t.tracerFor(exporter, name).Start(ctx, call.name, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(call.attrs...), trace.WithTimestamp(now.Add(skew)))
+	span.SetStatus(codes.Ok, "Everything's good")
+	t.addSamplingFields(span)
+	t.addServiceVersion(span, name)
+	t.addRunLabel(span)
+	t.addBaggageFields(ctx, span)
+	return ctx, OTelSendable{Span: span, start: now, exporter: exporter, skew: skew, times: times}
+}
+
+// addSamplingFields marks a span that was kept by --samplerate; spans that
+// weren't kept aren't recorded, so they don't need them.
+func (t *SenderOTel) addSamplingFields(span trace.Span) {
+	if t.sampler == nil || !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		attribute.Int(samplingPriority, 1),
+		attribute.Int64(sampleRateField, int64(math.Round(1/t.sampleRate))),
+	)
+}
+
+// addServiceVersion gives a span the service.version of its service.
+func (t *SenderOTel) addServiceVersion(span trace.Span, service string) {
+	if version := t.versions.version(service); version != "" {
+		span.SetAttributes(attribute.String("service.version", version))
+	}
+}
+
+// addRunLabel labels a span that has no fields of its own, the caller's half of
+// a call or a call to a database, as the fielder labels the rest.
+func (t *SenderOTel) addRunLabel(span trace.Span) {
+	if t.runLabel != "" {
+		span.SetAttributes(attribute.String(runLabelField, t.runLabel))
+	}
+}
+
+// addBaggageFields copies the baggage in the span's context to its attributes
+// (--baggageattrs), as a collector's baggage processor would.
+func (t *SenderOTel) addBaggageFields(ctx context.Context, span trace.Span) {
+	if !t.baggageAttrs || !span.IsRecording() {
+		return
+	}
+	for _, m := range baggage.FromContext(ctx).Members() {
+		span.SetAttributes(attribute.String(m.Key(), m.Value()))
+	}
+}
+
+// RecordMetrics records the count as a counter, each numeric field as a gauge
+// of the same name, and each /hist field as a histogram with the field's
+// buckets, all attributed to the given service.
+func (t *SenderOTel) RecordMetrics(ctx context.Context, service string, fielder *Fielder, count int64) {
+	attrs := metric.WithAttributes(attribute.String("service.name", service))
+	// the histograms' values are recorded after the lock is released (this is
+	// deferred first, so it runs last), so that the other metric goroutines
+	// aren't kept waiting
+	type histValues struct {
+		hist       metric.Float64Histogram
+		boundaries []float64
+		counts     []int64
+	}
+	var hists []histValues
+	defer func() {
+		// a bucket's upper bound is inside it, so recording it as many times
+		// as the bucket's count reproduces the counts exactly
+		for _, h := range hists {
+			for i, count := range h.counts {
+				for j := int64(0); j < count; j++ {
+					h.hist.Record(ctx, h.boundaries[i], attrs)
+				}
+			}
+		}
+	}()
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.datapoints == nil {
+		var err error
+		t.datapoints, err = t.meter.Int64Counter("loadgen.datapoints")
+		if err != nil {
+			t.log.Error("unable to create counter: %v\n", err)
+			return
+		}
+		t.intGauges = make(map[string]metric.Int64Gauge)
+		t.floatGauges = make(map[string]metric.Float64Gauge)
+		t.histograms = make(map[string]metric.Float64Histogram)
+	}
+	t.datapoints.Add(ctx, 1, attrs)
+	for name, value := range fielder.GetFields(0, 0) {
+		switch v := value.(type) {
+		case int64:
+			gauge, ok := t.intGauges[name]
+			if !ok {
+				var err error
+				if gauge, err = t.meter.Int64Gauge(name); err != nil {
+					t.log.Error("unable to create gauge %s: %v\n", name, err)
+					continue
+				}
+				t.intGauges[name] = gauge
+			}
+			gauge.Record(ctx, v, attrs)
+		case float64:
+			gauge, ok := t.floatGauges[name]
+			if !ok {
+				var err error
+				if gauge, err = t.meter.Float64Gauge(name); err != nil {
+					t.log.Error("unable to create gauge %s: %v\n", name, err)
+					continue
+				}
+				t.floatGauges[name] = gauge
+			}
+			gauge.Record(ctx, v, attrs)
+		case []int64:
+			boundaries, ok := fielder.HistBoundaries(name)
+			if !ok || len(v) != len(boundaries) {
+				continue
+			}
+			hist, ok := t.histograms[name]
+			if !ok {
+				var err error
+				if hist, err = t.meter.Float64Histogram(name, metric.WithExplicitBucketBoundaries(boundaries...)); err != nil {
+					t.log.Error("unable to create histogram %s: %v\n", name, err)
+					continue
+				}
+				t.histograms[name] = hist
+			}
+			hists = append(hists, histValues{hist, boundaries, v})
+		}
+	}
+}
@@ -0,0 +1,1092 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSenderOTel_RecordMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer mp.Shutdown(context.Background())
+	sender := &SenderOTel{meter: mp.Meter("test"), log: NewLogger(0)}
+
+	fielder, err := NewFielder("test", map[string]string{"ival": "/i10", "fval": "/f10", "sval": "/s", "hval": "/hist 10,1,2,3"}, 0, 2, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(1); i <= 5; i++ {
+		sender.RecordMetrics(context.Background(), fielder.GetServiceName(int(i)), fielder, i)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			found[m.Name] = true
+			if m.Name == "loadgen.datapoints" {
+				var total int64
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					total += dp.Value
+				}
+				if total != 5 {
+					t.Errorf("expected 5 data points, got %d", total)
+				}
+			}
+			if m.Name == "hval" {
+				var count uint64
+				for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+					if !slices.Equal(dp.Bounds, []float64{1, 2, 3}) {
+						t.Errorf("expected the buckets of the /hist field, got %v", dp.Bounds)
+					}
+					count += dp.Count
+				}
+				// each data point's bucket counts sum to the /hist total
+				if count != 5*10 {
+					t.Errorf("expected 50 values in the histogram, got %d", count)
+				}
+			}
+		}
+	}
+	for _, name := range []string{"loadgen.datapoints", "ival", "fval", "hval", "process_id"} {
+		if !found[name] {
+			t.Errorf("expected a metric named %s, got %v", name, found)
+		}
+	}
+	if found["sval"] {
+		t.Errorf("string fields should not become metrics")
+	}
+}
+
+func TestOTLPLogExporter_http(t *testing.T) {
+	var mut sync.Mutex
+	received := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" || r.Header.Get("x-honeycomb-team") != "key" {
+			t.Errorf("unexpected request %s with headers %v", r.URL.Path, r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req collogspb.ExportLogsServiceRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			t.Errorf("unable to decode request: %v", err)
+		}
+		mut.Lock()
+		defer mut.Unlock()
+		for _, rl := range req.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				received += len(sl.LogRecords)
+			}
+		}
+	}))
+	defer server.Close()
+
+	opts := newOptions()
+	opts.Output.Protocol = "protobuf"
+	opts.Output.BatchSize = 4
+	opts.apihost, _ = url.Parse(server.URL)
+	exporter, err := newOTLPLogExporter(NewLogger(0), opts, map[string]string{"x-honeycomb-team": "key"}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tid, sid := randomTraceIDs()
+	for i := 0; i < 10; i++ {
+		exporter.emit("svc", LogRecord{Time: time.Now(), TraceID: tid, SpanID: sid, SeverityNumber: 9, SeverityText: "INFO", Body: "hello"})
+	}
+	exporter.shutdown()
+
+	if received != 10 {
+		t.Errorf("expected 10 log records, got %d", received)
+	}
+}
+
+func TestSenderOTel_perServiceDataset(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	opts := newOptions()
+	opts.Telemetry.Dataset = ""
+	sender := &SenderOTel{opts: opts, exceptions: newExceptionGen("test", nil), exporters: []*sharedExporter{{SpanExporter: exporter, log: NewLogger(0)}}, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0)}
+
+	fielder := newTestFielder(t, 3)
+	root, child := fielder.GetServiceName(0), fielder.GetServiceName(1)
+	ctx, rootSpan := sender.CreateTrace(context.Background(), root, fielder, 1)
+	_, childSpan := sender.CreateSpan(ctx, child, 1, fielder)
+	childSpan.Send()
+	rootSpan.Send()
+	for _, tp := range sender.providers {
+		tp.ForceFlush(context.Background())
+	}
+	if f := sender.QueueFullness(); f != 0 {
+		t.Errorf("expected an empty queue after flushing, got %v full", f)
+	}
+
+	// the call to the child's service is a pair of spans, and the caller's
+	// half is in the root's service; each service's provider flushes
+	// separately, so the spans may be in any order
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	var rootStub, callStub, childStub tracetest.SpanStub
+	for _, span := range spans {
+		switch {
+		case !span.Parent.IsValid():
+			rootStub = span
+		case span.SpanKind == trace.SpanKindClient || span.SpanKind == trace.SpanKindProducer:
+			callStub = span
+		default:
+			childStub = span
+		}
+	}
+	for _, tt := range []struct {
+		span    tracetest.SpanStub
+		service string
+	}{{rootStub, root}, {callStub, root}, {childStub, child}} {
+		dataset, _ := tt.span.Resource.Set().Value("service.name")
+		if want := resolveDataset(opts, tt.service); dataset.AsString() != want || want != tt.service {
+			t.Errorf("%s span %s has dataset %s, expected %s", tt.span.SpanKind, tt.span.Name, dataset.AsString(), want)
+		}
+	}
+	if callStub.Parent.SpanID() != rootStub.SpanContext.SpanID() || childStub.Parent.SpanID() != callStub.SpanContext.SpanID() {
+		t.Errorf("child span isn't part of the root's trace")
+	}
+
+	opts.Telemetry.Dataset = "fixed"
+	if got := resolveDataset(opts, root); got != "fixed" {
+		t.Errorf("expected --dataset to override the service, got %s", got)
+	}
+}
+
+func TestSenderOTel_spanKinds(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	sender := &SenderOTel{opts: newOptions(), exceptions: newExceptionGen("test", nil), exporters: []*sharedExporter{{SpanExporter: exporter, log: NewLogger(0)}}, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0)}
+
+	// each trace is frontend -> backend -> backend
+	fielder := newTestFielder(t, 3)
+	const ntraces = 500
+	for i := 0; i < ntraces; i++ {
+		ctx, root := sender.CreateTrace(context.Background(), "frontend", fielder, int64(i))
+		ctx, call := sender.CreateSpan(ctx, "backend", 1, fielder)
+		_, work := sender.CreateSpan(ctx, "backend", 2, fielder)
+		work.Send()
+		call.Send()
+		root.Send()
+	}
+	for _, tp := range sender.providers {
+		tp.ForceFlush(context.Background())
+	}
+
+	spans := exporter.GetSpans()
+	byID := map[trace.SpanID]tracetest.SpanStub{}
+	for _, span := range spans {
+		byID[span.SpanContext.SpanID()] = span
+	}
+	pairs := map[trace.SpanKind]trace.SpanKind{trace.SpanKindClient: trace.SpanKindServer, trace.SpanKindProducer: trace.SpanKindConsumer}
+	kinds := map[trace.SpanKind]int{}
+	for _, span := range spans {
+		kinds[span.SpanKind]++
+		service, _ := span.Resource.Set().Value("service.name")
+		parent, hasParent := byID[span.Parent.SpanID()]
+		switch {
+		case !hasParent:
+			if span.SpanKind != trace.SpanKindServer {
+				t.Errorf("expected the root to be a server span, got %s", span.SpanKind)
+			}
+		case parent.SpanKind == trace.SpanKindServer && service.AsString() == "frontend":
+			// the call from frontend to backend
+			if _, ok := pairs[span.SpanKind]; !ok {
+				t.Errorf("expected the call to another service to be a client span, got %s", span.SpanKind)
+			}
+			attrs := attribute.NewSet(span.Attributes...)
+			peer, _ := attrs.Value("peer.service")
+			if peer.AsString() != "backend" {
+				t.Errorf("expected the call to have a peer.service of backend, got %q", peer.AsString())
+			}
+		case pairs[parent.SpanKind] != 0:
+			if span.SpanKind != pairs[parent.SpanKind] {
+				t.Errorf("expected the callee of a %s span to be a %s span, got %s", parent.SpanKind, pairs[parent.SpanKind], span.SpanKind)
+			}
+		default:
+			if span.SpanKind != trace.SpanKindInternal {
+				t.Errorf("expected work within a service to be internal, got %s", span.SpanKind)
+			}
+		}
+	}
+	if kinds[trace.SpanKindClient] < ntraces*8/10 || kinds[trace.SpanKindProducer] == 0 || kinds[trace.SpanKindProducer] > ntraces*2/10 {
+		t.Errorf("expected mostly client calls and a few async ones, got %v", kinds)
+	}
+	if kinds[trace.SpanKindInternal] != ntraces {
+		t.Errorf("expected %d internal spans, got %d", ntraces, kinds[trace.SpanKindInternal])
+	}
+
+	// a root that links to an earlier trace consumes its work
+	exporter.Reset()
+	ctx := contextWithLink(context.Background(), spans[0].SpanContext)
+	_, root := sender.CreateTrace(ctx, "frontend", fielder, 1)
+	root.Send()
+	for _, tp := range sender.providers {
+		tp.ForceFlush(context.Background())
+	}
+	if spans := exporter.GetSpans(); len(spans) != 1 || spans[0].SpanKind != trace.SpanKindConsumer {
+		t.Errorf("expected a linked root to be a consumer span, got %v", spans)
+	}
+}
+
+func TestSenderOTel_externalCalls(t *testing.T) {
+	leaves := func(rate float64) (db, all int) {
+		exporter := tracetest.NewInMemoryExporter()
+		sender := &SenderOTel{opts: newOptions(), exceptions: newExceptionGen("test", nil), externals: mustExternalCallGen(t), exporters: []*sharedExporter{{SpanExporter: exporter, log: NewLogger(0)}}, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0)}
+		opts := newOptions()
+		opts.Format.Depth = 3
+		opts.Format.NSpans = 6
+		opts.Format.LeafRate = rate
+		gen, err := NewTraceGenerator(sender, nil, NewLogger(0), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 200; i++ {
+			gen.generate_root(newServiceFielders(newTestFielder(t, 3), gen.serviceFielder, gen.log), int64(i), 3, 6, 0)
+		}
+		for _, tp := range sender.providers {
+			tp.ForceFlush(context.Background())
+		}
+
+		spans := exporter.GetSpans()
+		byID := map[trace.SpanID]tracetest.SpanStub{}
+		parents := map[trace.SpanID]bool{}
+		for _, span := range spans {
+			byID[span.SpanContext.SpanID()] = span
+			parents[span.Parent.SpanID()] = true
+		}
+		for _, span := range spans {
+			if parents[span.SpanContext.SpanID()] {
+				continue
+			}
+			all++
+			attrs := attribute.NewSet(span.Attributes...)
+			if _, ok := attrs.Value("db.system"); !ok {
+				continue
+			}
+			db++
+			if statement, _ := attrs.Value("db.statement"); span.SpanKind != trace.SpanKindClient || statement.AsString() == "" {
+				t.Errorf("expected a client span with a statement, got a %s span with %v", span.SpanKind, span.Attributes)
+			}
+			// the call is made by the parent's service
+			service, _ := span.Resource.Set().Value("service.name")
+			parentService, _ := byID[span.Parent.SpanID()].Resource.Set().Value("service.name")
+			if service != parentService {
+				t.Errorf("expected the call to be made by %s, got %s", parentService.AsString(), service.AsString())
+			}
+		}
+		return db, all
+	}
+
+	if db, all := leaves(0.5); float64(db) < 0.4*float64(all) || float64(db) > 0.6*float64(all) {
+		t.Errorf("expected about half of the %d leaves to be calls to a database or cache, got %d", all, db)
+	}
+	if db, _ := leaves(0); db != 0 {
+		t.Errorf("expected no calls to a database or cache without --leafrate, got %d", db)
+	}
+}
+
+func TestSenderOTel_errorFields(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	sender := &SenderOTel{opts: newOptions(), exceptions: newExceptionGen("test", nil), exporters: []*sharedExporter{{SpanExporter: exporter, log: NewLogger(0)}}, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0)}
+	fielder, err := NewFielder("test", map[string]string{"is_error": "/iserror"}, 0, 1, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		ctx, root := sender.CreateTrace(context.Background(), "frontend", fielder, int64(i))
+		_, child := sender.CreateSpan(ctx, "frontend", 1, fielder)
+		child.Send()
+		root.Send()
+	}
+	for _, tp := range sender.providers {
+		tp.ForceFlush(context.Background())
+	}
+
+	var errors int
+	for _, span := range exporter.GetSpans() {
+		attrs := attribute.NewSet(span.Attributes...)
+		isError, ok := attrs.Value("is_error")
+		if !ok {
+			t.Fatalf("expected is_error on every span, got %v", span.Attributes)
+		}
+		if isError.AsBool() != (span.Status.Code == codes.Error) {
+			t.Errorf("expected is_error to be %v on a span with status %s", span.Status.Code == codes.Error, span.Status.Code)
+		}
+		if isError.AsBool() {
+			errors++
+		}
+	}
+	if errors == 0 {
+		t.Errorf("expected some spans with an error status")
+	}
+
+	// the other senders have no error status
+	if fields := fielder.GetFields(0, 1); fields["is_error"] != false {
+		t.Errorf("expected is_error to be false without an error status, got %v", fields["is_error"])
+	}
+	if _, err := NewFielder("test", map[string]string{"is_error": "/iserror 10"}, 0, 1, 3, 3); err == nil {
+		t.Errorf("expected an error for /iserror with an argument")
+	}
+}
+
+func TestSenderOTel_clockSkew(t *testing.T) {
+	const maxSkew = 50 * time.Millisecond
+	skew, err := newClockSkew("skew", maxSkew)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	sender := &SenderOTel{opts: newOptions(), exceptions: newExceptionGen("test", nil), exporters: []*sharedExporter{{SpanExporter: exporter, log: NewLogger(0)}}, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0), skew: skew}
+
+	fielder := newTestFielder(t, 2)
+	var before, after []time.Time
+	for i := 0; i < 20; i++ {
+		before = append(before, time.Now())
+		ctx, root := sender.CreateTrace(context.Background(), "frontend", fielder, int64(i))
+		_, child := sender.CreateSpan(ctx, "backend", 1, fielder)
+		child.Send()
+		root.Send()
+		after = append(after, time.Now())
+	}
+	for _, tp := range sender.providers {
+		tp.ForceFlush(context.Background())
+	}
+
+	offsets := map[string]time.Duration{"frontend": skew.offset("frontend"), "backend": skew.offset("backend")}
+	for service, off := range offsets {
+		if off == 0 || off < -maxSkew || off > maxSkew {
+			t.Errorf("expected %s's offset to be within %v, got %v", service, maxSkew, off)
+		}
+	}
+	if offsets["frontend"] == offsets["backend"] {
+		t.Errorf("expected the services to have different offsets, got %v", offsets)
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 3*20 {
+		t.Fatalf("expected 60 spans, got %d", len(spans))
+	}
+	for _, span := range spans {
+		service, _ := span.Resource.Set().Value("service.name")
+		off := offsets[service.AsString()]
+		// each span's real times are within those of its trace
+		start, end := span.StartTime.Add(-off), span.EndTime.Add(-off)
+		var inTrace bool
+		for i := range before {
+			if !start.Before(before[i]) && !end.After(after[i]) {
+				inTrace = true
+				break
+			}
+		}
+		if !inTrace {
+			t.Errorf("expected the %s span at %v-%v to be shifted by %v", service.AsString(), span.StartTime, span.EndTime, off)
+		}
+	}
+
+	// the offsets depend only on the seed and the service
+	again, _ := newClockSkew("skew", maxSkew)
+	if off := again.offset("backend"); off != offsets["backend"] {
+		t.Errorf("expected the same offset for the same seed, got %v and %v", offsets["backend"], off)
+	}
+	if _, err := newClockSkew("skew", -time.Second); err == nil {
+		t.Errorf("expected an error for a negative clockskew")
+	}
+}
+
+func TestSenderOTel_syntheticTime(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	sender := &SenderOTel{opts: newOptions(), exceptions: newExceptionGen("test", nil), exporters: []*sharedExporter{{SpanExporter: exporter, log: NewLogger(0)}}, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0)}
+	opts := newOptions()
+	opts.Format.SyntheticTime = true
+	opts.Quantity.TPS = 100
+	gen, err := NewTraceGenerator(sender, nil, NewLogger(0), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, interval := gen.targetGenerators(), gen.interval(); n != 1 || interval != 10*time.Millisecond {
+		t.Errorf("expected one generator starting a trace every 10ms with synthetic time, got %d every %v", n, interval)
+	}
+
+	// 20 traces of 10s each are made without waiting for them
+	const traceTime = 10 * time.Second
+	begin := time.Now()
+	for i := 0; i < 20; i++ {
+		gen.generate_root(newServiceFielders(newTestFielder(t, 3), gen.serviceFielder, gen.log), int64(i), 3, 6, traceTime)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("expected the traces to be made at once, took %v", elapsed)
+	}
+	for _, tp := range sender.providers {
+		tp.ForceFlush(context.Background())
+	}
+
+	spans := exporter.GetSpans()
+	byID := map[trace.SpanID]tracetest.SpanStub{}
+	for _, span := range spans {
+		byID[span.SpanContext.SpanID()] = span
+	}
+	var roots int
+	for _, span := range spans {
+		parent, ok := byID[span.Parent.SpanID()]
+		if !ok {
+			roots++
+			// the leaves don't take all the time that's left for them
+			if d := span.EndTime.Sub(span.StartTime); d <= 0 || d > traceTime {
+				t.Errorf("expected the root to take up to %v, got %v", traceTime, d)
+			}
+			continue
+		}
+		if span.StartTime.Before(parent.StartTime) || span.EndTime.After(parent.EndTime) || !span.EndTime.After(span.StartTime) {
+			t.Errorf("expected the span at %v-%v to be within its parent's %v-%v", span.StartTime, span.EndTime, parent.StartTime, parent.EndTime)
+		}
+	}
+	if roots != 20 {
+		t.Errorf("expected 20 roots, got %d of %d spans", roots, len(spans))
+	}
+
+	// a span takes exactly as long as the clock was advanced
+	exporter.Reset()
+	clock := newTraceClock(true)
+	ctx, times := clock.start(context.Background())
+	ctx, root := sender.CreateTrace(ctx, "frontend", newTestFielder(t, 2), 1)
+	clock.sleep(time.Second)
+	ctx, childTimes := clock.start(ctx)
+	_, child := sender.CreateSpan(ctx, "backend", 1, newTestFielder(t, 2))
+	clock.sleep(3 * time.Second)
+	clock.end(childTimes)
+	child.Send()
+	clock.sleep(time.Second)
+	clock.end(times)
+	root.Send()
+	for _, tp := range sender.providers {
+		tp.ForceFlush(context.Background())
+	}
+	for _, span := range exporter.GetSpans() {
+		want := 3 * time.Second // the call, both the caller's and callee's halves
+		if !span.Parent.IsValid() {
+			want = 5 * time.Second
+		}
+		if d := span.EndTime.Sub(span.StartTime); d != want {
+			t.Errorf("expected the %s span to take %v, got %v", span.SpanKind, want, d)
+		}
+	}
+}
+
+func TestSenderOTel_runLabel(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	opts := newOptions()
+	opts.Format.RunLabel = "brave-widget"
+	opts.Format.LeafRate = 0.5
+	sender := &SenderOTel{opts: opts, exceptions: newExceptionGen("test", nil), externals: mustExternalCallGen(t), exporters: []*sharedExporter{{SpanExporter: exporter, log: NewLogger(0)}}, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0), runLabel: opts.Format.RunLabel}
+	gen, err := NewTraceGenerator(sender, nil, NewLogger(0), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		fielder := newTestFielder(t, 3)
+		fielder.SetRunLabel(opts.Format.RunLabel)
+		gen.generate_root(newServiceFielders(fielder, gen.serviceFielder, gen.log), int64(i), 3, 6, 0)
+	}
+	for _, tp := range sender.providers {
+		tp.ForceFlush(context.Background())
+	}
+
+	// every span has it, including the callers' halves and the database calls
+	spans := exporter.GetSpans()
+	kinds := map[trace.SpanKind]bool{}
+	for _, span := range spans {
+		kinds[span.SpanKind] = true
+		attrs := attribute.NewSet(span.Attributes...)
+		if label, _ := attrs.Value(runLabelField); label.AsString() != "brave-widget" {
+			t.Errorf("expected a %s span to have %s=brave-widget, got %v", span.SpanKind, runLabelField, span.Attributes)
+		}
+	}
+	if len(spans) < 20*6 || !kinds[trace.SpanKindClient] {
+		t.Errorf("expected at least 120 spans with some client spans, got %d of kinds %v", len(spans), kinds)
+	}
+}
+
+func TestSenderOTel_duplicateSpans(t *testing.T) {
+	const ntraces, rate = 500, 0.2
+	exporter := tracetest.NewInMemoryExporter()
+	shared := &sharedExporter{SpanExporter: exporter, dupRate: rate, log: NewLogger(0)}
+	sender := &SenderOTel{opts: newOptions(), exceptions: newExceptionGen("test", nil), exporters: []*sharedExporter{shared}, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0)}
+	fielder := newTestFielder(t, 2)
+	for i := 0; i < ntraces; i++ {
+		ctx, root := sender.CreateTrace(context.Background(), "frontend", fielder, int64(i))
+		_, child := sender.CreateSpan(ctx, "frontend", 1, fielder)
+		child.Send()
+		root.Send()
+	}
+	for _, tp := range sender.providers {
+		tp.ForceFlush(context.Background())
+	}
+
+	// a duplicate has the same ids and times as the first one
+	spans := exporter.GetSpans()
+	first := make(map[trace.SpanID]tracetest.SpanStub)
+	var dups int
+	for _, span := range spans {
+		orig, ok := first[span.SpanContext.SpanID()]
+		if !ok {
+			first[span.SpanContext.SpanID()] = span
+			continue
+		}
+		dups++
+		if orig.SpanContext.TraceID() != span.SpanContext.TraceID() || orig.Parent.SpanID() != span.Parent.SpanID() ||
+			!orig.StartTime.Equal(span.StartTime) || !orig.EndTime.Equal(span.EndTime) {
+			t.Errorf("expected a duplicate of %v to be the same, got %v", orig, span)
+		}
+	}
+	if len(first) != 2*ntraces {
+		t.Errorf("expected %d distinct spans, got %d", 2*ntraces, len(first))
+	}
+	if int64(dups) != shared.duplicated.Load() {
+		t.Errorf("expected %d duplicates to be counted, got %d", dups, shared.duplicated.Load())
+	}
+	if got := float64(dups) / float64(len(first)); math.Abs(got-rate) > 0.05 {
+		t.Errorf("expected about %.0f%% of the spans to be duplicated, got %.1f%%", 100*rate, 100*got)
+	}
+}
+
+func TestSenderOTel_serviceVersions(t *testing.T) {
+	spansByVersion := func(canary float64) map[string]map[string]int {
+		versions, err := newServiceVersions("versions", canary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exporter := tracetest.NewInMemoryExporter()
+		sender := &SenderOTel{opts: newOptions(), exceptions: newExceptionGen("test", nil), exporters: []*sharedExporter{{SpanExporter: exporter, log: NewLogger(0)}}, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0), versions: versions}
+		fielder := newTestFielder(t, 2)
+		for i := 0; i < 500; i++ {
+			ctx, root := sender.CreateTrace(context.Background(), "frontend", fielder, int64(i))
+			_, child := sender.CreateSpan(ctx, "backend", 1, fielder)
+			child.Send()
+			root.Send()
+		}
+		for _, tp := range sender.providers {
+			tp.ForceFlush(context.Background())
+		}
+		counts := map[string]map[string]int{}
+		for _, span := range exporter.GetSpans() {
+			service, _ := span.Resource.Set().Value("service.name")
+			attrs := attribute.NewSet(span.Attributes...)
+			version, _ := attrs.Value("service.version")
+			if counts[service.AsString()] == nil {
+				counts[service.AsString()] = map[string]int{}
+			}
+			counts[service.AsString()][version.AsString()]++
+		}
+		return counts
+	}
+
+	// the frontend has the roots and the callers' halves of the calls, and
+	// the backend has the callees' halves
+	totals := map[string]int{"frontend": 1000, "backend": 500}
+	versions, _ := newServiceVersions("versions", 0)
+	for service, counts := range spansByVersion(0.2) {
+		stable, canary := versions.of(service)
+		if len(counts) != 2 || counts[stable]+counts[canary] != totals[service] || stable == canary {
+			t.Errorf("expected %s to have versions %s and %s, got %v", service, stable, canary, counts)
+		}
+		// the canaries are drawn from the seed, so the fraction is the same on every run
+		if frac := float64(counts[canary]) / float64(totals[service]); frac < 0.15 || frac > 0.25 {
+			t.Errorf("expected about 20%% of %s's spans to be canaries, got %.3f", service, frac)
+		}
+	}
+	for service, counts := range spansByVersion(0) {
+		if stable, _ := versions.of(service); len(counts) != 1 || counts[stable] != totals[service] {
+			t.Errorf("expected all of %s's spans to have version %s, got %v", service, stable, counts)
+		}
+	}
+
+	// the versions depend only on the seed and the service
+	again, _ := newServiceVersions("versions", 0.5)
+	stable, canary := versions.of("backend")
+	if stable2, canary2 := again.of("backend"); stable != stable2 || canary != canary2 {
+		t.Errorf("expected the same versions for the same seed, got %s and %s, then %s and %s", stable, canary, stable2, canary2)
+	}
+	if _, err := newServiceVersions("versions", 1.5); err == nil {
+		t.Errorf("expected an error for a canary fraction over 1")
+	}
+}
+
+func TestOTelBatch_options(t *testing.T) {
+	applied := func(b otelBatch) sdktrace.BatchSpanProcessorOptions {
+		var o sdktrace.BatchSpanProcessorOptions
+		for _, opt := range b.options() {
+			opt(&o)
+		}
+		return o
+	}
+
+	opts := newOptions()
+	b, err := newOTelBatch(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := applied(b)
+	if o.MaxExportBatchSize != sdktrace.DefaultMaxExportBatchSize || o.MaxQueueSize != sdktrace.DefaultMaxQueueSize ||
+		o.BatchTimeout != sdktrace.DefaultScheduleDelay*time.Millisecond || !o.BlockOnQueueFull {
+		t.Errorf("expected the SDK's defaults, got %+v", o)
+	}
+
+	opts.Output.BatchSize = 1000
+	opts.Output.BatchQueue = 8192
+	opts.Output.BatchTimeout = 200 * time.Millisecond
+	if b, err = newOTelBatch(opts); err != nil {
+		t.Fatal(err)
+	}
+	o = applied(b)
+	if o.MaxExportBatchSize != 1000 || o.MaxQueueSize != 8192 || o.BatchTimeout != 200*time.Millisecond || !o.BlockOnQueueFull {
+		t.Errorf("expected the options to map through, got %+v", o)
+	}
+	if b.queueSize() != 8192 {
+		t.Errorf("expected QueueFullness to use a queue of 8192, got %d", b.queueSize())
+	}
+
+	// a batch can't be bigger than the queue
+	opts.Output.BatchQueue = 500
+	if b, err = newOTelBatch(opts); err != nil || b.size != 500 {
+		t.Errorf("expected the batch size to be limited to the queue, got %d (%v)", b.size, err)
+	}
+
+	opts.Output.BatchQueue = -1
+	if _, err := newOTelBatch(opts); err == nil {
+		t.Error("expected an error for a negative queue")
+	}
+}
+
+func TestSenderOTel_hosts(t *testing.T) {
+	var memories []*tracetest.InMemoryExporter
+	var exporters []*sharedExporter
+	for _, host := range []string{"a:4317", "b:4317", "c:4317"} {
+		memory := tracetest.NewInMemoryExporter()
+		memories = append(memories, memory)
+		exporters = append(exporters, &sharedExporter{SpanExporter: memory, host: host, log: NewLogger(0)})
+	}
+	sender := &SenderOTel{opts: newOptions(), exceptions: newExceptionGen("test", nil), exporters: exporters, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0)}
+
+	fielder := newTestFielder(t, 2)
+	const ntraces = 300
+	for i := 0; i < ntraces; i++ {
+		ctx, root := sender.CreateTrace(context.Background(), "svc", fielder, int64(i))
+		_, child := sender.CreateSpan(ctx, "svc2", 1, fielder)
+		child.Send()
+		root.Send()
+	}
+	for _, tp := range sender.providers {
+		tp.ForceFlush(context.Background())
+	}
+
+	host := map[trace.TraceID]int{}
+	for i, memory := range memories {
+		spans := memory.GetSpans()
+		// each trace is a root and a call to another service, which is a pair of spans
+		if n := len(spans); n < ntraces*9/10 || n > ntraces*11/10 {
+			t.Errorf("expected about %d spans sent to %s, got %d", ntraces, exporters[i].host, n)
+		}
+		if n := exporters[i].traces.Load(); n < ntraces/3*9/10 || n > ntraces/3*11/10 {
+			t.Errorf("expected about %d traces counted for %s, got %d", ntraces/3, exporters[i].host, n)
+		}
+		for _, span := range spans {
+			id := span.SpanContext.TraceID()
+			if h, ok := host[id]; ok && h != i {
+				t.Errorf("trace %s was split between %s and %s", id, exporters[h].host, exporters[i].host)
+			}
+			host[id] = i
+		}
+	}
+	if len(host) != ntraces {
+		t.Errorf("expected %d traces, got %d", ntraces, len(host))
+	}
+}
+
+func TestSenderOTel_sampleRate(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	sampler, err := newSampler(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &SenderOTel{opts: newOptions(), exceptions: newExceptionGen("test", nil), exporters: []*sharedExporter{{SpanExporter: exporter, log: NewLogger(0)}}, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0), sampleRate: 0.5, sampler: sampler}
+
+	fielder := newTestFielder(t, 2)
+	const ntraces = 2000
+	for i := 0; i < ntraces; i++ {
+		ctx, root := sender.CreateTrace(context.Background(), "svc", fielder, int64(i))
+		_, child := sender.CreateSpan(ctx, "svc2", 1, fielder)
+		child.Send()
+		root.Send()
+	}
+	for _, tp := range sender.providers {
+		tp.ForceFlush(context.Background())
+	}
+
+	spansPerTrace := map[trace.TraceID]int{}
+	for _, span := range exporter.GetSpans() {
+		spansPerTrace[span.SpanContext.TraceID()]++
+		attrs := attribute.NewSet(span.Attributes...)
+		rate, _ := attrs.Value(sampleRateField)
+		if rate.AsInt64() != 2 {
+			t.Errorf("expected a %s of 2, got %v", sampleRateField, rate.Emit())
+		}
+	}
+	if n := len(spansPerTrace); n < ntraces*4/10 || n > ntraces*6/10 {
+		t.Errorf("expected about half of %d traces to be exported, got %d", ntraces, n)
+	}
+	if n := sender.sampled.Load(); n != int64(len(spansPerTrace)) || sender.traces.Load() != ntraces {
+		t.Errorf("counted %d of %d traces sampled, but %d were exported", n, sender.traces.Load(), len(spansPerTrace))
+	}
+	for id, n := range spansPerTrace {
+		if n != 3 {
+			t.Errorf("trace %s has %d spans; children should follow the root's decision", id, n)
+		}
+	}
+
+	if _, err := newSampler(0); err == nil {
+		t.Errorf("expected an error for a sample rate of 0")
+	}
+	if s, err := newSampler(1); s != nil || err != nil {
+		t.Errorf("expected no sampler for a sample rate of 1")
+	}
+}
+
+func TestSenderOTel_traceState(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background())
+	ts, err := trace.ParseTraceState("vendor=value,other=123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &SenderOTel{tracer: tp.Tracer("test"), exceptions: newExceptionGen("test", nil), traceState: ts}
+
+	fielder := newTestFielder(t, 2)
+	ctx, root := sender.CreateTrace(context.Background(), "svc", fielder, 1)
+	if got := trace.SpanContextFromContext(ctx).TraceState().String(); got != "vendor=value,other=123" {
+		t.Errorf("expected the root's context to have the tracestate, got %q", got)
+	}
+	_, child := sender.CreateSpan(ctx, "svc", 1, fielder)
+	child.Send()
+	root.Send()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	for _, span := range spans {
+		if got := span.SpanContext.TraceState().String(); got != "vendor=value,other=123" {
+			t.Errorf("%s: expected the tracestate, got %q", span.Name, got)
+		}
+	}
+	if rootSpan := spans[1]; rootSpan.Parent.IsValid() {
+		t.Errorf("expected the root span to have no parent, got %v", rootSpan.Parent.SpanID())
+	}
+}
+
+func TestSenderOTel_baggage(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background())
+	bag, err := baggage.Parse("user.id=123,tenant=acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &SenderOTel{tracer: tp.Tracer("test"), exceptions: newExceptionGen("test", nil), baggage: bag, baggageAttrs: true}
+
+	fielder := newTestFielder(t, 3)
+	ctx, root := sender.CreateTrace(context.Background(), "frontend", fielder, 1)
+	ctx, child := sender.CreateSpan(ctx, "backend", 1, fielder)
+	grandchildCtx, grandchild := sender.CreateSpan(ctx, "backend", 2, fielder)
+	for _, c := range []context.Context{ctx, grandchildCtx} {
+		b := baggage.FromContext(c)
+		if b.Member("user.id").Value() != "123" || b.Member("tenant").Value() != "acme" {
+			t.Errorf("expected a child's context to have the baggage, got %q", b.String())
+		}
+	}
+	grandchild.Send()
+	child.Send()
+	root.Send()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(spans))
+	}
+	for _, span := range spans {
+		attrs := attribute.NewSet(span.Attributes...)
+		if v, _ := attrs.Value("tenant"); v.AsString() != "acme" {
+			t.Errorf("%s: expected the baggage as attributes, got %v", span.Name, span.Attributes)
+		}
+	}
+
+	// without --baggageattrs, the spans don't get them
+	exporter.Reset()
+	sender.baggageAttrs = false
+	_, root = sender.CreateTrace(context.Background(), "frontend", fielder, 2)
+	root.Send()
+	if attrs := attribute.NewSet(exporter.GetSpans()[0].Attributes...); attrs.HasValue("tenant") {
+		t.Errorf("expected no baggage attributes, got %v", exporter.GetSpans()[0].Attributes)
+	}
+}
+
+func TestSenderOTel_resource(t *testing.T) {
+	opts := newOptions()
+	opts.Telemetry.HostName = "loadgen-1"
+	opts.Telemetry.Region = "us-east-1"
+	opts.Telemetry.Environment = "staging"
+	opts.Telemetry.Resource = []string{"team=platform", "k8s.pod.name=pod=7"}
+	attrs, err := resourceAttributes(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := newResource(attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	sender := &SenderOTel{opts: opts, exceptions: newExceptionGen("test", nil), exporters: []*sharedExporter{{SpanExporter: exporter, log: NewLogger(0)}}, providers: map[providerKey]*sdktrace.TracerProvider{}, resource: res, log: NewLogger(0)}
+
+	_, span := sender.CreateTrace(context.Background(), "svc", newTestFielder(t, 1), 1)
+	span.Send()
+	for _, tp := range sender.providers {
+		tp.ForceFlush(context.Background())
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	set := spans[0].Resource.Set()
+	for key, want := range map[string]string{
+		"service.name":           "svc",
+		"host.name":              "loadgen-1",
+		"cloud.region":           "us-east-1",
+		"deployment.environment": "staging",
+		"team":                   "platform",
+		"k8s.pod.name":           "pod=7",
+		"telemetry.sdk.name":     "opentelemetry",
+	} {
+		if got, ok := set.Value(attribute.Key(key)); !ok || got.AsString() != want {
+			t.Errorf("expected resource attribute %s=%s, got %q", key, want, got.AsString())
+		}
+	}
+
+	opts.Telemetry.Resource = []string{"novalue"}
+	if _, err := resourceAttributes(opts); err == nil {
+		t.Errorf("expected an error for a resource attribute without '='")
+	}
+}
+
+// writeTestCert writes a self-signed certificate and its key as PEM files in dir,
+// and returns their paths and the DER bytes of the certificate.
+func writeTestCert(t *testing.T, dir string) (string, string, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "loadgen"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, der
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, der := writeTestCert(t, dir)
+
+	opts := newOptions()
+	if cfg, err := newTLSConfig(opts); cfg != nil || err != nil {
+		t.Errorf("expected no TLS config without the options, got %v, %v", cfg, err)
+	}
+
+	opts.Telemetry.TLSCert = certFile
+	opts.Telemetry.TLSKey = keyFile
+	opts.Telemetry.TLSCA = certFile
+	cfg, err := newTLSConfig(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Certificates) != 1 || len(cfg.Certificates[0].Certificate) != 1 || !bytes.Equal(cfg.Certificates[0].Certificate[0], der) {
+		t.Errorf("expected the client certificate to be loaded, got %v", cfg.Certificates)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: cfg.RootCAs}); err != nil {
+		t.Errorf("expected the CA to be trusted: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		change  func(o *Options)
+		message string
+	}{
+		{"missing cert", func(o *Options) { o.Telemetry.TLSCert = filepath.Join(dir, "missing.pem") }, "missing.pem"},
+		{"key without cert", func(o *Options) { o.Telemetry.TLSCert = "" }, "must be used together"},
+		{"missing CA", func(o *Options) { o.Telemetry.TLSCA = filepath.Join(dir, "missing-ca.pem") }, "missing-ca.pem"},
+		{"CA isn't PEM", func(o *Options) { o.Telemetry.TLSCA = keyFile }, "no CA certificates"},
+		{"insecure", func(o *Options) { o.Telemetry.Insecure = true }, "--insecure"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newOptions()
+			o.Telemetry.TLSCert = certFile
+			o.Telemetry.TLSKey = keyFile
+			o.Telemetry.TLSCA = certFile
+			tt.change(o)
+			_, err := newTLSConfig(o)
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("expected an error mentioning %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
+
+func TestNewTraceExporter_headersAndPath(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+	}))
+	defer server.Close()
+
+	opts := newOptions()
+	opts.Telemetry.APIKey = "key"
+	opts.Telemetry.Insecure = true
+	opts.Telemetry.Headers = []string{"authorization=Bearer abc=", "x-scope-orgid=tenant1"}
+	opts.Telemetry.TracesPath = "/gateway/traces"
+	opts.Output.Protocol = "protobuf"
+	opts.apihost, _ = url.Parse(server.URL)
+	headers, err := otelHeaders(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter, err := newTraceExporter(opts, opts.apihost, headers, newRetryPolicy(NewLogger(0), opts), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exporter.Shutdown(context.Background())
+	if err := exporter.ExportSpans(context.Background(), tracetest.SpanStubs{{Name: "test"}}.Snapshots()); err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-requests
+	if r.URL.Path != "/gateway/traces" {
+		t.Errorf("expected traces to be posted to /gateway/traces, got %s", r.URL.Path)
+	}
+	expected := map[string]string{
+		"x-honeycomb-team": "key",
+		"authorization":    "Bearer abc=",
+		"x-scope-orgid":    "tenant1",
+	}
+	for key, want := range expected {
+		if got := r.Header.Get(key); got != want {
+			t.Errorf("expected header %s to be %q, got %q", key, want, got)
+		}
+	}
+
+	opts.Telemetry.Headers = []string{"no-value"}
+	if _, err := otelHeaders(opts); err == nil {
+		t.Error("expected an error for a header without a value")
+	}
+}
+
+func TestSenderOTel_datasetSuffix(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	opts := newOptions()
+	opts.Telemetry.Dataset = ""
+	opts.Telemetry.Suffix = "-ci42"
+	sender := &SenderOTel{opts: opts, exceptions: newExceptionGen("test", nil), exporters: []*sharedExporter{{SpanExporter: exporter, log: NewLogger(0)}}, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0)}
+
+	fielder := newTestFielder(t, 1)
+	service := fielder.GetServiceName(0)
+	_, root := sender.CreateTrace(context.Background(), service, fielder, 1)
+	root.Send()
+	for _, tp := range sender.providers {
+		tp.ForceFlush(context.Background())
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if dataset, _ := spans[0].Resource.Set().Value("service.name"); dataset.AsString() != service+"-ci42" {
+		t.Errorf("expected the span in dataset %s-ci42, got %s", service, dataset.AsString())
+	}
+
+	opts.Telemetry.Dataset = "fixed"
+	if got := resolveDataset(opts, service); got != "fixed-ci42" {
+		t.Errorf("expected the suffix after --dataset, got %s", got)
+	}
+}
+
+func Test_autoDatasetSuffix(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.FixedZone("PDT", -7*3600))
+	env := map[string]string{}
+	getenv := func(name string) string { return env[name] }
+	if got := autoDatasetSuffix(getenv, now); got != "-20240506-140809" {
+		t.Errorf("expected the UTC time outside of CI, got %s", got)
+	}
+	env["CI_COMMIT_SHA"] = "0123456789abcdef"
+	if got := autoDatasetSuffix(getenv, now); got != "-0123456" {
+		t.Errorf("expected the short commit sha, got %s", got)
+	}
+	env["GITHUB_SHA"] = "fedcba9876543210"
+	if got := autoDatasetSuffix(getenv, now); got != "-fedcba9" {
+		t.Errorf("expected GitHub's commit sha first, got %s", got)
+	}
+}
@@ -0,0 +1,203 @@
+package loadgen
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protodelim"
+)
+
+// make sure it implements Sender
+var _ Sender = (*SenderOTLPFile)(nil)
+
+type otlpFileKey struct{}
+
+// pendingSpan is a finished span waiting to be written, along with its service.
+type pendingSpan struct {
+	service string
+	span    *tracepb.Span
+}
+
+type OTLPFileSendable struct {
+	pendingSpan
+	start  time.Time
+	sender *SenderOTLPFile
+}
+
+func (s *OTLPFileSendable) Send() {
+	s.span.StartTimeUnixNano = uint64(s.start.UnixNano())
+	s.span.EndTimeUnixNano = uint64(time.Now().UnixNano())
+	s.sender.enqueue(s.pendingSpan)
+}
+
+// SenderOTLPFile writes spans to a file as length-delimited OTLP
+// ExportTraceServiceRequest protobufs (each one preceded by its size as a
+// varint), in batches of up to --batchsize spans, so that they can be read by
+// otel tooling or a collector's file receiver. Like the otel sender, each
+// batch has a resource per dataset.
+type SenderOTLPFile struct {
+	out        io.WriteCloser
+	w          *bufio.Writer
+	filename   string
+	dataset    string
+	suffix     string // --datasetsuffix
+	scope      *commonpb.InstrumentationScope
+	resource   []*commonpb.KeyValue // added to service.name on every resource
+	batchSize  int
+	mut        sync.Mutex
+	batch      []pendingSpan
+	requests   int
+	tracecount int
+	nspans     int
+	log        Logger
+}
+
+func NewSenderOTLPFile(log Logger, opts *Options) *SenderOTLPFile {
+	attrs, err := resourceAttributes(opts)
+	if err != nil {
+		log.Fatal("%v\n", err)
+	}
+	var out io.WriteCloser = os.Stdout
+	filename := opts.Output.OutFile
+	if filename != "" && filename != "-" {
+		f, err := os.Create(filename)
+		if err != nil {
+			log.Fatal("unable to create %s: %v\n", filename, err)
+		}
+		out = f
+	} else {
+		filename = "stdout"
+	}
+	batchSize := opts.BatchSize(defaultBatchSize)
+	return &SenderOTLPFile{
+		out:       out,
+		w:         bufio.NewWriter(out),
+		filename:  filename,
+		dataset:   opts.Telemetry.Dataset,
+		suffix:    opts.Telemetry.Suffix,
+		scope:     &commonpb.InstrumentationScope{Name: ResourceLibrary, Version: ResourceVersion},
+		resource:  otlpResourceAttributes(attrs),
+		batchSize: batchSize,
+		batch:     make([]pendingSpan, 0, batchSize),
+		log:       log,
+	}
+}
+
+func (t *SenderOTLPFile) enqueue(span pendingSpan) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.batch = append(t.batch, span)
+	if len(t.batch) >= t.batchSize {
+		t.write()
+	}
+}
+
+// write writes the batch as a request; the caller must hold the lock.
+func (t *SenderOTLPFile) write() {
+	if len(t.batch) == 0 {
+		return
+	}
+	if _, err := protodelim.MarshalTo(t.w, t.request(t.batch)); err != nil {
+		t.log.Error("unable to write %d spans to %s: %v\n", len(t.batch), t.filename, err)
+	} else {
+		t.requests++
+	}
+	t.batch = make([]pendingSpan, 0, t.batchSize)
+}
+
+// request builds an export request with one resource per dataset, so that
+// without a --dataset each service's spans go to a dataset of their own.
+func (t *SenderOTLPFile) request(batch []pendingSpan) *coltracepb.ExportTraceServiceRequest {
+	req := &coltracepb.ExportTraceServiceRequest{}
+	byDataset := make(map[string]*tracepb.ScopeSpans)
+	for _, p := range batch {
+		dataset := datasetName(t.dataset, t.suffix, p.service)
+		ss, ok := byDataset[dataset]
+		if !ok {
+			ss = &tracepb.ScopeSpans{Scope: t.scope}
+			byDataset[dataset] = ss
+			req.ResourceSpans = append(req.ResourceSpans, &tracepb.ResourceSpans{
+				Resource: &resourcepb.Resource{Attributes: append([]*commonpb.KeyValue{
+					otlpKeyValue("service.name", dataset),
+				}, t.resource...)},
+				ScopeSpans: []*tracepb.ScopeSpans{ss},
+			})
+		}
+		ss.Spans = append(ss.Spans, p.span)
+	}
+	return req
+}
+
+// Close writes the spans that are left, and closes the file.
+func (t *SenderOTLPFile) Close() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.write()
+	if err := t.w.Flush(); err != nil {
+		t.log.Error("unable to write %s: %v\n", t.filename, err)
+	}
+	if t.out != os.Stdout {
+		if err := t.out.Close(); err != nil {
+			t.log.Error("unable to close %s: %v\n", t.filename, err)
+		}
+	}
+	t.log.Warn("sender wrote %d traces with %d spans in %d requests to %s\n", t.tracecount, t.nspans, t.requests, t.filename)
+}
+
+// otlpID returns n random bytes, for a trace or span id.
+func otlpID(n int) []byte {
+	id := make([]byte, n)
+	rand.Read(id)
+	return id
+}
+
+func (t *SenderOTLPFile) newSendable(service string, name string, kind tracepb.Span_SpanKind, traceID, parentID []byte, fields map[string]any) *OTLPFileSendable {
+	attrs := make([]*commonpb.KeyValue, 0, len(fields))
+	for k, v := range fields {
+		attrs = append(attrs, otlpKeyValue(k, v))
+	}
+	return &OTLPFileSendable{
+		pendingSpan: pendingSpan{
+			service: service,
+			span: &tracepb.Span{
+				TraceId:      traceID,
+				SpanId:       otlpID(8),
+				ParentSpanId: parentID,
+				Name:         name,
+				Kind:         kind,
+				Attributes:   attrs,
+			},
+		},
+		start:  time.Now(),
+		sender: t,
+	}
+}
+
+func (t *SenderOTLPFile) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	t.mut.Lock()
+	t.tracecount++
+	t.nspans++
+	t.mut.Unlock()
+	s := t.newSendable(name, fielder.GetSpanName(name), tracepb.Span_SPAN_KIND_SERVER, otlpID(16), nil, fielder.GetFields(count, 0))
+	ctx = context.WithValue(ctx, otlpFileKey{}, s.span)
+	return ctx, s
+}
+
+func (t *SenderOTLPFile) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	t.mut.Lock()
+	t.nspans++
+	t.mut.Unlock()
+	parent := ctx.Value(otlpFileKey{}).(*tracepb.Span)
+	s := t.newSendable(name, fielder.GetSpanName(name), tracepb.Span_SPAN_KIND_INTERNAL, parent.TraceId, parent.SpanId, fielder.GetFields(0, level))
+	ctx = context.WithValue(ctx, otlpFileKey{}, s.span)
+	return ctx, s
+}
@@ -0,0 +1,83 @@
+package loadgen
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/encoding/protodelim"
+)
+
+func TestSenderOTLPFile(t *testing.T) {
+	opts := newOptions()
+	opts.Output.OutFile = filepath.Join(t.TempDir(), "spans.pb")
+	opts.Output.BatchSize = 4
+	sender := NewSenderOTLPFile(NewLogger(0), opts)
+	fielder, err := NewFielder("otlpfile", map[string]string{"answer": "/i42,42", "tags": "/arr s,2"}, 0, 1, 4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		ctx, root := sender.CreateTrace(context.Background(), "frontend", fielder, int64(i+1))
+		_, child := sender.CreateSpan(ctx, "backend", 1, fielder)
+		child.Send()
+		root.Send()
+	}
+	sender.Close()
+
+	f, err := os.Open(opts.Output.OutFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var requests, spans int
+	parents := map[string]bool{}
+	for {
+		req := &coltracepb.ExportTraceServiceRequest{}
+		if err := protodelim.UnmarshalFrom(r, req); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		requests++
+		for _, rs := range req.ResourceSpans {
+			service := rs.Resource.Attributes[0]
+			if service.Key != "service.name" {
+				t.Errorf("expected the resource to start with service.name, got %v", service)
+			}
+			for _, ss := range rs.ScopeSpans {
+				for _, span := range ss.Spans {
+					spans++
+					if len(span.TraceId) != 16 || len(span.SpanId) != 8 || span.EndTimeUnixNano < span.StartTimeUnixNano {
+						t.Errorf("unexpected ids or times in %v", span)
+					}
+					if service.Value.GetStringValue() == "backend" {
+						parents[string(span.ParentSpanId)] = true
+					} else if len(span.ParentSpanId) != 0 {
+						t.Errorf("expected the root to have no parent, got %x", span.ParentSpanId)
+					}
+					attrs := map[string]bool{}
+					for _, kv := range span.Attributes {
+						attrs[kv.Key] = true
+					}
+					if !attrs["answer"] || !attrs["tags"] {
+						t.Errorf("expected the fields as attributes, got %v", span.Attributes)
+					}
+				}
+			}
+		}
+	}
+	// 10 spans in batches of up to 4
+	if spans != 10 || requests != 3 {
+		t.Errorf("expected 10 spans in 3 requests, got %d in %d", spans, requests)
+	}
+	if len(parents) != 5 {
+		t.Errorf("expected each child to have its own parent, got %d parents", len(parents))
+	}
+}
@@ -0,0 +1,172 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// make sure it implements Sender, MetricSender, and LogSender
+var _ Sender = (*SenderPrint)(nil)
+var _ MetricSender = (*SenderPrint)(nil)
+var _ LogSender = (*SenderPrint)(nil)
+
+func ft(ts time.Time) string {
+	return ts.Format("15:04:05.000")
+}
+
+// randID creates a random byte array of length l and returns it as a hex string.
+func randID(l int) string {
+	id := make([]byte, l)
+	for i := 0; i < l; i++ {
+		id[i] = byte(rand.Intn(256))
+	}
+	return fmt.Sprintf("%x", id)
+}
+
+type traceInfo struct {
+	TraceId  string
+	SpanId   string
+	ParentId string
+}
+
+func (t *traceInfo) span(parent string) *traceInfo {
+	return &traceInfo{
+		TraceId:  t.TraceId,
+		SpanId:   randID(4),
+		ParentId: parent,
+	}
+}
+
+type PrintSendable struct {
+	TInfo     *traceInfo
+	Service   string
+	Name      string
+	StartTime time.Time
+	Fields    map[string]interface{}
+	format    string
+	log       Logger
+	times     *spanTimes // if set, the span's explicit times, as when replaying
+}
+
+// printedSpan is a span as printed by --printformat=json.
+type printedSpan struct {
+	Service   string
+	Name      string
+	TraceId   string
+	SpanId    string
+	ParentId  string
+	StartTime string
+	EndTime   string
+	Fields    map[string]interface{}
+}
+
+func (s *PrintSendable) Send() {
+	endTime := s.times.endTime()
+	if s.format == "json" {
+		b, err := json.Marshal(printedSpan{
+			Service:   s.Service,
+			Name:      s.Name,
+			TraceId:   s.TInfo.TraceId,
+			SpanId:    s.TInfo.SpanId,
+			ParentId:  s.TInfo.ParentId,
+			StartTime: s.StartTime.Format(time.RFC3339Nano),
+			EndTime:   endTime.Format(time.RFC3339Nano),
+			Fields:    s.Fields,
+		})
+		if err != nil {
+			s.log.Error("unable to marshal span: %v\n", err)
+			return
+		}
+		s.log.Printf("%s\n", b)
+		return
+	}
+	label := s.Service
+	if s.Name != s.Service {
+		label += " " + s.Name
+	}
+	s.log.Printf("%s - T:%6.6s S:%4.4s P%4.4s start:%v end:%v %v\n", label, s.TInfo.TraceId, s.TInfo.SpanId, s.TInfo.ParentId, ft(s.StartTime), ft(endTime), s.Fields)
+}
+
+type SenderPrint struct {
+	tracecount int
+	nspans     int
+	datapoints int
+	logs       int
+	format     string
+	log        Logger
+}
+
+func NewSenderPrint(log Logger, opts *Options) Sender {
+	return &SenderPrint{
+		format: opts.Output.PrintFormat,
+		log:    log,
+	}
+}
+
+func (t *SenderPrint) Close() {
+	if t.logs > 0 {
+		t.log.Warn("sender sent %d log records\n", t.logs)
+		return
+	}
+	if t.datapoints > 0 {
+		t.log.Warn("sender sent %d metric data points\n", t.datapoints)
+		return
+	}
+	t.log.Warn("sender sent %d traces with %d spans\n", t.tracecount, t.nspans)
+}
+
+type PrintKey string
+
+func (t *SenderPrint) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	t.tracecount++
+	t.nspans++
+	tinfo := &traceInfo{
+		TraceId:  randID(6),
+		SpanId:   randID(4),
+		ParentId: "",
+	}
+	times := spanTimesFromContext(ctx)
+	ctx = context.WithValue(ctx, PrintKey("trace"), tinfo)
+	return ctx, &PrintSendable{
+		Service:   name,
+		Name:      fielder.GetSpanName(name),
+		TInfo:     tinfo,
+		StartTime: times.startTime(),
+		Fields:    fielder.GetFields(count, 0),
+		format:    t.format,
+		log:       t.log,
+		times:     times,
+	}
+}
+
+func (t *SenderPrint) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	t.nspans++
+	parent := ctx.Value(PrintKey("trace")).(*traceInfo)
+	// the span's children need its own id as their parent id
+	tinfo := parent.span(parent.SpanId)
+	times := spanTimesFromContext(ctx)
+	ctx = context.WithValue(ctx, PrintKey("trace"), tinfo)
+	return ctx, &PrintSendable{
+		Service:   name,
+		Name:      fielder.GetSpanName(name),
+		TInfo:     tinfo,
+		StartTime: times.startTime(),
+		Fields:    fielder.GetFields(0, level),
+		format:    t.format,
+		log:       t.log,
+		times:     times,
+	}
+}
+
+func (t *SenderPrint) RecordMetrics(ctx context.Context, service string, fielder *Fielder, count int64) {
+	t.datapoints++
+	t.log.Printf("%s - metrics at %s: %v\n", service, ft(time.Now()), fielder.GetFields(count, 0))
+}
+
+func (t *SenderPrint) SendLog(ctx context.Context, service string, record LogRecord) {
+	t.logs++
+	t.log.Printf("%s - %s T:%6.6s S:%4.4s at %s: %s %v\n", service, record.SeverityText, record.TraceID, record.SpanID, ft(record.Time), record.Body, record.Fields)
+}
@@ -0,0 +1,73 @@
+package loadgen
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureStdout returns what f prints to stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	f()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestSenderPrint_json(t *testing.T) {
+	opts := newOptions()
+	opts.Output.PrintFormat = "json"
+	sender := NewSenderPrint(NewLogger(0), opts)
+	fielder := newTestFielder(t, 2)
+	out := captureStdout(t, func() {
+		ctx, root := sender.CreateTrace(context.Background(), "svc", fielder, 1)
+		_, child := sender.CreateSpan(ctx, "svc", 1, fielder)
+		child.Send()
+		root.Send()
+	})
+
+	var spans []map[string]any
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		var span map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &span); err != nil {
+			t.Fatalf("line %q isn't JSON: %v", scanner.Text(), err)
+		}
+		spans = append(spans, span)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(spans), out)
+	}
+	for _, span := range spans {
+		for _, key := range []string{"Name", "TraceId", "SpanId", "ParentId", "StartTime", "EndTime", "Fields"} {
+			if _, ok := span[key]; !ok {
+				t.Errorf("expected %s in %v", key, span)
+			}
+		}
+		for _, key := range []string{"StartTime", "EndTime"} {
+			if _, err := time.Parse(time.RFC3339Nano, span[key].(string)); err != nil {
+				t.Errorf("%s isn't RFC3339: %v", key, err)
+			}
+		}
+	}
+	child, root := spans[0], spans[1]
+	if child["TraceId"] != root["TraceId"] || child["ParentId"] != root["SpanId"] || root["ParentId"] != "" {
+		t.Errorf("child %v isn't a child of root %v", child, root)
+	}
+}
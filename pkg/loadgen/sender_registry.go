@@ -0,0 +1,48 @@
+package loadgen
+
+import "fmt"
+
+// A SenderFactory creates a sender from the options; it returns an error
+// rather than exiting if it can't.
+type SenderFactory func(log Logger, opts *Options) (Sender, error)
+
+// the senders that --sender and --tee can name, in the order they were registered
+var (
+	senderFactories = make(map[string]SenderFactory)
+	senderNames     []string
+)
+
+// RegisterSender makes a sender available to --sender and --tee by name. It's
+// meant to be called from an init function, so that a sender can be added
+// without changing main; like database/sql.Register, it panics if the name is
+// empty or already taken, or the factory is nil.
+func RegisterSender(name string, factory SenderFactory) {
+	if name == "" || factory == nil {
+		panic("RegisterSender needs a name and a factory")
+	}
+	if _, ok := senderFactories[name]; ok {
+		panic(fmt.Sprintf("RegisterSender called twice for sender %s", name))
+	}
+	senderFactories[name] = factory
+	senderNames = append(senderNames, name)
+}
+
+// builtinSender adapts the constructor of a built-in sender, which exits if it
+// can't create the sender, to a SenderFactory.
+func builtinSender[S Sender](newSender func(Logger, *Options) S) SenderFactory {
+	return func(log Logger, opts *Options) (Sender, error) {
+		return newSender(log, opts), nil
+	}
+}
+
+func init() {
+	RegisterSender("honeycomb", builtinSender(NewSenderHoneycomb))
+	RegisterSender("otel", builtinSender(NewSenderOTel))
+	RegisterSender("zipkin", builtinSender(NewSenderZipkin))
+	RegisterSender("datadog", builtinSender(NewSenderDatadog))
+	RegisterSender("honeycombdirect", builtinSender(NewSenderHoneycombDirect))
+	RegisterSender("print", builtinSender(NewSenderPrint))
+	RegisterSender("csv", builtinSender(NewSenderCSV))
+	RegisterSender("otlpfile", builtinSender(NewSenderOTLPFile))
+	RegisterSender("dummy", builtinSender(NewSenderDummy))
+}
@@ -0,0 +1,36 @@
+package loadgen
+
+import (
+	"testing"
+)
+
+func TestRegisterSender(t *testing.T) {
+	custom := &recordingSender{}
+	var got *Options
+	RegisterSender("test-custom", func(log Logger, opts *Options) (Sender, error) {
+		got = opts
+		return custom, nil
+	})
+	t.Cleanup(func() {
+		delete(senderFactories, "test-custom")
+		senderNames = senderNames[:len(senderNames)-1]
+	})
+
+	opts := newOptions()
+	if sender := newSender(NewLogger(0), "test-custom", opts); sender != custom {
+		t.Errorf("expected the registered sender, got %T", sender)
+	}
+	if got != opts {
+		t.Errorf("expected the factory to get the options")
+	}
+	if names := senderNames; names[0] != "honeycomb" || names[len(names)-1] != "test-custom" {
+		t.Errorf("expected the built-in senders and then the custom one, got %v", names)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected registering a sender twice to panic")
+		}
+	}()
+	RegisterSender("otel", builtinSender(NewSenderOTel))
+}
@@ -0,0 +1,79 @@
+package loadgen
+
+import (
+	"context"
+)
+
+// SenderTee sends every span through each of several senders (--tee), like
+// the otel sender and the print sender, to see what's being sent. The context
+// is passed through each sender in turn, so each one finds its own parent in
+// it. Each sender gets the span's fields from the fielder itself, so the random
+// values differ between them.
+type SenderTee struct {
+	senders []Sender
+}
+
+// make sure it implements Sender
+var _ Sender = (*SenderTee)(nil)
+
+func NewSenderTee(senders ...Sender) *SenderTee {
+	return &SenderTee{senders: senders}
+}
+
+// teeSendable sends the span of each sender.
+type teeSendable []Sendable
+
+func (s teeSendable) Send() {
+	for _, sendable := range s {
+		sendable.Send()
+	}
+}
+
+func (t *SenderTee) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	sendables := make(teeSendable, len(t.senders))
+	for i, sender := range t.senders {
+		ctx, sendables[i] = sender.CreateTrace(ctx, name, fielder, count)
+	}
+	return ctx, sendables
+}
+
+func (t *SenderTee) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	sendables := make(teeSendable, len(t.senders))
+	for i, sender := range t.senders {
+		ctx, sendables[i] = sender.CreateSpan(ctx, name, level, fielder)
+	}
+	return ctx, sendables
+}
+
+func (t *SenderTee) Close() {
+	for _, sender := range t.senders {
+		sender.Close()
+	}
+}
+
+// SendErrors adds up the error counts of the senders that keep one.
+func (t *SenderTee) SendErrors() int64 {
+	var n int64
+	for _, sender := range t.senders {
+		n += sendErrors(sender)
+	}
+	return n
+}
+
+// RejectedSpans adds up the rejected spans of the senders that count them.
+func (t *SenderTee) RejectedSpans() int64 {
+	var n int64
+	for _, sender := range t.senders {
+		n += rejectedSpans(sender)
+	}
+	return n
+}
+
+// QueueFullness is that of the fullest queue, if any of the senders has one.
+func (t *SenderTee) QueueFullness() float64 {
+	var fullest float64
+	for _, sender := range t.senders {
+		fullest = max(fullest, queueFullness(sender))
+	}
+	return fullest
+}
@@ -0,0 +1,98 @@
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// parentSender records each span it creates and sends, by its parent's name,
+// and keeps its spans' names in the context under its own key, so that it
+// finds its own parent after the other senders have added theirs.
+type parentKey string
+
+type parentSender struct {
+	key    parentKey
+	spans  []string // "parent>span" for each span created
+	sent   []string
+	closed bool
+}
+
+type parentSendable struct {
+	sender *parentSender
+	name   string
+}
+
+func (s parentSendable) Send() {
+	s.sender.sent = append(s.sender.sent, s.name)
+}
+
+func (s *parentSender) create(ctx context.Context, name string) (context.Context, Sendable) {
+	parent, _ := ctx.Value(s.key).(string)
+	name = fmt.Sprintf("%s%d", name, len(s.spans))
+	s.spans = append(s.spans, parent+">"+name)
+	return context.WithValue(ctx, s.key, name), parentSendable{s, name}
+}
+
+func (s *parentSender) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	return s.create(ctx, name)
+}
+
+func (s *parentSender) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	return s.create(ctx, name)
+}
+
+func (s *parentSender) Close() {
+	s.closed = true
+}
+
+func TestSenderTee(t *testing.T) {
+	a, b := &parentSender{key: "a"}, &parentSender{key: "b"}
+	tee := NewSenderTee(a, b)
+	fielder := newTestFielder(t, 2)
+	for i := 0; i < 2; i++ {
+		ctx, root := tee.CreateTrace(context.Background(), "root", fielder, int64(i))
+		childctx, child := tee.CreateSpan(ctx, "child", 1, fielder)
+		_, grandchild := tee.CreateSpan(childctx, "grandchild", 2, fielder)
+		grandchild.Send()
+		child.Send()
+		root.Send()
+	}
+	tee.Close()
+
+	wantSpans := []string{">root0", "root0>child1", "child1>grandchild2", ">root3", "root3>child4", "child4>grandchild5"}
+	wantSent := []string{"grandchild2", "child1", "root0", "grandchild5", "child4", "root3"}
+	for _, s := range []*parentSender{a, b} {
+		if !reflect.DeepEqual(s.spans, wantSpans) {
+			t.Errorf("expected sender %s to create %v, got %v", s.key, wantSpans, s.spans)
+		}
+		if !reflect.DeepEqual(s.sent, wantSent) {
+			t.Errorf("expected sender %s to send %v, got %v", s.key, wantSent, s.sent)
+		}
+		if !s.closed {
+			t.Errorf("expected sender %s to be closed", s.key)
+		}
+	}
+}
+
+// rejectingSender is a parentSender whose backend rejects a fixed number of spans.
+type rejectingSender struct {
+	parentSender
+	rejected int64
+}
+
+func (s *rejectingSender) RejectedSpans() int64 { return s.rejected }
+
+func TestSenderTee_rejectedSpans(t *testing.T) {
+	a, b := &rejectingSender{rejected: 2}, &rejectingSender{rejected: 3}
+	tee := NewSenderTee(a, &parentSender{}, b)
+	if n := tee.RejectedSpans(); n != 5 {
+		t.Errorf("expected the tee to add up 5 rejected spans, got %d", n)
+	}
+	// the wrappers pass them through
+	var sender Sender = NewSenderSummarized(NewSenderTimed(tee, NewLogger(0), 0), 0)
+	if rc, ok := sender.(RejectCounter); !ok || rc.RejectedSpans() != 5 {
+		t.Errorf("expected the wrapped tee to pass through 5 rejected spans")
+	}
+}
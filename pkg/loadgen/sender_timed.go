@@ -0,0 +1,124 @@
+package loadgen
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"pgregory.net/rand"
+)
+
+// maxIntervals bounds the memory used to record the intervals between traces;
+// after that many, a random sample of them is kept.
+const maxIntervals = 10000
+
+// intervalRecorder records the time between consecutive traces. It keeps the
+// exact minimum and maximum, and a uniform sample of the intervals (reservoir
+// sampling) to estimate the percentiles from.
+type intervalRecorder struct {
+	mut     sync.Mutex
+	rng     *rand.Rand
+	last    time.Time
+	count   int
+	min     time.Duration
+	max     time.Duration
+	samples []time.Duration
+}
+
+func newIntervalRecorder() *intervalRecorder {
+	return &intervalRecorder{rng: rand.New(), samples: make([]time.Duration, 0, maxIntervals)}
+}
+
+// record notes that a trace started at t; the first call only sets the starting point.
+func (r *intervalRecorder) record(t time.Time) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if r.last.IsZero() {
+		r.last = t
+		return
+	}
+	interval := t.Sub(r.last)
+	r.last = t
+	r.count++
+	if r.count == 1 || interval < r.min {
+		r.min = interval
+	}
+	if interval > r.max {
+		r.max = interval
+	}
+	if len(r.samples) < maxIntervals {
+		r.samples = append(r.samples, interval)
+	} else if i := r.rng.Intn(r.count); i < maxIntervals {
+		r.samples[i] = interval
+	}
+}
+
+// intervalSummary describes the distribution of the recorded intervals.
+type intervalSummary struct {
+	Count int
+	Min   time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	Max   time.Duration
+}
+
+// summary returns the distribution of the intervals, and false if fewer than two traces were recorded.
+func (r *intervalRecorder) summary() (intervalSummary, bool) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if r.count == 0 {
+		return intervalSummary{}, false
+	}
+	sorted := make([]time.Duration, len(r.samples))
+	copy(sorted, r.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1)+0.5)]
+	}
+	return intervalSummary{
+		Count: r.count,
+		Min:   r.min,
+		P50:   percentile(0.50),
+		P95:   percentile(0.95),
+		Max:   r.max,
+	}, true
+}
+
+// SenderTimed wraps another Sender to record when each trace starts, and
+// reports the distribution of the intervals between them, compared to the
+// interval that the target TPS calls for, when it's closed. That shows whether
+// loadgen itself kept up with the target.
+type SenderTimed struct {
+	senderWrapper
+	intervals *intervalRecorder
+	target    time.Duration
+	log       Logger
+}
+
+// make sure it implements Sender
+var _ Sender = (*SenderTimed)(nil)
+
+func NewSenderTimed(sender Sender, log Logger, tps int) *SenderTimed {
+	var target time.Duration
+	if tps > 0 {
+		target = time.Second / time.Duration(tps)
+	}
+	return &SenderTimed{senderWrapper: senderWrapper{sender}, intervals: newIntervalRecorder(), target: target, log: log}
+}
+
+func (t *SenderTimed) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	t.intervals.record(time.Now())
+	return t.Sender.CreateTrace(ctx, name, fielder, count)
+}
+
+func (t *SenderTimed) Close() {
+	t.Sender.Close()
+	s, ok := t.intervals.summary()
+	if !ok {
+		return
+	}
+	round := func(d time.Duration) time.Duration { return d.Round(time.Microsecond) }
+	t.log.Warn("interval between traces: min %v p50 %v p95 %v max %v (target %v, %d intervals)\n",
+		round(s.Min), round(s.P50), round(s.P95), round(s.Max), t.target, s.Count)
+}
@@ -0,0 +1,67 @@
+package loadgen
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIntervalRecorder_summary(t *testing.T) {
+	r := newIntervalRecorder()
+	if _, ok := r.summary(); ok {
+		t.Error("expected no summary before any intervals")
+	}
+
+	// intervals of 1ms, 2ms, ... 100ms, in a scrambled order
+	now := time.Unix(1000, 0)
+	r.record(now)
+	for i := 0; i < 100; i++ {
+		now = now.Add(time.Duration((i*37)%100+1) * time.Millisecond)
+		r.record(now)
+	}
+	s, ok := r.summary()
+	if !ok {
+		t.Fatal("expected a summary")
+	}
+	want := intervalSummary{Count: 100, Min: time.Millisecond, P50: 51 * time.Millisecond, P95: 95 * time.Millisecond, Max: 100 * time.Millisecond}
+	if s != want {
+		t.Errorf("expected %+v, got %+v", want, s)
+	}
+}
+
+func TestIntervalRecorder_sampled(t *testing.T) {
+	r := newIntervalRecorder()
+	now := time.Unix(1000, 0)
+	r.record(now)
+	// mostly 10ms, with a 1s gap in the middle that must still be the max
+	for i := 0; i < 5*maxIntervals; i++ {
+		gap := 10 * time.Millisecond
+		if i == maxIntervals*2 {
+			gap = time.Second
+		}
+		now = now.Add(gap)
+		r.record(now)
+	}
+	if len(r.samples) != maxIntervals {
+		t.Errorf("expected %d samples, got %d", maxIntervals, len(r.samples))
+	}
+	s, _ := r.summary()
+	if s.Count != 5*maxIntervals || s.Min != 10*time.Millisecond || s.P50 != 10*time.Millisecond || s.P95 != 10*time.Millisecond || s.Max != time.Second {
+		t.Errorf("unexpected summary %+v", s)
+	}
+}
+
+func TestSenderTimed(t *testing.T) {
+	sender := NewSenderTimed(&recordingSender{}, NewLogger(0), 100)
+	if sender.target != 10*time.Millisecond {
+		t.Errorf("expected a target of 10ms, got %v", sender.target)
+	}
+	fielder := newTestFielder(t, 1)
+	for i := 0; i < 3; i++ {
+		_, root := sender.CreateTrace(context.Background(), "svc", fielder, int64(i))
+		root.Send()
+	}
+	if s, _ := sender.intervals.summary(); s.Count != 2 {
+		t.Errorf("expected 2 intervals between 3 traces, got %d", s.Count)
+	}
+}
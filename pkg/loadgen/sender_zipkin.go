@@ -0,0 +1,175 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// make sure it implements Sender
+var _ Sender = (*SenderZipkin)(nil)
+
+// zipkinEndpoint and zipkinSpan follow the Zipkin v2 JSON span model.
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+type zipkinSpan struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	ParentID      string            `json:"parentId,omitempty"`
+	Name          string            `json:"name"`
+	Timestamp     int64             `json:"timestamp"` // microseconds since the epoch
+	Duration      int64             `json:"duration"`  // microseconds
+	LocalEndpoint zipkinEndpoint    `json:"localEndpoint"`
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+type zipkinKey string
+
+type ZipkinSendable struct {
+	span      zipkinSpan
+	startTime time.Time
+	sender    *SenderZipkin
+}
+
+func (s *ZipkinSendable) Send() {
+	s.span.Timestamp = s.startTime.UnixMicro()
+	s.span.Duration = time.Since(s.startTime).Microseconds()
+	s.sender.enqueue(s.span)
+}
+
+// SenderZipkin converts spans to the Zipkin v2 model and POSTs them in
+// batches as JSON to a Zipkin-compatible endpoint.
+type SenderZipkin struct {
+	url        string
+	batchSize  int
+	client     *http.Client
+	retry      retryPolicy
+	mut        sync.Mutex
+	batch      []zipkinSpan
+	tracecount int
+	nspans     int
+	dropped    int
+	errors     atomic.Int64
+	log        Logger
+}
+
+func NewSenderZipkin(log Logger, opts *Options) *SenderZipkin {
+	batchSize := opts.BatchSize(defaultBatchSize)
+	return &SenderZipkin{
+		url:       opts.Output.ZipkinURL,
+		batchSize: batchSize,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		retry:     newRetryPolicy(log, opts),
+		batch:     make([]zipkinSpan, 0, batchSize),
+		log:       log,
+	}
+}
+
+func (t *SenderZipkin) Close() {
+	t.mut.Lock()
+	batch := t.batch
+	t.batch = nil
+	t.mut.Unlock()
+	t.post(batch)
+	t.log.Warn("sender sent %d traces with %d spans\n", t.tracecount, t.nspans)
+	if t.dropped > 0 {
+		t.log.Warn("sender dropped %d spans that couldn't be sent\n", t.dropped)
+	}
+}
+
+func (t *SenderZipkin) enqueue(span zipkinSpan) {
+	t.mut.Lock()
+	t.batch = append(t.batch, span)
+	if len(t.batch) < t.batchSize {
+		t.mut.Unlock()
+		return
+	}
+	batch := t.batch
+	t.batch = make([]zipkinSpan, 0, t.batchSize)
+	t.mut.Unlock()
+	t.post(batch)
+}
+
+func (t *SenderZipkin) post(batch []zipkinSpan) {
+	if len(batch) == 0 {
+		return
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		t.log.Error("unable to marshal zipkin spans: %v\n", err)
+		return
+	}
+	err = t.retry.postWithRetry(t.client, "zipkin spans", func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		t.log.Error("unable to send %d zipkin spans: %v\n", len(batch), err)
+		t.errors.Add(1)
+		t.mut.Lock()
+		t.dropped += len(batch)
+		t.mut.Unlock()
+		return
+	}
+	t.log.Debug("sent %d zipkin spans\n", len(batch))
+}
+
+// SendErrors returns the number of batches that couldn't be sent.
+func (t *SenderZipkin) SendErrors() int64 {
+	return t.errors.Load()
+}
+
+// zipkinTags converts generated fields into Zipkin tags, which must be strings.
+func zipkinTags(fields map[string]any) map[string]string {
+	tags := make(map[string]string, len(fields))
+	for k, v := range fields {
+		tags[k] = fmt.Sprint(v)
+	}
+	return tags
+}
+
+func (t *SenderZipkin) newSendable(service string, name string, traceID string, parentID string, fields map[string]any) *ZipkinSendable {
+	return &ZipkinSendable{
+		span: zipkinSpan{
+			TraceID:       traceID,
+			ID:            randID(8),
+			ParentID:      parentID,
+			Name:          name,
+			LocalEndpoint: zipkinEndpoint{ServiceName: service},
+			Tags:          zipkinTags(fields),
+		},
+		startTime: time.Now(),
+		sender:    t,
+	}
+}
+
+func (t *SenderZipkin) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	t.mut.Lock()
+	t.tracecount++
+	t.nspans++
+	t.mut.Unlock()
+	zs := t.newSendable(name, fielder.GetSpanName(name), randID(16), "", fielder.GetFields(count, 0))
+	ctx = context.WithValue(ctx, zipkinKey("span"), &zs.span)
+	return ctx, zs
+}
+
+func (t *SenderZipkin) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	t.mut.Lock()
+	t.nspans++
+	t.mut.Unlock()
+	parent := ctx.Value(zipkinKey("span")).(*zipkinSpan)
+	zs := t.newSendable(name, fielder.GetSpanName(name), parent.TraceID, parent.ID, fielder.GetFields(0, level))
+	ctx = context.WithValue(ctx, zipkinKey("span"), &zs.span)
+	return ctx, zs
+}
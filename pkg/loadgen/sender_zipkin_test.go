@@ -0,0 +1,64 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSenderZipkin_spanShape(t *testing.T) {
+	var posted []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &posted); err != nil {
+			t.Errorf("unable to decode posted spans: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	opts := newOptions()
+	opts.Output.ZipkinURL = server.URL
+	opts.Output.BatchSize = 10
+	sender := NewSenderZipkin(NewLogger(0), opts)
+	fielder, err := NewFielder("test", map[string]string{"answer": "42"}, 0, 1, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, root := sender.CreateTrace(context.Background(), "svc", fielder, 1)
+	_, child := sender.CreateSpan(ctx, "svc", 1, fielder)
+	child.Send()
+	root.Send()
+	sender.Close()
+
+	if len(posted) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(posted))
+	}
+	span := posted[0]
+	for _, key := range []string{"traceId", "id", "parentId", "name", "timestamp", "duration", "localEndpoint", "tags"} {
+		if _, ok := span[key]; !ok {
+			t.Errorf("span is missing key %s: %v", key, span)
+		}
+	}
+	if len(span["traceId"].(string)) != 32 || len(span["id"].(string)) != 16 {
+		t.Errorf("unexpected id lengths in %v", span)
+	}
+	if span["traceId"] != posted[1]["traceId"] || span["parentId"] != posted[1]["id"] {
+		t.Errorf("child is not linked to root: %v", posted)
+	}
+	if _, ok := posted[1]["parentId"]; ok {
+		t.Errorf("root span should not have a parentId: %v", posted[1])
+	}
+	if svc := span["localEndpoint"].(map[string]any)["serviceName"]; svc != "svc" {
+		t.Errorf("expected serviceName svc, got %v", svc)
+	}
+	if tag := span["tags"].(map[string]any)["answer"]; tag != "42" {
+		t.Errorf("expected tag answer=42, got %v", tag)
+	}
+}
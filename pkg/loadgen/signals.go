@@ -0,0 +1,20 @@
+package loadgen
+
+import "os"
+
+// watchSignals closes stop when the first signal arrives on sigch (an interrupt,
+// or the SIGTERM that Kubernetes sends when it stops a pod), so loadgen shuts
+// down the usual way and its senders are flushed. Once it's shutting down, for
+// whatever reason, another signal calls exit right away, for an operator who
+// doesn't want to wait for that.
+func watchSignals(log Logger, sigch <-chan os.Signal, stop chan struct{}, exit func(int)) {
+	select {
+	case sig := <-sigch:
+		log.Warn("\nshutting down from %v signal; send another to exit immediately\n", sig)
+		closeStop(stop)
+	case <-stop:
+	}
+	sig := <-sigch
+	log.Warn("\nexiting immediately from %v signal\n", sig)
+	exit(1)
+}
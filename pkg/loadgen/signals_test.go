@@ -0,0 +1,57 @@
+package loadgen
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatchSignals(t *testing.T) {
+	for _, sig := range []os.Signal{os.Interrupt, syscall.SIGTERM} {
+		t.Run(sig.String(), func(t *testing.T) {
+			sigch := make(chan os.Signal, 1)
+			stop := make(chan struct{})
+			exited := make(chan int, 1)
+			go watchSignals(NewLogger(0), sigch, stop, func(code int) { exited <- code })
+
+			sigch <- sig
+			select {
+			case <-stop:
+			case <-time.After(time.Second):
+				t.Fatalf("expected %v to close stop", sig)
+			}
+			select {
+			case <-exited:
+				t.Fatalf("expected the first signal not to exit")
+			case <-time.After(10 * time.Millisecond):
+			}
+
+			sigch <- sig
+			select {
+			case code := <-exited:
+				if code != 1 {
+					t.Errorf("expected an exit status of 1, got %d", code)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("expected a second %v to exit", sig)
+			}
+		})
+	}
+
+	t.Run("after stopping", func(t *testing.T) {
+		sigch := make(chan os.Signal, 1)
+		stop := make(chan struct{})
+		exited := make(chan int, 1)
+		go watchSignals(NewLogger(0), sigch, stop, func(code int) { exited <- code })
+		closeStop(stop)
+		// give it a chance to see that stop is closed before the signal arrives
+		time.Sleep(10 * time.Millisecond)
+		sigch <- syscall.SIGTERM
+		select {
+		case <-exited:
+		case <-time.After(time.Second):
+			t.Fatalf("expected a signal while shutting down to exit")
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package loadgen
+
+import (
+	"time"
+)
+
+// The gains of the span rate controller: how much of the error in the span
+// rate, and of the error accumulated over time, goes into the next rate.
+const (
+	spanRateKp = 0.5
+	spanRateKi = 0.2
+	// the accumulated error is limited to this many seconds at the target
+	// rate, so that it doesn't grow without end while the target is out of reach
+	spanRateWindup = 5
+)
+
+// spanRateController adjusts the TPS of the generators to hold the rate of
+// spans at a target (--targetspanrate), since the number of spans in a trace
+// varies. Every interval it measures the spans per trace so far and the span
+// rate since the last time, and sets the TPS that would send the target rate at
+// that many spans per trace, corrected by a PI controller for whatever keeps
+// the generators from sending what they're asked to.
+type spanRateController struct {
+	target      float64 // spans per second
+	counts      func() (traces, spans int64)
+	controllers []TPSController
+	log         Logger
+
+	lastSpans int64
+	perTrace  float64 // the number of spans per trace so far
+	integral  float64 // the accumulated error, in spans
+}
+
+func newSpanRateController(target float64, counts func() (traces, spans int64), controllers []TPSController, log Logger) *spanRateController {
+	return &spanRateController{target: target, counts: counts, controllers: controllers, log: log}
+}
+
+// step takes the counts after elapsed and returns the TPS to ask for next, and
+// false if there isn't a measurement of the spans per trace yet.
+func (c *spanRateController) step(elapsed time.Duration) (float64, bool) {
+	traces, spans := c.counts()
+	dspans := spans - c.lastSpans
+	c.lastSpans = spans
+	if traces == 0 || elapsed <= 0 {
+		return 0, false
+	}
+	// over the whole run, so that the spans of the traces still in flight
+	// hardly matter
+	c.perTrace = float64(spans) / float64(traces)
+	err := c.target - float64(dspans)/elapsed.Seconds()
+	limit := spanRateWindup * c.target
+	c.integral = max(-limit, min(c.integral+err*elapsed.Seconds(), limit))
+	rate := c.target + spanRateKp*err + spanRateKi*c.integral
+	return max(rate/c.perTrace, 0), true
+}
+
+// run adjusts the generators every interval until stop is closed.
+func (c *spanRateController) run(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			tps, ok := c.step(now.Sub(last))
+			last = now
+			if !ok {
+				continue
+			}
+			setTotalTPS(c.controllers, tps)
+			c.log.Info("%.1f spans per trace, setting TPS to %.1f for %v spans per second\n", c.perTrace, tps, c.target)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package loadgen
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSpanRateController(t *testing.T) {
+	// the generators follow the TPS they're asked for with a lag, and every
+	// trace has 7 spans
+	const target, perTrace = 1000.0, 7
+	var traces, spans int64
+	c := newSpanRateController(target, func() (int64, int64) { return traces, spans }, nil, NewLogger(0))
+
+	if _, ok := c.step(time.Second); ok {
+		t.Fatalf("expected no TPS before any traces were counted")
+	}
+	asked, actual := 1.0, 0.0
+	var rate float64
+	for i := 0; i < 30; i++ {
+		actual += 0.5 * (asked - actual)
+		sent := int64(math.Round(actual))
+		traces += sent
+		spans += sent * perTrace
+		rate = float64(sent * perTrace)
+		tps, ok := c.step(time.Second)
+		if !ok {
+			t.Fatalf("expected a TPS after %d traces", traces)
+		}
+		asked = tps
+	}
+	if c.perTrace != perTrace {
+		t.Errorf("expected %d spans per trace, got %v", perTrace, c.perTrace)
+	}
+	if math.Abs(rate-target) > 0.02*target {
+		t.Errorf("expected about %v spans per second, got %v (asking for %.1f TPS)", target, rate, asked)
+	}
+
+	// a target that can't be reached doesn't make the TPS grow without end
+	for i := 0; i < 100; i++ {
+		traces += 10
+		spans += 10 * perTrace
+		asked, _ = c.step(time.Second)
+	}
+	if limit := (target + spanRateKp*target + spanRateKi*spanRateWindup*target) / perTrace; asked > limit {
+		t.Errorf("expected the TPS to stay under %.1f, got %.1f", limit, asked)
+	}
+}
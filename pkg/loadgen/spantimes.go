@@ -0,0 +1,78 @@
+package loadgen
+
+import (
+	"context"
+	"time"
+)
+
+// spanTimes are the times of a span with --synthetictime, which the generator
+// computes instead of sleeping; the sender reads the end once the span is sent.
+type spanTimes struct {
+	start time.Time
+	end   time.Time
+}
+
+type spanTimesKey struct{}
+
+// contextWithSpanTimes asks the sender to give the next span these times.
+func contextWithSpanTimes(ctx context.Context, times *spanTimes) context.Context {
+	return context.WithValue(ctx, spanTimesKey{}, times)
+}
+
+// spanTimesFromContext returns nil unless the span's times are synthetic.
+func spanTimesFromContext(ctx context.Context) *spanTimes {
+	times, _ := ctx.Value(spanTimesKey{}).(*spanTimes)
+	return times
+}
+
+// traceClock times the spans of a trace. Normally it sleeps for real, and the
+// spans take as long as they seem to; with --synthetictime, it only advances
+// its own time, and gives each span the times it would have had.
+type traceClock struct {
+	synthetic bool
+	now       time.Time
+}
+
+func newTraceClock(synthetic bool) *traceClock {
+	return &traceClock{synthetic: synthetic, now: time.Now()}
+}
+
+func (c *traceClock) sleep(d time.Duration) {
+	if c.synthetic {
+		c.now = c.now.Add(d)
+		return
+	}
+	time.Sleep(d)
+}
+
+// start begins a span now; its times are nil unless they're synthetic.
+func (c *traceClock) start(ctx context.Context) (context.Context, *spanTimes) {
+	if !c.synthetic {
+		return ctx, nil
+	}
+	times := &spanTimes{start: c.now}
+	return contextWithSpanTimes(ctx, times), times
+}
+
+// end ends a span that was begun by start now.
+func (c *traceClock) end(times *spanTimes) {
+	if times != nil {
+		times.end = c.now
+	}
+}
+
+// startTime is when a span starts: now, unless its times are synthetic.
+func (t *spanTimes) startTime() time.Time {
+	if t == nil {
+		return time.Now()
+	}
+	return t.start
+}
+
+// endTime is when a span ends: now, unless its times are synthetic.
+func (t *spanTimes) endTime() time.Time {
+	if t == nil {
+		return time.Now()
+	}
+	return t.end
+}
@@ -0,0 +1,117 @@
+package loadgen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The default numbers of distinct tables and columns in generated SQL.
+const (
+	defaultSQLTables  = 10
+	defaultSQLColumns = 8
+)
+
+// sqlVerbs are the statements that are generated, weighted like a typical
+// read-heavy application.
+var sqlVerbs = []string{"SELECT", "SELECT", "SELECT", "SELECT", "INSERT", "UPDATE", "DELETE"}
+
+// A sqlStatement is a generated query, with its verb and table for the OTel
+// db.operation and db.sql.table attributes.
+type sqlStatement struct {
+	verb  string
+	table string
+	text  string
+}
+
+// sqlGen generates plausible SQL statements against a fixed schema of tables
+// and columns, named for nouns, so that their cardinality is controlled. The
+// values are always placeholders, as in a parameterized query.
+type sqlGen struct {
+	rng     Rng
+	tables  []string
+	columns []string
+}
+
+// newSQLGen chooses a schema of the given numbers of tables and columns, the
+// same ones for a given Rng; every table has all of the columns, and an id.
+func newSQLGen(rng Rng, ntables, ncolumns int) (*sqlGen, error) {
+	if ntables < 1 || ntables > len(nouns) {
+		return nil, fmt.Errorf("the number of tables %d must be between 1 and %d", ntables, len(nouns))
+	}
+	if ncolumns < 1 || ncolumns > len(nouns) {
+		return nil, fmt.Errorf("the number of columns %d must be between 1 and %d", ncolumns, len(nouns))
+	}
+	g := &sqlGen{rng: rng}
+	for _, i := range rng.rng.Perm(len(nouns))[:ntables] {
+		g.tables = append(g.tables, nouns[i])
+	}
+	for _, i := range rng.rng.Perm(len(nouns))[:ncolumns] {
+		g.columns = append(g.columns, nouns[i])
+	}
+	return g, nil
+}
+
+// pickColumns returns between 1 and max different columns.
+func (g *sqlGen) pickColumns(max int) []string {
+	n := 1 + int(g.rng.Intn(min(max, len(g.columns))))
+	columns := make([]string, n)
+	for i, j := range g.rng.rng.Perm(len(g.columns))[:n] {
+		columns[i] = g.columns[j]
+	}
+	return columns
+}
+
+// placeholders returns n placeholders separated by commas.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func (g *sqlGen) next() sqlStatement {
+	verb := g.rng.Choice(sqlVerbs)
+	table := g.rng.Choice(g.tables)
+	var text string
+	switch verb {
+	case "SELECT":
+		columns := g.pickColumns(4)
+		where := g.rng.Choice(append([]string{"id"}, g.columns...))
+		text = fmt.Sprintf("SELECT id, %s FROM %s WHERE %s = ?", strings.Join(columns, ", "), table, where)
+		if g.rng.Intn(4) == 0 {
+			text += " ORDER BY " + g.rng.Choice(columns) + " LIMIT ?"
+		}
+	case "INSERT":
+		columns := g.pickColumns(len(g.columns))
+		text = fmt.Sprintf("INSERT INTO %s (id, %s) VALUES (%s)", table, strings.Join(columns, ", "), placeholders(len(columns)+1))
+	case "UPDATE":
+		columns := g.pickColumns(3)
+		for i, c := range columns {
+			columns[i] = c + " = ?"
+		}
+		text = fmt.Sprintf("UPDATE %s SET %s WHERE id = ?", table, strings.Join(columns, ", "))
+	case "DELETE":
+		text = fmt.Sprintf("DELETE FROM %s WHERE id = ?", table)
+	}
+	return sqlStatement{verb: verb, table: table, text: text}
+}
+
+// getSQLGen returns a generator of SQL statements (/sql) against p1 tables
+// with p2 columns.
+func getSQLGen(rng Rng, p1, p2 string) (func() any, error) {
+	ntables, ncolumns := defaultSQLTables, defaultSQLColumns
+	var err error
+	if p1 != "" {
+		if ntables, err = strconv.Atoi(p1); err != nil {
+			return nil, fmt.Errorf("the number of tables %s must be a whole number", p1)
+		}
+	}
+	if p2 != "" {
+		if ncolumns, err = strconv.Atoi(p2); err != nil {
+			return nil, fmt.Errorf("the number of columns %s must be a whole number", p2)
+		}
+	}
+	g, err := newSQLGen(rng, ntables, ncolumns)
+	if err != nil {
+		return nil, err
+	}
+	return func() any { return g.next().text }, nil
+}
@@ -0,0 +1,127 @@
+package loadgen
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func mustExternalCallGen(t testing.TB) *externalCallGen {
+	t.Helper()
+	g, err := newExternalCallGen("test", defaultSQLTables, defaultSQLColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// sqlTable finds the table of a generated statement.
+var sqlTable = regexp.MustCompile(`^(?:SELECT .* FROM|INSERT INTO|UPDATE|DELETE FROM) ([a-z]+) `)
+
+func TestSQLGen(t *testing.T) {
+	const ntables, ncolumns = 3, 4
+	g, err := newSQLGen(NewRng("sql"), ntables, ncolumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	known := map[string]bool{}
+	for _, table := range g.tables {
+		known[table] = true
+	}
+	if len(known) != ntables || len(g.columns) != ncolumns {
+		t.Fatalf("expected %d tables and %d columns, got %v and %v", ntables, ncolumns, g.tables, g.columns)
+	}
+	verbs, tables := map[string]int{}, map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		st := g.next()
+		verb, _, _ := strings.Cut(st.text, " ")
+		if verb != st.verb {
+			t.Fatalf("expected %q to start with %s", st.text, st.verb)
+		}
+		verbs[verb]++
+		m := sqlTable.FindStringSubmatch(st.text)
+		if m == nil || m[1] != st.table || !known[st.table] {
+			t.Fatalf("expected %q to reference one of the tables %v", st.text, g.tables)
+		}
+		tables[st.table] = true
+		if !strings.Contains(st.text, "?") {
+			t.Errorf("expected %q to have placeholders", st.text)
+		}
+	}
+	for _, verb := range []string{"SELECT", "INSERT", "UPDATE", "DELETE"} {
+		if verbs[verb] == 0 {
+			t.Errorf("expected some %s statements, got %v", verb, verbs)
+		}
+	}
+	if verbs["SELECT"] < verbs["INSERT"] {
+		t.Errorf("expected mostly SELECTs, got %v", verbs)
+	}
+	if len(tables) != ntables {
+		t.Errorf("expected all %d tables to be used, got %v", ntables, tables)
+	}
+
+	// the same Rng seed gives the same schema
+	again, _ := newSQLGen(NewRng("sql"), ntables, ncolumns)
+	if strings.Join(again.tables, ",") != strings.Join(g.tables, ",") || strings.Join(again.columns, ",") != strings.Join(g.columns, ",") {
+		t.Errorf("expected the same schema for the same seed, got %v %v and %v %v", g.tables, g.columns, again.tables, again.columns)
+	}
+}
+
+func Test_getSQLGen(t *testing.T) {
+	fields, err := parseUserFields(NewRng("sql"), map[string]string{"db.statement": "/sql2,3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := map[string]bool{}
+	for i := 0; i < 200; i++ {
+		statement := fields["db.statement"]().(string)
+		m := sqlTable.FindStringSubmatch(statement)
+		if m == nil {
+			t.Fatalf("expected a statement with a table, got %q", statement)
+		}
+		tables[m[1]] = true
+	}
+	if len(tables) != 2 {
+		t.Errorf("expected 2 tables, got %v", tables)
+	}
+
+	for _, spec := range []string{"/sql0", "/sql1,0", "/sql1.5", "/sql1000"} {
+		if _, err := parseUserFields(NewRng("sql"), map[string]string{"q": spec}); err == nil {
+			t.Errorf("expected an error for %s", spec)
+		}
+	}
+}
+
+func TestExternalCallGen_sql(t *testing.T) {
+	g, err := newExternalCallGen("test", 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		call := g.next()
+		var system, statement, table string
+		for _, kv := range call.attrs {
+			switch kv.Key {
+			case "db.system":
+				system = kv.Value.AsString()
+			case "db.statement":
+				statement = kv.Value.AsString()
+			case "db.sql.table":
+				table = kv.Value.AsString()
+			}
+		}
+		if system == "redis" {
+			continue
+		}
+		if !slices.Contains(g.sql.tables, table) || !strings.HasSuffix(call.name, " "+table) {
+			t.Errorf("expected a call to one of the tables %v, got %s on %q", g.sql.tables, call.name, table)
+		}
+		if m := sqlTable.FindStringSubmatch(statement); m == nil || m[1] != table {
+			t.Errorf("expected a statement on %s, got %q", table, statement)
+		}
+	}
+	if _, err := newExternalCallGen("test", 0, 2); err == nil {
+		t.Errorf("expected an error for no tables")
+	}
+}
@@ -0,0 +1,62 @@
+package loadgen
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+// statusCodes are the span statuses that --statusdist can choose from; unset
+// means the sender doesn't set a status at all, which OTLP distinguishes from ok.
+var statusCodes = map[string]codes.Code{
+	"ok":    codes.Ok,
+	"error": codes.Error,
+	"unset": codes.Unset,
+}
+
+// statusGen chooses the status of each of the otel sender's child spans from the
+// --statusdist weights, and the message of an error status from --statusmessage,
+// if any.
+type statusGen struct {
+	rng      *lockedRng
+	statuses func() any // draws from rng's Rng
+	messages []string
+}
+
+// newStatusGen parses a list of status:weight pairs, like ok:85,error:10,unset:5;
+// an empty one is nil, which makes a tenth of the spans errors and the rest ok.
+func newStatusGen(seed string, dist string, messages []string) (*statusGen, error) {
+	if dist == "" {
+		return nil, nil
+	}
+	for _, pair := range strings.Split(dist, ",") {
+		status, _, _ := strings.Cut(pair, ":")
+		if _, ok := statusCodes[strings.TrimSpace(status)]; !ok {
+			return nil, fmt.Errorf("unknown status %q in statusdist %s; it must be ok, error, or unset", strings.TrimSpace(status), dist)
+		}
+	}
+	rng := newLockedRng(seed + "statuses")
+	statuses, err := getEnumGen(rng.rng, dist)
+	if err != nil {
+		return nil, fmt.Errorf("invalid statusdist %s: %w", dist, err)
+	}
+	return &statusGen{rng: rng, statuses: statuses, messages: messages}, nil
+}
+
+// next returns the status of a span, and for an error, a message from the
+// pool, or "" to use the exception's message.
+func (g *statusGen) next() (codes.Code, string) {
+	if g == nil {
+		if rand.Intn(10) == 0 {
+			return codes.Error, ""
+		}
+		return codes.Ok, ""
+	}
+	code := statusCodes[withLock(g.rng, g.statuses).(string)]
+	if code != codes.Error || len(g.messages) == 0 {
+		return code, ""
+	}
+	return code, g.rng.Choice(g.messages)
+}
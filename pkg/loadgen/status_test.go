@@ -0,0 +1,65 @@
+package loadgen
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSenderOTel_statusDist(t *testing.T) {
+	statuses, err := newStatusGen("test", "ok:60,error:25,unset:15", []string{"upstream timed out", "connection reset"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	sender := &SenderOTel{opts: newOptions(), exceptions: newExceptionGen("test", nil), statuses: statuses, exporters: []*sharedExporter{{SpanExporter: exporter, log: NewLogger(0)}}, providers: map[providerKey]*sdktrace.TracerProvider{}, log: NewLogger(0)}
+	fielder := newTestFielder(t, 2)
+	const ntraces = 4000
+	for i := 0; i < ntraces; i++ {
+		ctx, root := sender.CreateTrace(context.Background(), "frontend", fielder, int64(i))
+		_, child := sender.CreateSpan(ctx, "frontend", 1, fielder)
+		child.Send()
+		root.Send()
+	}
+	for _, tp := range sender.providers {
+		tp.ForceFlush(context.Background())
+	}
+
+	counts := make(map[codes.Code]int)
+	for _, span := range exporter.GetSpans() {
+		if !span.Parent.IsValid() {
+			continue
+		}
+		counts[span.Status.Code]++
+		switch span.Status.Code {
+		case codes.Error:
+			if msg := span.Status.Description; msg != "upstream timed out" && msg != "connection reset" {
+				t.Errorf("expected an error message from the pool, got %q", msg)
+			}
+		case codes.Unset:
+			if span.Status.Description != "" {
+				t.Errorf("expected an unset status to have no message, got %q", span.Status.Description)
+			}
+		}
+	}
+	for code, want := range map[codes.Code]float64{codes.Ok: 0.6, codes.Error: 0.25, codes.Unset: 0.15} {
+		if got := float64(counts[code]) / ntraces; math.Abs(got-want) > 0.03 {
+			t.Errorf("expected about %.0f%% of the spans to be %s, got %.1f%%", 100*want, code, 100*got)
+		}
+	}
+}
+
+func Test_newStatusGen(t *testing.T) {
+	if g, err := newStatusGen("test", "", nil); g != nil || err != nil {
+		t.Errorf("expected no generator for an empty statusdist, got %v, %v", g, err)
+	}
+	for _, dist := range []string{"ok:5,bad:1", "ok:0,error:0", "ok:x"} {
+		if _, err := newStatusGen("test", dist, nil); err == nil {
+			t.Errorf("expected an error for %q", dist)
+		}
+	}
+}
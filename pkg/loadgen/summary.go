@@ -0,0 +1,95 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// A Skipper is a generator that can skip traces rather than send them, and
+// counts the ones it skipped.
+type Skipper interface {
+	Skipped() int64
+}
+
+// make sure it implements Skipper
+var _ Skipper = (*TraceGenerator)(nil)
+
+// runSummary is what --summaryjson writes at the end of a run.
+type runSummary struct {
+	TracesSent  int64   `json:"traces_sent"`
+	SpansSent   int64   `json:"spans_sent"`
+	Errors      int64   `json:"errors"`
+	Dropped     int64   `json:"dropped"`
+	Rejected    int64   `json:"rejected_spans"`
+	Duration    float64 `json:"duration"` // in seconds
+	AchievedTPS float64 `json:"achieved_tps"`
+}
+
+// SenderSummarized wraps another Sender to count the traces and spans that
+// pass through it, for --summaryjson and --targetspanrate. Like SenderCounted,
+// it doesn't count the first warmup traces.
+type SenderSummarized struct {
+	senderWrapper
+	warmup int64
+	traces atomic.Int64
+	spans  atomic.Int64
+}
+
+// make sure it implements Sender
+var _ Sender = (*SenderSummarized)(nil)
+
+func NewSenderSummarized(sender Sender, warmup int64) *SenderSummarized {
+	return &SenderSummarized{senderWrapper: senderWrapper{sender}, warmup: warmup}
+}
+
+func (t *SenderSummarized) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	// replayed traces have no count, and are never warmup traces
+	if count > 0 && count <= t.warmup {
+		ctx = context.WithValue(ctx, warmupKey{}, true)
+	} else {
+		t.traces.Add(1)
+		t.spans.Add(1)
+	}
+	return t.Sender.CreateTrace(ctx, name, fielder, count)
+}
+
+func (t *SenderSummarized) CreateSpan(ctx context.Context, name string, level int, fielder *Fielder) (context.Context, Sendable) {
+	if ctx.Value(warmupKey{}) == nil {
+		t.spans.Add(1)
+	}
+	return t.Sender.CreateSpan(ctx, name, level, fielder)
+}
+
+// counts returns the number of traces and spans so far.
+func (t *SenderSummarized) counts() (traces, spans int64) {
+	return t.traces.Load(), t.spans.Load()
+}
+
+// summary returns the counts, with the traces the generators dropped and the
+// spans the backend rejected, over a run that took elapsed.
+func (t *SenderSummarized) summary(elapsed time.Duration, dropped, rejected int64) runSummary {
+	s := runSummary{
+		TracesSent: t.traces.Load(),
+		SpansSent:  t.spans.Load(),
+		Errors:     t.SendErrors(),
+		Dropped:    dropped,
+		Rejected:   rejected,
+		Duration:   elapsed.Seconds(),
+	}
+	if elapsed > 0 {
+		s.AchievedTPS = float64(s.TracesSent) / elapsed.Seconds()
+	}
+	return s
+}
+
+// writeSummary writes the summary to filename as indented JSON.
+func writeSummary(filename string, s runSummary) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0o644)
+}
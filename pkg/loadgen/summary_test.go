@@ -0,0 +1,56 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSenderSummarized(t *testing.T) {
+	const spansPerTrace = 3
+	counter := make(chan int64)
+	stop := make(chan struct{})
+	go func() {
+		TraceCounter(NewLogger(0), 5, 2, counter, stop)
+		closeStop(stop)
+	}()
+
+	sender := NewSenderSummarized(&recordingSender{}, 2)
+	fielder := newTestFielder(t, 1)
+	func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case count := <-counter:
+				ctx, root := sender.CreateTrace(context.Background(), "svc", fielder, count)
+				for i := 1; i < spansPerTrace; i++ {
+					_, child := sender.CreateSpan(ctx, "svc", 1, fielder)
+					child.Send()
+				}
+				root.Send()
+			}
+		}
+	}()
+
+	filename := filepath.Join(t.TempDir(), "summary.json")
+	if err := writeSummary(filename, sender.summary(2*time.Second, 3, 4)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got runSummary
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unable to read the summary %s: %v", data, err)
+	}
+	// the 2 warmup traces aren't counted
+	want := runSummary{TracesSent: 5, SpansSent: 5 * spansPerTrace, Dropped: 3, Rejected: 4, Duration: 2, AchievedTPS: 2.5}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,74 @@
+package loadgen
+
+import (
+	"math"
+	"time"
+)
+
+// tpsDriftWatcher samples the number of traces the generators have started
+// and warns when the achieved TPS, averaged over the last window samples,
+// stays more than a tolerance away from the target for window samples in a
+// row (--drifttolerance), as it does when a backend is throttling the sender.
+type tpsDriftWatcher struct {
+	tolerance   float64 // a fraction of the target
+	window      int
+	controllers []TPSController
+	log         Logger
+
+	sent    []int64 // the traces sent at each of the last window+1 samples
+	outside int     // the number of samples in a row outside the tolerance
+}
+
+func newTPSDriftWatcher(tolerance float64, window int, controllers []TPSController, log Logger) *tpsDriftWatcher {
+	return &tpsDriftWatcher{tolerance: tolerance, window: window, controllers: controllers, log: log}
+}
+
+// step takes a sample interval after the last one, and returns the average
+// achieved and target TPS, and whether the rate has drifted for long enough to
+// warn about. It warns only once until the rate comes back within tolerance.
+func (w *tpsDriftWatcher) step(interval time.Duration) (achieved, target float64, drifted bool) {
+	var sent int64
+	for _, c := range w.controllers {
+		status := c.Status()
+		sent += status.Traces
+		target += status.TPS
+	}
+	w.sent = append(w.sent, sent)
+	if len(w.sent) <= w.window {
+		return 0, target, false
+	}
+	w.sent = w.sent[len(w.sent)-w.window-1:]
+	achieved = float64(w.sent[w.window]-w.sent[0]) / (float64(w.window) * interval.Seconds())
+	if target <= 0 || math.Abs(achieved-target) <= w.tolerance*target {
+		if w.outside >= w.window {
+			w.log.Info("achieved TPS of %.1f is back within %.0f%% of the target of %.1f\n", achieved, 100*w.tolerance, target)
+		}
+		w.outside = 0
+		return achieved, target, false
+	}
+	w.outside++
+	return achieved, target, w.outside == w.window
+}
+
+// run samples every interval, after the ramp has had delay to finish, until
+// stop is closed.
+func (w *tpsDriftWatcher) run(interval, delay time.Duration, stop chan struct{}) {
+	select {
+	case <-stop:
+		return
+	case <-time.After(delay):
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if achieved, target, drifted := w.step(interval); drifted {
+				w.log.Warn("achieved TPS of %.1f has been more than %.0f%% from the target of %.1f for %v; the sender may be throttled\n",
+					achieved, 100*w.tolerance, target, time.Duration(w.window)*interval)
+			}
+		}
+	}
+}
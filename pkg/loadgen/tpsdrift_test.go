@@ -0,0 +1,115 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fixedController reports a target TPS and the traces sent so far.
+type fixedController struct {
+	tps  float64
+	sent int64
+}
+
+func (c *fixedController) SetTPS(tps float64) { c.tps = tps }
+
+func (c *fixedController) Status() GeneratorStatus {
+	return GeneratorStatus{TPS: c.tps, Generators: 1, Traces: c.sent}
+}
+
+func TestTPSDriftWatcher_step(t *testing.T) {
+	const window = 3
+	c := &fixedController{tps: 100}
+	w := newTPSDriftWatcher(0.1, window, []TPSController{c}, NewLogger(0))
+	var warnings int
+	// on target, then at half of it for a while, then back on target
+	for i, rate := range []int64{100, 100, 100, 100, 100, 50, 50, 50, 50, 50, 50, 50, 100, 100, 100, 100} {
+		c.sent += rate
+		achieved, target, drifted := w.step(time.Second)
+		if target != 100 {
+			t.Fatalf("expected a target of 100, got %v", target)
+		}
+		if i > window && (achieved < 50 || achieved > 100) {
+			t.Errorf("expected the average of the last %d samples, got %v", window, achieved)
+		}
+		if drifted {
+			warnings++
+			// the first sample at half the rate takes the average outside the tolerance
+			if i != 5+window-1 {
+				t.Errorf("expected a warning after %d samples outside the tolerance, got one at sample %d", window, i)
+			}
+		}
+	}
+	if warnings != 1 {
+		t.Errorf("expected a single warning for a sustained drift, got %d", warnings)
+	}
+}
+
+// slowSender takes a while to start each trace, like one that's throttled.
+type slowSender struct {
+	recordingSender
+	delay time.Duration
+}
+
+func (s *slowSender) CreateTrace(ctx context.Context, name string, fielder *Fielder, count int64) (context.Context, Sendable) {
+	time.Sleep(s.delay)
+	return s.recordingSender.CreateTrace(ctx, name, fielder, count)
+}
+
+// lockedBuffer is a bytes.Buffer that can be read while it's written.
+type lockedBuffer struct {
+	mut sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.buf.String()
+}
+
+func TestTPSDriftWatcher_slowSender(t *testing.T) {
+	opts := newOptions()
+	opts.Format.Depth = 1
+	opts.Format.NSpans = 1
+	opts.Format.TraceTime = 10 * time.Millisecond
+	opts.Quantity.TPS = 100
+	opts.Quantity.RampTime = 10 * time.Millisecond
+	// a trace every 10ms is asked for, but each one takes 40ms to start
+	sender := &slowSender{delay: 40 * time.Millisecond}
+	gen, err := NewTraceGenerator(sender, func(string) (*Fielder, error) { return newTestFielder(t, 1), nil }, NewLogger(0), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	counter := make(chan int64)
+	wg := &sync.WaitGroup{}
+	go TraceCounter(NewLogger(0), 0, 0, counter, stop)
+	wg.Add(1)
+	go gen.Generate(opts, wg, stop, counter)
+	out := &lockedBuffer{}
+	log := &logger{level: LevelWarn, out: out}
+	go newTPSDriftWatcher(0.2, 3, []TPSController{gen}, log).run(50*time.Millisecond, opts.Quantity.RampTime, stop)
+	defer func() {
+		closeStop(stop)
+		wg.Wait()
+	}()
+
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if strings.Contains(out.String(), "may be throttled") {
+			return
+		}
+	}
+	t.Errorf("expected a warning that the TPS drifted, got %q", out.String())
+}
@@ -0,0 +1,76 @@
+package loadgen
+
+import "time"
+
+// TraceCounter sends an incrementing int64 on its channel, stopping
+// when it has generated maxcount values or when it receives a value on stop.
+// If maxcount is 0, it will run until it receives a value on stop.
+// The first warmup values are sent in addition to the maxcount, and aren't
+// included in the count it reports and returns.
+// It returns the count and true if it stopped because of a value on stop, false otherwise.
+func TraceCounter(log Logger, maxcount int64, warmup int64, output chan int64, stop chan struct{}) (int64, bool) {
+	var count int64
+
+	measured := func() int64 {
+		return max(count-warmup, 0)
+	}
+	defer func() {
+		log.Warn("trace counter exiting after %d traces\n", measured())
+	}()
+
+	for {
+		if maxcount > 0 && count >= maxcount+warmup {
+			return measured(), false
+		}
+		if warmup > 0 && count == warmup {
+			log.Warn("warmup of %d traces complete, starting measurement\n", warmup)
+		}
+		count++
+		select {
+		case <-stop:
+			// this count was never handed out
+			count--
+			return measured(), true
+		case output <- count:
+			// do nothing
+		}
+	}
+}
+
+// LimitCounts passes the counts from src to dest no faster than rate per
+// second (--tpscap), however many generators are waiting for them, so that
+// no more traces than that are started. It's a token bucket that holds a
+// single token: each count waits until 1/rate after the one before it, or
+// goes at once if it's been longer than that. It returns when src is closed
+// or a value is received on stop.
+func LimitCounts(src <-chan int64, dest chan<- int64, rate float64, stop chan struct{}) {
+	interval := time.Duration(float64(time.Second) / rate)
+	var next time.Time
+	for {
+		var count int64
+		select {
+		case <-stop:
+			return
+		case c, ok := <-src:
+			if !ok {
+				return
+			}
+			count = c
+		}
+		if wait := time.Until(next); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case dest <- count:
+			next = time.Now().Add(interval)
+		}
+	}
+}
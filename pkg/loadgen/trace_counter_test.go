@@ -0,0 +1,91 @@
+package loadgen
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTraceCounter_warmup(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxcount int64
+		warmup   int64
+	}{
+		{"no warmup", 10, 0},
+		{"warmup", 10, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := make(chan int64)
+			stop := make(chan struct{})
+			var total int64
+			done := make(chan struct{})
+			go func() {
+				for range output {
+					total++
+				}
+				close(done)
+			}()
+			reported, stopped := TraceCounter(NewLogger(0), tt.maxcount, tt.warmup, output, stop)
+			close(output)
+			<-done
+			if stopped {
+				t.Errorf("expected the counter to stop at its limit")
+			}
+			if total != tt.maxcount+tt.warmup {
+				t.Errorf("expected %d traces in total, got %d", tt.maxcount+tt.warmup, total)
+			}
+			if reported != total-tt.warmup {
+				t.Errorf("expected a reported count of %d, got %d", total-tt.warmup, reported)
+			}
+		})
+	}
+}
+
+func TestLimitCounts(t *testing.T) {
+	const rate = 50
+	src, dest := make(chan int64), make(chan int64)
+	stop := make(chan struct{})
+	go TraceCounter(NewLogger(0), 0, 0, src, stop)
+	go LimitCounts(src, dest, rate, stop)
+
+	// many more eager consumers than the rate needs
+	var mut sync.Mutex
+	var times []time.Time
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-dest:
+					mut.Lock()
+					times = append(times, time.Now())
+					mut.Unlock()
+				}
+			}
+		}()
+	}
+	time.Sleep(time.Second)
+	close(stop)
+	wg.Wait()
+
+	if len(times) < rate*8/10 || len(times) > rate+1 {
+		t.Errorf("expected about %d counts in a second, got %d", rate, len(times))
+	}
+	// no window of 200ms has more than its share, give or take one
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	const window = 200 * time.Millisecond
+	for i := range times {
+		n := sort.Search(len(times), func(j int) bool { return times[j].Sub(times[i]) >= window }) - i
+		if n > rate/5+1 {
+			t.Errorf("expected at most %d counts in %v, got %d", rate/5+1, window, n)
+			break
+		}
+	}
+}